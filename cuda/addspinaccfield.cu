@@ -0,0 +1,30 @@
+#include "amul.h"
+#include "float3.h"
+
+// Add the field of the spin accumulation s to B:
+// 	B += Dspin / (γ λJ² Ms) s = J_sd / (ħ γ Ms) s,  with λJ = sqrt(ħ Dspin / J_sd),
+// so that the precession of m around s matches the s-d exchange torque on s.
+// See spinaccumulation.go
+extern "C" __global__ void
+addspinaccfield(float* __restrict__ Bx, float* __restrict__ By, float* __restrict__ Bz,
+                float* __restrict__ sx, float* __restrict__ sy, float* __restrict__ sz,
+                float* __restrict__ D_, float D_mul,
+                float* __restrict__ lJ_, float lJ_mul,
+                float* __restrict__ Ms_, float Ms_mul,
+                float gamma, int N) {
+
+    int i =  ( blockIdx.y*gridDim.x + blockIdx.x ) * blockDim.x + threadIdx.x;
+    if (i >= N) {
+        return;
+    }
+
+    float lJ = amul(lJ_, lJ_mul, i);
+    if (lJ == 0.0f) {
+        return;
+    }
+    float pre = amul(D_, D_mul, i) * inv_Msat(Ms_, Ms_mul, i) / (gamma * lJ * lJ);
+
+    Bx[i] += pre * sx[i];
+    By[i] += pre * sy[i];
+    Bz[i] += pre * sz[i];
+}
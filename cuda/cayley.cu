@@ -0,0 +1,39 @@
+#include <stdint.h>
+#include "float3.h"
+
+// Rotates src around the rotation vector dt*ω by the Cayley transform:
+//
+// dst = cay(a) src = src + 4/(4+|a|²) (a x src + 1/2 a x (a x src)),  a = dt*ω
+//
+// where ω = mref x τ / |mref|² is the rotation vector of the torque τ at mref
+// (τ = ω x mref). cay(a) is orthogonal, so |dst| = |src| exactly.
+// dst may be the same as src or mref.
+// See cayley.go
+extern "C" __global__ void
+cayley(float* __restrict__ dstx, float* __restrict__ dsty, float* __restrict__ dstz,
+       float* __restrict__ srcx, float* __restrict__ srcy, float* __restrict__ srcz,
+       float* __restrict__ mx, float* __restrict__ my, float* __restrict__ mz,
+       float* __restrict__ tx, float* __restrict__ ty, float* __restrict__ tz,
+       float dt, int N) {
+
+    int i =  ( blockIdx.y*gridDim.x + blockIdx.x ) * blockDim.x + threadIdx.x;
+    if (i < N) {
+
+        float3 src = make_float3(srcx[i], srcy[i], srcz[i]);
+        float3 m   = make_float3(mx[i], my[i], mz[i]);
+        float3 tau = make_float3(tx[i], ty[i], tz[i]);
+
+        float m2 = dot(m, m);
+        float3 a = make_float3(0.0f, 0.0f, 0.0f);
+        if (m2 != 0.0f) {
+            a = (dt / m2) * cross(m, tau);
+        }
+
+        float3 axs = cross(a, src);
+        float3 r = src + (4.0f / (4.0f + dot(a, a))) * (axs + 0.5f * cross(a, axs));
+
+        dstx[i] = r.x;
+        dsty[i] = r.y;
+        dstz[i] = r.z;
+    }
+}
@@ -0,0 +1,26 @@
+package cuda
+
+import (
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/util"
+)
+
+// Sets dst to src rotated by the Cayley transform of dt times the rotation vector
+// of torque τ at mref, for each group of 3 components (sublattice).
+// dst may be the same as src or mref.
+// see cayley.cu
+func Cayley(dst, src, mref, τ *data.Slice, dt float32) {
+	nComp := dst.NComp()
+	util.Argument(nComp%3 == 0 && src.NComp() == nComp && mref.NComp() == nComp && τ.NComp() == nComp)
+	util.Argument(dst.Len() == src.Len() && dst.Len() == mref.Len() && dst.Len() == τ.Len())
+
+	N := dst.Len()
+	cfg := make1DConf(N)
+	for c := 0; c < nComp; c += 3 {
+		k_cayley_async(dst.DevPtr(c+X), dst.DevPtr(c+Y), dst.DevPtr(c+Z),
+			src.DevPtr(c+X), src.DevPtr(c+Y), src.DevPtr(c+Z),
+			mref.DevPtr(c+X), mref.DevPtr(c+Y), mref.DevPtr(c+Z),
+			τ.DevPtr(c+X), τ.DevPtr(c+Y), τ.DevPtr(c+Z),
+			dt, N, cfg)
+	}
+}
@@ -59,3 +59,31 @@ func fabs(x float32) float32 {
 	}
 	return x
 }
+
+// Extract imaginary parts, copy them from src to dst.
+// In the meanwhile, check if real parts are nearly zero
+// and scale the kernel to compensate for unnormalized FFTs.
+// Used for odd kernels, like the Biot-Savart kernel.
+func scaleImagParts(dst, src *data.Slice, scale float32) {
+	util.Argument(2*dst.Len() == src.Len())
+	util.Argument(dst.NComp() == 1 && src.NComp() == 1)
+
+	srcList := src.Host()[0]
+	dstList := dst.Host()[0]
+
+	maxreal, maximg := float32(0.), float32(0.)
+	for i := 0; i < src.Len()/2; i++ {
+		dstList[i] = srcList[2*i+1] * scale
+		if fabs(srcList[2*i]) > maxreal {
+			maxreal = fabs(srcList[2*i])
+		}
+		if fabs(srcList[2*i+1]) > maximg {
+			maximg = fabs(srcList[2*i+1])
+		}
+	}
+
+	// The kernel is not normalized, so compare real to imaginary parts.
+	if maxreal > FFT_IMAG_TOLERANCE*maximg {
+		log.Fatalf("FFT kernel real part: %v (imaginary: %v)\n", maxreal, maximg)
+	}
+}
@@ -43,3 +43,35 @@ func kernMulC_async(fftM, K *data.Slice, Nx, Ny int) {
 	cfg := make3DConf([3]int{Nx, Ny, 1})
 	k_kernmulC_async(fftM.DevPtr(0), K.DevPtr(0), Nx, Ny, cfg)
 }
+
+// kernel multiplication for the Oersted convolution: fftJ = fftJ x (i G),
+// with G the imaginary parts of the FFT'ed Biot-Savart kernel.
+func kernMulCross_async(fftJ [3]*data.Slice, Gx, Gy, Gz *data.Slice, Nx, Ny, Nz int) {
+	util.Argument(fftJ[X].NComp() == 1 && Gx.NComp() == 1)
+
+	cfg := make3DConf([3]int{Nx, Ny, Nz})
+	k_kernmulcross_async(fftJ[X].DevPtr(0), fftJ[Y].DevPtr(0), fftJ[Z].DevPtr(0),
+		Gx.DevPtr(0), Gy.DevPtr(0), Gz.DevPtr(0), Nx, Ny, Nz, cfg)
+}
+
+// kernel multiplication for the offset convolution, with a complex, symmetric kernel.
+func kernMulCSymm3D_async(fftM [3]*data.Slice, Kxx, Kyy, Kzz, Kyz, Kxz, Kxy *data.Slice, Nx, Ny, Nz int) {
+	util.Argument(fftM[X].NComp() == 1 && Kxx.NComp() == 1)
+
+	cfg := make3DConf([3]int{Nx, Ny, Nz})
+	k_kernmulCSymm3D_async(fftM[X].DevPtr(0), fftM[Y].DevPtr(0), fftM[Z].DevPtr(0),
+		Kxx.DevPtr(0), Kyy.DevPtr(0), Kzz.DevPtr(0), Kyz.DevPtr(0), Kxz.DevPtr(0), Kxy.DevPtr(0),
+		Nx, Ny, Nz, cfg)
+}
+
+// accumulating kernel multiplication for the layered demag convolution: fftB += K * fftM,
+// with K the real or imaginary parts of the FFT'ed kernel of a layer pair, see kernmullayer.cu.
+func kernMulLayer_async(fftB, fftM [3]*data.Slice, K [3][3]*data.Slice, sign float32, Nx, Ny int) {
+	util.Argument(fftB[X].NComp() == 1 && K[X][X].NComp() == 1)
+
+	cfg := make3DConf([3]int{Nx, Ny, 1})
+	k_kernmullayer_async(fftB[X].DevPtr(0), fftB[Y].DevPtr(0), fftB[Z].DevPtr(0),
+		fftM[X].DevPtr(0), fftM[Y].DevPtr(0), fftM[Z].DevPtr(0),
+		K[X][X].DevPtr(0), K[Y][Y].DevPtr(0), K[Z][Z].DevPtr(0), K[Y][Z].DevPtr(0), K[X][Z].DevPtr(0), K[X][Y].DevPtr(0),
+		sign, Nx, Ny, cfg)
+}
@@ -0,0 +1,244 @@
+package cuda
+
+import (
+	"unsafe"
+
+	"github.com/mumax/3/cuda/cu"
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/mag"
+	"github.com/mumax/3/util"
+)
+
+// Stores the necessary state to perform the FFT-accelerated demag convolution
+// of a stack of layers with non-equal thickness (see mag.CalcLayeredDemagKernel).
+// The field in each layer is the sum of 2D convolutions over all source layers,
+// so the kernel storage scales with the square of the number of layers.
+type LayeredDemagConvolution struct {
+	inputSize    [3]int                // 3D size of the input/output data
+	realKernSize [3]int                // Size of the 2D kernels and logical FFT size.
+	fftRBuf      *data.Slice           // FFT input buf, one layer
+	fftMBuf      [][3]*data.Slice      // FFT of the magnetization of each layer
+	fftBBuf      [3]*data.Slice        // FFT of the field of one layer
+	kern         [][][3][3]*data.Slice // FFT kernel on device for layer pairs i <= j, real or imaginary parts only
+	fwPlan       fft3DR2CPlan          // Forward FFT (1 component)
+	bwPlan       fft3DC2RPlan          // Backward FFT (1 component)
+}
+
+// Initializes a convolution to evaluate the demag field of a stack of layers,
+// given the kernel of each pair of layers.
+// Sanity-checked if test == true (slow-ish for large meshes).
+func NewLayeredDemag(inputSize [3]int, kernel [][][3][3]*data.Slice, test bool) *LayeredDemagConvolution {
+	util.Argument(len(kernel) == inputSize[Z])
+	c := new(LayeredDemagConvolution)
+	c.inputSize = inputSize
+	c.realKernSize = kernel[0][0][X][X].Size()
+	util.Argument(c.realKernSize[Z] == 1)
+	c.init(kernel)
+	if test {
+		testLayeredConvolution(c, kernel)
+	}
+	return c
+}
+
+// Calculate the demag field of m * vol * Msat, store result in B.
+//
+//	m:    magnetization normalized to unit length
+//	vol:  unitless mask used to scale m's length, may be nil
+//	Msat: saturation magnetization in A/m
+//	B:    resulting demag field, in Tesla
+func (c *LayeredDemagConvolution) Exec(B, m, vol *data.Slice, Msat MSlice) {
+	util.Argument(B.Size() == c.inputSize && m.Size() == c.inputSize)
+	lsize := c.layerSize()
+
+	// FW FFT of all layers first, so that B may be m.
+	for iz := range c.fftMBuf {
+		m_, vol_, Msat_ := layerOf(m, iz), layerOf(vol, iz), MakeMSlice(layerOf(Msat.arr, iz), Msat.mul)
+		for i := 0; i < 3; i++ {
+			zero1_async(c.fftRBuf)
+			copyPadMul(c.fftRBuf, m_.Comp(i), vol_, c.realKernSize, lsize, Msat_)
+			c.fwPlan.ExecAsync(c.fftRBuf, c.fftMBuf[iz][i])
+		}
+	}
+
+	N := fftR2COutputSizeFloats(c.realKernSize)
+	for iz := range c.fftMBuf {
+		for i := 0; i < 3; i++ {
+			zero1_async(c.fftBBuf[i])
+		}
+		for jz := range c.fftMBuf {
+			if jz >= iz {
+				kernMulLayer_async(c.fftBBuf, c.fftMBuf[jz], c.kern[iz][jz], 1, N[X]/2, N[Y])
+			} else {
+				kernMulLayer_async(c.fftBBuf, c.fftMBuf[jz], c.kern[jz][iz], -1, N[X]/2, N[Y])
+			}
+		}
+		B_ := layerOf(B, iz)
+		for i := 0; i < 3; i++ { // BW FFT
+			c.bwPlan.ExecAsync(c.fftBBuf[i], c.fftRBuf)
+			copyUnPad(B_.Comp(i), c.fftRBuf, lsize, c.realKernSize)
+		}
+	}
+}
+
+// size of one layer
+func (c *LayeredDemagConvolution) layerSize() [3]int {
+	return [3]int{c.inputSize[X], c.inputSize[Y], 1}
+}
+
+func (c *LayeredDemagConvolution) init(realKern [][][3][3]*data.Slice) {
+	Nz := c.inputSize[Z]
+	nc := fftR2COutputSizeFloats(c.realKernSize)
+	c.fftRBuf = NewSlice(1, c.realKernSize)
+	for i := 0; i < 3; i++ {
+		c.fftBBuf[i] = NewSlice(1, nc)
+	}
+	c.fftMBuf = make([][3]*data.Slice, Nz)
+	for iz := range c.fftMBuf {
+		for i := 0; i < 3; i++ {
+			c.fftMBuf[iz][i] = NewSlice(1, nc)
+		}
+	}
+
+	c.fwPlan = newFFT3DR2C(c.realKernSize[X], c.realKernSize[Y], c.realKernSize[Z])
+	c.bwPlan = newFFT3DC2R(c.realKernSize[X], c.realKernSize[Y], c.realKernSize[Z])
+
+	// FFT kernel: purely real for even elements, purely imaginary for the odd xz and yz elements.
+	kSize := [3]int{nc[X] / 2, nc[Y], 1}
+	fftKern := data.NewSlice(1, kSize)
+	kfull := data.NewSlice(1, nc)
+	scale := 1 / float32(c.fwPlan.InputLen())
+	c.kern = make([][][3][3]*data.Slice, Nz)
+	for iz := range c.kern {
+		c.kern[iz] = make([][3][3]*data.Slice, Nz)
+		for jz := iz; jz < Nz; jz++ {
+			k := &c.kern[iz][jz]
+			for i := 0; i < 3; i++ {
+				for j := i; j < 3; j++ {
+					data.Copy(c.fftRBuf, realKern[iz][jz][i][j])
+					c.fwPlan.ExecAsync(c.fftRBuf, c.fftBBuf[0])
+					data.Copy(kfull, c.fftBBuf[0])
+					if j == Z && i != Z {
+						scaleImagParts(fftKern, kfull, scale)
+					} else {
+						scaleRealParts(fftKern, kfull, scale)
+					}
+					k[i][j] = GPUCopy(fftKern)
+				}
+			}
+			k[Y][X] = k[X][Y]
+			k[Z][X] = k[X][Z]
+			k[Z][Y] = k[Y][Z]
+		}
+	}
+}
+
+func (c *LayeredDemagConvolution) Free() {
+	if c == nil {
+		return
+	}
+	c.inputSize = [3]int{}
+	c.realKernSize = [3]int{}
+	c.fftRBuf.Free()
+	c.fftRBuf = nil
+	for i := 0; i < 3; i++ {
+		c.fftBBuf[i].Free()
+		c.fftBBuf[i] = nil
+	}
+	for iz := range c.fftMBuf {
+		for i := 0; i < 3; i++ {
+			c.fftMBuf[iz][i].Free()
+		}
+		for jz := iz; jz < len(c.kern[iz]); jz++ {
+			for i := 0; i < 3; i++ {
+				for j := i; j < 3; j++ {
+					c.kern[iz][jz][i][j].Free()
+				}
+			}
+		}
+	}
+	c.fftMBuf = nil
+	c.kern = nil
+	c.fwPlan.Free()
+	c.bwPlan.Free()
+
+	cudaCtx.SetCurrent()
+}
+
+// Layer iz of s, sharing its storage. Layers of a nil slice are nil.
+func layerOf(s *data.Slice, iz int) *data.Slice {
+	size := s.Size()
+	lsize := [3]int{size[X], size[Y], 1}
+	if s.IsNil() {
+		return data.NilSlice(s.NComp(), lsize)
+	}
+	util.Argument(iz >= 0 && iz < size[Z])
+	offset := uintptr(iz*size[X]*size[Y]) * cu.SIZEOF_FLOAT32
+	ptrs := make([]unsafe.Pointer, s.NComp())
+	for c := range ptrs {
+		ptrs[c] = unsafe.Pointer(uintptr(s.DevPtr(c)) + offset)
+	}
+	return data.SliceFromPtrs(lsize, data.GPUMemory, ptrs)
+}
+
+// Compares the FFT-accelerated layered convolution against brute-force on sparse data.
+func testLayeredConvolution(c *LayeredDemagConvolution, realKern [][][3][3]*data.Slice) {
+	util.Log("//layered convolution self-test...")
+	inhost := data.NewSlice(3, c.inputSize)
+	initConvTestInput(inhost.Vectors())
+	gpu := NewSlice(3, c.inputSize)
+	defer gpu.Free()
+	data.Copy(gpu, inhost)
+
+	msat := MakeMSlice(data.NilSlice(1, c.inputSize), []float64{1 / mag.Mu0})
+	c.Exec(gpu, gpu, data.NilSlice(1, c.inputSize), msat)
+	output := gpu.HostCopy()
+
+	brute := bruteLayeredConv(inhost, realKern)
+
+	a, b := output.Host(), brute.Host()
+	err, max := float32(0), float32(0)
+	for c := range a {
+		for i := range a[c] {
+			if fabs(a[c][i]-b[c][i]) > err {
+				err = fabs(a[c][i] - b[c][i])
+			}
+			if fabs(b[c][i]) > max {
+				max = fabs(b[c][i])
+			}
+		}
+	}
+	if err > LAYERED_CONV_TOLERANCE*max {
+		util.Fatal("layered convolution self-test tolerance: ", err/max, " FAIL")
+	}
+}
+
+// Maximum tolerable error on the layered convolution self-test, relative to the largest field.
+const LAYERED_CONV_TOLERANCE = 1e-4
+
+// Brute-force layered convolution on CPU: bruteConv for each pair of layers.
+func bruteLayeredConv(in *data.Slice, kernel [][][3][3]*data.Slice) *data.Slice {
+	size := in.Size()
+	lsize := [3]int{size[X], size[Y], 1}
+	out := data.NewSlice(3, size)
+	o := out.Vectors()
+	tmp := data.NewSlice(3, lsize)
+	t := tmp.Vectors()
+	for iz := 0; iz < size[Z]; iz++ {
+		for jz := 0; jz < size[Z]; jz++ {
+			v := in.Vectors()
+			var layer [3][][][]float32
+			for c := range layer {
+				layer[c] = v[c][jz : jz+1]
+			}
+			bruteConv(layer, t, kernel[iz][jz])
+			for c := 0; c < 3; c++ {
+				for iy := range t[c][0] {
+					for ix := range t[c][0][iy] {
+						o[c][iz][iy][ix] += t[c][0][iy][ix]
+					}
+				}
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,75 @@
+package cuda
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/mag"
+)
+
+// The layered convolution should agree with brute-force convolution.
+func TestLayeredDemag(t *testing.T) {
+	size := [3]int{6, 5, 3}
+	kernel := mag.CalcLayeredDemagKernel(size, [3]int{0, 0, 0}, [3]float64{2e-9, 3e-9, 4e-9}, []float64{1e-9, 4e-9, 2.5e-9}, 6)
+	c := NewLayeredDemag(size, kernel, false)
+	defer c.Free()
+
+	inhost := data.NewSlice(3, size)
+	initConvTestInput(inhost.Vectors())
+	in := GPUCopy(inhost)
+	defer in.Free()
+	out := NewSlice(3, size)
+	defer out.Free()
+	c.Exec(out, in, data.NilSlice(1, size), MakeMSlice(data.NilSlice(1, size), []float64{1 / mag.Mu0}))
+	have := out.HostCopy().Host()
+
+	want := bruteLayeredConv(inhost, kernel).Host()
+	max := 0.
+	for i := range want {
+		for j := range want[i] {
+			max = math.Max(max, math.Abs(float64(want[i][j])))
+		}
+	}
+	for i := range want {
+		for j := range want[i] {
+			if math.Abs(float64(have[i][j]-want[i][j])) > 1e-4*max {
+				t.Fatalf("comp %v, cell %v: have %v, want %v", i, j, have[i][j], want[i][j])
+			}
+		}
+	}
+}
+
+// With equal layers, the layered convolution should agree with the 3D demag convolution.
+func TestLayeredDemagUniform(t *testing.T) {
+	size := [3]int{8, 4, 3}
+	cell := [3]float64{3e-9, 2e-9, 2e-9}
+	pbc := [3]int{0, 0, 0}
+	layered := NewLayeredDemag(size, mag.CalcLayeredDemagKernel(size, pbc, cell, []float64{cell[Z], cell[Z], cell[Z]}, 6), false)
+	defer layered.Free()
+	conv := NewDemag(size, pbc, mag.CalcDemagKernel(size, pbc, cell, 6), false)
+	defer conv.Free()
+
+	inhost := data.NewSlice(3, size)
+	initConvTestInput(inhost.Vectors())
+	in := GPUCopy(inhost)
+	defer in.Free()
+	msat := MakeMSlice(data.NilSlice(1, size), []float64{1e6})
+	vol := data.NilSlice(1, size)
+
+	B1 := NewSlice(3, size)
+	defer B1.Free()
+	layered.Exec(B1, in, vol, msat)
+	B2 := NewSlice(3, size)
+	defer B2.Free()
+	conv.Exec(B2, in, vol, msat)
+
+	have, want := B1.HostCopy().Host(), B2.HostCopy().Host()
+	for i := range want {
+		for j := range want[i] {
+			if math.Abs(float64(have[i][j]-want[i][j])) > 1e-5 {
+				t.Fatalf("comp %v, cell %v: have %v, want %v", i, j, have[i][j], want[i][j])
+			}
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package cuda
+
+// Oersted field of a current density, by FFT-accelerated convolution
+// with the Biot-Savart kernel (see mag/oerstedkernel.go).
+
+import (
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/mag"
+	"github.com/mumax/3/util"
+)
+
+// Stores the necessary state to perform FFT-accelerated convolution
+// with the Biot-Savart kernel.
+type OerstedConvolution struct {
+	inputSize        [3]int         // 3D size of the input/output data
+	realKernSize     [3]int         // Size of kernel and logical FFT size.
+	fftKernLogicSize [3]int         // logic size FFTed kernel, imaginary parts only
+	fftRBuf          *data.Slice    // FFT input buf, shared by all components
+	fftCBuf          [3]*data.Slice // FFT output bufs, one per component
+	kern             [3]*data.Slice // FFT kernel on device
+	fwPlan           fft3DR2CPlan   // Forward FFT (1 component)
+	bwPlan           fft3DC2RPlan   // Backward FFT (1 component)
+}
+
+// Initializes a convolution to evaluate the Oersted field for the given mesh geometry.
+// Sanity-checked if test == true (slow-ish for large meshes).
+func NewOersted(inputSize, PBC [3]int, kernel [3]*data.Slice, test bool) *OerstedConvolution {
+	c := new(OerstedConvolution)
+	c.inputSize = inputSize
+	c.realKernSize = kernel[X].Size()
+	c.init(kernel)
+	if test {
+		testOerstedConvolution(c, PBC, kernel)
+	}
+	return c
+}
+
+// Calculate the Oersted field of current density J * vol, store result in B.
+// 	J:   current density in A/m²
+// 	vol: unitless mask used to scale J, may be nil
+// 	B:   resulting Oersted field, in Tesla
+func (c *OerstedConvolution) Exec(B, J, vol *data.Slice) {
+	util.Argument(B.Size() == c.inputSize && J.Size() == c.inputSize)
+
+	// copyPadMul multiplies by μ0 * Msat, so we get μ0 J
+	one := MakeMSlice(data.NilSlice(1, c.inputSize), ones(1))
+
+	for i := 0; i < 3; i++ { // FW FFT
+		zero1_async(c.fftRBuf)
+		copyPadMul(c.fftRBuf, J.Comp(i), vol, c.realKernSize, c.inputSize, one)
+		c.fwPlan.ExecAsync(c.fftRBuf, c.fftCBuf[i])
+	}
+
+	kernMulCross_async(c.fftCBuf, c.kern[X], c.kern[Y], c.kern[Z],
+		c.fftKernLogicSize[X], c.fftKernLogicSize[Y], c.fftKernLogicSize[Z])
+
+	for i := 0; i < 3; i++ { // BW FFT
+		c.bwPlan.ExecAsync(c.fftCBuf[i], c.fftRBuf)
+		copyUnPad(B.Comp(i), c.fftRBuf, c.inputSize, c.realKernSize)
+	}
+}
+
+func (c *OerstedConvolution) init(realKern [3]*data.Slice) {
+	// init device buffers
+	nc := fftR2COutputSizeFloats(c.realKernSize)
+	for i := 0; i < 3; i++ {
+		c.fftCBuf[i] = NewSlice(1, nc)
+	}
+	c.fftRBuf = NewSlice(1, c.realKernSize)
+
+	// init FFT plans
+	c.fwPlan = newFFT3DR2C(c.realKernSize[X], c.realKernSize[Y], c.realKernSize[Z])
+	c.bwPlan = newFFT3DC2R(c.realKernSize[X], c.realKernSize[Y], c.realKernSize[Z])
+
+	// init FFT kernel, the kernel is odd: store imaginary parts only
+	c.fftKernLogicSize = nc
+	util.Assert(c.fftKernLogicSize[X]%2 == 0)
+	c.fftKernLogicSize[X] /= 2
+
+	output := c.fftCBuf[X]
+	kfull := data.NewSlice(1, output.Size())
+	fftKern := data.NewSlice(1, c.fftKernLogicSize)
+	for i := 0; i < 3; i++ {
+		data.Copy(c.fftRBuf, realKern[i])
+		c.fwPlan.ExecAsync(c.fftRBuf, output)
+		data.Copy(kfull, output)
+		scaleImagParts(fftKern, kfull, 1/float32(c.fwPlan.InputLen()))
+		c.kern[i] = GPUCopy(fftKern)
+	}
+}
+
+func (c *OerstedConvolution) Free() {
+	if c == nil {
+		return
+	}
+	c.inputSize = [3]int{}
+	c.realKernSize = [3]int{}
+	c.fftRBuf.Free()
+	c.fftRBuf = nil
+	for i := 0; i < 3; i++ {
+		c.fftCBuf[i].Free()
+		c.fftCBuf[i] = nil
+		c.kern[i].Free()
+		c.kern[i] = nil
+	}
+	c.fwPlan.Free()
+	c.bwPlan.Free()
+
+	cudaCtx.SetCurrent()
+}
+
+// Compares the FFT-accelerated Oersted convolution against brute-force on sparse data.
+func testOerstedConvolution(c *OerstedConvolution, PBC [3]int, G [3]*data.Slice) {
+	if PBC != [3]int{0, 0, 0} {
+		// the brute-force method does not work for pbc.
+		util.Log("skipping convolution self-test for PBC")
+		return
+	}
+	util.Log("//Oersted convolution self-test...")
+	inhost := data.NewSlice(3, c.inputSize)
+	initConvTestInput(inhost.Vectors())
+	gpu := NewSlice(3, c.inputSize)
+	defer gpu.Free()
+	data.Copy(gpu, inhost)
+
+	vol := data.NilSlice(1, c.inputSize)
+	c.Exec(gpu, gpu, vol)
+	output := gpu.HostCopy()
+
+	// B = J x G written as antisymmetric matrix kernel, including μ0.
+	var kern [3][3]*data.Slice
+	for i := 0; i < 3; i++ {
+		kern[i][i] = data.NewSlice(1, c.realKernSize)
+	}
+	signed := func(g *data.Slice, sign float64) *data.Slice {
+		k := g.HostCopy()
+		l := k.Host()[0]
+		for i := range l {
+			l[i] *= float32(sign * mag.Mu0)
+		}
+		return k
+	}
+	kern[X][Y], kern[Y][X] = signed(G[Z], 1), signed(G[Z], -1)
+	kern[Z][X], kern[X][Z] = signed(G[Y], 1), signed(G[Y], -1)
+	kern[Y][Z], kern[Z][Y] = signed(G[X], 1), signed(G[X], -1)
+
+	brute := data.NewSlice(3, c.inputSize)
+	bruteConv(inhost.Vectors(), brute.Vectors(), kern)
+
+	// kernel is not normalized: compare relative to largest field
+	a, b := output.Host(), brute.Host()
+	err, max := float32(0), float32(0)
+	for c := range a {
+		for i := range a[c] {
+			if fabs(a[c][i]-b[c][i]) > err {
+				err = fabs(a[c][i] - b[c][i])
+			}
+			if fabs(b[c][i]) > max {
+				max = fabs(b[c][i])
+			}
+		}
+	}
+	if err > CONV_TOLERANCE*max {
+		util.Fatal("Oersted convolution self-test tolerance: ", err/max, " FAIL")
+	}
+}
@@ -0,0 +1,139 @@
+package cuda
+
+import (
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/util"
+)
+
+// Stores the necessary state to perform the FFT-accelerated convolution
+// of the magnetization of one mesh with an offset kernel (see mag.CalcOffsetKernel),
+// yielding its stray field on a lattice of another size.
+type OffsetConvolution struct {
+	srcSize      [3]int            // 3D size of the input (source magnetization)
+	dstSize      [3]int            // 3D size of the output (field lattice)
+	realKernSize [3]int            // Size of kernel and logical FFT size.
+	fftRBuf      [3]*data.Slice    // FFT input buf
+	fftCBuf      [3]*data.Slice    // FFT output buf
+	kern         [3][3]*data.Slice // FFT kernel on device, complex
+	fwPlan       fft3DR2CPlan      // Forward FFT (1 component)
+	bwPlan       fft3DC2RPlan      // Backward FFT (1 component)
+}
+
+// Initializes a convolution from a source of srcSize cells to a lattice of dstSize points,
+// with kernel of size srcSize+dstSize-1.
+// Sanity-checked if test == true (slow-ish for large meshes).
+func NewOffsetConvolution(srcSize, dstSize [3]int, kernel [3][3]*data.Slice, test bool) *OffsetConvolution {
+	c := new(OffsetConvolution)
+	c.srcSize = srcSize
+	c.dstSize = dstSize
+	c.realKernSize = kernel[X][X].Size()
+	for i := 0; i < 3; i++ {
+		util.Argument(c.realKernSize[i] == srcSize[i]+dstSize[i]-1)
+	}
+	c.init(kernel)
+	if test {
+		testOffsetConvolution(c, kernel)
+	}
+	return c
+}
+
+// Calculate the stray field of m * vol * Msat, store result in B.
+//
+//	m:    magnetization normalized to unit length, of the source size
+//	vol:  unitless mask used to scale m's length, may be nil
+//	Msat: saturation magnetization in A/m
+//	B:    resulting stray field in Tesla, of the destination size
+func (c *OffsetConvolution) Exec(B, m, vol *data.Slice, Msat MSlice) {
+	util.Argument(B.Size() == c.dstSize && m.Size() == c.srcSize)
+	for i := 0; i < 3; i++ { // FW FFT
+		zero1_async(c.fftRBuf[i])
+		copyPadMul(c.fftRBuf[i], m.Comp(i), vol, c.realKernSize, c.srcSize, Msat)
+		c.fwPlan.ExecAsync(c.fftRBuf[i], c.fftCBuf[i])
+	}
+
+	N := fftR2COutputSizeFloats(c.realKernSize)
+	kernMulCSymm3D_async(c.fftCBuf,
+		c.kern[X][X], c.kern[Y][Y], c.kern[Z][Z],
+		c.kern[Y][Z], c.kern[X][Z], c.kern[X][Y],
+		N[X]/2, N[Y], N[Z])
+
+	for i := 0; i < 3; i++ { // BW FFT
+		c.bwPlan.ExecAsync(c.fftCBuf[i], c.fftRBuf[i])
+		copyUnPad(B.Comp(i), c.fftRBuf[i], c.dstSize, c.realKernSize)
+	}
+}
+
+func (c *OffsetConvolution) init(realKern [3][3]*data.Slice) {
+	nc := fftR2COutputSizeFloats(c.realKernSize)
+	for i := 0; i < 3; i++ {
+		c.fftCBuf[i] = NewSlice(1, nc)
+		c.fftRBuf[i] = NewSlice(1, c.realKernSize)
+	}
+
+	c.fwPlan = newFFT3DR2C(c.realKernSize[X], c.realKernSize[Y], c.realKernSize[Z])
+	c.bwPlan = newFFT3DC2R(c.realKernSize[X], c.realKernSize[Y], c.realKernSize[Z])
+
+	// FFT kernel, keep complex values: the offset kernel has no mirror symmetry.
+	scale := 1 / float32(c.fwPlan.InputLen())
+	for i := 0; i < 3; i++ {
+		for j := i; j < 3; j++ {
+			data.Copy(c.fftRBuf[0], realKern[i][j])
+			c.fwPlan.ExecAsync(c.fftRBuf[0], c.fftCBuf[0])
+			c.kern[i][j] = NewSlice(1, nc)
+			Madd2(c.kern[i][j], c.fftCBuf[0], c.fftCBuf[0], scale, 0)
+		}
+	}
+	c.kern[Y][X] = c.kern[X][Y]
+	c.kern[Z][X] = c.kern[X][Z]
+	c.kern[Z][Y] = c.kern[Y][Z]
+}
+
+func (c *OffsetConvolution) Free() {
+	if c == nil {
+		return
+	}
+	for i := 0; i < 3; i++ {
+		c.fftCBuf[i].Free()
+		c.fftRBuf[i].Free()
+		c.fftCBuf[i] = nil
+		c.fftRBuf[i] = nil
+		for j := i; j < 3; j++ {
+			c.kern[i][j].Free()
+		}
+	}
+	c.kern = [3][3]*data.Slice{}
+	c.fwPlan.Free()
+	c.bwPlan.Free()
+}
+
+// Compares the FFT-accelerated offset convolution against brute-force on sparse data.
+func testOffsetConvolution(c *OffsetConvolution, realKern [3][3]*data.Slice) {
+	util.Log("//offset convolution self-test...")
+	inhost := data.NewSlice(3, c.srcSize)
+	initConvTestInput(inhost.Vectors())
+	in := NewSlice(3, c.srcSize)
+	defer in.Free()
+	data.Copy(in, inhost)
+	out := NewSlice(3, c.dstSize)
+	defer out.Free()
+
+	msat := MakeMSlice(data.NilSlice(1, c.srcSize), []float64{1})
+	c.Exec(out, in, data.NilSlice(1, c.srcSize), msat)
+	output := out.HostCopy()
+
+	brute := data.NewSlice(3, c.dstSize)
+	bruteConv(inhost.Vectors(), brute.Vectors(), realKern)
+
+	a, b := output.Host(), brute.Host()
+	err := float32(0)
+	for c := range a {
+		for i := range a[c] {
+			if fabs(a[c][i]-b[c][i]) > err {
+				err = fabs(a[c][i] - b[c][i])
+			}
+		}
+	}
+	if err > CONV_TOLERANCE {
+		util.Fatal("offset convolution self-test tolerance: ", err, " FAIL")
+	}
+}
@@ -0,0 +1,85 @@
+package cuda
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/mag"
+)
+
+// The offset convolution should agree with brute-force convolution.
+func TestOffsetConvolution(t *testing.T) {
+	src, dst := [3]int{8, 4, 2}, [3]int{5, 6, 1}
+	cell := [3]float64{2e-9, 3e-9, 1e-9}
+	kernel := mag.CalcOffsetKernel(src, dst, cell, [3]float64{7e-9, -4e-9, 20e-9}, 6)
+	c := NewOffsetConvolution(src, dst, kernel, false)
+	defer c.Free()
+
+	inhost := data.NewSlice(3, src)
+	initConvTestInput(inhost.Vectors())
+	in := GPUCopy(inhost)
+	defer in.Free()
+	out := NewSlice(3, dst)
+	defer out.Free()
+	c.Exec(out, in, data.NilSlice(1, src), MakeMSlice(data.NilSlice(1, src), []float64{1}))
+	have := out.HostCopy().Host()
+
+	brute := data.NewSlice(3, dst)
+	bruteConv(inhost.Vectors(), brute.Vectors(), kernel)
+	want := brute.Host()
+	max := 0.
+	for i := range want {
+		for j := range want[i] {
+			max = math.Max(max, math.Abs(mag.Mu0*float64(want[i][j])))
+		}
+	}
+	for i := range want {
+		for j := range want[i] {
+			if math.Abs(float64(have[i][j])-mag.Mu0*float64(want[i][j])) > 1e-4*max {
+				t.Fatalf("comp %v, cell %v: have %v, want %v", i, j, have[i][j], mag.Mu0*want[i][j])
+			}
+		}
+	}
+}
+
+// Far away, the stray field of a uniformly magnetized mesh is that of a point dipole.
+func TestOffsetConvolutionDipole(t *testing.T) {
+	src, dst := [3]int{8, 8, 2}, [3]int{4, 4, 1}
+	cell := [3]float64{2e-9, 2e-9, 2e-9}
+	offset := [3]float64{-3e-9, 5e-9, 300e-9} // first destination point w.r.t. first source cell
+	kernel := mag.CalcOffsetKernel(src, dst, cell, offset, 6)
+	c := NewOffsetConvolution(src, dst, kernel, true)
+	defer c.Free()
+
+	const Ms = 1e6
+	mh := data.NewSlice(3, src)
+	for i := range mh.Host()[X] {
+		mh.Host()[X][i] = 1
+	}
+	m := GPUCopy(mh)
+	defer m.Free()
+	B := NewSlice(3, dst)
+	defer B.Free()
+	c.Exec(B, m, data.NilSlice(1, src), MakeMSlice(data.NilSlice(1, src), []float64{Ms}))
+	b := B.HostCopy().Vectors()
+
+	moment := Ms * float64(prod(src)) * cell[X] * cell[Y] * cell[Z] // along x
+	var center [3]float64                                           // of the source, w.r.t. its first cell
+	for i := range center {
+		center[i] = 0.5 * float64(src[i]-1) * cell[i]
+	}
+	for iy := 0; iy < dst[Y]; iy++ {
+		for ix := 0; ix < dst[X]; ix++ {
+			r := [3]float64{offset[X] + float64(ix)*cell[X] - center[X], offset[Y] + float64(iy)*cell[Y] - center[Y], offset[Z] - center[Z]}
+			d := norm3(r)
+			pre := mag.Mu0 / (4 * math.Pi * d * d * d)
+			want := [3]float64{pre * (3*moment*r[X]*r[X]/(d*d) - moment), pre * 3 * moment * r[X] * r[Y] / (d * d), pre * 3 * moment * r[X] * r[Z] / (d * d)}
+			for c := range want {
+				if math.Abs(float64(b[c][0][iy][ix])-want[c]) > 1e-2*math.Abs(want[X]) {
+					t.Errorf("B[%v] at (%v, %v): have %v, want %v", c, ix, iy, b[c][0][iy][ix], want[c])
+				}
+			}
+		}
+	}
+}
@@ -73,12 +73,13 @@ func bruteConv(in, out [3][][][]float32, kernel [3][3]*data.Slice) {
 	}
 
 	size := sizeOf(in[0])
+	dsize := sizeOf(out[0]) // may differ from the input size, see OffsetConvolution
 	ksize := sizeOf(kern[0][0])
 	// Zero output first
 	for c := 0; c < 3; c++ {
-		for iz := 0; iz < size[Z]; iz++ {
-			for iy := 0; iy < size[Y]; iy++ {
-				for ix := 0; ix < size[X]; ix++ {
+		for iz := 0; iz < dsize[Z]; iz++ {
+			for iy := 0; iy < dsize[Y]; iy++ {
+				for ix := 0; ix < dsize[X]; ix++ {
 					out[c][iz][iy][ix] = 0
 				}
 			}
@@ -98,13 +99,13 @@ func bruteConv(in, out [3][][][]float32, kernel [3][3]*data.Slice) {
 						if kern[dc][sc] == nil {
 							continue // skip zero kernel
 						}
-						for dz := 0; dz < size[Z]; dz++ {
+						for dz := 0; dz < dsize[Z]; dz++ {
 							k := wrap(dz-sz, ksize[Z])
 
-							for dy := 0; dy < size[Y]; dy++ {
+							for dy := 0; dy < dsize[Y]; dy++ {
 								j := wrap(dy-sy, ksize[Y])
 
-								for dx := 0; dx < size[X]; dx++ {
+								for dx := 0; dx < dsize[X]; dx++ {
 									i := wrap(dx-sx, ksize[X])
 
 									out[dc][dz][dy][dx] += in[sc][sz][sy][sx] * kern[dc][sc][k][j][i]
@@ -0,0 +1,179 @@
+package cuda
+
+import (
+	"math"
+	"math/rand"
+	"unsafe"
+
+	"github.com/mumax/3/cuda/cu"
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/mag"
+	"github.com/mumax/3/util"
+)
+
+// Hierarchical (treecode) evaluation of the demag field of sparse geometries,
+// on the occupied cells only (see mag.DemagTree).
+// Alternative to the FFT DemagConvolution, whose cost scales with the full mesh.
+type DemagTree struct {
+	tree               *mag.DemagTree
+	kern               [3][3]*data.Slice // demag kernel of the near leaves on device, size tree.NearSize, padded
+	mom                unsafe.Pointer    // multipole moments of all nodes, 12 per node
+	cells, cellLeaf    unsafe.Pointer    // GPU copies of the tree's int32 lists
+	nodeStart, nodeEnd unsafe.Pointer
+	nearStart, near    unsafe.Pointer
+	farStart, far      unsafe.Pointer
+	center             [3]*data.Slice // node centers
+}
+
+// Initializes the treecode for the given tree, with kernel the demag kernel for input size tree.NearSize.
+func NewDemagTree(tree *mag.DemagTree, kernel [3][3]*data.Slice) *DemagTree {
+	c := &DemagTree{tree: tree}
+	for i := 0; i < 3; i++ {
+		for j := i; j < 3; j++ {
+			k := kernel[i][j]
+			if k == nil {
+				k = data.NewSlice(1, kernel[X][X].Size()) // zero in 2D
+			}
+			c.kern[i][j] = GPUCopy(k)
+		}
+	}
+	nNodes := len(tree.NodeStart)
+	c.mom = MemAlloc(int64(12*nNodes) * cu.SIZEOF_FLOAT32)
+	c.cells = int32GPUCopy(tree.Cells)
+	c.cellLeaf = int32GPUCopy(tree.CellLeaf)
+	c.nodeStart = int32GPUCopy(tree.NodeStart)
+	c.nodeEnd = int32GPUCopy(tree.NodeEnd)
+	c.nearStart = int32GPUCopy(tree.NearStart)
+	c.near = int32GPUCopy(tree.Near)
+	c.farStart = int32GPUCopy(tree.FarStart)
+	c.far = int32GPUCopy(tree.Far)
+	for i := range c.center {
+		host := data.SliceFromArray([][]float32{tree.Center[i]}, [3]int{nNodes, 1, 1})
+		c.center[i] = GPUCopy(host)
+	}
+	return c
+}
+
+// Calculate the demag field of m * vol * Msat, store result in B.
+// B is zero outside the occupied cells of the tree, and may not be m.
+//
+//	m:    magnetization normalized to unit length
+//	vol:  unitless mask used to scale m's length, may be nil
+//	Msat: saturation magnetization in A/m
+//	B:    resulting demag field, in Tesla
+func (c *DemagTree) Exec(B, m, vol *data.Slice, Msat MSlice) {
+	t := c.tree
+	util.Argument(B.Size() == t.Size && m.Size() == t.Size)
+	util.Argument(B.DevPtr(X) != m.DevPtr(X))
+	cs := c.cellSize()
+	V := cs[X] * cs[Y] * cs[Z]
+	Nx, Ny := t.Size[X], t.Size[Y]
+	nNodes := len(t.NodeStart)
+
+	// upward pass: moments of the leaves, then of their parents level by level
+	nLeaves := t.Levels[1]
+	k_treeleafmoments_async(c.mom, m.DevPtr(X), m.DevPtr(Y), m.DevPtr(Z),
+		vol.DevPtr(0), Msat.DevPtr(0), Msat.Mul(0),
+		c.cells, c.nodeStart, c.nodeEnd,
+		c.center[X].DevPtr(0), c.center[Y].DevPtr(0), c.center[Z].DevPtr(0),
+		cs[X], cs[Y], cs[Z], V, Nx, Ny, nLeaves, nNodes, make1DConf(nLeaves))
+	for l := 1; l < len(t.Levels)-1; l++ {
+		first, last := t.Levels[l], t.Levels[l+1]
+		k_treeupward_async(c.mom, c.nodeStart, c.nodeEnd,
+			c.center[X].DevPtr(0), c.center[Y].DevPtr(0), c.center[Z].DevPtr(0),
+			first, last, nNodes, make1DConf(last-first))
+	}
+
+	// field in the occupied cells
+	Zero(B)
+	ksize := c.kern[X][X].Size()
+	nCells := len(t.Cells)
+	k_treefield_async(B.DevPtr(X), B.DevPtr(Y), B.DevPtr(Z),
+		m.DevPtr(X), m.DevPtr(Y), m.DevPtr(Z),
+		vol.DevPtr(0), Msat.DevPtr(0), Msat.Mul(0),
+		c.kern[X][X].DevPtr(0), c.kern[Y][Y].DevPtr(0), c.kern[Z][Z].DevPtr(0),
+		c.kern[Y][Z].DevPtr(0), c.kern[X][Z].DevPtr(0), c.kern[X][Y].DevPtr(0),
+		ksize[X], ksize[Y], ksize[Z],
+		c.mom, c.cells, c.cellLeaf, c.nodeStart, c.nodeEnd,
+		c.nearStart, c.near, c.farStart, c.far,
+		c.center[X].DevPtr(0), c.center[Y].DevPtr(0), c.center[Z].DevPtr(0),
+		cs[X], cs[Y], cs[Z], Nx, Ny, nCells, nNodes, make1DConf(nCells))
+}
+
+// Compares the treecode against the FFT convolution for random magnetization in the occupied cells,
+// with relative tolerance 0.2 theta², see mag.DemagTree.
+// This quickly uncovers errors in the tree or its kernels.
+func (c *DemagTree) SelfTest(fft *DemagConvolution) {
+	util.Log("//demag tree self-test...")
+	t := c.tree
+	size := t.Size
+	rng := rand.New(rand.NewSource(0)) // reproducible tests
+	inhost := data.NewSlice(3, size)
+	volhost := data.NewSlice(1, size)
+	m, v := inhost.Host(), volhost.Host()[0]
+	for _, I := range t.Cells {
+		for i := range m {
+			m[i][I] = 1 - 2*rng.Float32()
+		}
+		v[I] = 1
+	}
+	in := GPUCopy(inhost)
+	defer in.Free()
+	vol := GPUCopy(volhost)
+	defer vol.Free()
+	Msat := MakeMSlice(data.NilSlice(1, size), []float64{1 / mag.Mu0})
+
+	out := NewSlice(3, size)
+	defer out.Free()
+	c.Exec(out, in, vol, Msat)
+	have := out.HostCopy().Host()
+	fft.Exec(out, in, vol, Msat)
+	want := out.HostCopy().Host()
+
+	max, err := 0., 0.
+	for i := range want {
+		for _, I := range t.Cells {
+			max = math.Max(max, math.Abs(float64(want[i][I])))
+			err = math.Max(err, math.Abs(float64(have[i][I]-want[i][I])))
+		}
+	}
+	if err > 0.2*t.Theta*t.Theta*max {
+		util.Fatal("demag tree self-test relative error: ", err/max, " FAIL")
+	}
+}
+
+func (c *DemagTree) Free() {
+	if c == nil {
+		return
+	}
+	for i := 0; i < 3; i++ {
+		for j := i; j < 3; j++ {
+			c.kern[i][j].Free()
+		}
+		c.center[i].Free()
+	}
+	for _, ptr := range []unsafe.Pointer{c.mom, c.cells, c.cellLeaf, c.nodeStart, c.nodeEnd,
+		c.nearStart, c.near, c.farStart, c.far} {
+		memFree(ptr)
+	}
+	c.tree = nil
+}
+
+// cell size in units of the tree
+func (c *DemagTree) cellSize() [3]float32 {
+	t := c.tree
+	return [3]float32{float32(t.CellSize[X] / t.Unit), float32(t.CellSize[Y] / t.Unit), float32(t.CellSize[Z] / t.Unit)}
+}
+
+// GPU copy of an int32 list, which must not be empty
+func int32GPUCopy(list []int32) unsafe.Pointer {
+	n := len(list)
+	if n == 0 {
+		list = []int32{0} // e.g. no far nodes in a small geometry
+		n = 1
+	}
+	bytes := int64(n) * cu.SIZEOF_FLOAT32 // int32, same size
+	ptr := MemAlloc(bytes)
+	MemCpyHtoD(ptr, unsafe.Pointer(&list[0]), bytes)
+	return ptr
+}
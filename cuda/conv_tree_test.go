@@ -0,0 +1,65 @@
+package cuda
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/mag"
+)
+
+// The GPU treecode should agree with the host reference implementation.
+func TestDemagTree(t *testing.T) {
+	size := [3]int{40, 32, 4}
+	cell := [3]float64{2e-9, 3e-9, 1e-9}
+	volhost := data.NewSlice(1, size)
+	v := volhost.Scalars()
+	for iz := range v {
+		for iy := range v[iz] {
+			for ix := range v[iz][iy] {
+				if (ix/6+iy/5)%3 == 0 {
+					v[iz][iy][ix] = 0.5 + 0.5*float32(iz%2)
+				}
+			}
+		}
+	}
+	tree := mag.NewDemagTree(size, cell, volhost, 0.4)
+	kernel := mag.CalcDemagKernel(tree.NearSize, [3]int{0, 0, 0}, cell, 6)
+	c := NewDemagTree(tree, kernel)
+	defer c.Free()
+
+	inhost := data.NewSlice(3, size)
+	initConvTestInput(inhost.Vectors())
+	in := GPUCopy(inhost)
+	defer in.Free()
+	vol := GPUCopy(volhost)
+	defer vol.Free()
+	out := NewSlice(3, size)
+	defer out.Free()
+	const msat = 8e5
+	c.Exec(out, in, vol, MakeMSlice(data.NilSlice(1, size), []float64{msat}))
+	have := out.HostCopy().Host()
+
+	var M [3][]float32
+	m, vl := inhost.Host(), volhost.Host()[0]
+	for i := range M {
+		M[i] = make([]float32, len(vl))
+		for j := range vl {
+			M[i][j] = msat * vl[j] * m[i][j]
+		}
+	}
+	want := tree.Field(M, kernel)
+	max := 0.
+	for i := range want {
+		for j := range want[i] {
+			max = math.Max(max, math.Abs(float64(want[i][j])))
+		}
+	}
+	for i := range want {
+		for j := range want[i] {
+			if math.Abs(float64(have[i][j]-want[i][j])) > 1e-4*max {
+				t.Fatalf("comp %v, cell %v: have %v, want %v", i, j, have[i][j], want[i][j])
+			}
+		}
+	}
+}
@@ -93,7 +93,8 @@ func typemap(ctype string) string {
 	panic(fmt.Errorf("unsupported cuda type: %v", ctype))
 }
 
-var tm = map[string]string{"float*": "unsafe.Pointer", "float": "float32", "int": "int", "uint8_t*": "unsafe.Pointer", "uint8_t": "byte"}
+var tm = map[string]string{"float*": "unsafe.Pointer", "float": "float32", "int": "int", "uint8_t*": "unsafe.Pointer", "uint8_t": "byte",
+	"uint16_t*": "unsafe.Pointer", "uint16_t": "uint16", "uint32_t*": "unsafe.Pointer", "int*": "unsafe.Pointer"}
 
 // template data
 type Kernel struct {
@@ -16,7 +16,7 @@ adddmi(float* __restrict__ Hx, float* __restrict__ Hy, float* __restrict__ Hz,
        float* __restrict__ mx, float* __restrict__ my, float* __restrict__ mz,
        float* __restrict__ Ms_, float Ms_mul,
        float* __restrict__ aLUT2d, float* __restrict__ dLUT2d, uint8_t* __restrict__ regions,
-       float cx, float cy, float cz, int Nx, int Ny, int Nz, uint8_t PBC, uint8_t OpenBC) {
+       float cx, float cy, float cz, float* __restrict__ dz, int Nx, int Ny, int Nz, uint8_t PBC, uint8_t OpenBC) {
 
     int ix = blockIdx.x * blockDim.x + threadIdx.x;
     int iy = blockIdx.y * blockDim.y + threadIdx.y;
@@ -124,13 +124,20 @@ adddmi(float* __restrict__ Hx, float* __restrict__ Hy, float* __restrict__ Hz,
 
     // only take vertical derivative for 3D sim
     if (Nz != 1) {
+        float dz0 = cz, czl = cz, czh = cz; // layer thickness, distance to bottom and top neighbor
+        if (dz != NULL) {                   // non-uniform layers
+            dz0 = dz[iz];
+            czl = layerdist(dz, iz, lclampz(iz-1));
+            czh = layerdist(dz, iz, hclampz(iz+1));
+        }
+
         // bottom neighbor
         {
             i_  = idx(ix, iy, lclampz(iz-1));
             float3 m1  = make_float3(mx[i_], my[i_], mz[i_]);
             m1  = ( is0(m1)? m0: m1 );                         // Neumann BC
             float A1 = aLUT2d[symidx(r0, regions[i_])];
-            h += (2.0f*A1/(cz*cz)) * (m1 - m0);                // Exchange only
+            h += (2.0f*A1/(czl*dz0)) * (m1 - m0);              // Exchange only
         }
 
         // top neighbor
@@ -139,7 +146,7 @@ adddmi(float* __restrict__ Hx, float* __restrict__ Hy, float* __restrict__ Hz,
             float3 m2  = make_float3(mx[i_], my[i_], mz[i_]);
             m2  = ( is0(m2)? m0: m2 );
             float A2 = aLUT2d[symidx(r0, regions[i_])];
-            h += (2.0f*A2/(cz*cz)) * (m2 - m0);
+            h += (2.0f*A2/(czh*dz0)) * (m2 - m0);
         }
     }
 
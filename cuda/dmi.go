@@ -9,8 +9,9 @@ import (
 
 // Add effective field of Dzyaloshinskii-Moriya interaction to Beff (Tesla).
 // According to Bagdanov and Röβler, PRL 87, 3, 2001. eq.8 (out-of-plane symmetry breaking).
+// dz holds the layer thicknesses of a non-uniform grid along z, or is a nil slice.
 // See dmi.cu
-func AddDMI(Beff *data.Slice, m *data.Slice, Aex_red, Dex_red SymmLUT, Msat MSlice, regions *Bytes, mesh *data.Mesh, OpenBC bool) {
+func AddDMI(Beff *data.Slice, m *data.Slice, Aex_red, Dex_red InterLUT, Msat MSlice, regions RegionMap, mesh *data.Mesh, dz *data.Slice, OpenBC bool) {
 	cellsize := mesh.CellSize()
 	N := Beff.Size()
 	util.Argument(m.Size() == N)
@@ -20,9 +21,18 @@ func AddDMI(Beff *data.Slice, m *data.Slice, Aex_red, Dex_red SymmLUT, Msat MSli
 		openBC = 1
 	}
 
-	k_adddmi_async(Beff.DevPtr(X), Beff.DevPtr(Y), Beff.DevPtr(Z),
-		m.DevPtr(X), m.DevPtr(Y), m.DevPtr(Z),
-		Msat.DevPtr(0), Msat.Mul(0),
-		unsafe.Pointer(Aex_red), unsafe.Pointer(Dex_red), regions.Ptr,
-		float32(cellsize[X]), float32(cellsize[Y]), float32(cellsize[Z]), N[X], N[Y], N[Z], mesh.PBC_code(), openBC, cfg)
+	if r, wide := regions.regionPtr(); wide {
+		k_adddmi16_async(Beff.DevPtr(X), Beff.DevPtr(Y), Beff.DevPtr(Z),
+			m.DevPtr(X), m.DevPtr(Y), m.DevPtr(Z),
+			Msat.DevPtr(0), Msat.Mul(0),
+			unsafe.Pointer(Aex_red.Value), Aex_red.Keys, Aex_red.Scale, Aex_red.Inter, Aex_red.NPair,
+			unsafe.Pointer(Dex_red.Value), Dex_red.Keys, Dex_red.Scale, Dex_red.Inter, Dex_red.NPair, r,
+			float32(cellsize[X]), float32(cellsize[Y]), float32(cellsize[Z]), dz.DevPtr(0), N[X], N[Y], N[Z], mesh.PBC_code(), openBC, cfg)
+	} else {
+		k_adddmi_async(Beff.DevPtr(X), Beff.DevPtr(Y), Beff.DevPtr(Z),
+			m.DevPtr(X), m.DevPtr(Y), m.DevPtr(Z),
+			Msat.DevPtr(0), Msat.Mul(0),
+			unsafe.Pointer(Aex_red.Symm), unsafe.Pointer(Dex_red.Symm), r,
+			float32(cellsize[X]), float32(cellsize[Y]), float32(cellsize[Z]), dz.DevPtr(0), N[X], N[Y], N[Z], mesh.PBC_code(), openBC, cfg)
+	}
 }
@@ -35,7 +35,7 @@ adddmibulk(float* __restrict__ Hx, float* __restrict__ Hy, float* __restrict__ H
            float* __restrict__ Ms_, float Ms_mul,
            float* __restrict__ aLUT2d, float* __restrict__ DLUT2d,
            uint8_t* __restrict__ regions,
-           float cx, float cy, float cz, int Nx, int Ny, int Nz, uint8_t PBC, uint8_t OpenBC) {
+           float cx, float cy, float cz, float* __restrict__ dz, int Nx, int Ny, int Nz, uint8_t PBC, uint8_t OpenBC) {
 
     int ix = blockIdx.x * blockDim.x + threadIdx.x;
     int iy = blockIdx.y * blockDim.y + threadIdx.y;
@@ -148,6 +148,13 @@ adddmibulk(float* __restrict__ Hx, float* __restrict__ Hy, float* __restrict__ H
 
     // only take vertical derivative for 3D sim
     if (Nz != 1) {
+        float dz0 = cz, czl = cz, czh = cz; // layer thickness, distance to bottom and top neighbor
+        if (dz != NULL) {                   // non-uniform layers
+            dz0 = dz[iz];
+            czl = layerdist(dz, iz, lclampz(iz-1));
+            czh = layerdist(dz, iz, hclampz(iz+1));
+        }
+
         // bottom neighbor
         {
             float3 m1 = make_float3(0.0f, 0.0f, 0.0f);
@@ -161,13 +168,13 @@ adddmibulk(float* __restrict__ Hx, float* __restrict__ Hy, float* __restrict__ H
             float D_2A = D/(2.0f*A);
             if (!is0(m1) || !OpenBC){
                 if (is0(m1)) {
-                    m1.x = m0.x - (-cz * D_2A * m0.y);
-                    m1.y = m0.y + (-cz * D_2A * m0.x);
+                    m1.x = m0.x - (-czl * D_2A * m0.y);
+                    m1.y = m0.y + (-czl * D_2A * m0.x);
                     m1.z = m0.z;
                 }
-                h   += (2.0f*A/(cz*cz)) * (m1 - m0);
-                h.x += (D/cz)*(- m1.y);
-                h.y -= (D/cz)*(- m1.x);
+                h   += (2.0f*A/(czl*dz0)) * (m1 - m0);
+                h.x += (D/dz0)*(- m1.y);
+                h.y -= (D/dz0)*(- m1.x);
             }
         }
 
@@ -184,13 +191,13 @@ adddmibulk(float* __restrict__ Hx, float* __restrict__ Hy, float* __restrict__ H
             float D_2A = D/(2.0f*A);
             if (!is0(m2) || !OpenBC){
                 if (is0(m2)) {
-                    m2.x = m0.x - (+cz * D_2A * m0.y);
-                    m2.y = m0.y + (+cz * D_2A * m0.x);
+                    m2.x = m0.x - (+czh * D_2A * m0.y);
+                    m2.y = m0.y + (+czh * D_2A * m0.x);
                     m2.z = m0.z;
                 }
-                h   += (2.0f*A/(cz*cz)) * (m2 - m0);
-                h.x += (D/cz)*(m2.y );
-                h.y -= (D/cz)*(m2.x );
+                h   += (2.0f*A/(czh*dz0)) * (m2 - m0);
+                h.x += (D/dz0)*(m2.y );
+                h.y -= (D/dz0)*(m2.x );
             }
         }
     }
@@ -38,7 +38,7 @@ adddmifilm(float* __restrict__ Hx, float* __restrict__ Hy, float* __restrict__ H
            float* __restrict__ Ms_, float Ms_mul,
            float* __restrict__ aLUT2d, float* __restrict__ DLUT2d,
            uint8_t* __restrict__ regions,
-           float cx, float cy, float cz, int Nx, int Ny, int Nz, uint8_t PBC, uint8_t OpenBC) {
+           float cx, float cy, float cz, float* __restrict__ dz, int Nx, int Ny, int Nz, uint8_t PBC, uint8_t OpenBC) {
 
     int ix = blockIdx.x * blockDim.x + threadIdx.x;
     int iy = blockIdx.y * blockDim.y + threadIdx.y;
@@ -134,6 +134,13 @@ adddmifilm(float* __restrict__ Hx, float* __restrict__ Hy, float* __restrict__ H
 
     // only take vertical derivative for 3D sim
     if (Nz != 1) {
+        float dz0 = cz, czl = cz, czh = cz; // layer thickness, distance to bottom and top neighbor
+        if (dz != NULL) {                   // non-uniform layers
+            dz0 = dz[iz];
+            czl = layerdist(dz, iz, lclampz(iz-1));
+            czh = layerdist(dz, iz, hclampz(iz+1));
+        }
+
         // bottom neighbour
         {
             float3 m1 = make_float3(0.0f, 0.0f, 0.0f);
@@ -146,13 +153,13 @@ adddmifilm(float* __restrict__ Hx, float* __restrict__ Hy, float* __restrict__ H
             float D1 = DLUT2d[symidx(r0, r1)];
             if (!is0(m1) || !OpenBC){
                 if (is0(m1)) {
-                    m1.x = m0.x + (-cz * (0.5f*D1/A1) * m0.y);
-                    m1.y = m0.y - (-cz * (0.5f*D1/A1) * m0.x);
+                    m1.x = m0.x + (-czl * (0.5f*D1/A1) * m0.y);
+                    m1.y = m0.y - (-czl * (0.5f*D1/A1) * m0.x);
                     m1.z = m0.z;
                 }
-                h   += (2.0f*A1/(cz*cz)) * (m1 - m0);
-                h.x -= (D1/cz)*(- m1.y);
-                h.y += (D1/cz)*(- m1.x);
+                h   += (2.0f*A1/(czl*dz0)) * (m1 - m0);
+                h.x -= (D1/dz0)*(- m1.y);
+                h.y += (D1/dz0)*(- m1.x);
             }
         }
 
@@ -168,13 +175,13 @@ adddmifilm(float* __restrict__ Hx, float* __restrict__ Hy, float* __restrict__ H
             float D2 = DLUT2d[symidx(r0, r2)];
             if (!is0(m2) || !OpenBC){
                 if (is0(m2)) {
-                    m2.x = m0.x + (+cz * (0.5f*D2/A2) * m0.y);
-                    m2.y = m0.y - (+cz * (0.5f*D2/A2) * m0.x);
+                    m2.x = m0.x + (+czh * (0.5f*D2/A2) * m0.y);
+                    m2.y = m0.y - (+czh * (0.5f*D2/A2) * m0.x);
                     m2.z = m0.z;
                 }
-                h   += (2.0f*A2/(cz*cz)) * (m2 - m0);
-                h.x -= (D2/cz)*(m2.y );
-                h.y += (D2/cz)*(m2.x );
+                h   += (2.0f*A2/(czh*dz0)) * (m2 - m0);
+                h.x -= (D2/dz0)*(m2.y );
+                h.y += (D2/dz0)*(m2.x );
             }
         }
     }
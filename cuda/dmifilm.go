@@ -9,7 +9,7 @@ import (
 
 // Add effective field due to Dzyaloshinskii-Moriya interaction in films (dz) to Beff.
 // See dmifilm.cu
-func AddDMIFilm(Beff *data.Slice, m *data.Slice, Aex_red, D_red SymmLUT, Msat MSlice, regions *Bytes, mesh *data.Mesh, OpenBC bool) {
+func AddDMIFilm(Beff *data.Slice, m *data.Slice, Aex_red, D_red InterLUT, Msat MSlice, regions RegionMap, mesh *data.Mesh, dz *data.Slice, OpenBC bool) {
 	cellsize := mesh.CellSize()
 	N := Beff.Size()
 	util.Argument(m.Size() == N)
@@ -19,9 +19,18 @@ func AddDMIFilm(Beff *data.Slice, m *data.Slice, Aex_red, D_red SymmLUT, Msat MS
 		openBC = 1
 	}
 
-	k_adddmifilm_async(Beff.DevPtr(X), Beff.DevPtr(Y), Beff.DevPtr(Z),
-		m.DevPtr(X), m.DevPtr(Y), m.DevPtr(Z),
-		Msat.DevPtr(0), Msat.Mul(0),
-		unsafe.Pointer(Aex_red), unsafe.Pointer(D_red), regions.Ptr,
-		float32(cellsize[X]), float32(cellsize[Y]), float32(cellsize[Z]), N[X], N[Y], N[Z], mesh.PBC_code(), openBC, cfg)
+	if r, wide := regions.regionPtr(); wide {
+		k_adddmifilm16_async(Beff.DevPtr(X), Beff.DevPtr(Y), Beff.DevPtr(Z),
+			m.DevPtr(X), m.DevPtr(Y), m.DevPtr(Z),
+			Msat.DevPtr(0), Msat.Mul(0),
+			unsafe.Pointer(Aex_red.Value), Aex_red.Keys, Aex_red.Scale, Aex_red.Inter, Aex_red.NPair,
+			unsafe.Pointer(D_red.Value), D_red.Keys, D_red.Scale, D_red.Inter, D_red.NPair, r,
+			float32(cellsize[X]), float32(cellsize[Y]), float32(cellsize[Z]), dz.DevPtr(0), N[X], N[Y], N[Z], mesh.PBC_code(), openBC, cfg)
+	} else {
+		k_adddmifilm_async(Beff.DevPtr(X), Beff.DevPtr(Y), Beff.DevPtr(Z),
+			m.DevPtr(X), m.DevPtr(Y), m.DevPtr(Z),
+			Msat.DevPtr(0), Msat.Mul(0),
+			unsafe.Pointer(Aex_red.Symm), unsafe.Pointer(D_red.Symm), r,
+			float32(cellsize[X]), float32(cellsize[Y]), float32(cellsize[Z]), dz.DevPtr(0), N[X], N[Y], N[Z], mesh.PBC_code(), openBC, cfg)
+	}
 }
@@ -0,0 +1,45 @@
+package cuda
+
+import (
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/util"
+)
+
+// Sets f to the elastic force density ∇·σ of displacement u.
+// see elasticforce.cu
+func SetElasticForce(f, u *data.Slice, C11, C12, C44, rho MSlice, mesh *data.Mesh) {
+	util.Argument(f.Size() == u.Size() && f.NComp() == 3 && u.NComp() == 3)
+	N := mesh.Size()
+	w := invCellSize(mesh)
+	cfg := make3DConf(N)
+	k_elasticforce_async(f.DevPtr(X), f.DevPtr(Y), f.DevPtr(Z),
+		u.DevPtr(X), u.DevPtr(Y), u.DevPtr(Z),
+		C11.DevPtr(0), C11.Mul(0), C12.DevPtr(0), C12.Mul(0), C44.DevPtr(0), C44.Mul(0),
+		rho.DevPtr(0), rho.Mul(0),
+		w[X], w[Y], w[Z], N[X], N[Y], N[Z], mesh.PBC_code(), cfg)
+}
+
+// Sets the 6 components of e (xx, yy, zz, xy, xz, yz) to the strain of displacement u.
+// see elasticstrain.cu
+func SetElasticStrain(e, u *data.Slice, rho MSlice, mesh *data.Mesh) {
+	util.Argument(e.Size() == u.Size() && e.NComp() == 6 && u.NComp() == 3)
+	N := mesh.Size()
+	w := invCellSize(mesh)
+	cfg := make3DConf(N)
+	k_elasticstrain_async(e.DevPtr(0), e.DevPtr(1), e.DevPtr(2), e.DevPtr(3), e.DevPtr(4), e.DevPtr(5),
+		u.DevPtr(X), u.DevPtr(Y), u.DevPtr(Z),
+		rho.DevPtr(0), rho.Mul(0),
+		w[X], w[Y], w[Z], N[X], N[Y], N[Z], mesh.PBC_code(), cfg)
+}
+
+// Updates the lattice velocity v with force density f over dt, with damping eta.
+// see elastickick.cu
+func ElasticKick(v, f *data.Slice, rho, eta MSlice, dt float64) {
+	util.Argument(v.Size() == f.Size() && v.NComp() == 3 && f.NComp() == 3)
+	N := v.Len()
+	cfg := make1DConf(N)
+	k_elastickick_async(v.DevPtr(X), v.DevPtr(Y), v.DevPtr(Z),
+		f.DevPtr(X), f.DevPtr(Y), f.DevPtr(Z),
+		rho.DevPtr(0), rho.Mul(0), eta.DevPtr(0), eta.Mul(0),
+		float32(dt), N, cfg)
+}
@@ -0,0 +1,40 @@
+#ifndef _ELASTIC_H_
+#define _ELASTIC_H_
+
+#include "amul.h"
+#include "stencil.h"
+
+// Finite-difference helpers for elastodynamics, see elasticforce.cu, elasticstrain.cu
+
+// Neighbour of cell c along direction d (0, 1, 2) in the sense s (+1 or -1).
+// Returns c itself when the neighbour lies outside the grid or outside the
+// elastic medium (rho == 0), so that surfaces are stress-free.
+__device__ static inline int3
+elnbr(int3 c, int d, int s, float* __restrict__ rho_, float rho_mul, int Nx, int Ny, int Nz, uint8_t PBC) {
+    int3 n = c;
+    if (d == 0) {
+        n.x = (s < 0)? lclampx(c.x + s): hclampx(c.x + s);
+    } else if (d == 1) {
+        n.y = (s < 0)? lclampy(c.y + s): hclampy(c.y + s);
+    } else {
+        n.z = (s < 0)? lclampz(c.z + s): hclampz(c.z + s);
+    }
+    if (amul(rho_, rho_mul, idx(n.x, n.y, n.z)) == 0.0f) {
+        return c;
+    }
+    return n;
+}
+
+// ∂u/∂x_d in cell c, by central differences (one-sided at surfaces), w = 1/cellsize.
+__device__ static inline float
+elderiv(float* __restrict__ u, int3 c, int d, float w, float* __restrict__ rho_, float rho_mul, int Nx, int Ny, int Nz, uint8_t PBC) {
+    int3 p = elnbr(c, d, +1, rho_, rho_mul, Nx, Ny, Nz, PBC);
+    int3 m = elnbr(c, d, -1, rho_, rho_mul, Nx, Ny, Nz, PBC);
+    int n = (p.x != c.x || p.y != c.y || p.z != c.z) + (m.x != c.x || m.y != c.y || m.z != c.z);
+    if (n == 0) {
+        return 0.0f;
+    }
+    return (u[idx(p.x, p.y, p.z)] - u[idx(m.x, m.y, m.z)]) * w / n;
+}
+
+#endif
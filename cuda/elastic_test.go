@@ -0,0 +1,301 @@
+package cuda
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/mumax/3/data"
+)
+
+// elastic constants and density of the test medium
+const (
+	testC11 = 2.5e11
+	testC12 = 1.5e11
+	testC44 = 1.2e11
+	testRho = 7.9e3
+)
+
+// uniform test medium, periodic along x
+func elasticTestMedium(nx int, dx float64) (*data.Mesh, MSlice, MSlice, MSlice, MSlice) {
+	mesh := data.NewMesh(nx, 2, 1, dx, 3e-9, 2e-9, 1, 0, 0)
+	uniform := func(v float64) MSlice { return MakeMSlice(data.NilSlice(1, mesh.Size()), []float64{v}) }
+	return mesh, uniform(testC11), uniform(testC12), uniform(testC44), uniform(testRho)
+}
+
+// plane wave u_c = A sin(k x + φ) along x, polarized along c
+func planeWave(mesh *data.Mesh, c int, A, k, φ float64) *data.Slice {
+	u := data.NewSlice(3, mesh.Size())
+	v := u.Vectors()
+	dx := mesh.CellSize()[X]
+	for iz := range v[c] {
+		for iy := range v[c][iz] {
+			for ix := range v[c][iz][iy] {
+				v[c][iz][iy][ix] = float32(A * math.Sin(k*float64(ix)*dx+φ))
+			}
+		}
+	}
+	return u
+}
+
+// A plane wave along x is an eigenmode of the elastic force in a uniform medium: f = -ρ ω² u,
+// with phase velocity ω/k = sqrt(C11/ρ) for longitudinal and sqrt(C44/ρ) for transverse polarization,
+// up to the discretization error (k dx)²/12.
+func TestElasticWaveForce(t *testing.T) {
+	const nx, dx, A = 32, 2e-9, 1e-11
+	k := 2 * math.Pi / (nx * dx)
+	mesh, c11, c12, c44, rho := elasticTestMedium(nx, dx)
+	for c, C := range []float64{testC11, testC44, testC44} {
+		ω := k * math.Sqrt(C/testRho)
+		uh := planeWave(mesh, c, A, k, 0)
+		u := GPUCopy(uh)
+		f := NewSlice(3, mesh.Size())
+		SetElasticForce(f, u, c11, c12, c44, rho, mesh)
+		have := f.HostCopy().Host()
+		want := uh.Host()
+		u.Free()
+		f.Free()
+
+		fmax := testRho * ω * ω * A
+		tol := 1e-2 * fmax // (k dx)²/12 = 3.2e-3
+		for d := 0; d < 3; d++ {
+			for i := range want[d] {
+				if w := -testRho * ω * ω * float64(want[d][i]); math.Abs(float64(have[d][i])-w) > tol {
+					t.Fatalf("polarization %v, f%v[%v]: have %v, want %v", c, d, i, have[d][i], w)
+				}
+			}
+		}
+	}
+}
+
+// A longitudinal plane wave integrated with velocity Verlet, as in elastodynamics,
+// travels a quarter wavelength in a quarter period 2π/(4 k sqrt(C11/ρ)).
+func TestElasticWavePropagation(t *testing.T) {
+	const nx, dx, A = 32, 2e-9, 1e-11
+	k := 2 * math.Pi / (nx * dx)
+	ω := k * math.Sqrt(testC11/testRho)
+	mesh, c11, c12, c44, rho := elasticTestMedium(nx, dx)
+	eta := MakeMSlice(data.NilSlice(1, mesh.Size()), []float64{0})
+
+	// u = A sin(kx - ωt), v = -A ω cos(kx - ωt)
+	u := GPUCopy(planeWave(mesh, X, A, k, 0))
+	defer u.Free()
+	v := GPUCopy(planeWave(mesh, X, -A*ω, k, math.Pi/2))
+	defer v.Free()
+	f := NewSlice(3, mesh.Size())
+	defer f.Free()
+
+	T := math.Pi / (2 * ω)
+	n := 1000
+	dt := T / float64(n)
+	SetElasticForce(f, u, c11, c12, c44, rho, mesh)
+	for i := 0; i < n; i++ {
+		ElasticKick(v, f, rho, eta, dt/2)
+		Madd2(u, u, v, 1, float32(dt))
+		SetElasticForce(f, u, c11, c12, c44, rho, mesh)
+		ElasticKick(v, f, rho, eta, dt/2)
+	}
+
+	have := u.HostCopy().Host()[X]
+	want := planeWave(mesh, X, A, k, -math.Pi/2).Host()[X]
+	for i := range want {
+		// phase error of the discrete dispersion: (k dx)²/24 * π/2 = 2.5e-3
+		if math.Abs(float64(have[i]-want[i])) > 1e-2*A {
+			t.Fatalf("u[%v]: have %v, want %v", i, have[i], want[i])
+		}
+	}
+}
+
+// Host reference implementation of the elastic force density (elasticforce.cu) and strain (elasticstrain.cu), in float64:
+// 	f_a = ∂_a (C11 ∂_a u_a) + Σ_{b≠a} [∂_b (C44 ∂_b u_a) + (C12 + C44) ∂_a ∂_b u_b]
+// with stress-free surfaces at the edges of the grid and of the medium (rho == 0).
+type elasticRef struct {
+	u                [3][]float32
+	C11, C12, C44, ρ []float32
+	size             [3]int
+	pbc              [3]bool
+	w                [3]float64
+}
+
+func (e *elasticRef) index(c [3]int) int {
+	return (c[Z]*e.size[Y]+c[Y])*e.size[X] + c[X]
+}
+
+// neighbour of c along d in the sense s, c itself at surfaces.
+func (e *elasticRef) nbr(c [3]int, d, s int) [3]int {
+	n := c
+	n[d] += s
+	if e.pbc[d] {
+		n[d] = (n[d] + e.size[d]) % e.size[d]
+	}
+	if n[d] < 0 || n[d] >= e.size[d] || e.ρ[e.index(n)] == 0 {
+		return c
+	}
+	return n
+}
+
+func (e *elasticRef) deriv(u []float32, c [3]int, d int) float64 {
+	p, m := e.nbr(c, d, +1), e.nbr(c, d, -1)
+	n := 0
+	if p != c {
+		n++
+	}
+	if m != c {
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+	return float64(u[e.index(p)]-u[e.index(m)]) * e.w[d] / float64(n)
+}
+
+func (e *elasticRef) force(c [3]int) [3]float64 {
+	var f [3]float64
+	I := e.index(c)
+	if e.ρ[I] == 0 {
+		return f
+	}
+	for a := 0; a < 3; a++ {
+		for d := 0; d < 3; d++ {
+			K := e.C44
+			if a == d {
+				K = e.C11
+			}
+			for _, s := range []int{-1, 1} {
+				J := e.index(e.nbr(c, d, s))
+				K0, KJ := float64(K[I]), float64(K[J])
+				Kf := 0.
+				if K0+KJ != 0 {
+					Kf = 2 * K0 * KJ / (K0 + KJ)
+				}
+				f[a] += e.w[d] * e.w[d] * Kf * float64(e.u[a][J]-e.u[a][I])
+			}
+		}
+		for b := 0; b < 3; b++ {
+			if b == a {
+				continue
+			}
+			p, m := e.nbr(c, a, +1), e.nbr(c, a, -1)
+			n := 0
+			if p != c {
+				n++
+			}
+			if m != c {
+				n++
+			}
+			if n == 0 {
+				continue
+			}
+			d := e.deriv(e.u[b], p, b) - e.deriv(e.u[b], m, b)
+			f[a] += float64(e.C12[I]+e.C44[I]) * d * e.w[a] / float64(n)
+		}
+	}
+	return f
+}
+
+// random heterogeneous medium: two materials and holes (rho == 0), periodic along x only, open elsewhere,
+// with a random displacement.
+func elasticRefMedium() (*data.Mesh, *data.Slice, *data.Slice, elasticRef) {
+	size := [3]int{12, 10, 4}
+	mesh := data.NewMesh(size[X], size[Y], size[Z], 2e-9, 3e-9, 1e-9, 1, 0, 0)
+	N := mesh.NCell()
+	rng := rand.New(rand.NewSource(0))
+
+	uh := data.NewSlice(3, size)
+	params := data.NewSlice(4, size) // C11, C12, C44, rho
+	u, p := uh.Host(), params.Host()
+	for i := 0; i < N; i++ {
+		for c := 0; c < 3; c++ {
+			u[c][i] = float32(1e-10 * (rng.Float64() - 0.5))
+		}
+		p[0][i], p[1][i], p[2][i], p[3][i] = testC11, testC12, testC44, testRho
+		if rng.Float64() < 0.3 {
+			p[0][i], p[1][i], p[2][i], p[3][i] = 1.1e11, 0.6e11, 0.3e11, 2.3e3
+		}
+		if rng.Float64() < 0.1 {
+			p[3][i] = 0
+		}
+	}
+	c := mesh.CellSize()
+	ref := elasticRef{u: [3][]float32{u[X], u[Y], u[Z]}, C11: p[0], C12: p[1], C44: p[2], ρ: p[3],
+		size: size, pbc: [3]bool{true, false, false}, w: [3]float64{1 / c[X], 1 / c[Y], 1 / c[Z]}}
+	return mesh, uh, params, ref
+}
+
+// The GPU elastic force should agree with the host reference on a heterogeneous medium with free surfaces:
+// harmonic mean of the stiffness between neighbours, holes and edges of the grid.
+func TestElasticForce(t *testing.T) {
+	mesh, uh, params, ref := elasticRefMedium()
+	size := mesh.Size()
+
+	uGPU := GPUCopy(uh)
+	defer uGPU.Free()
+	pGPU := GPUCopy(params)
+	defer pGPU.Free()
+	fGPU := NewSlice(3, size)
+	defer fGPU.Free()
+	param := func(c int) MSlice { return ToMSlice(pGPU.Comp(c)) }
+
+	SetElasticForce(fGPU, uGPU, param(0), param(1), param(2), param(3), mesh)
+	f := fGPU.HostCopy().Host()
+
+	c := mesh.CellSize()
+	tol := 1e-5 * testC11 * 1e-10 / (c[Z] * c[Z]) // float32 round-off on the largest terms
+	for iz := 0; iz < size[Z]; iz++ {
+		for iy := 0; iy < size[Y]; iy++ {
+			for ix := 0; ix < size[X]; ix++ {
+				cell := [3]int{ix, iy, iz}
+				want := ref.force(cell)
+				i := ref.index(cell)
+				for comp := 0; comp < 3; comp++ {
+					if math.Abs(float64(f[comp][i])-want[comp]) > tol {
+						t.Fatal("cell", cell, "comp", comp, "got:", f[comp][i], "want:", want[comp])
+					}
+				}
+			}
+		}
+	}
+}
+
+// The GPU strain should agree with the host reference derivatives, one-sided at surfaces.
+func TestElasticStrain(t *testing.T) {
+	mesh, uh, params, ref := elasticRefMedium()
+	size := mesh.Size()
+
+	uGPU := GPUCopy(uh)
+	defer uGPU.Free()
+	pGPU := GPUCopy(params)
+	defer pGPU.Free()
+	eGPU := NewSlice(6, size)
+	defer eGPU.Free()
+
+	SetElasticStrain(eGPU, uGPU, ToMSlice(pGPU.Comp(3)), mesh)
+	e := eGPU.HostCopy().Host()
+
+	c := mesh.CellSize()
+	tol := 1e-5 * 1e-10 / c[Z]
+	for iz := 0; iz < size[Z]; iz++ {
+		for iy := 0; iy < size[Y]; iy++ {
+			for ix := 0; ix < size[X]; ix++ {
+				cell := [3]int{ix, iy, iz}
+				i := ref.index(cell)
+				var want [6]float64
+				if ref.ρ[i] != 0 {
+					var D [3][3]float64 // D[a][b] = ∂_a u_b
+					for a := 0; a < 3; a++ {
+						for b := 0; b < 3; b++ {
+							D[a][b] = ref.deriv(ref.u[b], cell, a)
+						}
+					}
+					want = [6]float64{D[X][X], D[Y][Y], D[Z][Z],
+						0.5 * (D[X][Y] + D[Y][X]), 0.5 * (D[X][Z] + D[Z][X]), 0.5 * (D[Y][Z] + D[Z][Y])}
+				}
+				for comp := 0; comp < 6; comp++ {
+					if math.Abs(float64(e[comp][i])-want[comp]) > tol {
+						t.Fatal("cell", cell, "comp", comp, "got:", e[comp][i], "want:", want[comp])
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+#include <stdint.h>
+#include "amul.h"
+#include "stencil.h"
+#include "elastic.h"
+
+// Elastic force density f = ∇·σ, with σ the stress of the displacement u
+// in a medium with cubic stiffness constants C11, C12, C44:
+// 	f_a = ∂_a (C11 ∂_a u_a) + Σ_{b≠a} [∂_b (C44 ∂_b u_a) + (C12 + C44) ∂_a ∂_b u_b]
+// Second derivatives use the harmonic mean of the stiffness between neighbours,
+// mixed derivatives use the local stiffness.
+// Cells outside the medium (rho == 0) have zero force.
+// See elastodynamics.go
+extern "C" __global__ void
+elasticforce(float* __restrict__ fx, float* __restrict__ fy, float* __restrict__ fz,
+             float* __restrict__ ux, float* __restrict__ uy, float* __restrict__ uz,
+             float* __restrict__ C11_, float C11_mul,
+             float* __restrict__ C12_, float C12_mul,
+             float* __restrict__ C44_, float C44_mul,
+             float* __restrict__ rho_, float rho_mul,
+             float wx, float wy, float wz, int Nx, int Ny, int Nz, uint8_t PBC) {
+
+    int ix = blockIdx.x * blockDim.x + threadIdx.x;
+    int iy = blockIdx.y * blockDim.y + threadIdx.y;
+    int iz = blockIdx.z * blockDim.z + threadIdx.z;
+
+    if (ix >= Nx || iy >= Ny || iz >= Nz) {
+        return;
+    }
+
+    int I = idx(ix, iy, iz);
+    if (amul(rho_, rho_mul, I) == 0.0f) {
+        fx[I] = 0.0f;
+        fy[I] = 0.0f;
+        fz[I] = 0.0f;
+        return;
+    }
+
+    float* u[3] = {ux, uy, uz};
+    float w[3] = {wx, wy, wz};
+    int3 c = make_int3(ix, iy, iz);
+    float C11 = amul(C11_, C11_mul, I);
+    float C12 = amul(C12_, C12_mul, I);
+    float C44 = amul(C44_, C44_mul, I);
+    float f[3] = {0.0f, 0.0f, 0.0f};
+
+    for (int a = 0; a < 3; a++) {
+        // ∂_d (K ∂_d u_a), K = C11 for d == a, C44 otherwise
+        for (int d = 0; d < 3; d++) {
+            float K0 = (a == d)? C11: C44;
+            for (int s = -1; s <= 1; s += 2) {
+                int3 n = elnbr(c, d, s, rho_, rho_mul, Nx, Ny, Nz, PBC);
+                int J = idx(n.x, n.y, n.z);
+                float KJ = (a == d)? amul(C11_, C11_mul, J): amul(C44_, C44_mul, J);
+                float K = (K0 + KJ == 0.0f)? 0.0f: 2.0f * K0 * KJ / (K0 + KJ);
+                f[a] += w[d] * w[d] * K * (u[a][J] - u[a][I]);
+            }
+        }
+
+        // (C12 + C44) ∂_a ∂_b u_b
+        for (int b = 0; b < 3; b++) {
+            if (b == a) {
+                continue;
+            }
+            int3 p = elnbr(c, a, +1, rho_, rho_mul, Nx, Ny, Nz, PBC);
+            int3 m = elnbr(c, a, -1, rho_, rho_mul, Nx, Ny, Nz, PBC);
+            int n = (p.x != c.x || p.y != c.y || p.z != c.z) + (m.x != c.x || m.y != c.y || m.z != c.z);
+            if (n == 0) {
+                continue;
+            }
+            float dp = elderiv(u[b], p, b, w[b], rho_, rho_mul, Nx, Ny, Nz, PBC);
+            float dm = elderiv(u[b], m, b, w[b], rho_, rho_mul, Nx, Ny, Nz, PBC);
+            f[a] += (C12 + C44) * (dp - dm) * w[a] / n;
+        }
+    }
+
+    fx[I] = f[0];
+    fy[I] = f[1];
+    fz[I] = f[2];
+}
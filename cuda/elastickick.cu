@@ -0,0 +1,32 @@
+#include "amul.h"
+
+// Velocity update of the elastic lattice over dt:
+// 	v += dt (f - eta v) / rho
+// with f the total force density. v is set to zero outside the medium (rho == 0).
+// See elastodynamics.go
+extern "C" __global__ void
+elastickick(float* __restrict__ vx, float* __restrict__ vy, float* __restrict__ vz,
+            float* __restrict__ fx, float* __restrict__ fy, float* __restrict__ fz,
+            float* __restrict__ rho_, float rho_mul,
+            float* __restrict__ eta_, float eta_mul,
+            float dt, int N) {
+
+    int i =  ( blockIdx.y*gridDim.x + blockIdx.x ) * blockDim.x + threadIdx.x;
+    if (i >= N) {
+        return;
+    }
+
+    float rho = amul(rho_, rho_mul, i);
+    if (rho == 0.0f) {
+        vx[i] = 0.0f;
+        vy[i] = 0.0f;
+        vz[i] = 0.0f;
+        return;
+    }
+    float eta = amul(eta_, eta_mul, i);
+    float r = dt / rho;
+
+    vx[i] += r * (fx[i] - eta * vx[i]);
+    vy[i] += r * (fy[i] - eta * vy[i]);
+    vz[i] += r * (fz[i] - eta * vz[i]);
+}
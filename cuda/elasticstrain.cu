@@ -0,0 +1,53 @@
+#include <stdint.h>
+#include "amul.h"
+#include "stencil.h"
+#include "elastic.h"
+
+// Strain ε_ab = ½ (∂_a u_b + ∂_b u_a) of the displacement u,
+// stored as xx, yy, zz, xy, xz, yz. Zero outside the medium (rho == 0).
+// See elastodynamics.go
+extern "C" __global__ void
+elasticstrain(float* __restrict__ exx, float* __restrict__ eyy, float* __restrict__ ezz,
+              float* __restrict__ exy, float* __restrict__ exz, float* __restrict__ eyz,
+              float* __restrict__ ux, float* __restrict__ uy, float* __restrict__ uz,
+              float* __restrict__ rho_, float rho_mul,
+              float wx, float wy, float wz, int Nx, int Ny, int Nz, uint8_t PBC) {
+
+    int ix = blockIdx.x * blockDim.x + threadIdx.x;
+    int iy = blockIdx.y * blockDim.y + threadIdx.y;
+    int iz = blockIdx.z * blockDim.z + threadIdx.z;
+
+    if (ix >= Nx || iy >= Ny || iz >= Nz) {
+        return;
+    }
+
+    int I = idx(ix, iy, iz);
+    if (amul(rho_, rho_mul, I) == 0.0f) {
+        exx[I] = 0.0f;
+        eyy[I] = 0.0f;
+        ezz[I] = 0.0f;
+        exy[I] = 0.0f;
+        exz[I] = 0.0f;
+        eyz[I] = 0.0f;
+        return;
+    }
+
+    int3 c = make_int3(ix, iy, iz);
+
+    // D[a][b] = ∂_a u_b
+    float* u[3] = {ux, uy, uz};
+    float w[3] = {wx, wy, wz};
+    float D[3][3];
+    for (int a = 0; a < 3; a++) {
+        for (int b = 0; b < 3; b++) {
+            D[a][b] = elderiv(u[b], c, a, w[a], rho_, rho_mul, Nx, Ny, Nz, PBC);
+        }
+    }
+
+    exx[I] = D[0][0];
+    eyy[I] = D[1][1];
+    ezz[I] = D[2][2];
+    exy[I] = 0.5f * (D[0][1] + D[1][0]);
+    exz[I] = 0.5f * (D[0][2] + D[2][0]);
+    eyz[I] = 0.5f * (D[1][2] + D[2][1]);
+}
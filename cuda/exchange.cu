@@ -10,7 +10,7 @@ addexchange(float* __restrict__ Bx, float* __restrict__ By, float* __restrict__
             float* __restrict__ mx, float* __restrict__ my, float* __restrict__ mz,
             float* __restrict__ Ms_, float Ms_mul,
             float* __restrict__ aLUT2d, uint8_t* __restrict__ regions,
-            float wx, float wy, float wz, int Nx, int Ny, int Nz, uint8_t PBC) {
+            float wx, float wy, float wz, float* __restrict__ dz, int Nx, int Ny, int Nz, uint8_t PBC) {
 
     int ix = blockIdx.x * blockDim.x + threadIdx.x;
     int iy = blockIdx.y * blockDim.y + threadIdx.y;
@@ -65,19 +65,25 @@ addexchange(float* __restrict__ Bx, float* __restrict__ By, float* __restrict__
 
     // only take vertical derivative for 3D sim
     if (Nz != 1) {
+        float wzl = wz, wzh = wz; // weights of the bottom and top neighbor
+        if (dz != NULL) {         // non-uniform layers
+            wzl = 2.0f / (dz[iz] * layerdist(dz, iz, lclampz(iz-1)));
+            wzh = 2.0f / (dz[iz] * layerdist(dz, iz, hclampz(iz+1)));
+        }
+
         // bottom neighbor
         i_  = idx(ix, iy, lclampz(iz-1));
         m_  = make_float3(mx[i_], my[i_], mz[i_]);
         m_  = ( is0(m_)? m0: m_ );
         a__ = aLUT2d[symidx(r0, regions[i_])];
-        B += wz * a__ *(m_ - m0);
+        B += wzl * a__ *(m_ - m0);
 
         // top neighbor
         i_  = idx(ix, iy, hclampz(iz+1));
         m_  = make_float3(mx[i_], my[i_], mz[i_]);
         m_  = ( is0(m_)? m0: m_ );
         a__ = aLUT2d[symidx(r0, regions[i_])];
-        B += wz * a__ *(m_ - m0);
+        B += wzh * a__ *(m_ - m0);
     }
 
     float invMs = inv_Msat(Ms_, Ms_mul, I);
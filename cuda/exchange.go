@@ -10,8 +10,9 @@ import (
 // 	m: normalized magnetization
 // 	B: effective field in Tesla
 // 	Aex_red: Aex / (Msat * 1e18 m2)
+// 	dz: layer thicknesses of a non-uniform grid along z, may be nil
 // see exchange.cu
-func AddExchange(B, m *data.Slice, Aex_red SymmLUT, Msat MSlice, regions *Bytes, mesh *data.Mesh) {
+func AddExchange(B, m *data.Slice, Aex_red InterLUT, Msat MSlice, regions RegionMap, mesh *data.Mesh, dz *data.Slice) {
 	c := mesh.CellSize()
 	wx := float32(2 / (c[X] * c[X]))
 	wy := float32(2 / (c[Y] * c[Y]))
@@ -19,15 +20,23 @@ func AddExchange(B, m *data.Slice, Aex_red SymmLUT, Msat MSlice, regions *Bytes,
 	N := mesh.Size()
 	pbc := mesh.PBC_code()
 	cfg := make3DConf(N)
-	k_addexchange_async(B.DevPtr(X), B.DevPtr(Y), B.DevPtr(Z),
-		m.DevPtr(X), m.DevPtr(Y), m.DevPtr(Z),
-		Msat.DevPtr(0), Msat.Mul(0),
-		unsafe.Pointer(Aex_red), regions.Ptr,
-		wx, wy, wz, N[X], N[Y], N[Z], pbc, cfg)
+	if r, wide := regions.regionPtr(); wide {
+		k_addexchange16_async(B.DevPtr(X), B.DevPtr(Y), B.DevPtr(Z),
+			m.DevPtr(X), m.DevPtr(Y), m.DevPtr(Z),
+			Msat.DevPtr(0), Msat.Mul(0),
+			unsafe.Pointer(Aex_red.Value), Aex_red.Keys, Aex_red.Scale, Aex_red.Inter, Aex_red.NPair, r,
+			wx, wy, wz, dz.DevPtr(0), N[X], N[Y], N[Z], pbc, cfg)
+	} else {
+		k_addexchange_async(B.DevPtr(X), B.DevPtr(Y), B.DevPtr(Z),
+			m.DevPtr(X), m.DevPtr(Y), m.DevPtr(Z),
+			Msat.DevPtr(0), Msat.Mul(0),
+			unsafe.Pointer(Aex_red.Symm), r,
+			wx, wy, wz, dz.DevPtr(0), N[X], N[Y], N[Z], pbc, cfg)
+	}
 }
 
 // Finds the average exchange strength around each cell, for debugging.
-func ExchangeDecode(dst *data.Slice, Aex_red SymmLUT, regions *Bytes, mesh *data.Mesh) {
+func ExchangeDecode(dst *data.Slice, Aex_red InterLUT, regions RegionMap, mesh *data.Mesh) {
 	c := mesh.CellSize()
 	wx := float32(2 / (c[X] * c[X]))
 	wy := float32(2 / (c[Y] * c[Y]))
@@ -35,5 +44,10 @@ func ExchangeDecode(dst *data.Slice, Aex_red SymmLUT, regions *Bytes, mesh *data
 	N := mesh.Size()
 	pbc := mesh.PBC_code()
 	cfg := make3DConf(N)
-	k_exchangedecode_async(dst.DevPtr(0), unsafe.Pointer(Aex_red), regions.Ptr, wx, wy, wz, N[X], N[Y], N[Z], pbc, cfg)
+	if r, wide := regions.regionPtr(); wide {
+		k_exchangedecode16_async(dst.DevPtr(0), unsafe.Pointer(Aex_red.Value), Aex_red.Keys, Aex_red.Scale, Aex_red.Inter, Aex_red.NPair,
+			r, wx, wy, wz, N[X], N[Y], N[Z], pbc, cfg)
+	} else {
+		k_exchangedecode_async(dst.DevPtr(0), unsafe.Pointer(Aex_red.Symm), r, wx, wy, wz, N[X], N[Y], N[Z], pbc, cfg)
+	}
 }
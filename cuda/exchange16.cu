@@ -0,0 +1,96 @@
+#include <stdint.h>
+#include "exchange16.h"
+#include "float3.h"
+#include "stencil.h"
+#include "amul.h"
+
+// See exchange.go for more details.
+// Same as exchange.cu, for 16-bit regions with sparse inter-region coupling (see exchange16.h).
+extern "C" __global__ void
+addexchange16(float* __restrict__ Bx, float* __restrict__ By, float* __restrict__ Bz,
+              float* __restrict__ mx, float* __restrict__ my, float* __restrict__ mz,
+              float* __restrict__ Ms_, float Ms_mul,
+              float* __restrict__ aLUT, uint32_t* __restrict__ aKeys, float* __restrict__ aScale, float* __restrict__ aInter, int aN,
+              uint16_t* __restrict__ regions,
+              float wx, float wy, float wz, float* __restrict__ dz, int Nx, int Ny, int Nz, uint8_t PBC) {
+
+    int ix = blockIdx.x * blockDim.x + threadIdx.x;
+    int iy = blockIdx.y * blockDim.y + threadIdx.y;
+    int iz = blockIdx.z * blockDim.z + threadIdx.z;
+
+    if (ix >= Nx || iy >= Ny || iz >= Nz) {
+        return;
+    }
+
+    // central cell
+    int I = idx(ix, iy, iz);
+    float3 m0 = make_float3(mx[I], my[I], mz[I]);
+
+    if (is0(m0)) {
+        return;
+    }
+
+    uint16_t r0 = regions[I];
+    float3 B  = make_float3(0.0,0.0,0.0);
+
+    int i_;    // neighbor index
+    float3 m_; // neighbor mag
+    float a__; // inter-cell exchange stiffness
+
+    // left neighbor
+    i_  = idx(lclampx(ix-1), iy, iz);           // clamps or wraps index according to PBC
+    m_  = make_float3(mx[i_], my[i_], mz[i_]);  // load m
+    m_  = ( is0(m_)? m0: m_ );                  // replace missing non-boundary neighbor
+    a__ = AEX16(r0, regions[i_]);
+    B += wx * a__ *(m_ - m0);
+
+    // right neighbor
+    i_  = idx(hclampx(ix+1), iy, iz);
+    m_  = make_float3(mx[i_], my[i_], mz[i_]);
+    m_  = ( is0(m_)? m0: m_ );
+    a__ = AEX16(r0, regions[i_]);
+    B += wx * a__ *(m_ - m0);
+
+    // back neighbor
+    i_  = idx(ix, lclampy(iy-1), iz);
+    m_  = make_float3(mx[i_], my[i_], mz[i_]);
+    m_  = ( is0(m_)? m0: m_ );
+    a__ = AEX16(r0, regions[i_]);
+    B += wy * a__ *(m_ - m0);
+
+    // front neighbor
+    i_  = idx(ix, hclampy(iy+1), iz);
+    m_  = make_float3(mx[i_], my[i_], mz[i_]);
+    m_  = ( is0(m_)? m0: m_ );
+    a__ = AEX16(r0, regions[i_]);
+    B += wy * a__ *(m_ - m0);
+
+    // only take vertical derivative for 3D sim
+    if (Nz != 1) {
+        float wzl = wz, wzh = wz; // weights of the bottom and top neighbor
+        if (dz != NULL) {         // non-uniform layers
+            wzl = 2.0f / (dz[iz] * layerdist(dz, iz, lclampz(iz-1)));
+            wzh = 2.0f / (dz[iz] * layerdist(dz, iz, hclampz(iz+1)));
+        }
+
+        // bottom neighbor
+        i_  = idx(ix, iy, lclampz(iz-1));
+        m_  = make_float3(mx[i_], my[i_], mz[i_]);
+        m_  = ( is0(m_)? m0: m_ );
+        a__ = AEX16(r0, regions[i_]);
+        B += wzl * a__ *(m_ - m0);
+
+        // top neighbor
+        i_  = idx(ix, iy, hclampz(iz+1));
+        m_  = make_float3(mx[i_], my[i_], mz[i_]);
+        m_  = ( is0(m_)? m0: m_ );
+        a__ = AEX16(r0, regions[i_]);
+        B += wzh * a__ *(m_ - m0);
+    }
+
+    float invMs = inv_Msat(Ms_, Ms_mul, I);
+    Bx[I] += B.x*invMs;
+    By[I] += B.y*invMs;
+    Bz[I] += B.z*invMs;
+}
+
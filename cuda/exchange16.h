@@ -0,0 +1,57 @@
+#ifndef _EXCHANGE16_H_
+#define _EXCHANGE16_H_
+
+#include <stdint.h>
+
+// Inter-region coupling for 16-bit regions, where a full symmetric matrix
+// (see exchange.h) would be too large. See InterLUT in lut.go.
+//
+// The coupling between regions r1 and r2 is the average of the per-region
+// values in lut, unless (r1, r2) is one of the npair explicitly set pairs:
+// 	keys:  sorted pair keys (min(r1,r2) << 16 | max(r1,r2))
+// 	scale: factor for the average of the pair
+// 	inter: extra term for the pair
+
+// Average of two exchange/DMI strengths.
+// (!) Code duplicated in engine/exchange.go: exchAverage
+inline __device__ float exchAverage(float a, float b) {
+    if (a*b >= 0.0f) {
+        return 2.0f / (1.0f/a + 1.0f/b);
+    } else {
+        float sign = copysignf(1.0f, a+b);
+        return sign * sqrtf(sqrtf(-a*b) * fabsf(a+b) * 0.5f);
+    }
+}
+
+inline __device__ float coupling16(float* __restrict__ lut, uint32_t* __restrict__ keys,
+                                   float* __restrict__ scale, float* __restrict__ inter, int npair,
+                                   int r1, int r2) {
+    float a = exchAverage(lut[r1], lut[r2]);
+    if (npair == 0) {
+        return a;
+    }
+
+    uint32_t key = (r1 <= r2)? ((r1 << 16) | r2): ((r2 << 16) | r1);
+    int lo = 0;
+    int hi = npair-1;
+    while (lo <= hi) {  // binary search
+        int mid = (lo + hi) / 2;
+        uint32_t k = keys[mid];
+        if (k == key) {
+            return scale[mid]*a + inter[mid];
+        }
+        if (k < key) {
+            lo = mid + 1;
+        } else {
+            hi = mid - 1;
+        }
+    }
+    return a;
+}
+
+// shorthands for the coupling tables passed as
+// aLUT, aKeys, aScale, aInter, aN and dLUT, dKeys, dScale, dInter, dN.
+#define AEX16(r1, r2) coupling16(aLUT, aKeys, aScale, aInter, aN, r1, r2)
+#define DEX16(r1, r2) coupling16(dLUT, dKeys, dScale, dInter, dN, r1, r2)
+
+#endif
@@ -0,0 +1,77 @@
+package cuda
+
+import (
+	"math"
+
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/util"
+)
+
+// Returns Σ F·A over all cells, with A the vector potential of the
+// (divergence-free) vector field F: ∇×A = F, in the Coulomb gauge ∇·A = 0.
+// In Fourier space:
+//
+//	A(k) = i k × F(k) / k²
+//
+// F is treated as periodic, it should be zero-padded along open boundaries.
+// The k=0 component is dropped. Used for the Hopf index.
+func SumFDotCurlInverse(F *data.Slice, cellsize [3]float64) float64 {
+	util.Argument(F.NComp() == 3)
+	size := F.Size()
+	plan := newFFT3DR2C(size[X], size[Y], size[Z])
+	defer plan.Free()
+	out := NewSlice(1, fftR2COutputSizeFloats(size))
+	defer out.Free()
+
+	var Fk [3][]float32
+	for c := 0; c < 3; c++ {
+		plan.ExecAsync(F.Comp(c), out)
+		Fk[c] = out.HostCopy().Host()[0]
+	}
+
+	nx := size[X]/2 + 1
+	var k [3]float64
+	sum := 0.
+	for iz := 0; iz < size[Z]; iz++ {
+		k[Z] = wavenumber(iz, size[Z], cellsize[Z])
+		for iy := 0; iy < size[Y]; iy++ {
+			k[Y] = wavenumber(iy, size[Y], cellsize[Y])
+			for ix := 0; ix < nx; ix++ {
+				k[X] = wavenumber(ix, size[X], cellsize[X])
+				k2 := k[X]*k[X] + k[Y]*k[Y] + k[Z]*k[Z]
+				if k2 == 0 {
+					continue
+				}
+				i := 2 * ((iz*size[Y]+iy)*nx + ix)
+				var f [3]complex128
+				for c := range f {
+					f[c] = complex(float64(Fk[c][i]), float64(Fk[c][i+1]))
+				}
+				// F*·A = F*·(i k×F)/k²
+				kxF := [3]complex128{
+					complex(k[Y], 0)*f[Z] - complex(k[Z], 0)*f[Y],
+					complex(k[Z], 0)*f[X] - complex(k[X], 0)*f[Z],
+					complex(k[X], 0)*f[Y] - complex(k[Y], 0)*f[X]}
+				var FA complex128
+				for c := range f {
+					FA += complex(real(f[c]), -imag(f[c])) * 1i * kxF[c]
+				}
+				// the other half of k-space holds the complex conjugates
+				w := 2.
+				if ix == 0 || 2*ix == size[X] {
+					w = 1
+				}
+				sum += w * real(FA) / k2
+			}
+		}
+	}
+	return sum / float64(prod(size)) // Parseval
+}
+
+// wave number of FFT index i out of n, for cell size c.
+func wavenumber(i, n int, c float64) float64 {
+	if 2*i > n {
+		i -= n
+	}
+	return 2 * math.Pi * float64(i) / (float64(n) * c)
+}
@@ -0,0 +1,47 @@
+#include "float3.h"
+#include "amul.h"
+
+// Adds the interlayer exchange field to the pairs of cells I1 = idx1[i], I2 = idx2[i] (none if < 0),
+// with surface energy density σ = -J1 m1·m2 - J2 (m1·m2)²:
+// 	B1 = (J1 + 2 J2 m1·m2) m2 / (Ms1 dz1), and vice versa.
+// dz1: thickness of the layer of cell I1, dz[iz] for non-uniform layers, else 1/invdz.
+// See interlayerexchange.go for more details.
+extern "C" __global__ void
+addinterlayerexchange(float* __restrict__ Bx, float* __restrict__ By, float* __restrict__ Bz,
+                      float* __restrict__ mx, float* __restrict__ my, float* __restrict__ mz,
+                      float* __restrict__ Ms_, float Ms_mul,
+                      int* __restrict__ idx1, int* __restrict__ idx2,
+                      float J1, float J2, float invdz, float* __restrict__ dz,
+                      int Nx, int Ny, int N) {
+
+    int i =  ( blockIdx.y*gridDim.x + blockIdx.x ) * blockDim.x + threadIdx.x;
+    if (i >= N) {
+        return;
+    }
+
+    int I1 = idx1[i];
+    int I2 = idx2[i];
+    if (I1 < 0 || I2 < 0) {
+        return;
+    }
+
+    float3 m1 = make_float3(mx[I1], my[I1], mz[I1]);
+    float3 m2 = make_float3(mx[I2], my[I2], mz[I2]);
+    if (is0(m1) || is0(m2)) {
+        return;
+    }
+
+    float J = J1 + 2.0f * J2 * dot(m1, m2);
+    float invdz1 = (dz == NULL)? invdz: 1.0f / dz[I1 / (Nx*Ny)];
+    float invdz2 = (dz == NULL)? invdz: 1.0f / dz[I2 / (Nx*Ny)];
+
+    float j1 = J * invdz1 * inv_Msat(Ms_, Ms_mul, I1);
+    Bx[I1] += j1 * m2.x;
+    By[I1] += j1 * m2.y;
+    Bz[I1] += j1 * m2.z;
+
+    float j2 = J * invdz2 * inv_Msat(Ms_, Ms_mul, I2);
+    Bx[I2] += j2 * m1.x;
+    By[I2] += j2 * m1.y;
+    Bz[I2] += j2 * m1.z;
+}
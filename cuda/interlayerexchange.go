@@ -0,0 +1,34 @@
+package cuda
+
+import (
+	"unsafe"
+
+	"github.com/mumax/3/data"
+)
+
+// Add the interlayer exchange field to B, for N pairs of cells with linear indices
+// idx1[i], idx2[i] (int32 on the GPU, no coupling if negative), coupled with surface energy density
+//
+//	σ = -J1 m1·m2 - J2 (m1·m2)²
+//	dz: thickness of the coupled cells
+//	layers: thickness of each layer if non-uniform (overrides dz), else nil
+//
+// see interlayerexchange.cu
+func AddInterlayerExchange(B, m *data.Slice, Msat MSlice, idx1, idx2 unsafe.Pointer, N int, J1, J2, dz float64, layers *data.Slice) {
+	cfg := make1DConf(N)
+	size := m.Size()
+	k_addinterlayerexchange_async(B.DevPtr(X), B.DevPtr(Y), B.DevPtr(Z),
+		m.DevPtr(X), m.DevPtr(Y), m.DevPtr(Z),
+		Msat.DevPtr(0), Msat.Mul(0),
+		idx1, idx2, float32(J1), float32(J2), float32(1/dz), layers.DevPtr(0),
+		size[X], size[Y], N, cfg)
+}
+
+// Add the interlayer exchange energy density to edens, see AddInterlayerExchange.
+// see interlayerexchangeenergy.cu
+func AddInterlayerExchangeEnergyDensity(edens, m *data.Slice, idx1, idx2 unsafe.Pointer, N int, J1, J2, dz float64) {
+	cfg := make1DConf(N)
+	k_addinterlayerexchangeenergy_async(edens.DevPtr(0),
+		m.DevPtr(X), m.DevPtr(Y), m.DevPtr(Z),
+		idx1, idx2, float32(J1), float32(J2), float32(1/dz), N, cfg)
+}
@@ -0,0 +1,33 @@
+#include "float3.h"
+
+// Adds the interlayer exchange energy density σ/(2 dz) to both cells of the pairs
+// I1 = idx1[i], I2 = idx2[i] (none if < 0), with σ = -J1 m1·m2 - J2 (m1·m2)².
+// See interlayerexchange.go for more details.
+extern "C" __global__ void
+addinterlayerexchangeenergy(float* __restrict__ edens,
+                            float* __restrict__ mx, float* __restrict__ my, float* __restrict__ mz,
+                            int* __restrict__ idx1, int* __restrict__ idx2,
+                            float J1, float J2, float invdz, int N) {
+
+    int i =  ( blockIdx.y*gridDim.x + blockIdx.x ) * blockDim.x + threadIdx.x;
+    if (i >= N) {
+        return;
+    }
+
+    int I1 = idx1[i];
+    int I2 = idx2[i];
+    if (I1 < 0 || I2 < 0) {
+        return;
+    }
+
+    float3 m1 = make_float3(mx[I1], my[I1], mz[I1]);
+    float3 m2 = make_float3(mx[I2], my[I2], mz[I2]);
+    if (is0(m1) || is0(m2)) {
+        return;
+    }
+
+    float c = dot(m1, m2);
+    float e = -0.5f * (J1 * c + J2 * c * c) * invdz;
+    edens[I1] += e;
+    edens[I2] += e;
+}
@@ -0,0 +1,21 @@
+package cuda
+
+import (
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/util"
+)
+
+// Adds to dst the trilinear interpolation of src (of the same number of components)
+// at the points (ix, iy, iz) * step, in units of src cells.
+// With unit step and equal size, src is added to dst.
+// see interpolateadd.cu
+func InterpolateAdd(dst, src *data.Slice, step [3]float64) {
+	util.Argument(dst.NComp() == src.NComp())
+	D, S := dst.Size(), src.Size()
+	cfg := make3DConf(D)
+	for c := 0; c < dst.NComp(); c++ {
+		k_interpolateadd_async(dst.DevPtr(c), D[X], D[Y], D[Z],
+			src.DevPtr(c), S[X], S[Y], S[Z],
+			float32(step[X]), float32(step[Y]), float32(step[Z]), cfg)
+	}
+}
@@ -0,0 +1,32 @@
+// Adds to dst the trilinear interpolation of src at the points
+// (ix*stepx, iy*stepy, iz*stepz), in units of src cells.
+// Points beyond the last src cell are clamped to it.
+extern "C" __global__ void
+interpolateadd(float* __restrict__  dst, int Dx, int Dy, int Dz,
+               float* __restrict__  src, int Sx, int Sy, int Sz,
+               float stepx, float stepy, float stepz) {
+
+    int ix = blockIdx.x * blockDim.x + threadIdx.x;
+    int iy = blockIdx.y * blockDim.y + threadIdx.y;
+    int iz = blockIdx.z * blockDim.z + threadIdx.z;
+
+    if (ix>=Dx || iy>=Dy || iz>=Dz) {
+        return;
+    }
+
+    float x = fminf(ix*stepx, (float)(Sx-1));
+    float y = fminf(iy*stepy, (float)(Sy-1));
+    float z = fminf(iz*stepz, (float)(Sz-1));
+    int x0 = (int)x, y0 = (int)y, z0 = (int)z;
+    int x1 = min(x0+1, Sx-1), y1 = min(y0+1, Sy-1), z1 = min(z0+1, Sz-1);
+    float tx = x - x0, ty = y - y0, tz = z - z0;
+
+    #define S(i, j, k) src[((k)*Sy + (j))*Sx + (i)]
+    float v = (1-tz) * ((1-ty) * ((1-tx)*S(x0, y0, z0) + tx*S(x1, y0, z0)) +
+                        (  ty) * ((1-tx)*S(x0, y1, z0) + tx*S(x1, y1, z0))) +
+              (  tz) * ((1-ty) * ((1-tx)*S(x0, y0, z1) + tx*S(x1, y0, z1)) +
+                        (  ty) * ((1-tx)*S(x0, y1, z1) + tx*S(x1, y1, z1)));
+    #undef S
+
+    dst[(iz*Dy + iy)*Dx + ix] += v;
+}
@@ -0,0 +1,43 @@
+// Kernel multiplication for the Oersted field of a current density J:
+//
+// B = J x G
+//
+// in Fourier space. The Biot-Savart kernel G is real and odd,
+// so FFT(G) is purely imaginary and only its imaginary parts are stored.
+// Launch config ranges over all complex elements of FFT(J).
+extern "C" __global__ void
+kernmulcross(float* __restrict__  fftJx,  float* __restrict__  fftJy,  float* __restrict__  fftJz,
+             float* __restrict__  fftGx,  float* __restrict__  fftGy,  float* __restrict__  fftGz,
+             int Nx, int Ny, int Nz) {
+
+    int ix = blockIdx.x * blockDim.x + threadIdx.x;
+    int iy = blockIdx.y * blockDim.y + threadIdx.y;
+    int iz = blockIdx.z * blockDim.z + threadIdx.z;
+
+    if(ix>= Nx || iy>= Ny || iz>=Nz) {
+        return;
+    }
+
+    int I = (iz*Ny + iy)*Nx + ix;
+    int e = 2 * I;
+
+    float reJx = fftJx[e  ];
+    float imJx = fftJx[e+1];
+    float reJy = fftJy[e  ];
+    float imJy = fftJy[e+1];
+    float reJz = fftJz[e  ];
+    float imJz = fftJz[e+1];
+
+    float Gx = fftGx[I];
+    float Gy = fftGy[I];
+    float Gz = fftGz[I];
+
+    // J x (i G) = i (J x G): real and imaginary parts swap
+    fftJx[e  ] = -(imJy*Gz - imJz*Gy);
+    fftJx[e+1] =  (reJy*Gz - reJz*Gy);
+    fftJy[e  ] = -(imJz*Gx - imJx*Gz);
+    fftJy[e+1] =  (reJz*Gx - reJx*Gz);
+    fftJz[e  ] = -(imJx*Gy - imJy*Gx);
+    fftJz[e+1] =  (reJx*Gy - reJy*Gx);
+}
+
@@ -0,0 +1,52 @@
+// Kernel multiplication with a complex, symmetric 3x3 kernel:
+//
+// |Mx|   |Kxx Kxy Kxz|   |Mx|
+// |My| = |Kxy Kyy Kyz| * |My|
+// |Mz|   |Kxz Kyz Kzz|   |Mz|
+//
+// in Fourier space, without assuming any mirror symmetry of the kernel in real space.
+// Used for the stray field between separate meshes (offset kernel).
+// Launch config ranges over all complex elements of FFT(M).
+extern "C" __global__ void
+kernmulCSymm3D(float* __restrict__  fftMx,  float* __restrict__  fftMy,  float* __restrict__  fftMz,
+               float* __restrict__  fftKxx, float* __restrict__  fftKyy, float* __restrict__  fftKzz,
+               float* __restrict__  fftKyz, float* __restrict__  fftKxz, float* __restrict__  fftKxy,
+               int Nx, int Ny, int Nz) {
+
+    int ix = blockIdx.x * blockDim.x + threadIdx.x;
+    int iy = blockIdx.y * blockDim.y + threadIdx.y;
+    int iz = blockIdx.z * blockDim.z + threadIdx.z;
+
+    if(ix>= Nx || iy>= Ny || iz>=Nz) {
+        return;
+    }
+
+    int e = 2 * ((iz*Ny + iy)*Nx + ix);
+
+    float reMx = fftMx[e  ];
+    float imMx = fftMx[e+1];
+    float reMy = fftMy[e  ];
+    float imMy = fftMy[e+1];
+    float reMz = fftMz[e  ];
+    float imMz = fftMz[e+1];
+
+    float reKxx = fftKxx[e  ];
+    float imKxx = fftKxx[e+1];
+    float reKyy = fftKyy[e  ];
+    float imKyy = fftKyy[e+1];
+    float reKzz = fftKzz[e  ];
+    float imKzz = fftKzz[e+1];
+    float reKyz = fftKyz[e  ];
+    float imKyz = fftKyz[e+1];
+    float reKxz = fftKxz[e  ];
+    float imKxz = fftKxz[e+1];
+    float reKxy = fftKxy[e  ];
+    float imKxy = fftKxy[e+1];
+
+    fftMx[e  ] = reMx * reKxx - imMx * imKxx + reMy * reKxy - imMy * imKxy + reMz * reKxz - imMz * imKxz;
+    fftMx[e+1] = reMx * imKxx + imMx * reKxx + reMy * imKxy + imMy * reKxy + reMz * imKxz + imMz * reKxz;
+    fftMy[e  ] = reMx * reKxy - imMx * imKxy + reMy * reKyy - imMy * imKyy + reMz * reKyz - imMz * imKyz;
+    fftMy[e+1] = reMx * imKxy + imMx * reKxy + reMy * imKyy + imMy * reKyy + reMz * imKyz + imMz * reKyz;
+    fftMz[e  ] = reMx * reKxz - imMx * imKxz + reMy * reKyz - imMy * imKyz + reMz * reKzz - imMz * imKzz;
+    fftMz[e+1] = reMx * imKxz + imMx * reKxz + reMy * imKyz + imMy * reKyz + reMz * imKzz + imMz * reKzz;
+}
@@ -0,0 +1,52 @@
+// Accumulating kernel multiplication for the layered demag convolution.
+// The field of one destination layer is the sum over all source layers of
+//
+// |Bx|    |Kxx   Kxy  iKxz|   |Mx|
+// |By| += |Kxy   Kyy  iKyz| * |My|
+// |Bz|    |iKxz iKyz  Kzz |   |Mz|
+//
+// in Fourier space. Each 2D layer-pair kernel is even or odd along x and y,
+// so its FFT is purely real (Kxx, Kyy, Kzz, Kxy) or purely imaginary (Kxz, Kyz)
+// and only those parts are stored. sign = -1 reverses the imaginary elements,
+// to use the kernel of layer pair (i, j) for pair (j, i).
+// Launch config ranges over all complex elements of FFT(M).
+extern "C" __global__ void
+kernmullayer(float* __restrict__  fftBx,  float* __restrict__  fftBy,  float* __restrict__  fftBz,
+             float* __restrict__  fftMx,  float* __restrict__  fftMy,  float* __restrict__  fftMz,
+             float* __restrict__  fftKxx, float* __restrict__  fftKyy, float* __restrict__  fftKzz,
+             float* __restrict__  fftKyz, float* __restrict__  fftKxz, float* __restrict__  fftKxy,
+             float sign, int Nx, int Ny) {
+
+    int ix = blockIdx.x * blockDim.x + threadIdx.x;
+    int iy = blockIdx.y * blockDim.y + threadIdx.y;
+
+    if(ix>= Nx || iy>= Ny) {
+        return;
+    }
+
+    int I = iy*Nx + ix;
+    int e = 2 * I;
+
+    float reMx = fftMx[e  ];
+    float imMx = fftMx[e+1];
+    float reMy = fftMy[e  ];
+    float imMy = fftMy[e+1];
+    float reMz = fftMz[e  ];
+    float imMz = fftMz[e+1];
+
+    float Kxx = fftKxx[I];
+    float Kyy = fftKyy[I];
+    float Kzz = fftKzz[I];
+    float Kxy = fftKxy[I];
+    float Kxz = sign * fftKxz[I];
+    float Kyz = sign * fftKyz[I];
+
+    // i*K*M = K*(-imM, reM)
+    fftBx[e  ] += reMx * Kxx + reMy * Kxy - imMz * Kxz;
+    fftBx[e+1] += imMx * Kxx + imMy * Kxy + reMz * Kxz;
+    fftBy[e  ] += reMx * Kxy + reMy * Kyy - imMz * Kyz;
+    fftBy[e+1] += imMx * Kxy + imMy * Kyy + reMz * Kyz;
+    fftBz[e  ] += -imMx * Kxz - imMy * Kyz + reMz * Kzz;
+    fftBz[e+1] +=  reMx * Kxz + reMy * Kyz + imMz * Kzz;
+}
+
@@ -0,0 +1,27 @@
+package cuda
+
+import (
+	"github.com/mumax/3/data"
+)
+
+// Sets torque to the Landau-Lifshitz-Bloch torque/γ0, for effective field B (excluding thermal noise)
+// and standard normal noise for the transverse and longitudinal thermal terms.
+// kB2_VgammaDt = 2kB/(γ0 V dt). May overwrite B with torque.
+// see llbtorque.cu
+func LLBTorque(torque, m, B, noisePerp, noisePar *data.Slice, λ, Temp, Tc, me, χpar, Msat MSlice, kB2_VgammaDt float64) {
+	N := torque.Len()
+	cfg := make1DConf(N)
+
+	k_llbtorque_async(torque.DevPtr(X), torque.DevPtr(Y), torque.DevPtr(Z),
+		m.DevPtr(X), m.DevPtr(Y), m.DevPtr(Z),
+		B.DevPtr(X), B.DevPtr(Y), B.DevPtr(Z),
+		noisePerp.DevPtr(X), noisePerp.DevPtr(Y), noisePerp.DevPtr(Z),
+		noisePar.DevPtr(X), noisePar.DevPtr(Y), noisePar.DevPtr(Z),
+		λ.DevPtr(0), λ.Mul(0),
+		Temp.DevPtr(0), Temp.Mul(0),
+		Tc.DevPtr(0), Tc.Mul(0),
+		me.DevPtr(0), me.Mul(0),
+		χpar.DevPtr(0), χpar.Mul(0),
+		Msat.DevPtr(0), Msat.Mul(0),
+		float32(kB2_VgammaDt), N, cfg)
+}
@@ -0,0 +1,92 @@
+#include <stdint.h>
+#include "float3.h"
+#include "amul.h"
+
+// Landau-Lifshitz-Bloch torque/γ0 (Tesla), with |m| free
+// (m relative to the zero-temperature saturation magnetization):
+//
+// dm/dt/γ0 = - m x B + α∥/m² (m·B) m - α⊥/m² m x (m x (B + ζ⊥)) + ζ∥
+//
+// where B includes the longitudinal field
+//
+// T < Tc:  B_L =  1/(2χ∥) (1 - m²/m_e²) m
+// T >= Tc: B_L = -1/χ∥ (1 + 3/5 Tc m²/(T-Tc)) m
+//
+// and α∥ = 2λT/(3Tc), α⊥ = λ(1 - T/(3Tc)) (α⊥ = α∥ above Tc).
+// The thermal noise (Evans et al., PRB 85, 014433, 2012) is given as standard normal
+// numbers, scaled here by kB2_VgammaDt = 2kB/(γ0 V dt).
+// See llb.go
+extern "C" __global__ void
+llbtorque(float* __restrict__  tx, float* __restrict__  ty, float* __restrict__  tz,
+          float* __restrict__  mx, float* __restrict__  my, float* __restrict__  mz,
+          float* __restrict__  hx, float* __restrict__  hy, float* __restrict__  hz,
+          float* __restrict__  nperpx, float* __restrict__  nperpy, float* __restrict__  nperpz,
+          float* __restrict__  nparx,  float* __restrict__  npary,  float* __restrict__  nparz,
+          float* __restrict__  lambda_, float lambda_mul,
+          float* __restrict__  temp_,   float temp_mul,
+          float* __restrict__  Tc_,     float Tc_mul,
+          float* __restrict__  me_,     float me_mul,
+          float* __restrict__  chi_,    float chi_mul,
+          float* __restrict__  Ms_,     float Ms_mul,
+          float kB2_VgammaDt, int N) {
+
+    int i =  ( blockIdx.y*gridDim.x + blockIdx.x ) * blockDim.x + threadIdx.x;
+    if (i < N) {
+
+        float3 m = make_float3(mx[i], my[i], mz[i]);
+        float3 H = make_float3(hx[i], hy[i], hz[i]);
+        float m2 = dot(m, m);
+
+        if (m2 == 0.0f) { // outside geometry
+            tx[i] = 0.0f;
+            ty[i] = 0.0f;
+            tz[i] = 0.0f;
+            return;
+        }
+
+        float lambda = amul(lambda_, lambda_mul, i);
+        float T      = amul(temp_, temp_mul, i);
+        float Tc     = amul(Tc_, Tc_mul, i);
+        float me     = amul(me_, me_mul, i);
+        float chi    = amul(chi_, chi_mul, i);
+        float invMs  = inv_Msat(Ms_, Ms_mul, i);
+
+        // damping and longitudinal field, only if the Curie temperature is set
+        float apar  = 0.0f;
+        float aperp = lambda;
+        if (Tc > 0.0f) {
+            if (T < Tc) {
+                apar  = lambda * 2.0f * T / (3.0f * Tc);
+                aperp = lambda * (1.0f - T / (3.0f * Tc));
+                if (chi > 0.0f && me > 0.0f) {
+                    H += (0.5f / chi) * (1.0f - m2 / (me * me)) * m;
+                }
+            } else {
+                apar  = lambda * 2.0f * T / (3.0f * Tc);
+                aperp = apar;
+                if (chi > 0.0f) {
+                    H += (-1.0f / chi) * (1.0f + 0.6f * Tc * m2 / (T - Tc)) * m;
+                }
+            }
+        }
+
+        // transverse and longitudinal noise
+        float3 zperp = make_float3(0.0f, 0.0f, 0.0f);
+        float3 zpar  = make_float3(0.0f, 0.0f, 0.0f);
+        if (T > 0.0f && aperp > 0.0f) {
+            float sperp = sqrtf(kB2_VgammaDt * T * invMs * (aperp - apar) / (aperp * aperp));
+            float spar  = sqrtf(kB2_VgammaDt * T * invMs * apar);
+            zperp = sperp * make_float3(nperpx[i], nperpy[i], nperpz[i]);
+            zpar  = spar  * make_float3(nparx[i],  npary[i],  nparz[i]);
+        }
+
+        float3 mxH  = cross(m, H);
+        float3 mxmxH = cross(m, cross(m, H + zperp));
+        float3 torque = -1.0f * mxH + (apar / m2) * dot(m, H) * m - (aperp / m2) * mxmxH + zpar;
+
+        tx[i] = torque.x;
+        ty[i] = torque.y;
+        tz[i] = torque.z;
+    }
+}
+
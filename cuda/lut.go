@@ -5,6 +5,19 @@ package cuda
 
 import "unsafe"
 
-type LUTPtr unsafe.Pointer    // points to 256 float32's
-type LUTPtrs []unsafe.Pointer // elements point to 256 float32's
+type LUTPtr unsafe.Pointer    // points to one float32 per region
+type LUTPtrs []unsafe.Pointer // elements point to one float32 per region
 type SymmLUT unsafe.Pointer   // points to 256x256 symmetric matrix, only lower half stored. See exchange.cu
+
+// Coupling (e.g. exchange) between all pairs of regions.
+// With 8-bit regions, Symm holds the full symmetric matrix.
+// With 16-bit regions, the matrix would be too large. The coupling is then the average
+// of the per-region values in Value, except for NPair explicitly set pairs
+// with sorted Keys and their own Scale and Inter term. See exchange16.h
+type InterLUT struct {
+	Symm         SymmLUT        // 8-bit regions
+	Value        LUTPtr         // 16-bit regions: value per region
+	Keys         unsafe.Pointer // 16-bit regions: sorted pair keys, uint32 min<<16|max
+	Scale, Inter unsafe.Pointer // 16-bit regions: scale factor and extra term per pair
+	NPair        int            // 16-bit regions: number of pairs
+}
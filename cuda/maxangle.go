@@ -8,12 +8,19 @@ import (
 
 // SetMaxAngle sets dst to the maximum angle of each cells magnetization with all of its neighbors,
 // provided the exchange stiffness with that neighbor is nonzero.
-func SetMaxAngle(dst, m *data.Slice, Aex_red SymmLUT, regions *Bytes, mesh *data.Mesh) {
+func SetMaxAngle(dst, m *data.Slice, Aex_red InterLUT, regions RegionMap, mesh *data.Mesh) {
 	N := mesh.Size()
 	pbc := mesh.PBC_code()
 	cfg := make3DConf(N)
-	k_setmaxangle_async(dst.DevPtr(0),
-		m.DevPtr(X), m.DevPtr(Y), m.DevPtr(Z),
-		unsafe.Pointer(Aex_red), regions.Ptr,
-		N[X], N[Y], N[Z], pbc, cfg)
+	if r, wide := regions.regionPtr(); wide {
+		k_setmaxangle16_async(dst.DevPtr(0),
+			m.DevPtr(X), m.DevPtr(Y), m.DevPtr(Z),
+			unsafe.Pointer(Aex_red.Value), Aex_red.Keys, Aex_red.Scale, Aex_red.Inter, Aex_red.NPair, r,
+			N[X], N[Y], N[Z], pbc, cfg)
+	} else {
+		k_setmaxangle_async(dst.DevPtr(0),
+			m.DevPtr(X), m.DevPtr(Y), m.DevPtr(Z),
+			unsafe.Pointer(Aex_red.Symm), r,
+			N[X], N[Y], N[Z], pbc, cfg)
+	}
 }
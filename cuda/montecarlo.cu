@@ -0,0 +1,95 @@
+#include <stdint.h>
+#include "float3.h"
+#include "amul.h"
+#include "constants.h"
+
+// Metropolis (or heat-bath) Monte Carlo update of all cells with (ix+iy+iz)%2 == color.
+// Nearest neighbours have the other color, so exchange and DMI enter exactly through
+// the field B of the fixed neighbours, which also includes Zeeman and (stale) demag fields.
+// The demag field of the cell on itself, -MU0 Ms N m, is taken out of B and added quadratically.
+// The uniaxial anisotropy energy is evaluated exactly.
+//
+// Trial move: m' = normalized(m + sigma * n), with n standard normal.
+// Energy change, with B' = B + MU0 Ms N m the field of all other cells:
+// 	ΔE = V vol [-Ms (m'-m)·B' + MU0/2 Ms² (m'·N m' - m·N m) - K1 ((m'·u)² - (m·u)²) - K2 ((m'·u)⁴ - (m·u)⁴)]
+// N: demag self-tensor (xx, yy, zz, yz, xz, xy), already scaled by the volume fraction where applicable.
+// Acceptance: min(1, exp(-ΔE/kT)) (Metropolis) or 1/(1+exp(ΔE/kT)) (heat-bath).
+// rnd: uniform random numbers in (0, 1]. Writes 1 to tried/acc for tried/accepted cells.
+// See montecarlo.go
+extern "C" __global__ void
+montecarlo(float* __restrict__ mx, float* __restrict__ my, float* __restrict__ mz,
+           float* __restrict__ Bx, float* __restrict__ By, float* __restrict__ Bz,
+           float* __restrict__ nx, float* __restrict__ ny, float* __restrict__ nz,
+           float* __restrict__ rnd, float* __restrict__ tried, float* __restrict__ acc,
+           float* __restrict__ Ms_, float Ms_mul,
+           float* __restrict__ K1_, float K1_mul,
+           float* __restrict__ K2_, float K2_mul,
+           float* __restrict__ ux_, float ux_mul,
+           float* __restrict__ uy_, float uy_mul,
+           float* __restrict__ uz_, float uz_mul,
+           float* __restrict__ T_, float T_mul,
+           float* __restrict__ frozen_, float frozen_mul,
+           float* __restrict__ vol_,
+           float* __restrict__ Nxx, float* __restrict__ Nyy, float* __restrict__ Nzz,
+           float* __restrict__ Nyz, float* __restrict__ Nxz, float* __restrict__ Nxy,
+           float V, float kB, float sigma, int heatbath, int color,
+           int Nx, int Ny, int Nz) {
+
+    int i =  ( blockIdx.y*gridDim.x + blockIdx.x ) * blockDim.x + threadIdx.x;
+    int N = Nx * Ny * Nz;
+    if (i >= N) {
+        return;
+    }
+
+    int ix = i % Nx;
+    int iy = (i / Nx) % Ny;
+    int iz = i / (Nx * Ny);
+    if ((ix + iy + iz) % 2 != color) {
+        return;
+    }
+
+    float3 m = make_float3(mx[i], my[i], mz[i]);
+    float Ms = amul(Ms_, Ms_mul, i);
+    float vol = amul(vol_, 1.0f, i);
+    if (is0(m) || Ms == 0.0f || vol == 0.0f || amul(frozen_, frozen_mul, i) != 0.0f) {
+        return;
+    }
+
+    float3 m2 = normalized(m + sigma * make_float3(nx[i], ny[i], nz[i]));
+    float3 Nm  = make_float3(Nxx[i]*m.x + Nxy[i]*m.y + Nxz[i]*m.z,
+                             Nxy[i]*m.x + Nyy[i]*m.y + Nyz[i]*m.z,
+                             Nxz[i]*m.x + Nyz[i]*m.y + Nzz[i]*m.z);
+    float3 Nm2 = make_float3(Nxx[i]*m2.x + Nxy[i]*m2.y + Nxz[i]*m2.z,
+                             Nxy[i]*m2.x + Nyy[i]*m2.y + Nyz[i]*m2.z,
+                             Nxz[i]*m2.x + Nyz[i]*m2.y + Nzz[i]*m2.z);
+    float3 B  = make_float3(Bx[i], By[i], Bz[i]) + (float)(MU0) * Ms * Nm; // without self-demag
+    float3 u  = normalized(vmul(ux_, uy_, uz_, ux_mul, uy_mul, uz_mul, i));
+    float K1  = amul(K1_, K1_mul, i);
+    float K2  = amul(K2_, K2_mul, i);
+    float mu  = dot(m, u);
+    float mu2 = dot(m2, u);
+
+    float dE = -Ms * dot(m2 - m, B)
+               + 0.5f * (float)(MU0) * Ms * Ms * (dot(m2, Nm2) - dot(m, Nm))
+               - K1 * (mu2*mu2 - mu*mu)
+               - K2 * (mu2*mu2*mu2*mu2 - mu*mu*mu*mu);
+    dE *= V * vol;
+
+    float kT = kB * amul(T_, T_mul, i);
+    bool accept;
+    if (kT == 0.0f) {
+        accept = (dE < 0.0f);
+    } else if (heatbath) {
+        accept = (rnd[i] < 1.0f / (1.0f + expf(dE / kT)));
+    } else {
+        accept = (dE < 0.0f || rnd[i] < expf(-dE / kT));
+    }
+
+    tried[i] = 1.0f;
+    if (accept) {
+        acc[i] = 1.0f;
+        mx[i] = m2.x;
+        my[i] = m2.y;
+        mz[i] = m2.z;
+    }
+}
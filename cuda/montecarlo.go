@@ -0,0 +1,42 @@
+package cuda
+
+import (
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/mag"
+	"github.com/mumax/3/util"
+)
+
+// Metropolis (or heat-bath) Monte Carlo update of all cells with (ix+iy+iz)%2 == color,
+// in field B of the neighbours. noise: standard normal trial moves, rnd: uniform random numbers.
+// selfDemag: demag self-tensor of each cell (xx, yy, zz, yz, xz, xy), whose field is included in B.
+// vol: volume fraction of each cell (may be nil), cellVolume: volume of a full cell.
+// Sets tried and acc to 1 for tried and accepted cells, respectively.
+// see montecarlo.cu
+func MonteCarloStep(m, B, noise, rnd, tried, acc, vol, selfDemag *data.Slice, Msat, Ku1, Ku2, AnisU, Temp, frozen MSlice,
+	cellVolume, stepSize float64, heatBath bool, color int, mesh *data.Mesh) {
+	checkSize(m, B, noise, rnd, tried, acc, selfDemag, Msat, Ku1, Ku2, AnisU, Temp, frozen)
+	util.Argument(selfDemag.NComp() == 6)
+	N := mesh.Size()
+	cfg := make1DConf(mesh.NCell())
+	hb := 0
+	if heatBath {
+		hb = 1
+	}
+	k_montecarlo_async(m.DevPtr(X), m.DevPtr(Y), m.DevPtr(Z),
+		B.DevPtr(X), B.DevPtr(Y), B.DevPtr(Z),
+		noise.DevPtr(X), noise.DevPtr(Y), noise.DevPtr(Z),
+		rnd.DevPtr(0), tried.DevPtr(0), acc.DevPtr(0),
+		Msat.DevPtr(0), Msat.Mul(0),
+		Ku1.DevPtr(0), Ku1.Mul(0),
+		Ku2.DevPtr(0), Ku2.Mul(0),
+		AnisU.DevPtr(X), AnisU.Mul(X),
+		AnisU.DevPtr(Y), AnisU.Mul(Y),
+		AnisU.DevPtr(Z), AnisU.Mul(Z),
+		Temp.DevPtr(0), Temp.Mul(0),
+		frozen.DevPtr(0), frozen.Mul(0),
+		vol.DevPtr(0),
+		selfDemag.DevPtr(0), selfDemag.DevPtr(1), selfDemag.DevPtr(2),
+		selfDemag.DevPtr(3), selfDemag.DevPtr(4), selfDemag.DevPtr(5),
+		float32(cellVolume), float32(mag.Kb), float32(stepSize), hb, color,
+		N[X], N[Y], N[Z], cfg)
+}
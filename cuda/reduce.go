@@ -45,21 +45,29 @@ func MaxAbs(in *data.Slice) float32 {
 
 // Maximum of the norms of all vectors (x[i], y[i], z[i]).
 // 	max_i sqrt( x[i]*x[i] + y[i]*y[i] + z[i]*z[i] )
+// For more than 3 components (e.g. multiple sublattices), the maximum
+// is taken over each consecutive group of 3 components.
 func MaxVecNorm(v *data.Slice) float64 {
+	util.Argument(v.NComp()%3 == 0)
 	out := reduceBuf(0)
-	k_reducemaxvecnorm2_async(v.DevPtr(0), v.DevPtr(1), v.DevPtr(2), out, 0, v.Len(), reducecfg)
+	for c := 0; c < v.NComp(); c += 3 {
+		k_reducemaxvecnorm2_async(v.DevPtr(c), v.DevPtr(c+1), v.DevPtr(c+2), out, 0, v.Len(), reducecfg)
+	}
 	return math.Sqrt(float64(copyback(out)))
 }
 
 // Maximum of the norms of the difference between all vectors (x1,y1,z1) and (x2,y2,z2)
 // 	(dx, dy, dz) = (x1, y1, z1) - (x2, y2, z2)
 // 	max_i sqrt( dx[i]*dx[i] + dy[i]*dy[i] + dz[i]*dz[i] )
+// Like MaxVecNorm, more than 3 components are treated in groups of 3.
 func MaxVecDiff(x, y *data.Slice) float64 {
-	util.Argument(x.Len() == y.Len())
+	util.Argument(x.Len() == y.Len() && x.NComp() == y.NComp() && x.NComp()%3 == 0)
 	out := reduceBuf(0)
-	k_reducemaxvecdiff2_async(x.DevPtr(0), x.DevPtr(1), x.DevPtr(2),
-		y.DevPtr(0), y.DevPtr(1), y.DevPtr(2),
-		out, 0, x.Len(), reducecfg)
+	for c := 0; c < x.NComp(); c += 3 {
+		k_reducemaxvecdiff2_async(x.DevPtr(c), x.DevPtr(c+1), x.DevPtr(c+2),
+			y.DevPtr(c), y.DevPtr(c+1), y.DevPtr(c+2),
+			out, 0, x.Len(), reducecfg)
+	}
 	return math.Sqrt(float64(copyback(out)))
 }
 
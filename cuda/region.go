@@ -7,37 +7,64 @@ import (
 	"github.com/mumax/3/util"
 )
 
+// Region index per cell: *Bytes for up to 256 regions,
+// *Shorts for up to 65536 regions.
+type RegionMap interface {
+	regionPtr() (ptr unsafe.Pointer, wide bool) // wide: 16-bit
+}
+
+func (b *Bytes) regionPtr() (unsafe.Pointer, bool)  { return b.Ptr, false }
+func (s *Shorts) regionPtr() (unsafe.Pointer, bool) { return s.Ptr, true }
+
 // dst += LUT[region], for vectors. Used to add terms to excitation.
-func RegionAddV(dst *data.Slice, lut LUTPtrs, regions *Bytes) {
+func RegionAddV(dst *data.Slice, lut LUTPtrs, regions RegionMap) {
 	util.Argument(dst.NComp() == 3)
 	N := dst.Len()
 	cfg := make1DConf(N)
-	k_regionaddv_async(dst.DevPtr(X), dst.DevPtr(Y), dst.DevPtr(Z),
-		lut[X], lut[Y], lut[Z], regions.Ptr, N, cfg)
+	if r, wide := regions.regionPtr(); wide {
+		k_regionaddv16_async(dst.DevPtr(X), dst.DevPtr(Y), dst.DevPtr(Z),
+			lut[X], lut[Y], lut[Z], r, N, cfg)
+	} else {
+		k_regionaddv_async(dst.DevPtr(X), dst.DevPtr(Y), dst.DevPtr(Z),
+			lut[X], lut[Y], lut[Z], r, N, cfg)
+	}
 }
 
 // dst += LUT[region], for scalar. Used to add terms to scalar excitation.
-func RegionAddS(dst *data.Slice, lut LUTPtr, regions *Bytes) {
+func RegionAddS(dst *data.Slice, lut LUTPtr, regions RegionMap) {
 	util.Argument(dst.NComp() == 1)
 	N := dst.Len()
 	cfg := make1DConf(N)
-	k_regionadds_async(dst.DevPtr(0), unsafe.Pointer(lut), regions.Ptr, N, cfg)
+	if r, wide := regions.regionPtr(); wide {
+		k_regionadds16_async(dst.DevPtr(0), unsafe.Pointer(lut), r, N, cfg)
+	} else {
+		k_regionadds_async(dst.DevPtr(0), unsafe.Pointer(lut), r, N, cfg)
+	}
 }
 
 // decode the regions+LUT pair into an uncompressed array
-func RegionDecode(dst *data.Slice, lut LUTPtr, regions *Bytes) {
+func RegionDecode(dst *data.Slice, lut LUTPtr, regions RegionMap) {
 	N := dst.Len()
 	cfg := make1DConf(N)
-	k_regiondecode_async(dst.DevPtr(0), unsafe.Pointer(lut), regions.Ptr, N, cfg)
+	if r, wide := regions.regionPtr(); wide {
+		k_regiondecode16_async(dst.DevPtr(0), unsafe.Pointer(lut), r, N, cfg)
+	} else {
+		k_regiondecode_async(dst.DevPtr(0), unsafe.Pointer(lut), r, N, cfg)
+	}
 }
 
 // select the part of src within the specified region, set 0's everywhere else.
-func RegionSelect(dst, src *data.Slice, regions *Bytes, region byte) {
+func RegionSelect(dst, src *data.Slice, regions RegionMap, region int) {
 	util.Argument(dst.NComp() == src.NComp())
 	N := dst.Len()
 	cfg := make1DConf(N)
 
+	r, wide := regions.regionPtr()
 	for c := 0; c < dst.NComp(); c++ {
-		k_regionselect_async(dst.DevPtr(c), src.DevPtr(c), regions.Ptr, region, N, cfg)
+		if wide {
+			k_regionselect16_async(dst.DevPtr(c), src.DevPtr(c), r, uint16(region), N, cfg)
+		} else {
+			k_regionselect_async(dst.DevPtr(c), src.DevPtr(c), r, byte(region), N, cfg)
+		}
 	}
 }
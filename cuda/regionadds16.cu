@@ -0,0 +1,17 @@
+#include <stdint.h>
+
+// add region-based scalar to dst:
+// dst[i] += LUT[region[i]]
+extern "C" __global__ void
+regionadds16(float* __restrict__ dst,
+             float* __restrict__ LUT,
+             uint16_t* regions, int N) {
+
+	int i =  ( blockIdx.y*gridDim.x + blockIdx.x ) * blockDim.x + threadIdx.x;
+	if (i < N) {
+
+		uint16_t r = regions[i];
+		dst[i] += LUT[r];
+	}
+}
+
@@ -43,3 +43,16 @@ func ShiftBytesY(dst, src *Bytes, m *data.Mesh, shiftY int, clamp byte) {
 	cfg := make3DConf(N)
 	k_shiftbytesy_async(dst.Ptr, src.Ptr, N[X], N[Y], N[Z], shiftY, clamp, cfg)
 }
+
+// Like ShiftBytes, but for 16-bit regions
+func ShiftShorts(dst, src *Shorts, m *data.Mesh, shiftX int, clamp uint16) {
+	N := m.Size()
+	cfg := make3DConf(N)
+	k_shiftshorts_async(dst.Ptr, src.Ptr, N[X], N[Y], N[Z], shiftX, clamp, cfg)
+}
+
+func ShiftShortsY(dst, src *Shorts, m *data.Mesh, shiftY int, clamp uint16) {
+	N := m.Size()
+	cfg := make3DConf(N)
+	k_shiftshortsy_async(dst.Ptr, src.Ptr, N[X], N[Y], N[Z], shiftY, clamp, cfg)
+}
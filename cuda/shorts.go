@@ -0,0 +1,74 @@
+package cuda
+
+// This file provides GPU uint16 slices, used to store regions
+// when there are more than 256 of them.
+
+import (
+	"log"
+	"unsafe"
+
+	"github.com/mumax/3/cuda/cu"
+	"github.com/mumax/3/util"
+)
+
+// 3D uint16 slice, used for region lookup.
+type Shorts struct {
+	Ptr unsafe.Pointer
+	Len int
+}
+
+// Construct new uint16 slice with given length,
+// initialised to zeros.
+func NewShorts(Len int) *Shorts {
+	ptr := cu.MemAlloc(2 * int64(Len))
+	cu.MemsetD8(cu.DevicePtr(ptr), 0, 2*int64(Len))
+	return &Shorts{unsafe.Pointer(uintptr(ptr)), Len}
+}
+
+// Upload src (host) to dst (gpu).
+func (dst *Shorts) Upload(src []uint16) {
+	util.Argument(dst.Len == len(src))
+	MemCpyHtoD(dst.Ptr, unsafe.Pointer(&src[0]), 2*int64(dst.Len))
+}
+
+// Copy on device: dst = src.
+func (dst *Shorts) Copy(src *Shorts) {
+	util.Argument(dst.Len == src.Len)
+	MemCpy(dst.Ptr, src.Ptr, 2*int64(dst.Len))
+}
+
+// Copy to host: dst = src.
+func (src *Shorts) Download(dst []uint16) {
+	util.Argument(src.Len == len(dst))
+	MemCpyDtoH(unsafe.Pointer(&dst[0]), src.Ptr, 2*int64(src.Len))
+}
+
+// Set one element to value.
+// data.Index can be used to find the index for x,y,z.
+func (dst *Shorts) Set(index int, value uint16) {
+	if index < 0 || index >= dst.Len {
+		log.Panic("Shorts.Set: index out of range:", index)
+	}
+	src := value
+	MemCpyHtoD(unsafe.Pointer(uintptr(dst.Ptr)+2*uintptr(index)), unsafe.Pointer(&src), 2)
+}
+
+// Get one element.
+// data.Index can be used to find the index for x,y,z.
+func (src *Shorts) Get(index int) uint16 {
+	if index < 0 || index >= src.Len {
+		log.Panic("Shorts.Get: index out of range:", index)
+	}
+	var dst uint16
+	MemCpyDtoH(unsafe.Pointer(&dst), unsafe.Pointer(uintptr(src.Ptr)+2*uintptr(index)), 2)
+	return dst
+}
+
+// Frees the GPU memory and disables the slice.
+func (b *Shorts) Free() {
+	if b.Ptr != nil {
+		cu.MemFree(cu.DevicePtr(uintptr(b.Ptr)))
+	}
+	b.Ptr = nil
+	b.Len = 0
+}
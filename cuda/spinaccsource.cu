@@ -0,0 +1,74 @@
+#include <stdint.h>
+#include "amul.h"
+#include "float3.h"
+#include "stencil.h"
+
+// Source of the spin accumulation: minus the divergence of the polarized spin current
+// 	S_a = -(μB/e) Σ_d ∂_d (β m_a j_d)
+// by central differences (one-sided at the edges of the spin transport medium, Dspin == 0).
+// pre = μB/e. Zero outside the medium.
+// See spinaccumulation.go
+extern "C" __global__ void
+spinaccsource(float* __restrict__ Sx, float* __restrict__ Sy, float* __restrict__ Sz,
+              float* __restrict__ mx, float* __restrict__ my, float* __restrict__ mz,
+              float* __restrict__ jx_, float jx_mul,
+              float* __restrict__ jy_, float jy_mul,
+              float* __restrict__ jz_, float jz_mul,
+              float* __restrict__ pol_, float pol_mul,
+              float* __restrict__ D_, float D_mul,
+              float pre, float wx, float wy, float wz, int Nx, int Ny, int Nz, uint8_t PBC) {
+
+    int ix = blockIdx.x * blockDim.x + threadIdx.x;
+    int iy = blockIdx.y * blockDim.y + threadIdx.y;
+    int iz = blockIdx.z * blockDim.z + threadIdx.z;
+
+    if (ix >= Nx || iy >= Ny || iz >= Nz) {
+        return;
+    }
+
+    int I = idx(ix, iy, iz);
+    float3 S = make_float3(0.0f, 0.0f, 0.0f);
+    if (amul(D_, D_mul, I) == 0.0f) {
+        Sx[I] = 0.0f;
+        Sy[I] = 0.0f;
+        Sz[I] = 0.0f;
+        return;
+    }
+
+    float w[3] = {wx, wy, wz};
+    for (int d = 0; d < 3; d++) {
+        // neighbours along d, clamped to I outside the medium
+        int P, M;
+        if (d == 0) {
+            P = idx(hclampx(ix+1), iy, iz);
+            M = idx(lclampx(ix-1), iy, iz);
+        } else if (d == 1) {
+            P = idx(ix, hclampy(iy+1), iz);
+            M = idx(ix, lclampy(iy-1), iz);
+        } else {
+            P = idx(ix, iy, hclampz(iz+1));
+            M = idx(ix, iy, lclampz(iz-1));
+        }
+        if (amul(D_, D_mul, P) == 0.0f) {
+            P = I;
+        }
+        if (amul(D_, D_mul, M) == 0.0f) {
+            M = I;
+        }
+        int n = (P != I) + (M != I);
+        if (n == 0) {
+            continue;
+        }
+        float* j_ = (d == 0)? jx_: ((d == 1)? jy_: jz_);
+        float j_mul = (d == 0)? jx_mul: ((d == 1)? jy_mul: jz_mul);
+        float cP = amul(pol_, pol_mul, P) * amul(j_, j_mul, P);
+        float cM = amul(pol_, pol_mul, M) * amul(j_, j_mul, M);
+        float3 mP = make_float3(mx[P], my[P], mz[P]);
+        float3 mM = make_float3(mx[M], my[M], mz[M]);
+        S += (w[d] / n) * (cP * mP - cM * mM);
+    }
+
+    Sx[I] = -pre * S.x;
+    Sy[I] = -pre * S.y;
+    Sz[I] = -pre * S.z;
+}
@@ -0,0 +1,48 @@
+package cuda
+
+import (
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/util"
+)
+
+// Sets S to the source of the spin accumulation, -(μB/e) ∇·(β m⊗j).
+// see spinaccsource.cu
+func SetSpinAccSource(S, m *data.Slice, J, pol, Dspin MSlice, mesh *data.Mesh) {
+	util.Argument(S.Size() == m.Size())
+	const muB_e = 9.2740091523e-24 / 1.60217646e-19 // Bohr magneton / electron charge
+	w := invCellSize(mesh)
+	N := mesh.Size()
+	cfg := make3DConf(N)
+	k_spinaccsource_async(S.DevPtr(X), S.DevPtr(Y), S.DevPtr(Z),
+		m.DevPtr(X), m.DevPtr(Y), m.DevPtr(Z),
+		J.DevPtr(X), J.Mul(X), J.DevPtr(Y), J.Mul(Y), J.DevPtr(Z), J.Mul(Z),
+		pol.DevPtr(0), pol.Mul(0), Dspin.DevPtr(0), Dspin.Mul(0),
+		float32(muB_e), w[X], w[Y], w[Z], N[X], N[Y], N[Z], mesh.PBC_code(), cfg)
+}
+
+// One red-black SOR half-sweep of the spin accumulation s over cells of the given color,
+// with over-relaxation omega. diff receives the change of s.
+// see spinaccupdate.cu
+func SpinAccUpdate(s, diff, m, S *data.Slice, Dspin, lambdaSF, lambdaJ MSlice, omega float64, color int, mesh *data.Mesh) {
+	util.Argument(s.Size() == m.Size() && S.Size() == m.Size() && diff.NComp() == 1)
+	w := invCellSize(mesh)
+	N := mesh.Size()
+	cfg := make3DConf(N)
+	k_spinaccupdate_async(s.DevPtr(X), s.DevPtr(Y), s.DevPtr(Z), diff.DevPtr(0),
+		m.DevPtr(X), m.DevPtr(Y), m.DevPtr(Z),
+		S.DevPtr(X), S.DevPtr(Y), S.DevPtr(Z),
+		Dspin.DevPtr(0), Dspin.Mul(0), lambdaSF.DevPtr(0), lambdaSF.Mul(0), lambdaJ.DevPtr(0), lambdaJ.Mul(0),
+		w[X], w[Y], w[Z], float32(omega), color, N[X], N[Y], N[Z], mesh.PBC_code(), cfg)
+}
+
+// Adds the field of spin accumulation s to B.
+// see addspinaccfield.cu
+func AddSpinAccField(B, s *data.Slice, Dspin, lambdaJ, Msat MSlice, gamma float64) {
+	util.Argument(B.Size() == s.Size())
+	N := B.Len()
+	cfg := make1DConf(N)
+	k_addspinaccfield_async(B.DevPtr(X), B.DevPtr(Y), B.DevPtr(Z),
+		s.DevPtr(X), s.DevPtr(Y), s.DevPtr(Z),
+		Dspin.DevPtr(0), Dspin.Mul(0), lambdaJ.DevPtr(0), lambdaJ.Mul(0), Msat.DevPtr(0), Msat.Mul(0),
+		float32(gamma), N, cfg)
+}
@@ -0,0 +1,81 @@
+#include <stdint.h>
+#include "amul.h"
+#include "float3.h"
+#include "stencil.h"
+
+// One red-black SOR half-sweep for the steady-state spin accumulation s:
+// 	Dspin ∇²s - Dspin s/λsf² - Dspin s×m/λJ² + S = 0
+// Updates all cells with (ix+iy+iz)%2 == color, solving the local 3x3 system
+// 	a s + b s×m = r,  a = Σ w + Dspin/λsf²,  b = Dspin/λJ² = J_sd/ħ,  r = Σ w s_neighbour + S
+// exactly, with w = harmonic mean of Dspin / cellsize² over each face.
+// Faces towards cells outside the medium (Dspin == 0) carry no spin current.
+// diff is set to the norm of the change of s.
+// See spinaccumulation.go
+extern "C" __global__ void
+spinaccupdate(float* __restrict__ sx, float* __restrict__ sy, float* __restrict__ sz,
+              float* __restrict__ diff,
+              float* __restrict__ mx, float* __restrict__ my, float* __restrict__ mz,
+              float* __restrict__ Sx, float* __restrict__ Sy, float* __restrict__ Sz,
+              float* __restrict__ D_, float D_mul,
+              float* __restrict__ lsf_, float lsf_mul,
+              float* __restrict__ lJ_, float lJ_mul,
+              float wx, float wy, float wz, float omega, int color,
+              int Nx, int Ny, int Nz, uint8_t PBC) {
+
+    int ix = blockIdx.x * blockDim.x + threadIdx.x;
+    int iy = blockIdx.y * blockDim.y + threadIdx.y;
+    int iz = blockIdx.z * blockDim.z + threadIdx.z;
+
+    if (ix >= Nx || iy >= Ny || iz >= Nz || (ix + iy + iz) % 2 != color) {
+        return;
+    }
+
+    int I = idx(ix, iy, iz);
+    float D = amul(D_, D_mul, I);
+    if (D == 0.0f) {
+        sx[I] = 0.0f;
+        sy[I] = 0.0f;
+        sz[I] = 0.0f;
+        diff[I] = 0.0f;
+        return;
+    }
+
+    // neighbours: -x, +x, -y, +y, -z, +z
+    int nb[6] = {idx(lclampx(ix-1), iy, iz), idx(hclampx(ix+1), iy, iz),
+                 idx(ix, lclampy(iy-1), iz), idx(ix, hclampy(iy+1), iz),
+                 idx(ix, iy, lclampz(iz-1)), idx(ix, iy, hclampz(iz+1))};
+    float w2[3] = {wx*wx, wy*wy, wz*wz};
+
+    float a = 0.0f;
+    float3 r = make_float3(Sx[I], Sy[I], Sz[I]);
+    for (int k = 0; k < 6; k++) {
+        int J = nb[k];
+        float DJ = amul(D_, D_mul, J);
+        if (J == I || DJ == 0.0f) {
+            continue;
+        }
+        float w = w2[k/2] * 2.0f * D * DJ / (D + DJ);
+        a += w;
+        r += w * make_float3(sx[J], sy[J], sz[J]);
+    }
+
+    float lsf = amul(lsf_, lsf_mul, I);
+    if (lsf != 0.0f) {
+        a += D / (lsf * lsf);
+    }
+    float3 m = make_float3(mx[I], my[I], mz[I]);
+    float lJ = amul(lJ_, lJ_mul, I);
+    float b = (lJ == 0.0f || is0(m))? 0.0f: D / (lJ * lJ);
+
+    float3 s0 = make_float3(sx[I], sy[I], sz[I]);
+    float3 s = make_float3(0.0f, 0.0f, 0.0f);
+    if (a != 0.0f) {
+        s = (1.0f / (a * a + b * b)) * (a * r - b * cross(r, m) + (b * b / a) * dot(r, m) * m);
+    }
+    s = s0 + omega * (s - s0);
+
+    sx[I] = s.x;
+    sy[I] = s.y;
+    sz[I] = s.z;
+    diff[I] = len(s - s0);
+}
@@ -0,0 +1,35 @@
+package cuda
+
+import (
+	"github.com/mumax/3/data"
+)
+
+// Add spin-orbit torque to torque (Tesla).
+// If the polarization p is zero, the spins are polarized along J x normal.
+// see spinorbittorque.cu
+func AddSpinOrbitTorque(torque, m *data.Slice, Msat, J, p, alpha, θSH, ξDL, ξFL, thickness, tHM, λsf MSlice, normal data.Vector, mesh *data.Mesh) {
+	N := torque.Len()
+	cfg := make1DConf(N)
+	meshThickness := mesh.WorldSize()[Z]
+
+	k_addspinorbittorque_async(
+		torque.DevPtr(X), torque.DevPtr(Y), torque.DevPtr(Z),
+		m.DevPtr(X), m.DevPtr(Y), m.DevPtr(Z),
+		Msat.DevPtr(0), Msat.Mul(0),
+		J.DevPtr(X), J.Mul(X),
+		J.DevPtr(Y), J.Mul(Y),
+		J.DevPtr(Z), J.Mul(Z),
+		p.DevPtr(X), p.Mul(X),
+		p.DevPtr(Y), p.Mul(Y),
+		p.DevPtr(Z), p.Mul(Z),
+		alpha.DevPtr(0), alpha.Mul(0),
+		θSH.DevPtr(0), θSH.Mul(0),
+		ξDL.DevPtr(0), ξDL.Mul(0),
+		ξFL.DevPtr(0), ξFL.Mul(0),
+		thickness.DevPtr(0), thickness.Mul(0),
+		tHM.DevPtr(0), tHM.Mul(0),
+		λsf.DevPtr(0), λsf.Mul(0),
+		float32(normal[X]), float32(normal[Y]), float32(normal[Z]),
+		float32(meshThickness),
+		N, cfg)
+}
@@ -0,0 +1,112 @@
+package cuda
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/mumax/3/data"
+)
+
+// Host reference implementation of the spin-orbit torque for one cell,
+// in float64, written directly from
+// 	τ = β/(1+α²) [(ξDL + α ξFL) m × (p × m) + (ξFL - α ξDL) p × m]
+// 	β = ħ θ_eff j / (2 e t Ms)
+func spinOrbitTorqueRef(m, J, p [3]float64, Ms, alpha, θ, ξDL, ξFL, t, tHM, λsf float64, n [3]float64) [3]float64 {
+	const (
+		hbar = 1.05457173e-34
+		qe   = 1.60217646e-19
+	)
+	var j float64
+	if norm3(p) == 0 {
+		s := cross3(J, n)
+		j = norm3(s)
+		p = scale3(1/j, s)
+	} else {
+		j = norm3(J)
+		p = scale3(1/norm3(p), p)
+	}
+	if tHM != 0 && λsf != 0 {
+		θ *= 1 - 1/math.Cosh(tHM/λsf)
+	}
+	β := hbar * θ * j / (2 * qe * t * Ms)
+	pxm := cross3(p, m)
+	mxpxm := cross3(m, pxm)
+	gilb := 1 / (1 + alpha*alpha)
+	var τ [3]float64
+	for c := range τ {
+		τ[c] = β * gilb * ((ξDL+alpha*ξFL)*mxpxm[c] + (ξFL-alpha*ξDL)*pxm[c])
+	}
+	return τ
+}
+
+func TestSpinOrbitTorque(t *testing.T) {
+	size := [3]int{16, 8, 2}
+	mesh := data.NewMesh(size[X], size[Y], size[Z], 2e-9, 2e-9, 1e-9)
+	N := mesh.NCell()
+	rng := rand.New(rand.NewSource(0))
+
+	mh := data.NewSlice(3, size)
+	Jh := data.NewSlice(3, size)
+	m, J := mh.Host(), Jh.Host()
+	for i := 0; i < N; i++ {
+		var v [3]float64
+		for c := range v {
+			v[c] = rng.Float64() - 0.5
+			J[c][i] = float32(1e12 * (rng.Float64() - 0.5))
+		}
+		v = scale3(1/norm3(v), v)
+		for c := range v {
+			m[c][i] = float32(v[c])
+		}
+	}
+
+	const (
+		Ms, alpha, θ, ξDL, ξFL = 8e5, 0.02, 0.1, 1, -0.3
+		tFM, tHM, λsf          = 1e-9, 5e-9, 1.5e-9
+	)
+	normal := data.Vector{0, 0, 1}
+	uniform := func(ncomp int, v ...float64) MSlice {
+		return MakeMSlice(data.NilSlice(ncomp, size), v)
+	}
+
+	for _, pol := range [][3]float64{{0, 0, 0}, {0, 2, 1}} {
+		mGPU := NewSlice(3, size)
+		defer mGPU.Free()
+		JGPU := NewSlice(3, size)
+		defer JGPU.Free()
+		τGPU := NewSlice(3, size)
+		defer τGPU.Free()
+		data.Copy(mGPU, mh)
+		data.Copy(JGPU, Jh)
+		Zero(τGPU)
+
+		AddSpinOrbitTorque(τGPU, mGPU, uniform(1, Ms), ToMSlice(JGPU), uniform(3, pol[X], pol[Y], pol[Z]),
+			uniform(1, alpha), uniform(1, θ), uniform(1, ξDL), uniform(1, ξFL),
+			uniform(1, tFM), uniform(1, tHM), uniform(1, λsf), normal, mesh)
+		τ := τGPU.HostCopy().Host()
+
+		for i := 0; i < N; i++ {
+			mi := [3]float64{float64(m[X][i]), float64(m[Y][i]), float64(m[Z][i])}
+			Ji := [3]float64{float64(J[X][i]), float64(J[Y][i]), float64(J[Z][i])}
+			want := spinOrbitTorqueRef(mi, Ji, pol, Ms, alpha, θ, ξDL, ξFL, tFM, tHM, λsf, [3]float64(normal))
+			for c := 0; c < 3; c++ {
+				if math.Abs(float64(τ[c][i])-want[c]) > 1e-5*norm3(want)+1e-9 {
+					t.Fatal("pol", pol, "cell", i, "comp", c, "got:", τ[c][i], "want:", want[c])
+				}
+			}
+		}
+	}
+}
+
+func cross3(a, b [3]float64) [3]float64 {
+	return [3]float64{a[Y]*b[Z] - a[Z]*b[Y], a[Z]*b[X] - a[X]*b[Z], a[X]*b[Y] - a[Y]*b[X]}
+}
+
+func norm3(a [3]float64) float64 {
+	return math.Sqrt(a[X]*a[X] + a[Y]*a[Y] + a[Z]*a[Z])
+}
+
+func scale3(s float64, a [3]float64) [3]float64 {
+	return [3]float64{s * a[X], s * a[Y], s * a[Z]}
+}
@@ -0,0 +1,87 @@
+#include <stdint.h>
+#include "float3.h"
+#include "constants.h"
+#include "amul.h"
+
+// Spin-orbit torque from a charge current J in an adjacent heavy metal:
+// damping-like and field-like torques with spin polarization p.
+// If p is not set (zero), the spins are polarized along J x n,
+// with n the interface normal pointing into the magnet.
+// The effective spin Hall angle is reduced by spin diffusion in a heavy metal
+// of finite thickness: θ_eff = θ_SH (1 - sech(t_HM / λ_sf)).
+extern "C" __global__ void
+addspinorbittorque(float* __restrict__ tx, float* __restrict__ ty, float* __restrict__ tz,
+                   float* __restrict__ mx, float* __restrict__ my, float* __restrict__ mz,
+                   float* __restrict__ Ms_,        float  Ms_mul,
+                   float* __restrict__ jx_,        float  jx_mul,
+                   float* __restrict__ jy_,        float  jy_mul,
+                   float* __restrict__ jz_,        float  jz_mul,
+                   float* __restrict__ px_,        float  px_mul,
+                   float* __restrict__ py_,        float  py_mul,
+                   float* __restrict__ pz_,        float  pz_mul,
+                   float* __restrict__ alpha_,     float  alpha_mul,
+                   float* __restrict__ theta_,     float  theta_mul,
+                   float* __restrict__ xiDL_,      float  xiDL_mul,
+                   float* __restrict__ xiFL_,      float  xiFL_mul,
+                   float* __restrict__ thickness_, float  thickness_mul,
+                   float* __restrict__ tHM_,       float  tHM_mul,
+                   float* __restrict__ lsf_,       float  lsf_mul,
+                   float nx, float ny, float nz,
+                   float meshThickness,
+                   int N) {
+
+    int i =  ( blockIdx.y*gridDim.x + blockIdx.x ) * blockDim.x + threadIdx.x;
+    if (i < N) {
+
+        float3 m     = make_float3(mx[i], my[i], mz[i]);
+        float3 J     = vmul(jx_, jy_, jz_, jx_mul, jy_mul, jz_mul, i);
+        float3 p     = vmul(px_, py_, pz_, px_mul, py_mul, pz_mul, i);
+        float  Ms    = amul(Ms_, Ms_mul, i);
+        float  alpha = amul(alpha_, alpha_mul, i);
+        float  theta = amul(theta_, theta_mul, i);
+        float  xiDL  = amul(xiDL_, xiDL_mul, i);
+        float  xiFL  = amul(xiFL_, xiFL_mul, i);
+        float  tHM   = amul(tHM_, tHM_mul, i);
+        float  lsf   = amul(lsf_, lsf_mul, i);
+
+        float thickness = amul(thickness_, thickness_mul, i);
+        if (thickness == 0.0f) { // if thickness is not set, use the thickness of the mesh instead
+            thickness = meshThickness;
+        }
+
+        // current density carrying the spins, and their direction
+        float j;
+        if (len(p) == 0.0f) {
+            float3 s = cross(J, make_float3(nx, ny, nz));
+            j = len(s);
+            p = normalized(s);
+        } else {
+            j = len(J);
+            p = normalized(p);
+        }
+
+        if (j == 0.0f || Ms == 0.0f || theta == 0.0f) {
+            return;
+        }
+
+        if (tHM != 0.0f && lsf != 0.0f) {
+            theta *= 1.0f - 1.0f / coshf(tHM / lsf);
+        }
+
+        float beta = (HBAR / (2.0f * QE)) * theta * j / (thickness * Ms);
+        float A    = beta * xiDL;
+        float B    = beta * xiFL;
+
+        float gilb     = 1.0f / (1.0f + alpha * alpha);
+        float mxpxmFac = gilb * (A + alpha * B);
+        float pxmFac   = gilb * (B - alpha * A);
+
+        float3 pxm   = cross(p, m);
+        float3 mxpxm = cross(m, pxm);
+
+        tx[i] += mxpxmFac * mxpxm.x + pxmFac * pxm.x;
+        ty[i] += mxpxmFac * mxpxm.y + pxmFac * pxm.y;
+        tz[i] += mxpxmFac * mxpxm.z + pxmFac * pxm.z;
+    }
+}
+
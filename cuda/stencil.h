@@ -28,6 +28,10 @@
 #define hclampz(iz) (PBCz? MOD(iz, Nz) : min((iz), Nz-1))
 #define lclampz(iz) (PBCz? MOD(iz, Nz) : max((iz), 0))
 
+// distance between the centers of layer iz and neighboring layer jz,
+// for non-uniform layer thicknesses dz (see SetCellSizeZ)
+#define layerdist(dz, iz, jz) ( 0.5f*((dz)[(iz)] + (dz)[(jz)]) )
+
 
 #endif
 
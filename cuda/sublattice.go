@@ -0,0 +1,30 @@
+package cuda
+
+import (
+	"github.com/mumax/3/data"
+)
+
+// Add the exchange field on sublattice i due to sublattice j to B:
+//
+//	B += Ahom/Msat m_j + Ainh/Msat ∇²m_j
+//	m_j:  normalized magnetization of sublattice j
+//	Msat: saturation magnetization of sublattice i
+//	Ahom: homogeneous intersublattice exchange (J/m3)
+//	Ainh: inhomogeneous intersublattice exchange (J/m)
+//
+// see sublatticeexchange.cu
+func AddSublatticeExchange(B, mj *data.Slice, Msat, Ahom, Ainh MSlice, mesh *data.Mesh) {
+	c := mesh.CellSize()
+	wx := float32(1 / (c[X] * c[X]))
+	wy := float32(1 / (c[Y] * c[Y]))
+	wz := float32(1 / (c[Z] * c[Z]))
+	N := mesh.Size()
+	pbc := mesh.PBC_code()
+	cfg := make3DConf(N)
+	k_addsublatticeexchange_async(B.DevPtr(X), B.DevPtr(Y), B.DevPtr(Z),
+		mj.DevPtr(X), mj.DevPtr(Y), mj.DevPtr(Z),
+		Msat.DevPtr(0), Msat.Mul(0),
+		Ahom.DevPtr(0), Ahom.Mul(0),
+		Ainh.DevPtr(0), Ainh.Mul(0),
+		wx, wy, wz, N[X], N[Y], N[Z], pbc, cfg)
+}
@@ -0,0 +1,87 @@
+#include <stdint.h>
+#include "float3.h"
+#include "stencil.h"
+#include "amul.h"
+
+// Adds the exchange field on sublattice i due to sublattice j:
+// 	B_i += Ahom/Ms_i m_j + Ainh/Ms_i ∇²m_j
+// See sublattice.go for more details.
+extern "C" __global__ void
+addsublatticeexchange(float* __restrict__ Bx, float* __restrict__ By, float* __restrict__ Bz,
+                      float* __restrict__ mx, float* __restrict__ my, float* __restrict__ mz,
+                      float* __restrict__ Ms_, float Ms_mul,
+                      float* __restrict__ Ahom_, float Ahom_mul,
+                      float* __restrict__ Ainh_, float Ainh_mul,
+                      float wx, float wy, float wz, int Nx, int Ny, int Nz, uint8_t PBC) {
+
+    int ix = blockIdx.x * blockDim.x + threadIdx.x;
+    int iy = blockIdx.y * blockDim.y + threadIdx.y;
+    int iz = blockIdx.z * blockDim.z + threadIdx.z;
+
+    if (ix >= Nx || iy >= Ny || iz >= Nz) {
+        return;
+    }
+
+    // central cell
+    int I = idx(ix, iy, iz);
+    float3 m0 = make_float3(mx[I], my[I], mz[I]);
+
+    if (is0(m0)) {
+        return;
+    }
+
+    float Ahom = amul(Ahom_, Ahom_mul, I);
+    float Ainh = amul(Ainh_, Ainh_mul, I);
+    float3 B = Ahom * m0;
+
+    if (Ainh != 0.0f) {
+        float3 lap = make_float3(0.0, 0.0, 0.0);
+        int i_;    // neighbor index
+        float3 m_; // neighbor mag
+
+        // left neighbor
+        i_  = idx(lclampx(ix-1), iy, iz);
+        m_  = make_float3(mx[i_], my[i_], mz[i_]);
+        m_  = ( is0(m_)? m0: m_ );                  // free boundary
+        lap += wx * (m_ - m0);
+
+        // right neighbor
+        i_  = idx(hclampx(ix+1), iy, iz);
+        m_  = make_float3(mx[i_], my[i_], mz[i_]);
+        m_  = ( is0(m_)? m0: m_ );
+        lap += wx * (m_ - m0);
+
+        // back neighbor
+        i_  = idx(ix, lclampy(iy-1), iz);
+        m_  = make_float3(mx[i_], my[i_], mz[i_]);
+        m_  = ( is0(m_)? m0: m_ );
+        lap += wy * (m_ - m0);
+
+        // front neighbor
+        i_  = idx(ix, hclampy(iy+1), iz);
+        m_  = make_float3(mx[i_], my[i_], mz[i_]);
+        m_  = ( is0(m_)? m0: m_ );
+        lap += wy * (m_ - m0);
+
+        // only take vertical derivative for 3D sim
+        if (Nz != 1) {
+            // bottom neighbor
+            i_  = idx(ix, iy, lclampz(iz-1));
+            m_  = make_float3(mx[i_], my[i_], mz[i_]);
+            m_  = ( is0(m_)? m0: m_ );
+            lap += wz * (m_ - m0);
+
+            // top neighbor
+            i_  = idx(ix, iy, hclampz(iz+1));
+            m_  = make_float3(mx[i_], my[i_], mz[i_]);
+            m_  = ( is0(m_)? m0: m_ );
+            lap += wz * (m_ - m0);
+        }
+        B += Ainh * lap;
+    }
+
+    float invMs = inv_Msat(Ms_, Ms_mul, I);
+    Bx[I] += B.x*invMs;
+    By[I] += B.y*invMs;
+    Bz[I] += B.z*invMs;
+}
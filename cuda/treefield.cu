@@ -0,0 +1,97 @@
+#include "amul.h"
+#include "constants.h"
+
+// Demag field of the occupied cells of the demag tree (see mag/demagtree.go):
+// near leaves with the demag kernel K (size kx, ky, kz, wrapped offsets),
+// far nodes with the multipole expansion of their moments mom (see treeleafmoments.cu):
+// 	H_a = 1/4π (T_ab p_b - ∂_c T_ab Q_bc),  T_ab = ∂_a ∂_b 1/r
+// Empty cells are not written.
+extern "C" __global__ void
+treefield(float* __restrict__ Bx, float* __restrict__ By, float* __restrict__ Bz,
+          float* __restrict__ mx, float* __restrict__ my, float* __restrict__ mz,
+          float* __restrict__ vol, float* __restrict__ Ms_, float Ms_mul,
+          float* __restrict__ Kxx, float* __restrict__ Kyy, float* __restrict__ Kzz,
+          float* __restrict__ Kyz, float* __restrict__ Kxz, float* __restrict__ Kxy,
+          int kx, int ky, int kz,
+          float* __restrict__ mom, int* __restrict__ cells, int* __restrict__ cellLeaf,
+          int* __restrict__ nodeStart, int* __restrict__ nodeEnd,
+          int* __restrict__ nearStart, int* __restrict__ near,
+          int* __restrict__ farStart, int* __restrict__ far,
+          float* __restrict__ centerx, float* __restrict__ centery, float* __restrict__ centerz,
+          float csx, float csy, float csz, int Nx, int Ny, int Ncells, int Nnodes) {
+
+    int i =  ( blockIdx.y*gridDim.x + blockIdx.x ) * blockDim.x + threadIdx.x;
+    if (i >= Ncells) {
+        return;
+    }
+
+    int I = cells[i];
+    int ix = I % Nx;
+    int iy = (I / Nx) % Ny;
+    int iz = I / (Nx * Ny);
+    int leaf = cellLeaf[i];
+    float3 H = make_float3(0.0f, 0.0f, 0.0f);
+
+    // near field
+    for (int j = nearStart[leaf]; j < nearStart[leaf+1]; j++) {
+        int n = near[j];
+        for (int s = nodeStart[n]; s < nodeEnd[n]; s++) {
+            int S = cells[s];
+            int dx = ix - S % Nx;
+            int dy = iy - (S / Nx) % Ny;
+            int dz = iz - S / (Nx * Ny);
+            dx = (dx < 0)? (dx + kx): dx;
+            dy = (dy < 0)? (dy + ky): dy;
+            dz = (dz < 0)? (dz + kz): dz;
+            int k = (dz*ky + dy)*kx + dx;
+
+            float Ms = amul(Ms_, Ms_mul, S) * amul(vol, 1.0f, S);
+            float3 M = Ms * make_float3(mx[S], my[S], mz[S]);
+            H.x += Kxx[k]*M.x + Kxy[k]*M.y + Kxz[k]*M.z;
+            H.y += Kxy[k]*M.x + Kyy[k]*M.y + Kyz[k]*M.z;
+            H.z += Kxz[k]*M.x + Kyz[k]*M.y + Kzz[k]*M.z;
+        }
+    }
+
+    // far field
+    float r0[3] = {ix * csx, iy * csy, iz * csz};
+    for (int j = farStart[leaf]; j < farStart[leaf+1]; j++) {
+        int n = far[j];
+        float R[3] = {r0[0] - centerx[n], r0[1] - centery[n], r0[2] - centerz[n]};
+
+        float p[3], QR[3] = {0.0f, 0.0f, 0.0f}, QtR[3] = {0.0f, 0.0f, 0.0f};
+        float trQ = 0.0f, RQR = 0.0f;
+        for (int b = 0; b < 3; b++) {
+            p[b] = mom[b*Nnodes + n];
+            trQ += mom[(3 + 3*b + b)*Nnodes + n];
+            for (int c = 0; c < 3; c++) {
+                float Q = mom[(3 + 3*b + c)*Nnodes + n];
+                QR[b] += Q * R[c];
+                QtR[c] += Q * R[b];
+                RQR += R[b] * Q * R[c];
+            }
+        }
+
+        float r2 = R[0]*R[0] + R[1]*R[1] + R[2]*R[2];
+        float ir = rsqrtf(r2);
+        float ir2 = ir * ir;
+        float ir3 = ir * ir2;
+        float ir5 = ir3 * ir2;
+        float ir7 = ir5 * ir2;
+        float Rp = R[0]*p[0] + R[1]*p[1] + R[2]*p[2];
+
+        float h[3];
+        for (int a = 0; a < 3; a++) {
+            float dipole = 3.0f*R[a]*Rp*ir5 - p[a]*ir3;
+            float first = -15.0f*R[a]*RQR*ir7 + 3.0f*(QR[a] + QtR[a] + R[a]*trQ)*ir5;
+            h[a] = dipole - first;
+        }
+        H.x += h[0] * (float)(1.0/(4.0*PI));
+        H.y += h[1] * (float)(1.0/(4.0*PI));
+        H.z += h[2] * (float)(1.0/(4.0*PI));
+    }
+
+    Bx[I] = MU0 * H.x;
+    By[I] = MU0 * H.y;
+    Bz[I] = MU0 * H.z;
+}
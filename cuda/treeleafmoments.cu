@@ -0,0 +1,44 @@
+#include "amul.h"
+
+// Multipole moments of the leaves of the demag tree (see mag/demagtree.go):
+// total moment p = Σ m and first moments Q_bc = Σ m_b d_c, with m = Msat vol V m
+// and d the cell position relative to the leaf center, in units of the tree.
+// Stored as mom[k*Nnodes + leaf], k < 3: p_k, k = 3 + 3b + c: Q_bc.
+extern "C" __global__ void
+treeleafmoments(float* __restrict__ mom,
+                float* __restrict__ mx, float* __restrict__ my, float* __restrict__ mz,
+                float* __restrict__ vol, float* __restrict__ Ms_, float Ms_mul,
+                int* __restrict__ cells, int* __restrict__ nodeStart, int* __restrict__ nodeEnd,
+                float* __restrict__ centerx, float* __restrict__ centery, float* __restrict__ centerz,
+                float csx, float csy, float csz, float V, int Nx, int Ny, int Nleaves, int Nnodes) {
+
+    int n =  ( blockIdx.y*gridDim.x + blockIdx.x ) * blockDim.x + threadIdx.x;
+    if (n >= Nleaves) {
+        return;
+    }
+
+    float M[12];
+    for (int k = 0; k < 12; k++) {
+        M[k] = 0.0f;
+    }
+
+    for (int s = nodeStart[n]; s < nodeEnd[n]; s++) {
+        int I = cells[s];
+        float Ms = V * amul(Ms_, Ms_mul, I) * amul(vol, 1.0f, I);
+        float m[3] = {Ms * mx[I], Ms * my[I], Ms * mz[I]};
+        float d[3] = {(I % Nx) * csx - centerx[n],
+                      ((I / Nx) % Ny) * csy - centery[n],
+                      (I / (Nx * Ny)) * csz - centerz[n]
+                     };
+        for (int b = 0; b < 3; b++) {
+            M[b] += m[b];
+            for (int c = 0; c < 3; c++) {
+                M[3 + 3*b + c] += m[b] * d[c];
+            }
+        }
+    }
+
+    for (int k = 0; k < 12; k++) {
+        mom[k*Nnodes + n] = M[k];
+    }
+}
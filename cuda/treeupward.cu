@@ -0,0 +1,38 @@
+// Multipole moments of the demag tree nodes first <= n < last from the moments of their children,
+// shifted to the node center (see treeleafmoments.cu):
+// 	p = Σ p_child,  Q_bc = Σ Q_child_bc + p_child_b (center_child - center)_c
+extern "C" __global__ void
+treeupward(float* mom, int* __restrict__ nodeStart, int* __restrict__ nodeEnd,
+           float* __restrict__ centerx, float* __restrict__ centery, float* __restrict__ centerz,
+           int first, int last, int Nnodes) {
+
+    int n = first + ( blockIdx.y*gridDim.x + blockIdx.x ) * blockDim.x + threadIdx.x;
+    if (n >= last) {
+        return;
+    }
+
+    float M[12];
+    for (int k = 0; k < 12; k++) {
+        M[k] = 0.0f;
+    }
+
+    for (int ch = nodeStart[n]; ch < nodeEnd[n]; ch++) {
+        float d[3] = {centerx[ch] - centerx[n],
+                      centery[ch] - centery[n],
+                      centerz[ch] - centerz[n]
+                     };
+        for (int k = 0; k < 12; k++) {
+            M[k] += mom[k*Nnodes + ch];
+        }
+        for (int b = 0; b < 3; b++) {
+            float p = mom[b*Nnodes + ch];
+            for (int c = 0; c < 3; c++) {
+                M[3 + 3*b + c] += p * d[c];
+            }
+        }
+    }
+
+    for (int k = 0; k < 12; k++) {
+        mom[k*Nnodes + n] = M[k];
+    }
+}
@@ -3,6 +3,7 @@ package cuda
 import (
 	"fmt"
 	"github.com/mumax/3/cuda/cu"
+	"github.com/mumax/3/data"
 )
 
 // CUDA Launch parameters.
@@ -73,3 +74,9 @@ func checkSize(a interface {
 		}
 	}
 }
+
+// inverse cell size, for finite differences
+func invCellSize(mesh *data.Mesh) [3]float32 {
+	c := mesh.CellSize()
+	return [3]float32{float32(1 / c[X]), float32(1 / c[Y]), float32(1 / c[Z])}
+}
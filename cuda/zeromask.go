@@ -7,11 +7,16 @@ import (
 )
 
 // Sets vector dst to zero where mask != 0.
-func ZeroMask(dst *data.Slice, mask LUTPtr, regions *Bytes) {
+func ZeroMask(dst *data.Slice, mask LUTPtr, regions RegionMap) {
 	N := dst.Len()
 	cfg := make1DConf(N)
 
+	r, wide := regions.regionPtr()
 	for c := 0; c < dst.NComp(); c++ {
-		k_zeromask_async(dst.DevPtr(c), unsafe.Pointer(mask), regions.Ptr, N, cfg)
+		if wide {
+			k_zeromask16_async(dst.DevPtr(c), unsafe.Pointer(mask), r, N, cfg)
+		} else {
+			k_zeromask_async(dst.DevPtr(c), unsafe.Pointer(mask), r, N, cfg)
+		}
 	}
 }
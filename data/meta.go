@@ -7,4 +7,5 @@ type Meta struct {
 	Time, TimeStep float64
 	CellSize       [3]float64
 	MeshUnit       string
+	LayerThickness []float64 // thickness of each layer if non-uniform along z, nil otherwise
 }
@@ -76,6 +76,11 @@ func AddAnisotropyField(dst *data.Slice) {
 
 // Add the anisotropy energy density to dst
 func AddAnisotropyEnergyDensity(dst *data.Slice) {
+	addAnisotropyEnergyDensityFrom(dst, M, Msat, Ku1, Ku2, Kc1, Kc2, Kc3, AnisU)
+}
+
+// Add the anisotropy energy density of magnetization M to dst.
+func addAnisotropyEnergyDensityFrom(dst *data.Slice, M magnetization, Msat, Ku1, Ku2, Kc1, Kc2, Kc3 *RegionwiseScalar, AnisU *RegionwiseVector) {
 	haveUnixial := Ku1.nonZero() || Ku2.nonZero()
 	haveCubic := Kc1.nonZero() || Kc2.nonZero() || Kc3.nonZero()
 
@@ -87,8 +92,9 @@ func AddAnisotropyEnergyDensity(dst *data.Slice) {
 	defer cuda.Recycle(buf)
 
 	// unnormalized magnetization:
-	Mf := ValueOf(M_full)
+	Mf := cuda.Buffer(3, Mesh().Size())
 	defer cuda.Recycle(Mf)
+	setMFullFrom(Mf, M, Msat)
 
 	if haveUnixial {
 		// 1st
@@ -0,0 +1,164 @@
+package engine
+
+// Static Oersted field profiles of current-carrying conductors (striplines,
+// coplanar waveguides, wires), for spin-wave excitation with B_ext.Add(mask, I(t)).
+// The masks hold the field in Tesla per Ampère, so I(t) is the current in A.
+//
+// Conductors with a rectangular cross-section are treated as infinitely long,
+// with uniform current density. Their field follows from integrating the field
+// of a line current over the cross-section (in local coordinates u across the
+// width, v along the thickness):
+// 	Bu =  μ0 J / 2π ∫∫ v / (u²+v²)
+// 	Bv = -μ0 J / 2π ∫∫ u / (u²+v²)
+// which has a closed form, see rectIntegral.
+
+import (
+	"math"
+
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/mag"
+	"github.com/mumax/3/util"
+)
+
+func init() {
+	DeclFunc("StriplineField", StriplineField, "Oersted field mask (T/A) of a long conductor with rectangular cross-section "+
+		"(width, thickness in m), centered at position, carrying current along direction. Use with B_ext.Add(mask, I(t))")
+	DeclFunc("CPWField", CPWField, "Oersted field mask (T/A) of a coplanar waveguide with given signal line width, gap, "+
+		"ground line width and thickness (m), centered at position, along direction. "+
+		"The current returns through both ground lines. Use with B_ext.Add(mask, I(t))")
+	DeclFunc("WireField", WireField, "Oersted field mask (T/A) of a thin straight wire carrying current from p1 to p2 (m). "+
+		"Use with B_ext.Add(mask, I(t))")
+}
+
+// StriplineField returns the field per unit current of an infinitely long conductor
+// along direction, with given width (in the plane perpendicular to z) and thickness.
+func StriplineField(width, thickness float64, position, direction data.Vector) *data.Slice {
+	util.Argument(width > 0 && thickness > 0)
+	c := newConductorFrame(position, direction)
+	c.addStrip(0, width, thickness, 1)
+	return c.mask()
+}
+
+// CPWField returns the field per unit current of a coplanar waveguide:
+// a signal line flanked by two ground lines, separated by gaps.
+// Half of the current returns through each ground line.
+func CPWField(signal, gap, ground, thickness float64, position, direction data.Vector) *data.Slice {
+	util.Argument(signal > 0 && gap >= 0 && ground > 0 && thickness > 0)
+	c := newConductorFrame(position, direction)
+	offset := signal/2 + gap + ground/2
+	c.addStrip(0, signal, thickness, 1)
+	c.addStrip(-offset, ground, thickness, -0.5)
+	c.addStrip(offset, ground, thickness, -0.5)
+	return c.mask()
+}
+
+// WireField returns the field per unit current of a thin wire segment from p1 to p2,
+// according to the Biot-Savart law. The field is zero on the wire itself.
+func WireField(p1, p2 data.Vector) *data.Slice {
+	l := p2.Sub(p1)
+	util.Argument(l.Len() > 0)
+	e := l.Div(l.Len())
+	return fieldMask(func(r data.Vector) data.Vector {
+		a := r.Sub(p1)
+		b := r.Sub(p2)
+		perp := a.MAdd(-a.Dot(e), e) // from wire to r
+		ρ := perp.Len()
+		if ρ == 0 || a.Len() == 0 || b.Len() == 0 {
+			return data.Vector{0, 0, 0}
+		}
+		cos1 := a.Dot(e) / a.Len()
+		cos2 := b.Dot(e) / b.Len()
+		B := mag.Mu0 / (4 * math.Pi * ρ) * (cos1 - cos2)
+		return e.Cross(perp).Mul(B / ρ)
+	})
+}
+
+// Local frame of a set of parallel conductors:
+// l along the current, u across the width (in-plane), v along the thickness.
+type conductorFrame struct {
+	center  data.Vector
+	l, u, v data.Vector
+	strips  []strip
+}
+
+// conductor with rectangular cross-section, centered at u0 across the width.
+type strip struct {
+	u0, width, thickness, current float64
+}
+
+func newConductorFrame(center, direction data.Vector) *conductorFrame {
+	util.Argument(direction.Len() > 0)
+	l := direction.Div(direction.Len())
+	u := l.Cross(data.Vector{0, 0, 1})
+	if u.Len() < 1e-6 { // current along z: width along x
+		u = data.Vector{1, 0, 0}
+	}
+	u = u.Div(u.Len())
+	v := u.Cross(l)
+	return &conductorFrame{center: center, l: l, u: u, v: v}
+}
+
+func (c *conductorFrame) addStrip(u0, width, thickness, current float64) {
+	c.strips = append(c.strips, strip{u0, width, thickness, current})
+}
+
+func (c *conductorFrame) mask() *data.Slice {
+	return fieldMask(func(r data.Vector) data.Vector {
+		d := r.Sub(c.center)
+		u, v := d.Dot(c.u), d.Dot(c.v)
+		var Bu, Bv float64
+		for _, s := range c.strips {
+			bu, bv := s.field(u, v)
+			Bu += bu
+			Bv += bv
+		}
+		return c.u.Mul(Bu).MAdd(Bv, c.v)
+	})
+}
+
+// field components across the width (u) and along the thickness (v) at position u, v.
+func (s *strip) field(u, v float64) (Bu, Bv float64) {
+	J := s.current / (s.width * s.thickness)
+	u1, u2 := u-s.u0-s.width/2, u-s.u0+s.width/2
+	v1, v2 := v-s.thickness/2, v+s.thickness/2
+	pre := mag.Mu0 * J / (2 * math.Pi)
+	Bu = pre * rectIntegral(u1, u2, v1, v2, false)
+	Bv = -pre * rectIntegral(u1, u2, v1, v2, true)
+	return
+}
+
+// Integral of v/(u²+v²) (or u/(u²+v²) if swap) over u in [u1, u2], v in [v1, v2],
+// from the primitive G(u,v) = u/2 ln(u²+v²) + v atan(u/v).
+func rectIntegral(u1, u2, v1, v2 float64, swap bool) float64 {
+	G := func(u, v float64) float64 {
+		if swap {
+			u, v = v, u
+		}
+		g := 0.
+		if u != 0 {
+			g += 0.5 * u * math.Log(u*u+v*v)
+		}
+		if v != 0 {
+			g += v * math.Atan(u/v)
+		}
+		return g
+	}
+	return G(u2, v2) - G(u1, v2) - G(u2, v1) + G(u1, v1)
+}
+
+// evaluate the field B(r) (T/A) in all cell centers.
+func fieldMask(B func(r data.Vector) data.Vector) *data.Slice {
+	n := MeshSize()
+	mask := data.NewSlice(3, n)
+	for iz := 0; iz < n[Z]; iz++ {
+		for iy := 0; iy < n[Y]; iy++ {
+			for ix := 0; ix < n[X]; ix++ {
+				b := B(Index2Coord(ix, iy, iz))
+				for c := 0; c < 3; c++ {
+					mask.Set(c, ix, iy, iz, b[c])
+				}
+			}
+		}
+	}
+	return mask
+}
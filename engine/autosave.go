@@ -25,6 +25,12 @@ func DoOutput() {
 	if Table.needSave() {
 		Table.Save()
 	}
+	if tracker.needSave() {
+		tracker.Save()
+	}
+	if blochTable.needSave() {
+		blochTable.Save()
+	}
 }
 
 // Register quant to be auto-saved every period.
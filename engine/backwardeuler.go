@@ -17,16 +17,16 @@ func (s *BackwardEuler) Step() {
 
 	t0 := Time
 
-	y := M.Buffer()
+	y := solverState()
 
-	y0 := cuda.Buffer(VECTOR, y.Size())
+	y0 := cuda.Buffer(y.NComp(), y.Size())
 	defer cuda.Recycle(y0)
 	data.Copy(y0, y)
 
-	dy0 := cuda.Buffer(VECTOR, y.Size())
+	dy0 := cuda.Buffer(y.NComp(), y.Size())
 	defer cuda.Recycle(dy0)
 	if s.dy1 == nil {
-		s.dy1 = cuda.Buffer(VECTOR, y.Size())
+		s.dy1 = cuda.Buffer(y.NComp(), y.Size())
 	}
 	dy1 := s.dy1
 
@@ -40,17 +40,17 @@ func (s *BackwardEuler) Step() {
 	// with temperature, previous torque cannot be used as predictor
 	if Temp.isZero() {
 		cuda.Madd2(y, y0, dy1, 1, dt) // predictor euler step with previous torque
-		M.normalize()
+		normalizeState()
 	}
 
 	torqueFn(dy0)
 	cuda.Madd2(y, y0, dy0, 1, dt) // y = y0 + dt * dy
-	M.normalize()
+	normalizeState()
 
 	// One iteration
 	torqueFn(dy1)
 	cuda.Madd2(y, y0, dy1, 1, dt) // y = y0 + dt * dy1
-	M.normalize()
+	normalizeState()
 
 	Time = t0 + Dt_si
 
@@ -0,0 +1,601 @@
+package engine
+
+// Separate magnetic bodies, each on its own mesh, coupled to the magnet and to each other by their stray field.
+//
+// Up to MAXBODY bodies (e.g. a distant fixed layer or an MFM tip) are defined with SetBody,
+// with their own grid, cell size and position. Body i has its own magnetization m_body<i>
+// and uniform parameters Msat_body<i>, Aex_body<i>, alpha_body<i>, Ku1_body<i>, anisU_body<i> and Temp_body<i>.
+// It feels its own demag, exchange and uniaxial anisotropy field, its thermal field,
+// the external field of region 0 plus the masks of B_ext (taken from the nearest cell of the magnet's mesh),
+// and the stray field of the magnet and of the other bodies. The magnet feels the stray field
+// of all bodies (B_bodies), with energy E_bodies, which is part of E_total.
+//
+// The stray field of one mesh on another is the convolution with an offset kernel (mag.CalcOffsetKernel)
+// on a lattice with the source cell size spanning the destination mesh, interpolated to the
+// destination cells (exactly when both cell sizes are equal). So memory scales with the size of
+// the meshes, not with their bounding box.
+// Bodies are advanced after every time step of the magnet, all together, in adaptive Heun sub-steps
+// with an error below MaxErr (or steps of FixDt). The torques on all bodies are evaluated before any
+// of them is updated. Bodies with frozen_body<i> set are not advanced.
+// Bodies cannot be combined with macrospins (DefMacrospin), whose mesh does not describe their positions.
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/mumax/3/cuda"
+	"github.com/mumax/3/cuda/curand"
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/mag"
+	"github.com/mumax/3/util"
+)
+
+const MAXBODY = 3 // maximum number of bodies
+
+var (
+	bodies       [MAXBODY]*body
+	bodiesTime   float64                             // time up to which the bodies have been advanced
+	bodiesDt     float64                             // sub-step of the bodies, adapted to MaxErr
+	couplings    [MAXBODY + 1][MAXBODY + 1]*coupling // [source][destination], 0 is the magnet, i+1 body i
+	B_bodies     = NewVectorField("B_bodies", "T", "Stray field of all bodies on the magnet", SetBodiesField)
+	Edens_bodies = NewScalarField("Edens_bodies", "J/m3", "Energy density of the magnet in the stray field of the bodies", AddBodiesEnergyDensity)
+	E_bodies     = NewScalarValue("E_bodies", "J", "Energy of the magnet in the stray field of the bodies", GetBodiesEnergy)
+)
+
+var AddBodiesEnergyDensity = makeEdensAdder(&B_bodies, -1)
+
+func init() {
+	DeclFunc("SetBody", SetBody, "Defines body i (1-3) with its own grid size, cell size and center position")
+	registerEnergy(GetBodiesEnergy, AddBodiesEnergyDensity)
+	for i := range bodies {
+		bodies[i] = newBody(i)
+	}
+}
+
+// One magnetic body on its own mesh.
+type body struct {
+	index                 int
+	mesh                  data.Mesh
+	center                data.Vector
+	m                     bodyMagnetization
+	mbuf                  *data.Slice // magnetization, nil if the body is not defined
+	B_eff                 *bodyField
+	Msat, Alpha, Aex, Ku1 *RegionwiseScalar
+	Temp                  *RegionwiseScalar
+	AnisU                 *RegionwiseVector
+	lex2                  exchParam
+	frozen                bool
+	regions               cuda.RegionMap // all region 0, for the exchange lookup
+	demag                 *cuda.DemagConvolution
+	noise                 *data.Slice // standard normal thermal noise of the current sub-step
+	extMasks              []bodyMask  // masks of B_ext on the body mesh
+}
+
+// mask of B_ext sampled on the mesh of a body
+type bodyMask struct {
+	src *data.Slice // mask on the magnet's mesh
+	key string      // body mesh, position and window shift for which dst was sampled
+	dst *data.Slice // mask on the body mesh
+}
+
+// declares the magnetization and parameters of body i+1
+func newBody(i int) *body {
+	n := fmt.Sprint("_body", i+1)
+	which := fmt.Sprint(" of body ", i+1)
+	b := &body{index: i}
+	b.m = bodyMagnetization{b}
+	b.B_eff = &bodyField{b, "B_eff" + n, b.setEffectiveField}
+	b.Msat = NewScalarParam("Msat"+n, "A/m", "Saturation magnetization"+which)
+	b.Alpha = NewScalarParam("alpha"+n, "", "Landau-Lifshitz damping constant"+which)
+	b.Aex = NewScalarParam("Aex"+n, "J/m", "Exchange stiffness"+which, &b.lex2)
+	b.Ku1 = NewScalarParam("Ku1"+n, "J/m3", "1st order uniaxial anisotropy constant"+which)
+	b.AnisU = NewVectorParam("anisU"+n, "", "Uniaxial anisotropy direction"+which)
+	b.Temp = NewScalarParam("Temp"+n, "K", "Temperature"+which)
+	b.lex2.init(b.Aex)
+	DeclLValue("m"+n, &b.m, "Reduced magnetization"+which+" (unit length)")
+	Export(b.B_eff, "Effective field"+which)
+	DeclVar("frozen"+n, &b.frozen, "Keeps the magnetization"+which+" fixed (default=false)")
+	return b
+}
+
+// Defines body i (1-3) as a mesh of Nx x Ny x Nz cells of given size,
+// centered at the given position (relative to the center of the magnet's mesh).
+func SetBody(i, Nx, Ny, Nz int, cellSizeX, cellSizeY, cellSizeZ float64, center data.Vector) {
+	if i < 1 || i > MAXBODY {
+		util.Fatal("SetBody: need body 1-", MAXBODY, ", have: ", i)
+	}
+	arg("GridSize", Nx > 0 && Ny > 0 && Nz > 0)
+	arg("CellSize", cellSizeX > 0 && cellSizeY > 0 && cellSizeZ > 0)
+	if macrospinMode() {
+		util.Fatal("SetBody: not possible with macrospins (DefMacrospin), their mesh is not their geometry")
+	}
+	checkLayers("SetBody")
+	b := bodies[i-1]
+	b.free()
+	b.mesh = *data.NewMesh(Nx, Ny, Nz, cellSizeX, cellSizeY, cellSizeZ)
+	b.center = center
+	b.m.alloc()
+	if regions.wide() {
+		b.regions = cuda.NewShorts(b.mesh.NCell())
+	} else {
+		b.regions = cuda.NewBytes(b.mesh.NCell())
+	}
+}
+
+func (b *body) enabled() bool { return b.mbuf != nil }
+
+func (b *body) free() {
+	if !b.enabled() {
+		return
+	}
+	b.mbuf.Free()
+	b.mbuf = nil
+	switch r := b.regions.(type) {
+	case *cuda.Bytes:
+		r.Free()
+	case *cuda.Shorts:
+		r.Free()
+	}
+	b.regions = nil
+	b.demag.Free()
+	b.demag = nil
+	b.noise.Free()
+	b.noise = nil
+	for _, k := range b.extMasks {
+		k.dst.Free()
+	}
+	b.extMasks = nil
+	for i := range couplings {
+		couplings[i][b.index+1].free()
+		couplings[i][b.index+1] = nil
+		couplings[b.index+1][i].free()
+		couplings[b.index+1][i] = nil
+	}
+}
+
+func haveBodies() bool {
+	for _, b := range bodies {
+		if b.enabled() {
+			return true
+		}
+	}
+	return false
+}
+
+// position of the center of the first cell of the body
+func (b *body) origin() data.Vector {
+	return meshOrigin(&b.mesh).Add(b.center)
+}
+
+// position of the center of the first cell of a mesh centered at 0
+func meshOrigin(m *data.Mesh) data.Vector {
+	n, c := m.Size(), m.CellSize()
+	var o data.Vector
+	for i := range o {
+		o[i] = -0.5 * float64(n[i]-1) * c[i]
+	}
+	return o
+}
+
+// uniform parameter p on the body mesh
+func (b *body) param(p *regionwise) cuda.MSlice {
+	util.AssertMsg(p.IsUniform(), p.Name()+": parameters of bodies should be uniform")
+	return cuda.MakeMSlice(data.NilSlice(p.NComp(), b.mesh.Size()), p.getRegion(0))
+}
+
+// Sets dst to the effective field on the body.
+func (b *body) setEffectiveField(dst *data.Slice) {
+	m := b.m.Buffer()
+	msat := b.param(&b.Msat.regionwise)
+	unit := cuda.MakeMSlice(data.NilSlice(1, b.mesh.Size()), []float64{1})
+
+	if EnableDemag {
+		b.demagConv().Exec(dst, m, data.NilSlice(1, b.mesh.Size()), msat)
+	} else {
+		cuda.Zero(dst)
+	}
+	cuda.AddExchange(dst, m, b.lex2.Gpu(), msat, b.regions, &b.mesh, data.NilSlice(1, b.mesh.Size()))
+	if b.Ku1.nonZero() {
+		zero := cuda.MakeMSlice(data.NilSlice(1, b.mesh.Size()), []float64{0})
+		cuda.AddUniaxialAnisotropy2(dst, m, msat, b.param(&b.Ku1.regionwise), zero, b.param(&b.AnisU.regionwise))
+	}
+	if !B_ext.perRegion.isZero() {
+		cuda.RegionAddV(dst, B_ext.perRegion.gpuLUT(), b.regions)
+	}
+	for i, t := range B_ext.extraTerms {
+		var mul float32 = 1
+		if t.mul != nil {
+			mul = float32(t.mul())
+		}
+		cuda.Madd2(dst, dst, b.extMask(i, t.mask), 1, mul)
+	}
+	if EnableDemag {
+		Mfull := cuda.Buffer(VECTOR, Mesh().Size())
+		defer cuda.Recycle(Mfull)
+		setMagnetMoment(Mfull)
+		getCoupling(0, b.index+1).addField(dst, Mfull, geometry.Gpu(), unit)
+		for _, o := range bodies {
+			if o != b && o.enabled() {
+				getCoupling(o.index+1, b.index+1).addField(dst, o.m.Buffer(), data.NilSlice(1, o.mesh.Size()), o.param(&o.Msat.regionwise))
+			}
+		}
+	}
+}
+
+// Returns the mask of the i-th extra term of B_ext on the body mesh:
+// each body cell takes the value of the nearest cell of the magnet's mesh.
+// Resampled when the mask, the body or the simulation window changes.
+func (b *body) extMask(i int, src *data.Slice) *data.Slice {
+	for len(b.extMasks) <= i {
+		b.extMasks = append(b.extMasks, bodyMask{})
+	}
+	k := &b.extMasks[i]
+	key := fmt.Sprint(b.mesh, b.center, TotalShift, TotalYShift)
+	if k.src == src && k.key == key {
+		return k.dst
+	}
+	k.dst.Free()
+
+	mask := src.HostCopy().Vectors()
+	n, c := Mesh().Size(), Mesh().CellSize()
+	bn, bc := b.mesh.Size(), b.mesh.CellSize()
+	o := b.origin()
+	nearest := func(x, c float64, n int) int {
+		return clampIndex(int(math.Floor(x/c+0.5*float64(n-1)+0.5)), n)
+	}
+	host := data.NewSlice(3, bn)
+	h := host.Vectors()
+	for iz := 0; iz < bn[Z]; iz++ {
+		z := o[Z] + float64(iz)*bc[Z]
+		jz := nearest(z, c[Z], n[Z])
+		if layered() {
+			jz = clampIndex(int(math.Floor(layerIndex(z)+0.5)), n[Z])
+		}
+		for iy := 0; iy < bn[Y]; iy++ {
+			jy := nearest(o[Y]+float64(iy)*bc[Y], c[Y], n[Y])
+			for ix := 0; ix < bn[X]; ix++ {
+				jx := nearest(o[X]+float64(ix)*bc[X], c[X], n[X])
+				for m := range h {
+					h[m][iz][iy][ix] = mask[m][jz][jy][jx]
+				}
+			}
+		}
+	}
+	*k = bodyMask{src: src, key: key, dst: cuda.GPUCopy(host)}
+	return k.dst
+}
+
+// clamps index i to [0, n-1]
+func clampIndex(i, n int) int {
+	if i < 0 {
+		return 0
+	}
+	if i >= n {
+		return n - 1
+	}
+	return i
+}
+
+// Sets dst to the torque on the body, including the thermal field for a time step dt.
+func (b *body) setTorque(dst *data.Slice, dt float64) {
+	b.setEffectiveField(dst)
+	b.addThermalField(dst, dt)
+	alpha := b.param(&b.Alpha.regionwise)
+	if Precess {
+		cuda.LLTorque(dst, b.m.Buffer(), dst, alpha)
+	} else {
+		cuda.LLNoPrecess(dst, b.m.Buffer(), dst)
+	}
+}
+
+// Adds the thermal field for a time step dt to dst, from the noise drawn by newNoise.
+func (b *body) addThermalField(dst *data.Slice, dt float64) {
+	if !b.Temp.nonZero() {
+		return
+	}
+	if b.noise == nil {
+		b.newNoise()
+	}
+	c := b.mesh.CellSize()
+	k2_VgammaDt := 2 * mag.Kb / (GammaLL * c[X] * c[Y] * c[Z] * dt)
+	Bth := cuda.Buffer(1, b.mesh.Size())
+	defer cuda.Recycle(Bth)
+	msat := b.param(&b.Msat.regionwise)
+	temp := b.param(&b.Temp.regionwise)
+	alpha := b.param(&b.Alpha.regionwise)
+	for i := 0; i < 3; i++ {
+		cuda.SetTemperature(Bth, b.noise.Comp(i), k2_VgammaDt, msat, temp, alpha)
+		cuda.Add(dst.Comp(i), dst.Comp(i), Bth)
+	}
+}
+
+// Draws the thermal noise for the next sub-step.
+// It is kept when a sub-step is undone, the thermal field is rescaled with its length instead.
+func (b *body) newNoise() {
+	if !b.Temp.nonZero() {
+		return
+	}
+	if b.noise == nil {
+		b.noise = cuda.NewSlice(3, b.mesh.Size())
+	}
+	if B_therm.generator == 0 {
+		B_therm.generator = curand.CreateGenerator(curand.PSEUDO_DEFAULT)
+		B_therm.generator.SetSeed(B_therm.seed)
+	}
+	N := int64(b.mesh.NCell())
+	for c := 0; c < 3; c++ {
+		B_therm.generator.GenerateNormal(uintptr(b.noise.DevPtr(c)), N, 0, 1)
+	}
+}
+
+// Advances all bodies up to the current time, called after each time step of the magnet.
+func stepBodies() {
+	if !haveBodies() {
+		return
+	}
+	if Time <= bodiesTime {
+		bodiesTime = Time // time was reset
+		return
+	}
+	var free []*body
+	for _, b := range bodies {
+		if b.enabled() && !b.frozen {
+			free = append(free, b)
+		}
+	}
+	for len(free) != 0 && bodiesTime < Time {
+		if FixDt != 0 {
+			bodiesDt = FixDt
+		}
+		if bodiesDt <= 0 {
+			bodiesDt = Time - bodiesTime
+		}
+		dt := bodiesDt
+		last := dt >= Time-bodiesTime
+		if last {
+			dt = Time - bodiesTime
+		}
+		ok, err := heunBodies(free, dt)
+		if ok {
+			if last {
+				bodiesTime = Time
+			} else {
+				bodiesTime += dt
+			}
+			for _, b := range free {
+				b.newNoise()
+			}
+		}
+		corr := math.Min(math.Max(Headroom*math.Sqrt(MaxErr/err), 0.5), 2) // 2 for err == 0
+		// don't grow from a truncated last step
+		if !(ok && last && corr > 1) {
+			bodiesDt = dt * corr
+		}
+		if MaxDt != 0 && bodiesDt > MaxDt {
+			bodiesDt = MaxDt
+		}
+		if MinDt != 0 && bodiesDt < MinDt {
+			bodiesDt = MinDt
+		}
+	}
+	bodiesTime = Time
+}
+
+// One Heun step dt of the given bodies together: all torques of a stage are evaluated
+// before any body is updated. Returns whether the step was accepted (error below MaxErr),
+// otherwise the bodies are restored, and the error.
+func heunBodies(free []*body, dt float64) (bool, float64) {
+	n := len(free)
+	m0 := make([]*data.Slice, n)
+	t0 := make([]*data.Slice, n)
+	t1 := make([]*data.Slice, n)
+	for i, b := range free {
+		size := b.mesh.Size()
+		m0[i] = cuda.Buffer(VECTOR, size)
+		defer cuda.Recycle(m0[i])
+		t0[i] = cuda.Buffer(VECTOR, size)
+		defer cuda.Recycle(t0[i])
+		t1[i] = cuda.Buffer(VECTOR, size)
+		defer cuda.Recycle(t1[i])
+		data.Copy(m0[i], b.m.Buffer())
+	}
+
+	h := float32(dt * GammaLL)
+	for i, b := range free {
+		b.setTorque(t0[i], dt)
+	}
+	for i, b := range free {
+		cuda.Madd2(b.m.Buffer(), m0[i], t0[i], 1, h)
+		b.m.normalize()
+	}
+	for i, b := range free {
+		b.setTorque(t1[i], dt)
+	}
+
+	err := 0.
+	for i := range free {
+		err = math.Max(err, cuda.MaxVecDiff(t0[i], t1[i])*float64(h))
+	}
+	ok := err < MaxErr || dt <= MinDt || FixDt != 0 // mindt check to avoid infinite loop
+	for i, b := range free {
+		if ok {
+			cuda.Madd3(b.m.Buffer(), m0[i], t0[i], t1[i], 1, 0.5*h, 0.5*h)
+			b.m.normalize()
+		} else {
+			data.Copy(b.m.Buffer(), m0[i])
+		}
+	}
+	return ok, err
+}
+
+// Sets dst to the stray field of all bodies on the magnet.
+func SetBodiesField(dst *data.Slice) {
+	cuda.Zero(dst)
+	AddBodiesField(dst)
+}
+
+// Returns the energy of the magnet in the stray field of the bodies, in J.
+// The field is not generated by m, so the energy is Zeeman-like.
+func GetBodiesEnergy() float64 {
+	if !haveBodies() || !EnableDemag {
+		return 0
+	}
+	return -1 * cellVolume() * dot(&M_full, &B_bodies)
+}
+
+// Adds the stray field of all bodies on the magnet to dst.
+func AddBodiesField(dst *data.Slice) {
+	if !EnableDemag {
+		return
+	}
+	for _, b := range bodies {
+		if b.enabled() {
+			getCoupling(b.index+1, 0).addField(dst, b.m.Buffer(), data.NilSlice(1, b.mesh.Size()), b.param(&b.Msat.regionwise))
+		}
+	}
+}
+
+// returns the body's demag convolution, making sure it's initialized
+func (b *body) demagConv() *cuda.DemagConvolution {
+	if b.demag == nil {
+		SetBusy(true)
+		defer SetBusy(false)
+		kernel := mag.DemagKernel(b.mesh.Size(), b.mesh.PBC(), b.mesh.CellSize(), DemagAccuracy, *Flag_cachedir)
+		b.demag = cuda.NewDemag(b.mesh.Size(), b.mesh.PBC(), kernel, *Flag_selftest)
+	}
+	return b.demag
+}
+
+// Stray field of one mesh on another.
+type coupling struct {
+	src, dst         data.Mesh
+	srcOrig, dstOrig data.Vector
+	lattice          [3]int     // size of the field lattice, with the source cell size
+	step             [3]float64 // destination cell size in units of source cells
+	conv             *cuda.OffsetConvolution
+}
+
+// mesh and first cell position of the magnet (0) or body i-1
+func meshAndOrigin(i int) (data.Mesh, data.Vector) {
+	if i == 0 {
+		return *Mesh(), meshOrigin(Mesh())
+	}
+	b := bodies[i-1]
+	return b.mesh, b.origin()
+}
+
+// returns the coupling from mesh src to mesh dst (0 is the magnet, i+1 body i),
+// (re-)initialized when either has changed.
+func getCoupling(src, dst int) *coupling {
+	sm, so := meshAndOrigin(src)
+	dm, do := meshAndOrigin(dst)
+	c := couplings[src][dst]
+	if c != nil && c.src == sm && c.dst == dm && c.srcOrig == so && c.dstOrig == do {
+		return c
+	}
+	c.free()
+	c = newCoupling(sm, dm, so, do)
+	couplings[src][dst] = c
+	return c
+}
+
+func newCoupling(src, dst data.Mesh, srcOrig, dstOrig data.Vector) *coupling {
+	SetBusy(true)
+	defer SetBusy(false)
+	c := &coupling{src: src, dst: dst, srcOrig: srcOrig, dstOrig: dstOrig}
+	cs, cd, nd := src.CellSize(), dst.CellSize(), dst.Size()
+	for i := range c.lattice {
+		c.step[i] = cd[i] / cs[i]
+		c.lattice[i] = int(math.Ceil(float64(nd[i]-1)*c.step[i]-1e-6)) + 1
+	}
+	kernel := mag.CalcOffsetKernel(src.Size(), c.lattice, cs, dstOrig.Sub(srcOrig), DemagAccuracy)
+	c.conv = cuda.NewOffsetConvolution(src.Size(), c.lattice, kernel, *Flag_selftest)
+	return c
+}
+
+// Adds the stray field of magnetization m * vol * msat on the source mesh to dst, on the destination mesh.
+func (c *coupling) addField(dst, m, vol *data.Slice, msat cuda.MSlice) {
+	B := cuda.Buffer(VECTOR, c.lattice)
+	defer cuda.Recycle(B)
+	c.conv.Exec(B, m, vol, msat)
+	cuda.InterpolateAdd(dst, B, c.step)
+}
+
+func (c *coupling) free() {
+	if c != nil {
+		c.conv.Free()
+	}
+}
+
+// Magnetization of a body.
+type bodyMagnetization struct {
+	b *body
+}
+
+func (m *bodyMagnetization) Mesh() *data.Mesh        { m.checkAlloc(); return &m.b.mesh }
+func (m *bodyMagnetization) NComp() int              { return 3 }
+func (m *bodyMagnetization) Name() string            { return fmt.Sprint("m_body", m.b.index+1) }
+func (m *bodyMagnetization) Unit() string            { return "" }
+func (m *bodyMagnetization) Buffer() *data.Slice     { m.checkAlloc(); return m.b.mbuf }
+func (m *bodyMagnetization) EvalTo(dst *data.Slice)  { data.Copy(dst, m.Buffer()) }
+func (m *bodyMagnetization) average() []float64      { return sAverageUniverse(m.Buffer()) }
+func (m *bodyMagnetization) Average() data.Vector    { return unslice(m.average()) }
+func (m *bodyMagnetization) Comp(c int) ScalarField  { return Comp(m, c) }
+func (m *bodyMagnetization) SetValue(v interface{})  { m.Set(v.(Config)) }
+func (m *bodyMagnetization) InputType() reflect.Type { return reflect.TypeOf(Config(nil)) }
+func (m *bodyMagnetization) Type() reflect.Type      { return reflect.TypeOf(new(bodyMagnetization)) }
+func (m *bodyMagnetization) Eval() interface{}       { return m }
+func (m *bodyMagnetization) normalize() {
+	cuda.Normalize(m.Buffer(), data.NilSlice(1, m.b.mesh.Size()))
+}
+
+func (m *bodyMagnetization) checkAlloc() {
+	if !m.b.enabled() {
+		util.Fatal(m.Name(), ": body not defined, use SetBody first")
+	}
+}
+
+func (m *bodyMagnetization) alloc() {
+	m.b.mbuf = cuda.NewSlice(3, m.b.mesh.Size())
+	m.Set(RandomMag())
+}
+
+// Sets the magnetization to config c, in coordinates relative to the body center.
+func (m *bodyMagnetization) Set(c Config) {
+	host := data.NewSlice(3, m.b.mesh.Size())
+	h := host.Vectors()
+	n, cell := m.b.mesh.Size(), m.b.mesh.CellSize()
+	o := meshOrigin(&m.b.mesh)
+	for iz := 0; iz < n[Z]; iz++ {
+		for iy := 0; iy < n[Y]; iy++ {
+			for ix := 0; ix < n[X]; ix++ {
+				v := c(o[X]+float64(ix)*cell[X], o[Y]+float64(iy)*cell[Y], o[Z]+float64(iz)*cell[Z])
+				for i := range h {
+					h[i][iz][iy][ix] = float32(v[i])
+				}
+			}
+		}
+	}
+	data.Copy(m.Buffer(), host)
+	m.normalize()
+}
+
+// A vector field on the mesh of a body.
+type bodyField struct {
+	b    *body
+	name string
+	f    func(dst *data.Slice)
+}
+
+func (q *bodyField) Mesh() *data.Mesh       { return q.b.m.Mesh() }
+func (q *bodyField) NComp() int             { return 3 }
+func (q *bodyField) Name() string           { return q.name }
+func (q *bodyField) Unit() string           { return "T" }
+func (q *bodyField) EvalTo(dst *data.Slice) { q.f(dst) }
+func (q *bodyField) average() []float64 {
+	s := ValueOf(q)
+	defer cuda.Recycle(s)
+	return sAverageUniverse(s)
+}
+func (q *bodyField) Average() data.Vector { return unslice(q.average()) }
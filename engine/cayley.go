@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"math"
+
+	"github.com/mumax/3/cuda"
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/util"
+)
+
+// Adaptive Lie-group midpoint solver. Each stage rotates m by the Cayley transform
+// of the torque's rotation vector, so |m| is preserved exactly without renormalization:
+//
+//	m½ = cay(dt/2 ω(m0)) m0
+//	m1 = cay(dt ω(m½)) m0
+//
+// This is second order, the error is estimated against the first-order step cay(dt ω(m0)) m0.
+type Cayley struct{}
+
+func (_ *Cayley) Step() {
+	y := solverState()
+	y0 := cuda.Buffer(y.NComp(), y.Size())
+	defer cuda.Recycle(y0)
+	data.Copy(y0, y)
+
+	if FixDt != 0 {
+		Dt_si = FixDt
+	}
+
+	dt := float32(Dt_si * GammaLL)
+	util.Assert(dt > 0)
+
+	// stage 1: half step
+	dy0 := cuda.Buffer(y.NComp(), y.Size())
+	defer cuda.Recycle(dy0)
+	torqueFn(dy0)
+	cuda.Cayley(y, y0, y0, dy0, 0.5*dt)
+
+	// stage 2: full step with midpoint torque
+	dy := cuda.Buffer(y.NComp(), y.Size())
+	defer cuda.Recycle(dy)
+	Time += 0.5 * Dt_si
+	torqueFn(dy)
+	Time += 0.5 * Dt_si
+	cuda.Cayley(y, y0, y, dy, dt)
+
+	// first-order solution for error estimate
+	y1 := cuda.Buffer(y.NComp(), y.Size())
+	defer cuda.Recycle(y1)
+	cuda.Cayley(y1, y0, y0, dy0, dt)
+	err := cuda.MaxVecDiff(y, y1)
+
+	// adjust next time step
+	if err < MaxErr || Dt_si <= MinDt || FixDt != 0 { // mindt check to avoid infinite loop
+		// step OK, no normalization needed
+		NSteps++
+		adaptDt(math.Pow(MaxErr/err, 1./2.))
+		setLastErr(err)
+		setMaxTorque(dy)
+	} else {
+		// undo bad step
+		util.Assert(FixDt == 0)
+		Time -= Dt_si
+		data.Copy(y, y0)
+		NUndone++
+		adaptDt(math.Pow(MaxErr/err, 1./3.))
+	}
+}
+
+func (_ *Cayley) Free() {}
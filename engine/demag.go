@@ -6,6 +6,7 @@ import (
 	"github.com/mumax/3/cuda"
 	"github.com/mumax/3/data"
 	"github.com/mumax/3/mag"
+	"github.com/mumax/3/util"
 )
 
 // Demag variables
@@ -23,7 +24,14 @@ var (
 	DemagAccuracy = 6.0                  // Demag accuracy (divide cubes in at most N^3 points)
 )
 
-var AddEdens_demag = makeEdensAdder(&B_demag, -0.5)
+// Adds the demag energy density of the net moment of all sublattices to dst.
+func AddEdens_demag(dst *data.Slice) {
+	B := ValueOf(B_demag)
+	defer cuda.Recycle(B)
+	m := ValueOf(M_net)
+	defer cuda.Recycle(m)
+	cuda.AddDotProduct(dst, -0.5, B, m)
+}
 
 func init() {
 
@@ -34,22 +42,39 @@ func init() {
 
 // Sets dst to the current demag field
 func SetDemagField(dst *data.Slice) {
-	if EnableDemag {
-		msat := Msat.MSlice()
-		defer msat.Recycle()
-		if NoDemagSpins.isZero() {
-			// Normal demag, everywhere
-			demagConv().Exec(dst, M.Buffer(), geometry.Gpu(), msat)
-		} else {
-			setMaskedDemagField(dst, msat)
-		}
-	} else {
+	if !EnableDemag {
 		cuda.Zero(dst) // will ADD other terms to it
+		return
+	}
+	if macrospinMode() {
+		setMacrospinDemagField(dst)
+		return
+	}
+	if numSublattices > 1 {
+		// field of the net moment of all sublattices
+		m := cuda.Buffer(VECTOR, dst.Size())
+		defer cuda.Recycle(m)
+		setNetMoment(m)
+		setDemagFieldFrom(dst, m, cuda.MakeMSlice(data.NilSlice(1, dst.Size()), []float64{1}))
+		return
+	}
+	msat := Msat.MSlice()
+	defer msat.Recycle()
+	setDemagFieldFrom(dst, M.Buffer(), msat)
+}
+
+// Sets dst to the demag field of magnetization m scaled by msat.
+func setDemagFieldFrom(dst, m *data.Slice, msat cuda.MSlice) {
+	if NoDemagSpins.isZero() {
+		// Normal demag, everywhere
+		demagConv().Exec(dst, m, geometry.Gpu(), msat)
+	} else {
+		setMaskedDemagField(dst, m, msat)
 	}
 }
 
 // Sets dst to the demag field, but cells where NoDemagSpins != 0 do not generate nor recieve field.
-func setMaskedDemagField(dst *data.Slice, msat cuda.MSlice) {
+func setMaskedDemagField(dst, m *data.Slice, msat cuda.MSlice) {
 	// No-demag spins: mask-out geometry with zeros where NoDemagSpins is set,
 	// so these spins do not generate a field
 
@@ -67,7 +92,7 @@ func setMaskedDemagField(dst *data.Slice, msat cuda.MSlice) {
 	cuda.ZeroMask(buf, NoDemagSpins.gpuLUT1(), regions.Gpu())
 
 	// convolution with masked-out cells.
-	demagConv().Exec(dst, M.Buffer(), buf, msat)
+	demagConv().Exec(dst, m, buf, msat)
 
 	// After convolution, mask-out the field in the NoDemagSpins cells
 	// so they don't feel the field generated by others.
@@ -76,6 +101,11 @@ func setMaskedDemagField(dst *data.Slice, msat cuda.MSlice) {
 
 // Sets dst to the full (unnormalized) magnetization in A/m
 func SetMFull(dst *data.Slice) {
+	setMFullFrom(dst, M, Msat)
+}
+
+// Sets dst to the full magnetization of M with saturation magnetization Msat.
+func setMFullFrom(dst *data.Slice, M magnetization, Msat *RegionwiseScalar) {
 	// scale m by Msat...
 	msat, rM := Msat.Slice()
 	if rM {
@@ -97,8 +127,62 @@ func SetMFull(dst *data.Slice) {
 	}
 }
 
-// returns demag convolution, making sure it's initialized
-func demagConv() *cuda.DemagConvolution {
+// Sets dst to the net moment Σ Msat_i m_i of all sublattices in A/m, not scaled by the cell volume.
+func setNetMoment(dst *data.Slice) {
+	buf := cuda.Buffer(VECTOR, dst.Size())
+	defer cuda.Recycle(buf)
+	cuda.Zero(dst)
+	for _, s := range sublattices[:numSublattices] {
+		msat, r := s.Msat.Slice()
+		if r {
+			defer cuda.Recycle(msat)
+		}
+		m := s.m.Buffer()
+		for c := 0; c < 3; c++ {
+			cuda.Mul(buf.Comp(c), m.Comp(c), msat)
+		}
+		cuda.Add(dst, dst, buf)
+	}
+}
+
+// Sets dst to the magnetization Msat m in A/m, or the net moment of all sublattices,
+// not scaled by the cell volume.
+func setMagnetMoment(dst *data.Slice) {
+	if numSublattices > 1 {
+		setNetMoment(dst)
+		return
+	}
+	msat, r := Msat.Slice()
+	if r {
+		defer cuda.Recycle(msat)
+	}
+	for c := 0; c < 3; c++ {
+		cuda.Mul(dst.Comp(c), M.Buffer().Comp(c), msat)
+	}
+}
+
+// Evaluates the demag field, e.g. by FFT convolution.
+type demagSolver interface {
+	Exec(B, m, vol *data.Slice, Msat cuda.MSlice)
+}
+
+// returns the demag solver selected with the -demag flag, making sure it's initialized
+func demagConv() demagSolver {
+	switch *Flag_demag {
+	case "fft":
+		if layered() {
+			return layerDemagConv()
+		}
+		return fftDemagConv()
+	case "tree":
+		return demagTree()
+	}
+	util.Fatal(`-demag: unknown solver "`, *Flag_demag, `", want "fft" or "tree"`)
+	return nil
+}
+
+// returns the FFT demag convolution, making sure it's initialized
+func fftDemagConv() *cuda.DemagConvolution {
 	if conv_ == nil {
 		SetBusy(true)
 		defer SetBusy(false)
@@ -110,5 +194,5 @@ func demagConv() *cuda.DemagConvolution {
 
 // Returns the current demag energy in Joules.
 func GetDemagEnergy() float64 {
-	return -0.5 * cellVolume() * dot(&M_full, &B_demag)
+	return -0.5 * cellVolume() * dot(&M_net, &B_demag)
 }
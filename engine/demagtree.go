@@ -0,0 +1,105 @@
+package engine
+
+// Hierarchical (treecode) demag for sparse geometries, selected with the -demag=tree flag.
+//
+// Instead of the FFT convolution over the full (padded) mesh, the field is evaluated on the occupied cells only:
+// exact kernel between nearby cells, multipole expansion of distant groups of cells (see mag.DemagTree).
+// This pays off when the geometry fills a small part of the mesh, e.g. a few distant particles.
+// The relative error scales as DemagTreeTheta²: about 0.4% at the default 0.3, much larger than with the FFT.
+// The field is zero outside the geometry.
+// The tree is rebuilt when the geometry is set. After a shift of a moving frame, it is only rebuilt
+// when the set of occupied cells changed, which is usually not the case for e.g. a wire shifted along its length.
+
+import (
+	"github.com/mumax/3/cuda"
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/mag"
+	"github.com/mumax/3/util"
+)
+
+var (
+	DemagTreeTheta = 0.3           // opening angle of the tree demag: far nodes have radius < theta * distance
+	demagtree_     *cuda.DemagTree // tree demag solver, nil if not initialized
+	demagtreeTheta float64         // DemagTreeTheta of demagtree_
+	demagtreeCells []bool          // occupied cells of demagtree_, nil if all cells
+	demagtreeMoved bool            // geometry shifted since demagtree_ was checked
+)
+
+func init() {
+	DeclVar("DemagTreeTheta", &DemagTreeTheta, "Accuracy of the tree demag (-demag=tree): relative error scales as DemagTreeTheta², about 0.4% at the default 0.3")
+}
+
+// returns the tree demag solver, making sure it's initialized for the current geometry and accuracy
+func demagTree() *cuda.DemagTree {
+	if demagtree_ != nil && demagtreeTheta == DemagTreeTheta && !demagtreeMoved {
+		return demagtree_
+	}
+	vol := geometry.Gpu()
+	if !vol.IsNil() {
+		vol = vol.HostCopy()
+	}
+	cells := occupiedCells(vol)
+	if demagtree_ != nil && demagtreeTheta == DemagTreeTheta && sameCells(cells, demagtreeCells) {
+		demagtreeMoved = false
+		return demagtree_
+	}
+	freeDemagTree()
+	if layered() {
+		util.Fatal("-demag=tree: not possible with non-uniform layers")
+	}
+	if Mesh().PBC() != [3]int{0, 0, 0} {
+		util.Fatal("-demag=tree: not possible with periodic boundary conditions")
+	}
+	SetBusy(true)
+	defer SetBusy(false)
+
+	tree := mag.NewDemagTree(Mesh().Size(), Mesh().CellSize(), vol, DemagTreeTheta)
+	kernel := mag.DemagKernel(tree.NearSize, [3]int{0, 0, 0}, Mesh().CellSize(), DemagAccuracy, *Flag_cachedir)
+	demagtree_ = cuda.NewDemagTree(tree, kernel)
+	demagtreeTheta = DemagTreeTheta
+	demagtreeCells = cells
+	util.Log("//demag tree:", len(tree.Cells), "cells,", len(tree.Near), "near and", len(tree.Far), "far interactions")
+	if *Flag_selftest {
+		demagtree_.SelfTest(fftDemagConv())
+	}
+	return demagtree_
+}
+
+// invalidates the tree demag, e.g. when the geometry changes
+func freeDemagTree() {
+	demagtree_.Free()
+	demagtree_ = nil
+	demagtreeCells = nil
+	demagtreeMoved = false
+}
+
+// marks the tree demag to be checked when the geometry shifted:
+// it is rebuilt only if the occupied cells changed.
+func shiftDemagTree() {
+	demagtreeMoved = true
+}
+
+// returns which cells of the host volume fraction vol are occupied, nil if all cells (no geometry).
+func occupiedCells(vol *data.Slice) []bool {
+	if vol.IsNil() {
+		return nil
+	}
+	v := vol.Host()[0]
+	cells := make([]bool, len(v))
+	for i := range v {
+		cells[i] = v[i] != 0
+	}
+	return cells
+}
+
+func sameCells(a, b []bool) bool {
+	if (a == nil) != (b == nil) || len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -12,10 +12,16 @@ var B_eff = NewVectorField("B_eff", "T", "Effective field", SetEffectiveField)
 func SetEffectiveField(dst *data.Slice) {
 	SetDemagField(dst)    // set to B_demag...
 	AddExchangeField(dst) // ...then add other terms
+	AddSublatticeExchangeField(dst)
+	AddInterlayerExchangeField(dst)
 	AddAnisotropyField(dst)
+	AddVCMAField(dst)
 	AddMagnetoelasticField(dst)
+	AddSpinAccumulationField(dst)
+	AddBodiesField(dst)
 	B_ext.AddTo(dst)
-	if !relaxing {
+	AddOerstedField(dst)
+	if !relaxing && solvertype != LLB && solvertype != MONTECARLO { // LLB has its own thermal noise, MC samples it
 		B_therm.AddTo(dst)
 	}
 	AddCustomField(dst)
@@ -0,0 +1,457 @@
+package engine
+
+// Linear spin-wave eigenmodes around the current (relaxed) magnetization.
+//
+// For small deviations v ⊥ m0, the undamped LLG equation linearizes to
+// 	dv/dt = γ/Msat m0 × (L v)
+// with L the Hessian of the energy density on the tangent plane,
+// 	L v = Msat (h0 v - δB(v)),  h0 = m0·B_eff(m0)
+// where δB(v) is the change of B_eff, evaluated by central finite differences.
+// The eigenmodes v exp(iωt) solve the generalized eigenproblem
+// 	L v = iω G⁻¹ v,  G⁻¹ = -Msat/γ m0×
+// with L symmetric positive (semi-)definite at a stable equilibrium.
+// We shift-invert at zero: K = L⁻¹G⁻¹ is skew-adjoint in the L inner product,
+// so -K² is self-adjoint with eigenvalues 1/ω². Its largest eigenvalues,
+// i.e. the lowest frequencies, are found by Lanczos in the L inner product,
+// with full reorthogonalization. L⁻¹ is applied by conjugate gradients.
+//
+// The mode profiles ψ = Re ψ + i Im ψ are normalized so that
+// 	Σ m0·(Im ψ × Re ψ) = 1
+// over all cells, flipping the sign of Im ψ if needed, so the projection
+// Re b_k = Σ (m × Im ψ)·δm (ext_eigenmodeprojectionReIm.go) of δm = Re ψ yields +1. They are saved as psiRe_k and psiIm_k OVF files,
+// and their frequencies in eigenmodes.txt.
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/mumax/3/cuda"
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/httpfs"
+	"github.com/mumax/3/util"
+)
+
+var (
+	EigenTolerance = 1e-4    // relative tolerance of the eigenmode solver
+	EigenMaxIter   = 1000    // maximum number of conjugate gradient iterations per linear solve
+	eigenFreq      []float64 // frequencies of the last ComputeEigenmodes (Hz)
+	eigenFDStep    = 1e-2    // finite-difference step of δB, relative to the largest |v|
+)
+
+func init() {
+	DeclFunc("ComputeEigenmodes", ComputeEigenmodes, "Computes the n lowest-frequency spin-wave eigenmodes above fmin (Hz) around the current state. "+
+		"Writes frequencies to eigenmodes.txt and mode profiles to psiRe_k, psiIm_k OVF files.")
+	DeclFunc("EigenFrequency", EigenFrequency, "Frequency (Hz) of eigenmode i found by the last ComputeEigenmodes")
+	DeclVar("EigenTolerance", &EigenTolerance, "Relative tolerance of ComputeEigenmodes (default=1e-4)")
+	DeclVar("EigenMaxIter", &EigenMaxIter, "Maximum number of conjugate gradient iterations per linear solve of ComputeEigenmodes (default=1000)")
+}
+
+// Returns the frequency of eigenmode i, in Hz.
+func EigenFrequency(i int) float64 {
+	if i < 0 || i >= len(eigenFreq) {
+		util.Fatal("EigenFrequency: have ", len(eigenFreq), " eigenmodes, need index ", i)
+	}
+	return eigenFreq[i]
+}
+
+// Computes the n lowest-frequency eigenmodes with frequency >= fmin, around the current
+// magnetization, which should be an equilibrium state (e.g. after Relax()).
+func ComputeEigenmodes(n int, fmin float64) {
+	SanityCheck()
+	util.Argument(n > 0)
+	if M.NComp() != 3 {
+		util.Fatal("ComputeEigenmodes: not supported with multiple sublattices")
+	}
+	SetBusy(true)
+	defer SetBusy(false)
+
+	e := newEigenSolver()
+	defer e.free()
+
+	modes := e.lanczos(n, 2*math.Pi*fmin)
+	eigenFreq = eigenFreq[:0]
+	for _, md := range modes {
+		eigenFreq = append(eigenFreq, md.ω/(2*math.Pi))
+	}
+	e.save(modes)
+	LogOut("ComputeEigenmodes: found", len(modes), "modes:", eigenFreq, "Hz")
+	if len(modes) < n {
+		LogOut("ComputeEigenmodes: only", len(modes), "of", n, "modes converged")
+	}
+}
+
+// state of the linearized problem around m0
+type eigenSolver struct {
+	m0, B0 *data.Slice   // equilibrium magnetization and effective field (GPU)
+	h0     *data.Slice   // m0·B0
+	ms     *data.Slice   // Msat per cell
+	q, Lq  []*data.Slice // Lanczos vectors and L times them
+	nCell  int
+}
+
+type eigenmode struct {
+	ω      float64     // angular frequency (rad/s)
+	re, im *data.Slice // mode profile (GPU)
+}
+
+func newEigenSolver() *eigenSolver {
+	size := Mesh().Size()
+	e := &eigenSolver{nCell: prod(size)}
+	e.m0 = cuda.NewSlice(3, size)
+	data.Copy(e.m0, M.Buffer())
+	e.B0 = cuda.NewSlice(3, size)
+	e.effectiveField(e.B0, e.m0)
+	e.h0 = cuda.NewSlice(1, size)
+	cuda.Zero(e.h0)
+	cuda.AddDotProduct(e.h0, 1, e.m0, e.B0)
+	e.ms = cuda.NewSlice(1, size)
+	Msat.EvalTo(e.ms)
+	return e
+}
+
+func (e *eigenSolver) free() {
+	data.Copy(M.Buffer(), e.m0) // restore
+	for _, s := range []*data.Slice{e.m0, e.B0, e.h0, e.ms} {
+		s.Free()
+	}
+	for i := range e.q {
+		e.q[i].Free()
+		e.Lq[i].Free()
+	}
+}
+
+// effective field of (unnormalized) m, without thermal noise.
+func (e *eigenSolver) effectiveField(dst, m *data.Slice) {
+	prevRelaxing := relaxing
+	relaxing = true // no thermal field
+	data.Copy(M.Buffer(), m)
+	SetEffectiveField(dst)
+	relaxing = prevRelaxing
+}
+
+// project v onto the tangent plane of m0: v - (m0·v) m0
+func (e *eigenSolver) project(v *data.Slice) {
+	s := cuda.Buffer(1, v.Size())
+	defer cuda.Recycle(s)
+	buf := cuda.Buffer(1, v.Size())
+	defer cuda.Recycle(buf)
+	cuda.Zero(s)
+	cuda.AddDotProduct(s, 1, e.m0, v)
+	for c := 0; c < 3; c++ {
+		cuda.Mul(buf, s, e.m0.Comp(c))
+		cuda.Madd2(v.Comp(c), v.Comp(c), buf, 1, -1)
+	}
+}
+
+// multiply each component of v by scalar field s.
+func scaleVec(v, s *data.Slice) {
+	for c := 0; c < v.NComp(); c++ {
+		cuda.Mul(v.Comp(c), v.Comp(c), s)
+	}
+}
+
+// dst = L v = Msat (h0 v - δB(v)), projected on the tangent plane.
+func (e *eigenSolver) applyL(dst, v *data.Slice) {
+	max := cuda.MaxVecNorm(v)
+	if max == 0 {
+		cuda.Zero(dst)
+		return
+	}
+	ε := float32(eigenFDStep / max)
+	m := cuda.Buffer(3, v.Size())
+	defer cuda.Recycle(m)
+	Bm := cuda.Buffer(3, v.Size())
+	defer cuda.Recycle(Bm)
+
+	cuda.Madd2(m, e.m0, v, 1, ε)
+	e.effectiveField(dst, m)
+	cuda.Madd2(m, e.m0, v, 1, -ε)
+	e.effectiveField(Bm, m)
+	cuda.Madd2(dst, dst, Bm, -1/(2*ε), 1/(2*ε)) // -δB
+
+	h0v := m
+	data.Copy(h0v, v)
+	scaleVec(h0v, e.h0)
+	cuda.Add(dst, dst, h0v)
+	scaleVec(dst, e.ms)
+	e.project(dst)
+}
+
+// dst = G⁻¹ v = -Msat/γ m0 × v
+func (e *eigenSolver) applyGinv(dst, v *data.Slice) {
+	cuda.CrossProduct(dst, e.m0, v)
+	scaleVec(dst, e.ms)
+	cuda.Madd2(dst, dst, dst, float32(-1/GammaLL), 0)
+}
+
+// solves L x = b by conjugate gradients, starting from x = 0.
+func (e *eigenSolver) solveL(x, b *data.Slice) {
+	size := b.Size()
+	r := cuda.Buffer(3, size)
+	defer cuda.Recycle(r)
+	p := cuda.Buffer(3, size)
+	defer cuda.Recycle(p)
+	Ap := cuda.Buffer(3, size)
+	defer cuda.Recycle(Ap)
+
+	cuda.Zero(x)
+	data.Copy(r, b)
+	data.Copy(p, b)
+	bb := float64(cuda.Dot(b, b))
+	rr := bb
+	for i := 0; i < EigenMaxIter; i++ {
+		if rr <= sqr(EigenTolerance)*bb {
+			return
+		}
+		e.applyL(Ap, p)
+		pAp := float64(cuda.Dot(p, Ap))
+		if pAp <= 0 {
+			util.Fatal("ComputeEigenmodes: energy Hessian is not positive definite, relax the magnetization first")
+		}
+		α := float32(rr / pAp)
+		cuda.Madd2(x, x, p, 1, α)
+		cuda.Madd2(r, r, Ap, 1, -α)
+		rr2 := float64(cuda.Dot(r, r))
+		cuda.Madd2(p, r, p, 1, float32(rr2/rr))
+		rr = rr2
+	}
+	LogOut("ComputeEigenmodes: conjugate gradients did not converge, residual:", math.Sqrt(rr/bb))
+}
+
+// w = -K² q = -L⁻¹G⁻¹L⁻¹G⁻¹ q, and Lw = -G⁻¹L⁻¹G⁻¹ q.
+func (e *eigenSolver) applyOp(w, Lw, q *data.Slice) {
+	y := cuda.Buffer(3, q.Size())
+	defer cuda.Recycle(y)
+	z := cuda.Buffer(3, q.Size())
+	defer cuda.Recycle(z)
+	e.applyGinv(y, q)
+	e.solveL(z, y)
+	e.applyGinv(Lw, z)
+	e.solveL(w, Lw)
+	cuda.Madd2(w, w, w, -1, 0)
+	cuda.Madd2(Lw, Lw, Lw, -1, 0)
+}
+
+// Lanczos iteration for the n largest eigenvalues 1/ω² of -K², with ω >= ωmin.
+func (e *eigenSolver) lanczos(n int, ωmin float64) []eigenmode {
+	size := Mesh().Size()
+	maxIter := 2 * e.nCell // dimension of the tangent space
+	if maxIter > 10*n+50 {
+		maxIter = 10*n + 50
+	}
+
+	// random start vector
+	q := cuda.NewSlice(3, size)
+	h := data.NewSlice(3, size)
+	randomVec(h)
+	data.Copy(q, h)
+	e.project(q)
+	Lq := cuda.NewSlice(3, size)
+	e.applyL(Lq, q)
+	norm := math.Sqrt(float64(cuda.Dot(q, Lq)))
+	cuda.Madd2(q, q, q, float32(1/norm), 0)
+	cuda.Madd2(Lq, Lq, Lq, float32(1/norm), 0)
+	e.q, e.Lq = append(e.q, q), append(e.Lq, Lq)
+
+	var α, β []float64
+	var θ []float64
+	var s [][]float64
+	for j := 0; j < maxIter; j++ {
+		w := cuda.NewSlice(3, size)
+		Lw := cuda.NewSlice(3, size)
+		e.applyOp(w, Lw, e.q[j])
+
+		// full reorthogonalization in the L inner product, twice for stability
+		α = append(α, float64(cuda.Dot(w, e.Lq[j])))
+		for pass := 0; pass < 2; pass++ {
+			for i := range e.q {
+				c := float32(cuda.Dot(w, e.Lq[i]))
+				cuda.Madd2(w, w, e.q[i], 1, -c)
+				cuda.Madd2(Lw, Lw, e.Lq[i], 1, -c)
+			}
+		}
+		b := math.Sqrt(math.Max(float64(cuda.Dot(w, Lw)), 0))
+
+		θ, s = symmTridiagEig(α, β)
+		if e.converged(θ, s, b, n, ωmin) || b <= EigenTolerance*math.Abs(θ[0]) || j == maxIter-1 {
+			w.Free()
+			Lw.Free()
+			break
+		}
+		β = append(β, b)
+		cuda.Madd2(w, w, w, float32(1/b), 0)
+		cuda.Madd2(Lw, Lw, Lw, float32(1/b), 0)
+		e.q, e.Lq = append(e.q, w), append(e.Lq, Lw)
+	}
+
+	// Ritz vectors of the accepted eigenvalues, in order of increasing frequency
+	var modes []eigenmode
+	for k := range θ {
+		if len(modes) == n || θ[k] <= 0 {
+			break
+		}
+		ω := 1 / math.Sqrt(θ[k])
+		if ω < ωmin {
+			continue
+		}
+		modes = append(modes, e.mode(ω, s[k]))
+	}
+	return modes
+}
+
+// true when the n largest Ritz values with ω >= ωmin have converged.
+func (e *eigenSolver) converged(θ []float64, s [][]float64, β float64, n int, ωmin float64) bool {
+	count := 0
+	for k := range θ {
+		if θ[k] <= 0 {
+			return false
+		}
+		if 1/math.Sqrt(θ[k]) < ωmin {
+			continue
+		}
+		last := s[k][len(s[k])-1]
+		if math.Abs(β*last) > EigenTolerance*θ[k] {
+			return false
+		}
+		count++
+		if count == n {
+			return true
+		}
+	}
+	return false
+}
+
+// mode profile from the Lanczos coefficients of Re ψ: Im ψ = ω K Re ψ.
+func (e *eigenSolver) mode(ω float64, coeff []float64) eigenmode {
+	size := Mesh().Size()
+	re := cuda.NewSlice(3, size)
+	im := cuda.NewSlice(3, size)
+	cuda.Zero(re)
+	for i, c := range coeff {
+		cuda.Madd2(re, re, e.q[i], 1, float32(c))
+	}
+	y := cuda.Buffer(3, size)
+	defer cuda.Recycle(y)
+	e.applyGinv(y, re)
+	e.solveL(im, y)
+	cuda.Madd2(im, im, im, float32(ω), 0)
+
+	// normalize: Σ m0·(Im ψ × Re ψ) = 1
+	cuda.CrossProduct(y, im, re)
+	d := float64(cuda.Dot(e.m0, y))
+	norm := float32(1 / math.Sqrt(math.Abs(d)))
+	cuda.Madd2(re, re, re, norm, 0)
+	if d < 0 {
+		cuda.Madd2(im, im, im, -norm, 0)
+	} else {
+		cuda.Madd2(im, im, im, norm, 0)
+	}
+	return eigenmode{ω: ω, re: re, im: im}
+}
+
+// write the frequency table and mode profiles, frees the profiles.
+func (e *eigenSolver) save(modes []eigenmode) {
+	table, err := httpfs.Create(OD() + "eigenmodes.txt")
+	util.FatalErr(err)
+	defer table.Close()
+	fprintln(table, "# mode\tf (Hz)")
+
+	info := data.Meta{Time: Time, CellSize: Mesh().CellSize(), LayerThickness: layerMeta(Mesh())}
+	for i, md := range modes {
+		fprintln(table, i, "\t", md.ω/(2*math.Pi))
+		for _, p := range []struct {
+			name string
+			s    *data.Slice
+		}{{"psiRe_k", md.re}, {"psiIm_k", md.im}} {
+			info := info
+			info.Name = p.name
+			fname := autoFname(p.name, outputFormat, i)
+			host := p.s.HostCopy()
+			queOutput(func() { saveAs_sync(fname, host, info, outputFormat) })
+			p.s.Free()
+		}
+	}
+}
+
+// fill v with random numbers in [-0.5, 0.5).
+func randomVec(v *data.Slice) {
+	rng := rand.New(rand.NewSource(0))
+	for _, c := range v.Host() {
+		for i := range c {
+			c[i] = float32(rng.Float64() - 0.5)
+		}
+	}
+}
+
+// Eigenvalues, in descending order, and eigenvectors of the symmetric tridiagonal matrix
+// with diagonal α and off-diagonal β, by cyclic Jacobi rotations.
+func symmTridiagEig(α, β []float64) ([]float64, [][]float64) {
+	n := len(α)
+	a := make([][]float64, n)
+	v := make([][]float64, n) // v[k]: k-th eigenvector
+	for i := range a {
+		a[i] = make([]float64, n)
+		v[i] = make([]float64, n)
+		a[i][i] = α[i]
+		v[i][i] = 1
+		if i < len(β) && i+1 < n {
+			a[i][i+1], a[i+1][i] = β[i], β[i]
+		}
+	}
+
+	for sweep := 0; sweep < 100; sweep++ {
+		off := 0.
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				off += sqr(a[i][j])
+			}
+		}
+		if off < 1e-30 {
+			break
+		}
+		for p := 0; p < n; p++ {
+			for q := p + 1; q < n; q++ {
+				if a[p][q] == 0 {
+					continue
+				}
+				τ := (a[q][q] - a[p][p]) / (2 * a[p][q])
+				t := math.Copysign(1, τ) / (math.Abs(τ) + math.Sqrt(1+τ*τ))
+				c := 1 / math.Sqrt(1+t*t)
+				s := t * c
+				for k := 0; k < n; k++ {
+					akp, akq := a[k][p], a[k][q]
+					a[k][p], a[k][q] = c*akp-s*akq, s*akp+c*akq
+				}
+				for k := 0; k < n; k++ {
+					apk, aqk := a[p][k], a[q][k]
+					a[p][k], a[q][k] = c*apk-s*aqk, s*apk+c*aqk
+				}
+				for k := 0; k < n; k++ {
+					vpk, vqk := v[p][k], v[q][k]
+					v[p][k], v[q][k] = c*vpk-s*vqk, s*vpk+c*vqk
+				}
+			}
+		}
+	}
+
+	θ := make([]float64, n)
+	for i := range θ {
+		θ[i] = a[i][i]
+	}
+	sort.Sort(byEigenvalue{θ, v})
+	return θ, v
+}
+
+// sorts eigenvalues in descending order, together with their eigenvectors
+type byEigenvalue struct {
+	θ []float64
+	v [][]float64
+}
+
+func (b byEigenvalue) Len() int           { return len(b.θ) }
+func (b byEigenvalue) Less(i, j int) bool { return b.θ[i] > b.θ[j] }
+func (b byEigenvalue) Swap(i, j int) {
+	b.θ[i], b.θ[j] = b.θ[j], b.θ[i]
+	b.v[i], b.v[j] = b.v[j], b.v[i]
+}
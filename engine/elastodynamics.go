@@ -0,0 +1,255 @@
+package engine
+
+// Elastodynamics coupled to magneto-elasticity.
+//
+// When enabled, the elastic displacement u of the lattice evolves according to
+// 	rho ∂²u/∂t² = ∇·σ + F_mel + F_el - eta ∂u/∂t
+// with σ the stress of a medium with cubic stiffness constants C11, C12, C44,
+// F_mel the magneto-elastic force density, F_el an external force density and eta a damping constant.
+// The strain of u is added to the prescribed strain excitations exx...eyz, so that it feeds
+// back into B_mel. Cells with rho = 0 are not part of the elastic medium, its surfaces are stress-free.
+//
+// u is integrated with the velocity-Verlet scheme after each time step of m,
+// over the time covered by that step, in sub-steps that respect the CFL condition
+// (or of ElasticDt, if set), with m fixed.
+// Shift and YShift move u and its velocity together with m.
+
+import (
+	"math"
+
+	"github.com/mumax/3/cuda"
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/util"
+)
+
+var (
+	Rho             = NewScalarParam("rho", "kg/m3", "Mass density of the elastic medium")
+	C11             = NewScalarParam("C11", "N/m2", "Elastic stiffness constant C11")
+	C12             = NewScalarParam("C12", "N/m2", "Elastic stiffness constant C12")
+	C44             = NewScalarParam("C44", "N/m2", "Elastic stiffness constant C44")
+	Eta             = NewScalarParam("eta", "kg/(m3 s)", "Damping constant of the elastic medium")
+	F_el            = NewExcitation("F_el", "N/m3", "External force density on the elastic medium")
+	U_el            = NewVectorField("u", "m", "Elastic displacement", setDisplacement)
+	V_el            = NewVectorField("du", "m/s", "Elastic velocity", setElasticVelocity)
+	Strain          = &fieldFunc{info{6, "strain", ""}, SetStrain}
+	E_kin_el        = NewScalarValue("E_kin_el", "J", "Kinetic energy of the elastic medium", GetElasticKineticEnergy)
+	Elastodynamics  = false // integrate the elastic displacement
+	ElasticDt       = 0.0   // elastic sub-step (s), 0 = automatic
+	elastic         elasticState
+	elasticStepsMax = 10000 // maximum number of sub-steps per time step
+)
+
+func init() {
+	Export(Strain, "Strain tensor xx, yy, zz, xy, xz, yz: prescribed plus elastic")
+	DeclVar("EnableElastodynamics", &Elastodynamics, "Integrate the elastic displacement, coupled to m by magneto-elasticity (default=false)")
+	DeclVar("ElasticDt", &ElasticDt, "Time step of the elastic displacement (s), 0 = automatic (default=0)")
+	DeclFunc("SetDisplacement", SetDisplacement, "Sets the elastic displacement (m) and sets the velocity to zero")
+}
+
+// elastic displacement and velocity
+type elasticState struct {
+	u, v *data.Slice
+	time float64 // time up to which u has been integrated
+}
+
+// allocates u and v if needed, zero.
+func (e *elasticState) alloc() {
+	if e.u != nil && e.u.Size() == Mesh().Size() {
+		return
+	}
+	e.free()
+	e.u = cuda.NewSlice(3, Mesh().Size())
+	e.v = cuda.NewSlice(3, Mesh().Size())
+	e.time = Time
+}
+
+func (e *elasticState) free() {
+	if e.u != nil {
+		e.u.Free()
+		e.v.Free()
+		e.u, e.v = nil, nil
+	}
+}
+
+// shifts u and v with the magnetization over d cells along direction dir (X or Y),
+// see Shift. The new cells at the edge are at rest.
+func (e *elasticState) shift(d, dir int) {
+	if e.u == nil {
+		return
+	}
+	buf := cuda.Buffer(1, e.u.Size())
+	defer cuda.Recycle(buf)
+	for _, s := range []*data.Slice{e.u, e.v} {
+		for c := 0; c < s.NComp(); c++ {
+			comp := s.Comp(c)
+			if dir == X {
+				cuda.ShiftX(buf, comp, d, 0, 0)
+			} else {
+				cuda.ShiftY(buf, comp, d, 0, 0)
+			}
+			data.Copy(comp, buf)
+		}
+	}
+}
+
+// Sets the elastic displacement, with zero velocity.
+func SetDisplacement(c Config) {
+	checkMesh()
+	elastic.alloc()
+	h := data.NewSlice(3, Mesh().Size())
+	u := h.Vectors()
+	n := Mesh().Size()
+	for iz := 0; iz < n[Z]; iz++ {
+		for iy := 0; iy < n[Y]; iy++ {
+			for ix := 0; ix < n[X]; ix++ {
+				r := Index2Coord(ix, iy, iz)
+				v := c(r[X], r[Y], r[Z])
+				for comp := range u {
+					u[comp][iz][iy][ix] = float32(v[comp])
+				}
+			}
+		}
+	}
+	data.Copy(elastic.u, h)
+	cuda.Zero(elastic.v)
+}
+
+func setDisplacement(dst *data.Slice) {
+	if elastic.u != nil && elastic.u.Size() == dst.Size() {
+		data.Copy(dst, elastic.u)
+	}
+}
+
+func setElasticVelocity(dst *data.Slice) {
+	if elastic.v != nil && elastic.v.Size() == dst.Size() {
+		data.Copy(dst, elastic.v)
+	}
+}
+
+// Sets dst to the total strain: the prescribed strain excitations plus the strain of u.
+func SetStrain(dst *data.Slice) {
+	for c, e := range strainSlices() {
+		data.Copy(dst.Comp(c), e)
+		cuda.Recycle(e)
+	}
+}
+
+// Returns the strain components xx, yy, zz, xy, xz, yz: the prescribed strain excitations
+// plus the strain of u, if Elastodynamics is enabled. To be recycled by the caller.
+func strainSlices() [6]*data.Slice {
+	var e [6]*data.Slice
+	for c, s := range []*ScalarExcitation{exx, eyy, ezz, exy, exz, eyz} {
+		e[c], _ = s.Slice()
+	}
+	if !Elastodynamics || elastic.u == nil {
+		return e
+	}
+	el := cuda.Buffer(6, Mesh().Size())
+	defer cuda.Recycle(el)
+	rho := Rho.MSlice()
+	defer rho.Recycle()
+	cuda.SetElasticStrain(el, elastic.u, rho, Mesh())
+	for c := range e {
+		cuda.Madd2(e[c], e[c], el.Comp(c), 1, 1)
+	}
+	return e
+}
+
+// strainSlices as MSlices, for the magneto-elastic field.
+func strainMSlices() (xx, yy, zz, xy, xz, yz cuda.MSlice) {
+	e := strainSlices()
+	return cuda.ToMSlice(e[0]), cuda.ToMSlice(e[1]), cuda.ToMSlice(e[2]),
+		cuda.ToMSlice(e[3]), cuda.ToMSlice(e[4]), cuda.ToMSlice(e[5])
+}
+
+// Integrates u from the time of the previous call up to the current time.
+// Called after each time step.
+func stepElastic() {
+	if !Elastodynamics {
+		return
+	}
+	checkMesh()
+	elastic.alloc()
+	if Time <= elastic.time {
+		elastic.time = Time // time was reset
+		return
+	}
+	T := Time - elastic.time
+	dt := ElasticDt
+	if dt == 0 {
+		dt = elasticCFL()
+	}
+	n := int(math.Ceil(T / dt))
+	if n > elasticStepsMax {
+		util.Fatal("Elastodynamics: time step of m much larger than elastic time step, set MaxDt")
+	}
+	dt = T / float64(n)
+
+	size := Mesh().Size()
+	f := cuda.Buffer(3, size)
+	defer cuda.Recycle(f)
+	Fmel := cuda.Buffer(3, size)
+	defer cuda.Recycle(Fmel)
+	cuda.Zero(Fmel)
+	GetMagnetoelasticForceDensity(Fmel) // m is fixed during the sub-steps
+
+	rho := Rho.MSlice()
+	defer rho.Recycle()
+	eta := Eta.MSlice()
+	defer eta.Recycle()
+	c11 := C11.MSlice()
+	defer c11.Recycle()
+	c12 := C12.MSlice()
+	defer c12.Recycle()
+	c44 := C44.MSlice()
+	defer c44.Recycle()
+
+	u, v := elastic.u, elastic.v
+	force := func() {
+		cuda.SetElasticForce(f, u, c11, c12, c44, rho, Mesh())
+		cuda.Madd2(f, f, Fmel, 1, 1)
+		F_el.AddTo(f)
+	}
+	// velocity Verlet
+	force()
+	for i := 0; i < n; i++ {
+		cuda.ElasticKick(v, f, rho, eta, dt/2)
+		cuda.Madd2(u, u, v, 1, float32(dt))
+		force()
+		cuda.ElasticKick(v, f, rho, eta, dt/2)
+	}
+	elastic.time = Time
+}
+
+// stable time step: a fraction of the time for the fastest (longitudinal) sound wave to cross a cell.
+func elasticCFL() float64 {
+	C := C11.cpuLUT()[0]
+	ρ := Rho.cpuLUT()[0]
+	vmax := 0.
+	for r := range C {
+		if ρ[r] != 0 {
+			vmax = math.Max(vmax, math.Sqrt(float64(C[r]/ρ[r])))
+		}
+	}
+	if vmax == 0 {
+		util.Fatal("Elastodynamics: need non-zero rho and C11")
+	}
+	c := Mesh().CellSize()
+	h := math.Min(c[X], math.Min(c[Y], c[Z]))
+	return 0.5 * h / (vmax * math.Sqrt(3))
+}
+
+// Returns the kinetic energy of the elastic medium in J.
+func GetElasticKineticEnergy() float64 {
+	if elastic.v == nil {
+		return 0
+	}
+	v2 := cuda.Buffer(1, Mesh().Size())
+	defer cuda.Recycle(v2)
+	cuda.Zero(v2)
+	cuda.AddDotProduct(v2, 1, elastic.v, elastic.v)
+	rho, _ := Rho.Slice()
+	defer cuda.Recycle(rho)
+	cuda.Mul(v2, v2, rho)
+	return 0.5 * cellVolume() * float64(cuda.Sum(v2))
+}
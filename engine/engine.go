@@ -51,6 +51,7 @@ func GetBusy() bool {
 
 // Cleanly exits the simulation, assuring all output is flushed.
 func Close() {
+	SpectrumSave()
 	drainOutput()
 	LogUsedRefs()
 	Table.flush()
@@ -9,8 +9,8 @@ type Euler struct{}
 
 // Euler method, can be used as solver.Step.
 func (_ *Euler) Step() {
-	y := M.Buffer()
-	dy0 := cuda.Buffer(VECTOR, y.Size())
+	y := solverState()
+	dy0 := cuda.Buffer(y.NComp(), y.Size())
 	defer cuda.Recycle(dy0)
 
 	torqueFn(dy0)
@@ -30,7 +30,7 @@ func (_ *Euler) Step() {
 	setLastErr(float64(dt) * LastTorque)
 
 	cuda.Madd2(y, y, dy0, 1, dt) // y = y + dt * dy
-	M.normalize()
+	normalizeState()
 	Time += Dt_si
 	NSteps++
 }
@@ -5,6 +5,7 @@ package engine
 
 import (
 	"math"
+	"sort"
 	"unsafe"
 
 	"github.com/mumax/3/cuda"
@@ -51,21 +52,30 @@ func init() {
 
 // Adds the current exchange field to dst
 func AddExchangeField(dst *data.Slice) {
-	inter := !Dind.isZero()
+	addExchangeFrom(dst, M, Msat, &lex2)
+}
+
+// Adds the exchange field of magnetization M with stiffness lex2 to dst.
+// The DMI strengths are shared by all sublattices.
+func addExchangeFrom(dst *data.Slice, M magnetization, Msat *RegionwiseScalar, lex2 *exchParam) {
+	if macrospinMode() {
+		return // macrospins have no neighbours
+	}
+	inter := din2.nonZero()
 	bulk := !Dbulk.isZero()
 	film := !Dfilm.isZero()
 	ms := Msat.MSlice()
 	defer ms.Recycle()
 	switch {
 	case !inter && !bulk && !film:
-		cuda.AddExchange(dst, M.Buffer(), lex2.Gpu(), ms, regions.Gpu(), M.Mesh())
+		cuda.AddExchange(dst, M.Buffer(), lex2.Gpu(), ms, regions.Gpu(), M.Mesh(), layersGpu())
 	case inter && !bulk && !film:
 		Refer("mulkers2017")
-		cuda.AddDMI(dst, M.Buffer(), lex2.Gpu(), din2.Gpu(), ms, regions.Gpu(), M.Mesh(), OpenBC) // dmi+exchange
+		cuda.AddDMI(dst, M.Buffer(), lex2.Gpu(), din2.Gpu(), ms, regions.Gpu(), M.Mesh(), layersGpu(), OpenBC) // dmi+exchange
 	case bulk && !inter && !film:
-		cuda.AddDMIBulk(dst, M.Buffer(), lex2.Gpu(), dbulk2.Gpu(), ms, regions.Gpu(), M.Mesh(), OpenBC) // dmi+exchange
+		cuda.AddDMIBulk(dst, M.Buffer(), lex2.Gpu(), dbulk2.Gpu(), ms, regions.Gpu(), M.Mesh(), layersGpu(), OpenBC) // dmi+exchange
 	case film && !inter && !bulk:
-		cuda.AddDMIFilm(dst, M.Buffer(), lex2.Gpu(), dfilm2.Gpu(), ms, regions.Gpu(), M.Mesh(), OpenBC) // dmi+exchange	
+		cuda.AddDMIFilm(dst, M.Buffer(), lex2.Gpu(), dfilm2.Gpu(), ms, regions.Gpu(), M.Mesh(), layersGpu(), OpenBC) // dmi+exchange	
 		// TODO: add ScaleInterDbulk, InterDbulk, ScaleInterDfilm, InterDfilm
 	case inter && bulk:
 		util.Fatal("Cannot have interfacial-induced DMI and bulk DMI at the same time")
@@ -112,11 +122,17 @@ func InterDind(region1, region2 int, value float64) {
 // the interregion exchange/DMI by default is the harmonic mean (scale=1, inter=0)
 type exchParam struct {
 	parent         *RegionwiseScalar
-	lut            [NREGION * (NREGION + 1) / 2]float32 // harmonic mean of regions (i,j)
-	scale          [NREGION * (NREGION + 1) / 2]float32 // extra scale factor for lut[SymmIdx(i, j)]
-	inter          [NREGION * (NREGION + 1) / 2]float32 // extra term for lut[SymmIdx(i, j)]
-	gpu            cuda.SymmLUT                         // gpu copy of lut, lazily transferred when needed
-	gpu_ok, cpu_ok bool                                 // gpu cache up-to date with lut source
+	offset         *DerivedParam        // added to the parent values, e.g. voltage-controlled DMI (may be nil)
+	sum            []float32            // parent + offset values in use, only if offset != nil
+	lut            []float32            // harmonic mean of regions (i,j), only for 8-bit regions
+	pairs          map[uint32]interPair // explicitly set couplings, by pairKey(i, j)
+	gpu            cuda.InterLUT        // gpu copy of lut or pairs, lazily transferred when needed
+	gpu_ok, cpu_ok bool                 // gpu cache up-to date with lut source
+}
+
+// coupling between two regions: scale*harmonic mean + inter
+type interPair struct {
+	scale, inter float32
 }
 
 // to be called after Aex or scaling changed
@@ -126,46 +142,82 @@ func (p *exchParam) invalidate() {
 }
 
 func (p *exchParam) init(parent *RegionwiseScalar) {
-	for i := range p.scale {
-		p.scale[i] = 1 // default scaling
-		p.inter[i] = 0 // default additional interexchange term
-	}
+	p.pairs = make(map[uint32]interPair)
 	p.parent = parent
 }
 
 // Get a GPU mirror of the look-up table.
 // Copies to GPU first only if needed.
-func (p *exchParam) Gpu() cuda.SymmLUT {
+func (p *exchParam) Gpu() cuda.InterLUT {
 	p.update()
 	if !p.gpu_ok {
 		p.upload()
 	}
+	if regions.wide() {
+		if p.offset != nil && p.offset.nonZero() {
+			util.Fatal(p.parent.Name(), ": voltage-controlled offset not supported with more than 256 regions")
+		}
+		p.gpu.Value = p.parent.gpuLUT1() // harmonic mean is taken on the fly
+	}
 	return p.gpu
 }
 
+// true if the parent or offset is non-zero somewhere
+func (p *exchParam) nonZero() bool {
+	return p.parent.nonZero() || p.offset != nil && p.offset.nonZero()
+}
+
+// parent values plus offset, if any. Invalidates me if the sum changed.
+func (p *exchParam) values() []float32 {
+	ex := p.parent.cpuLUT()[0]
+	if p.offset == nil {
+		return ex
+	}
+	off := p.offset.cpuLUT()[0]
+	if len(p.sum) != len(ex) {
+		p.sum = make([]float32, len(ex))
+		p.invalidate()
+	}
+	for i := range ex {
+		if v := ex[i] + off[i]; v != p.sum[i] {
+			p.sum[i] = v
+			p.invalidate()
+		}
+	}
+	return p.sum
+}
+
 // sets the interregion exchange/DMI using a specified value (scale = 0)
 func (p *exchParam) setInter(region1, region2 int, value float64) {
-	p.scale[symmidx(region1, region2)] = float32(0.)
-	p.inter[symmidx(region1, region2)] = float32(value)
+	p.pairs[pairKey(region1, region2)] = interPair{scale: 0, inter: float32(value)}
 	p.invalidate()
 }
 
 // sets the interregion exchange/DMI by rescaling the harmonic mean (inter = 0)
 func (p *exchParam) setScale(region1, region2 int, scale float64) {
-	p.scale[symmidx(region1, region2)] = float32(scale)
-	p.inter[symmidx(region1, region2)] = float32(0.)
+	p.pairs[pairKey(region1, region2)] = interPair{scale: float32(scale), inter: 0}
 	p.invalidate()
 }
 
 func (p *exchParam) update() {
+	p.parent.update() // may invalidate me, e.g. upon temperature change
+	ex := p.values()  // may invalidate me, e.g. upon electric field change
 	if !p.cpu_ok {
-		ex := p.parent.cpuLUT()
-		for i := 0; i < NREGION; i++ {
-			exi := ex[0][i]
-			for j := i; j < NREGION; j++ {
-				exj := ex[0][j]
-				I := symmidx(i, j)
-				p.lut[I] = p.scale[I]*exchAverage(exi, exj) + p.inter[I]
+		// the full table is only stored for 8-bit regions, it would be too large for 16-bit
+		if !regions.wide() {
+			if p.lut == nil {
+				p.lut = make([]float32, NREGION*(NREGION+1)/2)
+			}
+			for i := 0; i < NREGION; i++ {
+				exi := ex[i]
+				for j := i; j < NREGION; j++ {
+					exj := ex[j]
+					pair, ok := p.pairs[pairKey(i, j)]
+					if !ok {
+						pair = interPair{scale: 1, inter: 0}
+					}
+					p.lut[symmidx(i, j)] = pair.scale*exchAverage(exi, exj) + pair.inter
+				}
 			}
 		}
 		p.gpu_ok = false
@@ -174,15 +226,63 @@ func (p *exchParam) update() {
 }
 
 func (p *exchParam) upload() {
-	// alloc if  needed
-	if p.gpu == nil {
-		p.gpu = cuda.SymmLUT(cuda.MemAlloc(int64(len(p.lut)) * cu.SIZEOF_FLOAT32))
+	if regions.wide() {
+		p.uploadPairs()
+	} else {
+		// alloc if  needed
+		if p.gpu.Symm == nil {
+			p.gpu.Symm = cuda.SymmLUT(cuda.MemAlloc(int64(len(p.lut)) * cu.SIZEOF_FLOAT32))
+		}
+		cuda.MemCpyHtoD(unsafe.Pointer(p.gpu.Symm), unsafe.Pointer(&p.lut[0]), cu.SIZEOF_FLOAT32*int64(len(p.lut)))
 	}
-	lut := p.lut // Copy, to work around Go 1.6 cgo pointer limitations.
-	cuda.MemCpyHtoD(unsafe.Pointer(p.gpu), unsafe.Pointer(&lut[0]), cu.SIZEOF_FLOAT32*int64(len(p.lut)))
 	p.gpu_ok = true
 }
 
+// upload the explicitly set pairs, sorted by key for binary search on the GPU (see cuda/exchange16.h).
+func (p *exchParam) uploadPairs() {
+	n := len(p.pairs)
+	keys := make([]uint32, 0, n)
+	for k := range p.pairs {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	scale := make([]float32, n)
+	inter := make([]float32, n)
+	for i, k := range keys {
+		scale[i] = p.pairs[k].scale
+		inter[i] = p.pairs[k].inter
+	}
+
+	for _, ptr := range []unsafe.Pointer{p.gpu.Keys, p.gpu.Scale, p.gpu.Inter} {
+		if ptr != nil {
+			cu.MemFree(cu.DevicePtr(uintptr(ptr)))
+		}
+	}
+	p.gpu.Keys, p.gpu.Scale, p.gpu.Inter = nil, nil, nil
+	p.gpu.NPair = n
+	if n == 0 {
+		return
+	}
+	p.gpu.Keys = cuda.MemAlloc(int64(n) * cu.SIZEOF_FLOAT32) // uint32, same size
+	p.gpu.Scale = cuda.MemAlloc(int64(n) * cu.SIZEOF_FLOAT32)
+	p.gpu.Inter = cuda.MemAlloc(int64(n) * cu.SIZEOF_FLOAT32)
+	cuda.MemCpyHtoD(p.gpu.Keys, unsafe.Pointer(&keys[0]), int64(n)*cu.SIZEOF_FLOAT32)
+	cuda.MemCpyHtoD(p.gpu.Scale, unsafe.Pointer(&scale[0]), int64(n)*cu.SIZEOF_FLOAT32)
+	cuda.MemCpyHtoD(p.gpu.Inter, unsafe.Pointer(&inter[0]), int64(n)*cu.SIZEOF_FLOAT32)
+}
+
+// Key for a pair of regions in exchParam.pairs, independent of order.
+// (!) Code duplicated in exchange16.h
+func pairKey(i, j int) uint32 {
+	if i > j {
+		i, j = j, i
+	}
+	if i < 0 || j >= nregion {
+		util.Fatalf("region id should be 0 -%v, have: %v, %v", nregion-1, i, j)
+	}
+	return uint32(i)<<16 | uint32(j)
+}
+
 // Index in symmetric matrix where only one half is stored.
 // (!) Code duplicated in exchange.cu
 func symmidx(i, j int) int {
@@ -194,6 +294,7 @@ func symmidx(i, j int) int {
 }
 
 // Returns the intermediate value of two exchange/dmi strengths.
+// (!) Code duplicated in exchange16.h
 // If both arguments have the same sign, the average mean is returned. If the arguments differ in sign
 // (which is possible in the case of DMI), the geometric mean of the geometric and arithmetic mean is
 // used. This average is continuous everywhere, monotonic increasing, and bounded by the argument values.
@@ -28,6 +28,7 @@ func NewExcitation(name, unit, desc string) *Excitation {
 	e.name = name
 	e.perRegion.init(3, "_"+name+"_perRegion", unit, nil) // name starts with underscore: unexported
 	DeclLValue(name, e, cat(desc, unit))
+	excitations = append(excitations, e)
 	return e
 }
 
@@ -51,6 +52,17 @@ func (e *Excitation) AddTo(dst *data.Slice) {
 	}
 }
 
+// masks of the extra terms, shifted with the simulation window.
+func (e *Excitation) masks() []*data.Slice {
+	var m []*data.Slice
+	for _, t := range e.extraTerms {
+		if t.mask != nil {
+			m = append(m, t.mask)
+		}
+	}
+	return m
+}
+
 func (e *Excitation) isZero() bool {
 	return e.perRegion.isZero() && len(e.extraTerms) == 0
 }
@@ -107,7 +119,7 @@ func (e *Excitation) AddGo(mask *data.Slice, mul func() float64) {
 
 func (e *Excitation) SetRegion(region int, f script.VectorFunction) { e.perRegion.SetRegion(region, f) }
 func (e *Excitation) SetValue(v interface{})                        { e.perRegion.SetValue(v) }
-func (e *Excitation) Set(v data.Vector)                             { e.perRegion.setRegions(0, NREGION, slice(v)) }
+func (e *Excitation) Set(v data.Vector)                             { e.perRegion.setRegions(0, nregion, slice(v)) }
 func (e *Excitation) getRegion(region int) []float64                { return e.perRegion.getRegion(region) } // for gui
 
 func (e *Excitation) SetRegionFn(region int, f func() [3]float64) {
@@ -0,0 +1,72 @@
+package engine
+
+// Moving simulation window following a tracked object (skyrmion, bubble, vortex),
+// along x and/or y. The object is segmented like in ext_TrackObjects (see ext_TrackMz etc.),
+// the one nearest to the previously followed position is kept in the centre of the window.
+// The window only moves by whole cells, but the object position is measured with sub-cell
+// accuracy in the lab frame, so that sub-cell displacements accumulate until a shift is needed.
+
+import (
+	"math"
+
+	"github.com/mumax/3/data"
+)
+
+var (
+	FollowThreshold = 1.0 // shift when the object is this many cells off-centre
+	FollowPos       = NewVectorValue("ext_followpos", "m", "Position of the object followed by ext_centerObject", getFollowPos)
+	followPos       data.Vector // last position of the followed object
+	following       bool
+)
+
+func init() {
+	DeclFunc("ext_centerObject", CenterObject, "centerObject(followX, followY) shifts m after each step to keep the tracked object nearest to the window centre in the centre")
+	DeclVar("ext_FollowThreshold", &FollowThreshold, "ext_centerObject shifts the window when the object is this many cells off-centre (default=1)")
+}
+
+// This post-step function centers the simulation window on the tracked object nearest
+// to the centre, along x and/or y.
+func CenterObject(followX, followY bool) {
+	followPos = data.Vector{GetShiftPos(), GetShiftYPos(), 0}
+	following = true
+	PostStep(func() { centerObject(followX, followY) })
+}
+
+func centerObject(followX, followY bool) {
+	o := nearestObject(followPos)
+	if o == nil {
+		return
+	}
+	followPos = data.Vector{o.x, o.y, 0}
+
+	// offset from the window centre, in cells
+	c := Mesh().CellSize()
+	dx := (o.x - GetShiftPos()) / c[X]
+	dy := (o.y - GetShiftYPos()) / c[Y]
+	if followX && math.Abs(dx) >= FollowThreshold {
+		Shift(-int(math.Floor(dx + 0.5)))
+	}
+	if followY && math.Abs(dy) >= FollowThreshold {
+		YShift(-int(math.Floor(dy + 0.5)))
+	}
+}
+
+// the object nearest to position r, nil if there are none.
+func nearestObject(r data.Vector) *object {
+	var nearest *object
+	min := math.Inf(1)
+	for _, o := range findObjects() {
+		dx, dy := wrapDist(o.x-r[X], o.y-r[Y])
+		if d := math.Hypot(dx, dy); d < min {
+			nearest, min = o, d
+		}
+	}
+	return nearest
+}
+
+func getFollowPos() []float64 {
+	if !following {
+		return []float64{0, 0, 0}
+	}
+	return slice(followPos)
+}
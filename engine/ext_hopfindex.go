@@ -0,0 +1,294 @@
+package engine
+
+// 3D topological invariants.
+//
+// The Hopf index is computed from the emergent field
+// 	F_i = ½ ε_ijk m·(∂_j m × ∂_k m)
+// and its vector potential A (∇×A = F), obtained by an FFT Poisson solve:
+// 	H = -1/(16π²) ∫ F·A dV
+// Along open boundaries F is zero-padded, so that its periodic images do not interact.
+//
+// Bloch points are found as the dual cells (cubes between 8 neighbouring cell centres)
+// enclosing a non-zero solid-angle charge: the solid angles of the 12 triangles on the cube's
+// surface (according to Berg and Lüscher) add up to 4π times the enclosed charge.
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/mumax/3/cuda"
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/httpfs"
+	"github.com/mumax/3/util"
+)
+
+var (
+	Ext_HopfIndex             = NewScalarValue("ext_hopfindex", "", "Hopf index", GetHopfIndex)
+	Ext_EmergentField         = NewVectorField("ext_emergentfield", "1/m2", "Emergent field ½ ε_ijk m·(∂_j m ✕ ∂_k m)", SetEmergentField)
+	Ext_TopologicalChargeZ    = new(layerCharge)
+	Ext_BlochPointCount       = NewScalarValue("ext_blochpointcount", "", "Number of Bloch points", func() float64 { return float64(len(findBlochPoints())) })
+	Ext_BlochPointChargeTotal = NewScalarValue("ext_blochpointcharge", "", "Total charge of all Bloch points", GetBlochPointCharge)
+	blochTable                blochPointTable
+)
+
+func init() {
+	Export(Ext_TopologicalChargeZ, "2D topological charge of each layer along z, one table column per layer")
+	DeclFunc("ext_BlochPointsSave", BlochPointsSave, "Append the position and charge of all Bloch points to blochpoints.txt right now")
+	DeclFunc("ext_BlochPointsAutoSave", BlochPointsAutoSave, "Append the position and charge of all Bloch points to blochpoints.txt every period (s). Zero disables")
+}
+
+// Returns the Hopf index of the magnetization.
+func GetHopfIndex() float64 {
+	n := Mesh().Size()
+	pbc := Mesh().PBC()
+	F := emergentField()
+
+	// zero-pad along open boundaries
+	var p [3]int
+	for c := range p {
+		p[c] = n[c]
+		if pbc[c] == 0 {
+			p[c] = 2 * n[c]
+		}
+	}
+	padded := data.NewSlice(3, p)
+	src, dst := F.Vectors(), padded.Vectors()
+	for c := range src {
+		for iz := range src[c] {
+			for iy := range src[c][iz] {
+				copy(dst[c][iz][iy], src[c][iz][iy])
+			}
+		}
+	}
+	Fgpu := cuda.GPUCopy(padded)
+	defer Fgpu.Free()
+	return -cellVolume() * cuda.SumFDotCurlInverse(Fgpu, Mesh().CellSize()) / (16 * math.Pi * math.Pi)
+}
+
+// Sets dst to the emergent field.
+func SetEmergentField(dst *data.Slice) {
+	data.Copy(dst, emergentField())
+}
+
+// emergent field on the host, by central differences.
+// Derivatives are one-sided at the edges of the geometry, and zero across them.
+func emergentField() *data.Slice {
+	n := Mesh().Size()
+	m := M.Buffer().HostCopy().Vectors()
+	F := data.NewSlice(3, n)
+	f := F.Vectors()
+	for iz := 0; iz < n[Z]; iz++ {
+		for iy := 0; iy < n[Y]; iy++ {
+			for ix := 0; ix < n[X]; ix++ {
+				i := [3]int{ix, iy, iz}
+				m0 := vecAt(m, i)
+				if m0 == (data.Vector{}) {
+					continue
+				}
+				dx := derivative(m, i, X)
+				dy := derivative(m, i, Y)
+				dz := derivative(m, i, Z)
+				f[X][iz][iy][ix] = float32(m0.Dot(dy.Cross(dz)))
+				f[Y][iz][iy][ix] = float32(m0.Dot(dz.Cross(dx)))
+				f[Z][iz][iy][ix] = float32(m0.Dot(dx.Cross(dy)))
+			}
+		}
+	}
+	return F
+}
+
+// central difference of m along direction d at cell index i.
+func derivative(m [3][][][]float32, i [3]int, d int) data.Vector {
+	lo, hi := i, i
+	lo[d]--
+	hi[d]++
+	mlo, okLo := neighbourAt(m, lo)
+	mhi, okHi := neighbourAt(m, hi)
+	m0 := vecAt(m, i)
+	h := Mesh().CellSize()[d]
+	switch {
+	case okLo && okHi:
+		return mhi.Sub(mlo).Div(2 * h)
+	case okHi:
+		return mhi.Sub(m0).Div(h)
+	case okLo:
+		return m0.Sub(mlo).Div(h)
+	default:
+		return data.Vector{}
+	}
+}
+
+// m at index i, wrapped around periodic boundaries.
+// ok is false outside the mesh or the geometry.
+func neighbourAt(m [3][][][]float32, i [3]int) (v data.Vector, ok bool) {
+	n := Mesh().Size()
+	pbc := Mesh().PBC()
+	for c := range i {
+		if pbc[c] != 0 {
+			i[c] = (i[c] + n[c]) % n[c]
+		}
+		if i[c] < 0 || i[c] >= n[c] {
+			return data.Vector{}, false
+		}
+	}
+	v = vecAt(m, i)
+	return v, v != (data.Vector{})
+}
+
+func vecAt(m [3][][][]float32, i [3]int) data.Vector {
+	ix, iy, iz := i[X], i[Y], i[Z]
+	return data.Vector{float64(m[X][iz][iy][ix]), float64(m[Y][iz][iy][ix]), float64(m[Z][iz][iy][ix])}
+}
+
+// Topological charge of each layer along z, as a table quantity with one component per layer.
+type layerCharge struct{}
+
+func (q *layerCharge) Name() string { return "ext_topologicalchargez" }
+func (q *layerCharge) Unit() string { return "" }
+func (q *layerCharge) NComp() int   { return Mesh().Size()[Z] }
+
+// one column per layer, also for 2 or 3 layers
+func (q *layerCharge) CompName(c int) string { return fmt.Sprint(q.Name(), c) }
+
+func (q *layerCharge) average() []float64 {
+	s := ValueOf(Ext_TopologicalChargeDensity)
+	density := s.HostCopy().Scalars()
+	cuda.Recycle(s)
+	c := Mesh().CellSize()
+	Q := make([]float64, len(density))
+	for iz := range density {
+		for iy := range density[iz] {
+			for ix := range density[iz][iy] {
+				Q[iz] += float64(density[iz][iy][ix])
+			}
+		}
+		Q[iz] *= 0.25 * c[X] * c[Y] / math.Pi
+	}
+	return Q
+}
+
+func (q *layerCharge) EvalTo(dst *data.Slice) {
+	for c, v := range q.average() {
+		cuda.Memset(dst.Comp(c), float32(v))
+	}
+}
+
+// A Bloch point, at the centre of a dual cell.
+type blochPoint struct {
+	x, y, z float64 // position (m)
+	charge  float64
+}
+
+// Faces of the dual cell, as corner offsets (dx, dy, dz) ordered counter-clockwise
+// when seen from outside.
+var dualCellFaces = [6][4][3]int{
+	{{0, 0, 0}, {0, 0, 1}, {0, 1, 1}, {0, 1, 0}},
+	{{1, 0, 0}, {1, 1, 0}, {1, 1, 1}, {1, 0, 1}},
+	{{0, 0, 0}, {1, 0, 0}, {1, 0, 1}, {0, 0, 1}},
+	{{0, 1, 0}, {0, 1, 1}, {1, 1, 1}, {1, 1, 0}},
+	{{0, 0, 0}, {0, 1, 0}, {1, 1, 0}, {1, 0, 0}},
+	{{0, 0, 1}, {1, 0, 1}, {1, 1, 1}, {0, 1, 1}},
+}
+
+// returns all dual cells with a solid-angle charge of at least ½ in absolute value.
+// Dual cells with corners outside the geometry are skipped.
+func findBlochPoints() []blochPoint {
+	n := Mesh().Size()
+	pbc := Mesh().PBC()
+	c := Mesh().CellSize()
+	m := M.Buffer().HostCopy().Vectors()
+
+	// number of dual cells along each direction
+	var nd [3]int
+	for i := range nd {
+		nd[i] = n[i] - 1
+		if pbc[i] != 0 {
+			nd[i] = n[i]
+		}
+	}
+
+	var points []blochPoint
+	for iz := 0; iz < nd[Z]; iz++ {
+		for iy := 0; iy < nd[Y]; iy++ {
+		cell:
+			for ix := 0; ix < nd[X]; ix++ {
+				var corner [2][2][2]data.Vector
+				for dz := 0; dz < 2; dz++ {
+					for dy := 0; dy < 2; dy++ {
+						for dx := 0; dx < 2; dx++ {
+							v, ok := neighbourAt(m, [3]int{ix + dx, iy + dy, iz + dz})
+							if !ok {
+								continue cell
+							}
+							corner[dx][dy][dz] = v.Div(v.Len())
+						}
+					}
+				}
+				Ω := 0.
+				for _, face := range dualCellFaces {
+					var p [4]data.Vector
+					for k, o := range face {
+						p[k] = corner[o[X]][o[Y]][o[Z]]
+					}
+					Ω += solidAngle(p[0], p[1], p[2]) + solidAngle(p[0], p[2], p[3])
+				}
+				q := Ω / (4 * math.Pi)
+				if math.Abs(q) < 0.5 {
+					continue
+				}
+				r := Index2Coord(ix, iy, iz)
+				points = append(points, blochPoint{
+					x:      r[X] + 0.5*c[X],
+					y:      r[Y] + 0.5*c[Y],
+					z:      r[Z] + 0.5*c[Z],
+					charge: math.Floor(q + 0.5)})
+			}
+		}
+	}
+	return points
+}
+
+// signed solid angle spanned by the unit vectors a, b, c (Berg and Lüscher).
+func solidAngle(a, b, c data.Vector) float64 {
+	return 2 * math.Atan2(a.Dot(b.Cross(c)), 1+a.Dot(b)+b.Dot(c)+c.Dot(a))
+}
+
+// Returns the total charge of all Bloch points.
+func GetBlochPointCharge() float64 {
+	Q := 0.
+	for _, p := range findBlochPoints() {
+		Q += p.charge
+	}
+	return Q
+}
+
+// Save the Bloch point table now.
+func BlochPointsSave() {
+	blochTable.Save()
+}
+
+// Auto-save the Bloch point table every period.
+func BlochPointsAutoSave(period float64) {
+	blochTable.autosave = autosave{period, Time, -1, nil} // count -1 allows output on t=0
+}
+
+type blochPointTable struct {
+	output httpfs.WriteCloseFlusher
+	autosave
+}
+
+// appends one row per Bloch point to blochpoints.txt
+func (t *blochPointTable) Save() {
+	Refer("Berg1981")
+	if t.output == nil {
+		f, err := httpfs.Create(OD() + "blochpoints.txt")
+		util.FatalErr(err)
+		t.output = f
+		fprintln(f, "# t (s)\tx (m)\ty (m)\tz (m)\tcharge ()")
+	}
+	for _, p := range findBlochPoints() {
+		fprintln(t.output, Time, "\t", float32(p.x), "\t", float32(p.y), "\t", float32(p.z), "\t", float32(p.charge))
+	}
+	util.FatalErr(t.output.Flush())
+	t.count++
+}
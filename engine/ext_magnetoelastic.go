@@ -32,27 +32,22 @@ func init() {
 }
 
 func AddMagnetoelasticField(dst *data.Slice) {
+	addMagnetoelasticFieldFrom(dst, M, Msat, B1, B2)
+}
+
+// Adds the magneto-elastic field on magnetization M to dst.
+func addMagnetoelasticFieldFrom(dst *data.Slice, M magnetization, Msat, B1, B2 *RegionwiseScalar) {
 	haveMel := B1.nonZero() || B2.nonZero()
 	if !haveMel {
 		return
 	}
 
-	Exx := exx.MSlice()
+	Exx, Eyy, Ezz, Exy, Exz, Eyz := strainMSlices() // includes elastic strain
 	defer Exx.Recycle()
-
-	Eyy := eyy.MSlice()
 	defer Eyy.Recycle()
-
-	Ezz := ezz.MSlice()
 	defer Ezz.Recycle()
-
-	Exy := exy.MSlice()
 	defer Exy.Recycle()
-
-	Exz := exz.MSlice()
 	defer Exz.Recycle()
-
-	Eyz := eyz.MSlice()
 	defer Eyz.Recycle()
 
 	b1 := B1.MSlice()
@@ -70,25 +65,35 @@ func AddMagnetoelasticField(dst *data.Slice) {
 		b1, b2, ms)
 }
 
+// Sets dst to the magneto-elastic force density, of all sublattices.
 func GetMagnetoelasticForceDensity(dst *data.Slice) {
-	haveMel := B1.nonZero() || B2.nonZero()
-	if !haveMel {
-		return
-	}
+	cuda.Zero(dst)
+	buf := cuda.Buffer(3, dst.Size())
+	defer cuda.Recycle(buf)
+	for _, s := range sublattices[:numSublattices] {
+		if !(s.B1.nonZero() || s.B2.nonZero()) {
+			continue
+		}
+		util.AssertMsg(s.B1.IsUniform() && s.B2.IsUniform(), "Magnetoelastic: B1, B2 must be uniform")
 
-	util.AssertMsg(B1.IsUniform() && B2.IsUniform(), "Magnetoelastic: B1, B2 must be uniform")
+		b1 := s.B1.MSlice()
+		defer b1.Recycle()
 
-	b1 := B1.MSlice()
-	defer b1.Recycle()
-
-	b2 := B2.MSlice()
-	defer b2.Recycle()
+		b2 := s.B2.MSlice()
+		defer b2.Recycle()
 
-	cuda.GetMagnetoelasticForceDensity(dst, M.Buffer(),
-		b1, b2, M.Mesh())
+		cuda.GetMagnetoelasticForceDensity(buf, s.m.Buffer(),
+			b1, b2, s.m.Mesh())
+		cuda.Add(dst, dst, buf)
+	}
 }
 
 func AddMagnetoelasticEnergyDensity(dst *data.Slice) {
+	addMagnetoelasticEnergyDensityFrom(dst, M, Msat, B1, B2)
+}
+
+// Adds the magneto-elastic energy density of magnetization M to dst.
+func addMagnetoelasticEnergyDensityFrom(dst *data.Slice, M magnetization, Msat, B1, B2 *RegionwiseScalar) {
 	haveMel := B1.nonZero() || B2.nonZero()
 	if !haveMel {
 		return
@@ -98,25 +103,16 @@ func AddMagnetoelasticEnergyDensity(dst *data.Slice) {
 	defer cuda.Recycle(buf)
 
 	// unnormalized magnetization:
-	Mf := ValueOf(M_full)
+	Mf := cuda.Buffer(3, Mesh().Size())
 	defer cuda.Recycle(Mf)
+	setMFullFrom(Mf, M, Msat)
 
-	Exx := exx.MSlice()
+	Exx, Eyy, Ezz, Exy, Exz, Eyz := strainMSlices() // includes elastic strain
 	defer Exx.Recycle()
-
-	Eyy := eyy.MSlice()
 	defer Eyy.Recycle()
-
-	Ezz := ezz.MSlice()
 	defer Ezz.Recycle()
-
-	Exy := exy.MSlice()
 	defer Exy.Recycle()
-
-	Exz := exz.MSlice()
 	defer Exz.Recycle()
-
-	Eyz := eyz.MSlice()
 	defer Eyz.Recycle()
 
 	b1 := B1.MSlice()
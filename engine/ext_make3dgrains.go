@@ -5,6 +5,8 @@ package engine
 import (
 	"math"
 	"math/rand"
+
+	"github.com/mumax/3/util"
 )
 
 func init() {
@@ -15,6 +17,9 @@ func Voronoi3d(grainsize float64, startRegion int, numRegions int, inputShape Sh
 	Refer("Lel2014")
 	SetBusy(true)
 	defer SetBusy(false)
+	if startRegion+numRegions > nregion {
+		util.Fatal("ext_make3dgrains: at most ", nregion, " regions, use SetRegionBits(16) for more")
+	}
 
 	t := newTesselation3d(grainsize, numRegions, int64(seed), startRegion, inputShape)
 	regions.hist = append(regions.hist, t.RegionOf)
@@ -33,7 +38,7 @@ type tesselation3d struct {
 // Stores location of each Voronoi center
 type center3d struct {
 	x, y, z float64 // center position (m)
-	region  byte    // region for all cells near center
+	region  int     // region for all cells near center
 }
 
 // Stores location of each cell
@@ -82,7 +87,7 @@ func (t *tesselation3d) makeRandomCenters() {
 		t.centers[p].y = rndCell.y
 		t.centers[p].z = rndCell.z
 		randRegion := t.startRegion + t.rnd.Intn(t.maxRegion)
-		t.centers[p].region = byte(randRegion)
+		t.centers[p].region = randRegion
 	}
 
 	return
@@ -133,7 +138,7 @@ func (t *tesselation3d) RegionOf(x, y, z float64) int {
 				mindist = dist
 			}
 		}
-		return int(nearest.region)
+		return nearest.region
 	} else {
 		return -1 //When the regions are rendered, any region < 0 will not be rastered.
 	}
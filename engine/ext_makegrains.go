@@ -3,6 +3,8 @@ package engine
 import (
 	"math"
 	"math/rand"
+
+	"github.com/mumax/3/util"
 )
 
 func init() {
@@ -13,6 +15,9 @@ func Voronoi(grainsize float64, numRegions, seed int) {
 	Refer("Lel2014")
 	SetBusy(true)
 	defer SetBusy(false)
+	if numRegions > nregion {
+		util.Fatal("ext_makegrains: at most ", nregion, " regions, use SetRegionBits(16) for more")
+	}
 
 	t := newTesselation(grainsize, numRegions, int64(seed))
 	regions.hist = append(regions.hist, t.RegionOf)
@@ -34,7 +39,7 @@ type int2 struct{ x, y int }
 // Voronoi center info
 type center struct {
 	x, y   float64 // center position (m)
-	region byte    // region for all cells near center
+	region int     // region for all cells near center
 }
 
 // nRegion exclusive
@@ -73,7 +78,7 @@ func (t *tesselation) RegionOf(x, y, z float64) int {
 	}
 
 	//fmt.Println("nearest", x, y, ":", nearest)
-	return int(nearest.region)
+	return nearest.region
 }
 
 // Returns the list of Voronoi centers in tile(ix, iy), using only ix,iy to seed the random generator
@@ -95,7 +100,7 @@ func (t *tesselation) centersInTile(tx, ty int) []center {
 			// random position inside tile
 			c[i].x = x0 + t.rnd.Float64()*t.tilesize
 			c[i].y = y0 + t.rnd.Float64()*t.tilesize
-			c[i].region = byte(t.rnd.Intn(t.maxRegion))
+			c[i].region = t.rnd.Intn(t.maxRegion)
 		}
 		t.cache[pos] = c
 		return c
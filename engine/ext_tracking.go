@@ -0,0 +1,240 @@
+package engine
+
+// Tracking of multiple skyrmions, bubbles or vortices.
+//
+// The topological charge density (or mz) is averaged over z and segmented on the host
+// into connected components of cells (4-connectivity, wrapping around periodic boundaries):
+// 	charge mode: |q| > ext_TrackThreshold * max|q|
+// 	mz mode:     mz * ext_TrackCoreMz > ext_TrackThreshold
+// For each object, the position (weighted centre), radius sqrt(area/π), topological charge
+// and velocity are reported. The radius is that of the segmented area: in charge mode the
+// area where |q| exceeds the threshold, which for a large skyrmion is only its wall (an annulus),
+// not its core. Use mz mode for the core radius. Objects keep their ID between outputs by greedy
+// nearest-neighbour matching, new objects get a new ID.
+// Each output appends one row per object to objects.txt.
+
+import (
+	"math"
+	"sort"
+
+	"github.com/mumax/3/cuda"
+	"github.com/mumax/3/httpfs"
+	"github.com/mumax/3/util"
+)
+
+var (
+	TrackMz        = false // segment mz instead of the topological charge density
+	TrackCoreMz    = -1.0  // core magnetization in mz mode
+	TrackThreshold = 0.1   // segmentation threshold
+	TrackMaxDist   = 0.0   // maximum displacement between outputs to keep an ID (m), 0 = no limit
+	TrackMinCells  = 1     // smaller objects are ignored
+	ObjectCount    = NewScalarValue("ext_objectcount", "", "Number of tracked objects", func() float64 { return float64(len(findObjects())) })
+	tracker        objectTracker
+)
+
+func init() {
+	DeclFunc("ext_TrackObjects", TrackObjects, "Append the position, radius, charge and velocity of all objects to objects.txt every period (s). Zero disables")
+	DeclFunc("ext_TrackSave", TrackSave, "Append the position, radius, charge and velocity of all objects to objects.txt right now")
+	DeclVar("ext_TrackMz", &TrackMz, "Track objects by mz instead of topological charge density, their radius is then that of the core (default=false)")
+	DeclVar("ext_TrackCoreMz", &TrackCoreMz, "Core magnetization of tracked objects in mz mode: 1.0 or -1.0 (default=-1.0)")
+	DeclVar("ext_TrackThreshold", &TrackThreshold, "Object threshold: fraction of max. charge density, or mz*ext_TrackCoreMz in mz mode (default=0.1)")
+	DeclVar("ext_TrackMaxDist", &TrackMaxDist, "Maximum displacement (m) between outputs for an object to keep its ID, 0 = no limit (default=0)")
+	DeclVar("ext_TrackMinCells", &TrackMinCells, "Minimum number of cells of a tracked object (default=1)")
+}
+
+// Auto-save the object table every period.
+func TrackObjects(period float64) {
+	tracker.autosave = autosave{period, Time, -1, nil} // count -1 allows output on t=0
+}
+
+// Save the object table now.
+func TrackSave() {
+	tracker.Save()
+}
+
+// A tracked object.
+type object struct {
+	id      int
+	x, y    float64 // position (m)
+	radius  float64 // sqrt(area/π) of the segmented cells (m)
+	charge  float64 // topological charge
+	ncell   int
+	vx, vy  float64 // velocity (m/s)
+	matched bool
+}
+
+type objectTracker struct {
+	output  httpfs.WriteCloseFlusher
+	objects []*object // objects at previous output
+	time    float64   // time of previous output
+	nextID  int
+	autosave
+}
+
+func (t *objectTracker) Save() {
+	if t.output == nil {
+		f, err := httpfs.Create(OD() + "objects.txt")
+		util.FatalErr(err)
+		t.output = f
+		fprintln(f, "# t (s)\tid\tx (m)\ty (m)\tradius (m)\tcharge ()\tvx (m/s)\tvy (m/s)")
+	}
+	objs := findObjects()
+	t.match(objs)
+	for _, o := range objs {
+		fprintln(t.output, Time, "\t", o.id, "\t", float32(o.x), "\t", float32(o.y), "\t", float32(o.radius),
+			"\t", float32(o.charge), "\t", float32(o.vx), "\t", float32(o.vy))
+	}
+	util.FatalErr(t.output.Flush())
+	t.objects = objs
+	t.time = Time
+	t.count++
+}
+
+// give objects the ID of the nearest previous object, and set their velocity.
+func (t *objectTracker) match(objs []*object) {
+	type pair struct {
+		prev, cur *object
+		dist      float64
+	}
+	var pairs []pair
+	for _, p := range t.objects {
+		for _, c := range objs {
+			dx, dy := wrapDist(c.x-p.x, c.y-p.y)
+			d := math.Hypot(dx, dy)
+			if TrackMaxDist == 0 || d <= TrackMaxDist {
+				pairs = append(pairs, pair{p, c, d})
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].dist < pairs[j].dist })
+
+	dt := Time - t.time
+	for _, pr := range pairs {
+		if pr.prev.matched || pr.cur.matched {
+			continue
+		}
+		pr.prev.matched, pr.cur.matched = true, true
+		pr.cur.id = pr.prev.id
+		if dt > 0 {
+			dx, dy := wrapDist(pr.cur.x-pr.prev.x, pr.cur.y-pr.prev.y)
+			pr.cur.vx, pr.cur.vy = dx/dt, dy/dt
+		}
+	}
+	for _, c := range objs {
+		if !c.matched {
+			c.id = t.nextID
+			t.nextID++
+		}
+		c.matched = false // ready for next match
+	}
+}
+
+// displacement wrapped to the nearest periodic image
+func wrapDist(dx, dy float64) (float64, float64) {
+	w := Mesh().WorldSize()
+	pbc := Mesh().PBC()
+	if pbc[X] != 0 {
+		dx -= w[X] * math.Floor(dx/w[X]+0.5)
+	}
+	if pbc[Y] != 0 {
+		dy -= w[Y] * math.Floor(dy/w[Y]+0.5)
+	}
+	return dx, dy
+}
+
+// segments the current magnetization into objects.
+func findObjects() []*object {
+	n := Mesh().Size()
+	c := Mesh().CellSize()
+	pbc := Mesh().PBC()
+
+	// charge density and mz, averaged over z
+	qbuf := ValueOf(Ext_TopologicalChargeDensity)
+	q3 := qbuf.HostCopy().Scalars()
+	cuda.Recycle(qbuf)
+	var mz3 [][][]float32
+	if TrackMz {
+		mz3 = M.Buffer().Comp(Z).HostCopy().Scalars()
+	}
+	q := make([][]float64, n[Y])
+	sel := make([][]float64, n[Y]) // selection value per cell
+	max := 0.
+	for iy := range q {
+		q[iy] = make([]float64, n[X])
+		sel[iy] = make([]float64, n[X])
+		for ix := range q[iy] {
+			for iz := 0; iz < n[Z]; iz++ {
+				q[iy][ix] += float64(q3[iz][iy][ix]) / float64(n[Z])
+				if TrackMz {
+					sel[iy][ix] += float64(mz3[iz][iy][ix]) * TrackCoreMz / float64(n[Z])
+				}
+			}
+			if !TrackMz {
+				sel[iy][ix] = math.Abs(q[iy][ix])
+			}
+			max = math.Max(max, sel[iy][ix])
+		}
+	}
+	threshold := TrackThreshold
+	if !TrackMz {
+		threshold *= max
+		if max == 0 {
+			return nil
+		}
+	}
+
+	// flood fill, keeping unwrapped coordinates across periodic boundaries
+	label := make([][]bool, n[Y])
+	for iy := range label {
+		label[iy] = make([]bool, n[X])
+	}
+	type cell struct{ ix, iy, ux, uy int } // index and unwrapped index
+	var objs []*object
+	for iy0 := 0; iy0 < n[Y]; iy0++ {
+		for ix0 := 0; ix0 < n[X]; ix0++ {
+			if label[iy0][ix0] || sel[iy0][ix0] <= threshold {
+				continue
+			}
+			label[iy0][ix0] = true
+			stack := []cell{{ix0, iy0, ix0, iy0}}
+			var wsum, xsum, ysum, qsum float64
+			ncell := 0
+			for len(stack) > 0 {
+				cl := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				w := sel[cl.iy][cl.ix]
+				wsum += w
+				xsum += w * float64(cl.ux)
+				ysum += w * float64(cl.uy)
+				qsum += q[cl.iy][cl.ix]
+				ncell++
+				for _, d := range [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+					ix, iy := cl.ix+d[X], cl.iy+d[Y]
+					if pbc[X] != 0 {
+						ix = (ix + n[X]) % n[X]
+					}
+					if pbc[Y] != 0 {
+						iy = (iy + n[Y]) % n[Y]
+					}
+					if ix < 0 || ix >= n[X] || iy < 0 || iy >= n[Y] || label[iy][ix] || sel[iy][ix] <= threshold {
+						continue
+					}
+					label[iy][ix] = true
+					stack = append(stack, cell{ix, iy, cl.ux + d[X], cl.uy + d[Y]})
+				}
+			}
+			if ncell < TrackMinCells {
+				continue
+			}
+			x := c[X]*(xsum/wsum-0.5*float64(n[X]-1)) + GetShiftPos() // as Index2Coord
+			y := c[Y]*(ysum/wsum-0.5*float64(n[Y]-1)) + GetShiftYPos()
+			objs = append(objs, &object{
+				x:      x,
+				y:      y,
+				radius: math.Sqrt(float64(ncell) * c[X] * c[Y] / math.Pi),
+				charge: qsum * c[X] * c[Y] / (4 * math.Pi),
+				ncell:  ncell})
+		}
+	}
+	return objs
+}
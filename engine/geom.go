@@ -22,6 +22,7 @@ type geom struct {
 	info
 	buffer *data.Slice
 	shape  Shape
+	sdf    SDF // signed distance version of shape, if any
 }
 
 func (g *geom) init() {
@@ -71,6 +72,7 @@ func (g *geom) average() []float64 {
 func (g *geom) Average() float64 { return g.average()[0] }
 
 func SetGeom(s Shape) {
+	geometry.sdf = nil
 	geometry.setGeom(s)
 }
 
@@ -94,12 +96,14 @@ func (geometry *geom) setGeom(s Shape) {
 	v := array
 	n := geometry.Mesh().Size()
 	c := geometry.Mesh().CellSize()
-	cx, cy, cz := c[X], c[Y], c[Z]
+	cx, cy := c[X], c[Y]
 
 	progress, progmax := 0, n[Y]*n[Z]
 
 	var ok bool
 	for iz := 0; iz < n[Z]; iz++ {
+		cz := layerThicknessOf(iz)
+		frac := float32(cz / c[Z]) // layers thinner than the cell size only fill part of it
 		for iy := 0; iy < n[Y]; iy++ {
 
 			progress++
@@ -134,12 +138,12 @@ func (geometry *geom) setGeom(s Shape) {
 
 				switch {
 				case allIn:
-					v[iz][iy][ix] = 1
+					v[iz][iy][ix] = frac
 					ok = true
 				case allOut:
 					v[iz][iy][ix] = 0
 				default:
-					v[iz][iy][ix] = geometry.cellVolume(ix, iy, iz)
+					v[iz][iy][ix] = frac * geometry.cellVolume(ix, iy, iz)
 					ok = ok || (v[iz][iy][ix] != 0)
 				}
 			}
@@ -173,14 +177,20 @@ func (geometry *geom) setGeom(s Shape) {
 	}
 
 	M.normalize() // removes m outside vol
+	freeDemagTree()
 }
 
-// Sample edgeSmooth^3 points inside the cell to estimate its volume.
+// Sample edgeSmooth^3 points inside the cell to estimate its volume,
+// or use the signed distance function if the geometry has one.
 func (g *geom) cellVolume(ix, iy, iz int) float32 {
 	r := Index2Coord(ix, iy, iz)
 	x0, y0, z0 := r[X], r[Y], r[Z]
 
 	c := geometry.Mesh().CellSize()
+	c[Z] = layerThicknessOf(iz)
+	if g.sdf != nil {
+		return sdfCellVolume(g.sdf, x0, y0, z0, c)
+	}
 	cx, cy, cz := c[X], c[Y], c[Z]
 	s := geometry.shape
 	var vol float32
@@ -217,9 +227,10 @@ func (g *geom) shift(dx int) {
 	newv := float32(1) // initially fill edges with 1's
 	cuda.ShiftX(s2, s, dx, newv, newv)
 	data.Copy(s, s2)
+	shiftDemagTree() // occupied cells may have changed
 
 	n := Mesh().Size()
-	x1, x2 := shiftDirtyRange(dx)
+	x1, x2 := shiftDirtyRange(dx, n[X])
 
 	for iz := 0; iz < n[Z]; iz++ {
 		for iy := 0; iy < n[Y]; iy++ {
@@ -247,9 +258,10 @@ func (g *geom) shiftY(dy int) {
 	newv := float32(1) // initially fill edges with 1's
 	cuda.ShiftY(s2, s, dy, newv, newv)
 	data.Copy(s, s2)
+	shiftDemagTree() // occupied cells may have changed
 
 	n := Mesh().Size()
-	y1, y2 := shiftDirtyRange(dy)
+	y1, y2 := shiftDirtyRange(dy, n[Y])
 
 	for iz := 0; iz < n[Z]; iz++ {
 		for ix := 0; ix < n[X]; ix++ {
@@ -264,9 +276,8 @@ func (g *geom) shiftY(dy int) {
 
 }
 
-// x range that needs to be refreshed after shift over dx
-func shiftDirtyRange(dx int) (x1, x2 int) {
-	nx := Mesh().Size()[X]
+// index range (out of nx cells) that needs to be refreshed after shift over dx
+func shiftDirtyRange(dx, nx int) (x1, x2 int) {
 	util.Argument(dx != 0)
 	if dx < 0 {
 		x1 = nx + dx
@@ -13,6 +13,7 @@ import (
 var (
 	// These flags are shared between cmd/mumax3 and Go input files.
 	Flag_cachedir    = flag.String("cache", os.TempDir(), "Kernel cache directory (empty disables caching)")
+	Flag_demag       = flag.String("demag", "fft", `Demag solver: "fft" convolution, or "tree" (treecode) for sparse geometries, with about 0.4% error (see DemagTreeTheta)`)
 	Flag_gpu         = flag.Int("gpu", 0, "Specify GPU")
 	Flag_interactive = flag.Bool("i", false, "Open interactive browser session")
 	Flag_od          = flag.String("o", "", "Override output directory")
@@ -248,8 +248,8 @@ func (g *guistate) prepareM() {
 }
 
 var (
-	solvertypes = map[string]int{"bw_euler": -1, "euler": 1, "heun": 2, "rk23": 3, "rk4": 4, "rk45": 5, "rkf56": 6}
-	solvernames = map[int]string{-1: "bw_euler", 1: "euler", 2: "heun", 3: "rk23", 4: "rk4", 5: "rk45", 6: "rkf56"}
+	solvertypes = map[string]int{"bw_euler": -1, "euler": 1, "heun": 2, "rk23": 3, "rk4": 4, "rk45": 5, "rkf56": 6, "llb": 7, "mc": 8, "cayley": 9, "midpoint": 10}
+	solvernames = map[int]string{-1: "bw_euler", 1: "euler", 2: "heun", 3: "rk23", 4: "rk4", 5: "rk45", 6: "rkf56", 7: "llb", 8: "mc", 9: "cayley", 10: "midpoint"}
 )
 
 func Break() {
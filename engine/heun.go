@@ -11,8 +11,8 @@ type Heun struct{}
 
 // Adaptive Heun method, can be used as solver.Step
 func (_ *Heun) Step() {
-	y := M.Buffer()
-	dy0 := cuda.Buffer(VECTOR, y.Size())
+	y := solverState()
+	dy0 := cuda.Buffer(y.NComp(), y.Size())
 	defer cuda.Recycle(dy0)
 
 	if FixDt != 0 {
@@ -27,7 +27,7 @@ func (_ *Heun) Step() {
 	cuda.Madd2(y, y, dy0, 1, dt) // y = y + dt * dy
 
 	// stage 2
-	dy := cuda.Buffer(3, y.Size())
+	dy := cuda.Buffer(y.NComp(), y.Size())
 	defer cuda.Recycle(dy)
 	Time += Dt_si
 	torqueFn(dy)
@@ -38,7 +38,7 @@ func (_ *Heun) Step() {
 	if err < MaxErr || Dt_si <= MinDt || FixDt != 0 { // mindt check to avoid infinite loop
 		// step OK
 		cuda.Madd3(y, y, dy, dy0, 1, 0.5*dt, -0.5*dt)
-		M.normalize()
+		normalizeState()
 		NSteps++
 		adaptDt(math.Pow(MaxErr/err, 1./2.))
 		setLastErr(err)
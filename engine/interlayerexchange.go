@@ -0,0 +1,170 @@
+package engine
+
+// Interlayer (RKKY) exchange between two regions separated by a spacer along z.
+//
+// In each column of cells, the facing cells of both regions are coupled across the gap,
+// regardless of its thickness, with surface energy density
+// 	σ = -J1 m1·m2 - J2 (m1·m2)²
+// J1 > 0 is ferromagnetic, J1 < 0 antiferromagnetic (e.g. synthetic antiferromagnets),
+// J2 < 0 favours perpendicular alignment (biquadratic coupling).
+// The field on each facing cell is inversely proportional to the thickness of its layer.
+// See also cuda/interlayerexchange.cu
+
+import (
+	"unsafe"
+
+	"github.com/mumax/3/cuda"
+	"github.com/mumax/3/cuda/cu"
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/util"
+)
+
+var (
+	B_interlayer     = NewVectorField("B_interlayer", "T", "Interlayer exchange field", AddInterlayerExchangeField)
+	Edens_interlayer = NewScalarField("Edens_interlayer", "J/m3", "Interlayer exchange energy density", AddInterlayerExchangeEnergyDensity)
+	E_interlayer     = NewScalarValue("E_interlayer", "J", "Interlayer exchange energy", GetInterlayerExchangeEnergy)
+	interlayers      []*interlayerCoupling
+)
+
+func init() {
+	DeclFunc("InterlayerExchange", InterlayerExchange, "Sets the bilinear (J1) and biquadratic (J2) interlayer exchange (J/m2) "+
+		"between two regions separated along z, e.g. by a non-magnetic spacer")
+	registerEnergy(GetInterlayerExchangeEnergy, AddInterlayerExchangeEnergyDensity)
+}
+
+// Sets the interlayer exchange between region1 and region2, in J/m2.
+// Overrides the previous coupling between these regions, if any.
+func InterlayerExchange(region1, region2 int, J1, J2 float64) {
+	defRegionId(region1)
+	defRegionId(region2)
+	if region1 == region2 {
+		util.Fatal("InterlayerExchange: need two different regions, have: ", region1, ", ", region2)
+	}
+	for _, c := range interlayers {
+		if c.region1 == region1 && c.region2 == region2 || c.region1 == region2 && c.region2 == region1 {
+			c.J1, c.J2 = J1, J2
+			return
+		}
+	}
+	interlayers = append(interlayers, &interlayerCoupling{region1: region1, region2: region2, J1: J1, J2: J2})
+}
+
+// Adds the interlayer exchange field to dst.
+func AddInterlayerExchangeField(dst *data.Slice) {
+	addInterlayerExchangeFrom(dst, M, Msat)
+}
+
+// Adds the interlayer exchange field on magnetization M to dst.
+// With multiple sublattices, each one is coupled to itself across the spacer.
+func addInterlayerExchangeFrom(dst *data.Slice, M magnetization, Msat *RegionwiseScalar) {
+	if len(interlayers) == 0 {
+		return
+	}
+	ms := Msat.MSlice()
+	defer ms.Recycle()
+	dz := Mesh().CellSize()[Z]
+	for _, c := range interlayers {
+		c.update()
+		cuda.AddInterlayerExchange(dst, M.Buffer(), ms, c.idx1, c.idx2, c.ncol, c.J1, c.J2, dz, layersGpu())
+	}
+}
+
+// Adds the interlayer exchange energy density to dst.
+// Not obtained from the field, which is not linear in m for biquadratic coupling.
+// Per full cell volume, also for non-uniform layers, like the other energy densities.
+func AddInterlayerExchangeEnergyDensity(dst *data.Slice) {
+	addInterlayerExchangeEnergyDensityFrom(dst, M)
+}
+
+// Adds the interlayer exchange energy density of magnetization M to dst.
+func addInterlayerExchangeEnergyDensityFrom(dst *data.Slice, M magnetization) {
+	dz := Mesh().CellSize()[Z]
+	for _, c := range interlayers {
+		c.update()
+		cuda.AddInterlayerExchangeEnergyDensity(dst, M.Buffer(), c.idx1, c.idx2, c.ncol, c.J1, c.J2, dz)
+	}
+}
+
+// Returns the interlayer exchange energy in J.
+func GetInterlayerExchangeEnergy() float64 {
+	if len(interlayers) == 0 {
+		return 0
+	}
+	edens := cuda.Buffer(SCALAR, Mesh().Size())
+	defer cuda.Recycle(edens)
+	cuda.Zero(edens)
+	AddInterlayerExchangeEnergyDensity(edens)
+	return cellVolume() * float64(cuda.Sum(edens))
+}
+
+// Interlayer exchange between two regions, with the pairs of coupled cells per column.
+type interlayerCoupling struct {
+	region1, region2 int
+	J1, J2           float64        // bilinear and biquadratic coupling (J/m2)
+	idx1, idx2       unsafe.Pointer // per column: GPU int32 indices of both coupled cells, or -1
+	ncol             int            // number of columns
+	changes          int            // regions.changes when the pairs were found
+	size             [3]int         // mesh size when the pairs were found
+}
+
+// find the coupled cells again if the regions or mesh changed.
+func (c *interlayerCoupling) update() {
+	if c.idx1 != nil && c.changes == regions.changes && c.size == Mesh().Size() {
+		return
+	}
+	n := Mesh().Size()
+	reg := regions.HostArray()
+	ncol := n[X] * n[Y]
+	idx1 := make([]int32, ncol)
+	idx2 := make([]int32, ncol)
+
+	for iy := 0; iy < n[Y]; iy++ {
+		for ix := 0; ix < n[X]; ix++ {
+			// closest pair of cells of both regions in this column: the facing interfaces
+			z1, z2, dist := -1, -1, 0
+			for iz := 0; iz < n[Z]; iz++ {
+				r := int(reg[iz][iy][ix])
+				if r != c.region1 && r != c.region2 {
+					continue
+				}
+				for jz := 0; jz < n[Z]; jz++ {
+					if r2 := int(reg[jz][iy][ix]); r2 == r || r2 != c.region1 && r2 != c.region2 {
+						continue
+					}
+					d := iz - jz
+					if d < 0 {
+						d = -d
+					}
+					if z1 < 0 || d < dist {
+						z1, z2, dist = iz, jz, d
+					}
+				}
+			}
+			i := iy*n[X] + ix
+			idx1[i], idx2[i] = -1, -1
+			if z1 >= 0 {
+				idx1[i] = int32(data.Index(n, ix, iy, z1))
+				idx2[i] = int32(data.Index(n, ix, iy, z2))
+			}
+		}
+	}
+
+	c.free()
+	bytes := int64(ncol) * cu.SIZEOF_FLOAT32 // int32, same size
+	c.idx1 = cuda.MemAlloc(bytes)
+	c.idx2 = cuda.MemAlloc(bytes)
+	cuda.MemCpyHtoD(c.idx1, unsafe.Pointer(&idx1[0]), bytes)
+	cuda.MemCpyHtoD(c.idx2, unsafe.Pointer(&idx2[0]), bytes)
+	c.ncol = ncol
+	c.changes = regions.changes
+	c.size = n
+}
+
+func (c *interlayerCoupling) free() {
+	for _, ptr := range []unsafe.Pointer{c.idx1, c.idx2} {
+		if ptr != nil {
+			cu.MemFree(cu.DevicePtr(uintptr(ptr)))
+		}
+	}
+	c.idx1, c.idx2 = nil, nil
+}
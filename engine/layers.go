@@ -0,0 +1,204 @@
+package engine
+
+// Non-uniform cell size along z: a stack of layers with different thickness,
+// e.g. a thin layer at an interface, without forcing thin cells through the whole stack.
+//
+// The cell size along z is the largest layer thickness. Thinner layers fill a fraction
+// dz/cz of their cells, which is part of the cell volume fraction (geom),
+// so the moment, energies and averages are weighted by the layer volume.
+// The demag field is the sum of 2D convolutions between all pairs of layers (see mag.CalcLayeredDemagKernel),
+// the exchange and DMI stencils use the distance between layer centers.
+// Interlayer exchange and VCMA use the thickness of each layer.
+// VCDMI, the tree demag, bodies and the other z-dependent kernels (MFM, Oersted field, Zhang-Li torque,
+// spin accumulation), which assume uniform cells, are not supported and stop the simulation (see checkLayers).
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/mumax/3/cuda"
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/mag"
+	"github.com/mumax/3/util"
+)
+
+var (
+	layerThickness []float64                     // thickness of each layer, bottom to top, nil if uniform
+	layers_        *data.Slice                   // GPU copy of layerThickness
+	layerInvDz_    *data.Slice                   // 1/thickness of the layer of each cell
+	layerconv_     *cuda.LayeredDemagConvolution // demag convolution for non-uniform layers
+)
+
+func init() {
+	DeclFunc("SetCellSizeZ", SetCellSizeZ, "Sets the thickness of each layer (bottom to top) for a non-uniform cell size along z")
+}
+
+// Sets the thickness of each layer, bottom to top.
+// The number of cells along z becomes the number of layers.
+func SetCellSizeZ(dz ...interface{}) {
+	if len(dz) == 0 {
+		util.Fatal("SetCellSizeZ: need at least one layer thickness")
+	}
+	if lazy_gridsize == nil || lazy_cellsize == nil {
+		util.Fatal("SetCellSizeZ: set GridSize and CellSize first")
+	}
+	if macrospinMode() {
+		util.Fatal("SetCellSizeZ: not possible with macrospins")
+	}
+	if haveBodies() {
+		util.Fatal("SetCellSizeZ: not possible with bodies (SetBody)")
+	}
+	if lazy_pbc[Z] != 0 {
+		util.Fatal("SetCellSizeZ: non-uniform layers can not be periodic along z")
+	}
+	t := make([]float64, len(dz))
+	cz := 0.
+	for i := range dz {
+		t[i] = toFloat64(dz[i])
+		arg("CellSizeZ", t[i] > 0)
+		cz = math.Max(cz, t[i])
+	}
+
+	clearLayers()
+	SetMesh(lazy_gridsize[X], lazy_gridsize[Y], len(t), lazy_cellsize[X], lazy_cellsize[Y], cz, lazy_pbc[X], lazy_pbc[Y], 0)
+
+	layerThickness = t
+	host := data.NewSlice(1, [3]int{len(t), 1, 1})
+	for i, v := range t {
+		host.Host()[0][i] = float32(v)
+	}
+	layers_ = cuda.GPUCopy(host)
+
+	geometry.setGeom(geometry.shape) // volume fraction of thin layers
+	setLayerThermScale()
+	setLayerInvDz()
+	LogOut(fmt.Sprint("non-uniform layers: ", t))
+}
+
+// true if the layers have non-uniform thickness
+func layered() bool {
+	return layerThickness != nil
+}
+
+// stops the simulation if the named term, which does not support non-uniform layers, is used with them.
+func checkLayers(name string) {
+	if layered() {
+		util.Fatal(name, ": not supported with non-uniform layers (SetCellSizeZ)")
+	}
+}
+
+// back to uniform cell size, e.g. when the mesh changes
+func clearLayers() {
+	if !layered() {
+		return
+	}
+	layerThickness = nil
+	layers_.Free()
+	layers_ = nil
+	layerInvDz_.Free()
+	layerInvDz_ = nil
+	layerconv_.Free()
+	layerconv_ = nil
+	thermScale.Free()
+	thermScale = nil
+}
+
+// GPU copy of the layer thicknesses, nil slice if uniform.
+func layersGpu() *data.Slice {
+	if !layered() {
+		return data.NilSlice(1, Mesh().Size())
+	}
+	return layers_
+}
+
+// thickness of layer iz
+func layerThicknessOf(iz int) float64 {
+	if !layered() {
+		return Mesh().CellSize()[Z]
+	}
+	return layerThickness[iz]
+}
+
+// bottom z coordinate of layer iz (0 <= iz <= Nz), internal coordinates as Index2Coord.
+func layerBottom(iz int) float64 {
+	n := Mesh().Size()
+	c := Mesh().CellSize()
+	if !layered() {
+		return c[Z] * (float64(iz) - 0.5*float64(n[Z]))
+	}
+	z, total := 0., 0.
+	for i, t := range layerThickness {
+		if i < iz {
+			z += t
+		}
+		total += t
+	}
+	return z - total/2
+}
+
+// z coordinate of the center of layer iz.
+func layerCenter(iz int) float64 {
+	return layerBottom(iz) + layerThicknessOf(iz)/2
+}
+
+// fractional layer index of coordinate z, inverse of layerCenter
+func layerIndex(z float64) float64 {
+	Nz := len(layerThickness)
+	for iz := 0; iz < Nz-1; iz++ {
+		if z < layerBottom(iz+1) {
+			return float64(iz) + (z-layerCenter(iz))/layerThickness[iz]
+		}
+	}
+	return float64(Nz-1) + (z-layerCenter(Nz-1))/layerThickness[Nz-1]
+}
+
+// layer thicknesses for output of data on mesh m, nil if uniform or not the global mesh
+func layerMeta(m *data.Mesh) []float64 {
+	if !layered() || m != Mesh() {
+		return nil
+	}
+	return append([]float64{}, layerThickness...)
+}
+
+// thermal noise scale 1/sqrt(volume fraction) of each layer
+func setLayerThermScale() {
+	scale := data.NewSlice(1, Mesh().Size())
+	s := scale.Scalars()
+	cz := Mesh().CellSize()[Z]
+	for iz := range s {
+		v := float32(1 / math.Sqrt(layerThicknessOf(iz)/cz))
+		for iy := range s[iz] {
+			for ix := range s[iz][iy] {
+				s[iz][iy][ix] = v
+			}
+		}
+	}
+	thermScale = cuda.GPUCopy(scale)
+}
+
+// 1/thickness of the layer of each cell
+func setLayerInvDz() {
+	inv := data.NewSlice(1, Mesh().Size())
+	s := inv.Scalars()
+	for iz := range s {
+		v := float32(1 / layerThicknessOf(iz))
+		for iy := range s[iz] {
+			for ix := range s[iz][iy] {
+				s[iz][iy][ix] = v
+			}
+		}
+	}
+	layerInvDz_ = cuda.GPUCopy(inv)
+}
+
+// returns the layered demag convolution, making sure it's initialized
+func layerDemagConv() *cuda.LayeredDemagConvolution {
+	if layerconv_ == nil {
+		SetBusy(true)
+		defer SetBusy(false)
+		util.Log("//Not using kernel cache for non-uniform layers")
+		kernel := mag.CalcLayeredDemagKernel(Mesh().Size(), Mesh().PBC(), Mesh().CellSize(), layerThickness, DemagAccuracy)
+		layerconv_ = cuda.NewLayeredDemag(Mesh().Size(), kernel, *Flag_selftest)
+	}
+	return layerconv_
+}
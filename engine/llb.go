@@ -0,0 +1,140 @@
+package engine
+
+// Landau-Lifshitz-Bloch (LLB) dynamics: the length of m is not fixed,
+// but relaxes towards the equilibrium magnetization m_e(T) (see thermalscaling.go).
+// Selected with SetSolver(LLB), integrated with an (adaptive) Heun scheme.
+// Spin-transfer and spin-orbit torques are not included in LLB mode.
+
+import (
+	"math"
+
+	"github.com/mumax/3/cuda"
+	"github.com/mumax/3/cuda/curand"
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/mag"
+	"github.com/mumax/3/util"
+)
+
+var (
+	ChiPar   = NewScalarParam("ChiPar", "1/T", "LLB longitudinal susceptibility")
+	llbnoise llbNoise // thermal noise for LLB
+)
+
+// Heun solver for the LLB equation.
+type LLBSolver struct{}
+
+func (_ *LLBSolver) Step() {
+	if numSublattices != 1 {
+		util.Fatal("LLB solver does not support multiple sublattices")
+	}
+	y := M.Buffer()
+	dy0 := cuda.Buffer(VECTOR, y.Size())
+	defer cuda.Recycle(dy0)
+
+	if FixDt != 0 {
+		Dt_si = FixDt
+	}
+
+	dt := float32(Dt_si * GammaLL)
+	util.Assert(dt > 0)
+
+	// stage 1
+	torqueFn(dy0)
+	cuda.Madd2(y, y, dy0, 1, dt) // y = y + dt * dy
+
+	// stage 2
+	dy := cuda.Buffer(3, y.Size())
+	defer cuda.Recycle(dy)
+	Time += Dt_si
+	torqueFn(dy)
+
+	err := cuda.MaxVecDiff(dy0, dy) * float64(dt)
+
+	// adjust next time step
+	if err < MaxErr || Dt_si <= MinDt || FixDt != 0 { // mindt check to avoid infinite loop
+		// step OK, m is not normalized
+		cuda.Madd3(y, y, dy, dy0, 1, 0.5*dt, -0.5*dt)
+		NSteps++
+		adaptDt(math.Pow(MaxErr/err, 1./2.))
+		setLastErr(err)
+		setMaxTorque(dy)
+	} else {
+		// undo bad step
+		util.Assert(FixDt == 0)
+		Time -= Dt_si
+		cuda.Madd2(y, y, dy0, 1, -dt)
+		NUndone++
+		adaptDt(math.Pow(MaxErr/err, 1./3.))
+	}
+}
+
+func (_ *LLBSolver) Free() {}
+
+// Sets dst to the LLB torque, including thermal noise.
+func SetLLBTorque(dst *data.Slice) {
+	SetEffectiveField(dst) // without B_therm in LLB mode
+	llbnoise.update()
+
+	lambda := Alpha.MSlice()
+	defer lambda.Recycle()
+	temp := Temp.MSlice()
+	defer temp.Recycle()
+	tc := Tc.MSlice()
+	defer tc.Recycle()
+	me, _ := msatScaling.factor.Slice()
+	defer cuda.Recycle(me)
+	chi := ChiPar.MSlice()
+	defer chi.Recycle()
+	ms := Msat.MSlice() // zero-temperature value in LLB mode
+	defer ms.Recycle()
+
+	k2_VgammaDt := 2 * mag.Kb / (GammaLL * cellVolume() * Dt_si)
+	cuda.LLBTorque(dst, M.Buffer(), dst, llbnoise.perp, llbnoise.par,
+		lambda, temp, tc, cuda.ToMSlice(me), chi, ms, k2_VgammaDt)
+}
+
+// Standard normal noise for the transverse and longitudinal LLB thermal terms,
+// kept constant during a time step.
+type llbNoise struct {
+	perp, par *data.Slice
+	step      int
+	dt        float64
+}
+
+func (n *llbNoise) update() {
+	if n.perp == nil || n.perp.Size() != Mesh().Size() {
+		n.free()
+		n.perp = cuda.NewSlice(3, Mesh().Size())
+		n.par = cuda.NewSlice(3, Mesh().Size())
+		n.step = -1
+	}
+	if Temp.isZero() {
+		cuda.Zero(n.perp)
+		cuda.Zero(n.par)
+		return
+	}
+	if n.step == NSteps && n.dt == Dt_si {
+		return
+	}
+
+	// share the generator (and seed) of B_therm
+	if B_therm.generator == 0 {
+		B_therm.generator = curand.CreateGenerator(curand.PSEUDO_DEFAULT)
+		B_therm.generator.SetSeed(B_therm.seed)
+	}
+	N := int64(Mesh().NCell())
+	for c := 0; c < 3; c++ {
+		B_therm.generator.GenerateNormal(uintptr(n.perp.DevPtr(c)), N, 0, 1)
+		B_therm.generator.GenerateNormal(uintptr(n.par.DevPtr(c)), N, 0, 1)
+	}
+	n.step = NSteps
+	n.dt = Dt_si
+}
+
+func (n *llbNoise) free() {
+	if n.perp != nil {
+		n.perp.Free()
+		n.par.Free()
+		n.perp, n.par = nil, nil
+	}
+}
@@ -10,10 +10,10 @@ import (
 
 // look-up table for region based parameters
 type lut struct {
-	gpu_buf cuda.LUTPtrs       // gpu copy of cpu buffer, only transferred when needed
-	gpu_ok  bool               // gpu cache up-to date with cpu source?
-	cpu_buf [][NREGION]float32 // table data on cpu
-	source  updater            // updates cpu data
+	gpu_buf cuda.LUTPtrs // gpu copy of cpu buffer, only transferred when needed
+	gpu_ok  bool         // gpu cache up-to date with cpu source?
+	cpu_buf [][]float32  // table data on cpu, one value per region
+	source  updater      // updates cpu data
 }
 
 type updater interface {
@@ -22,12 +22,39 @@ type updater interface {
 
 func (p *lut) init(nComp int, source updater) {
 	p.gpu_buf = make(cuda.LUTPtrs, nComp)
-	p.cpu_buf = make([][NREGION]float32, nComp)
+	p.cpu_buf = make([][]float32, nComp)
+	for c := range p.cpu_buf {
+		p.cpu_buf[c] = make([]float32, nregion)
+	}
 	p.source = source
 }
 
+// make room for all regions after switching to 16-bit regions (SetRegionBits).
+// New regions get the value of region 0. Returns true if the table was grown.
+func (p *lut) grow() bool {
+	if len(p.cpu_buf[0]) == nregion {
+		return false
+	}
+	for c, old := range p.cpu_buf {
+		buf := make([]float32, nregion)
+		copy(buf, old)
+		for r := len(old); r < nregion; r++ {
+			buf[r] = old[0]
+		}
+		p.cpu_buf[c] = buf
+	}
+	for c, ptr := range p.gpu_buf {
+		if ptr != nil {
+			cu.MemFree(cu.DevicePtr(uintptr(ptr)))
+			p.gpu_buf[c] = nil
+		}
+	}
+	p.gpu_ok = false
+	return true
+}
+
 // get an up-to-date version of the lookup-table on CPU
-func (p *lut) cpuLUT() [][NREGION]float32 {
+func (p *lut) cpuLUT() [][]float32 {
 	p.source.update()
 	return p.cpu_buf
 }
@@ -40,7 +67,7 @@ func (p *lut) gpuLUT() cuda.LUTPtrs {
 		p.assureAlloc()
 		cuda.Sync() // sync previous kernels, may still be using gpu lut
 		for c := range p.gpu_buf {
-			cuda.MemCpyHtoD(p.gpu_buf[c], unsafe.Pointer(&p.cpu_buf[c][0]), cu.SIZEOF_FLOAT32*NREGION)
+			cuda.MemCpyHtoD(p.gpu_buf[c], unsafe.Pointer(&p.cpu_buf[c][0]), cu.SIZEOF_FLOAT32*int64(len(p.cpu_buf[c])))
 		}
 		p.gpu_ok = true
 		cuda.Sync() //sync upload
@@ -58,7 +85,7 @@ func (p *lut) gpuLUT1() cuda.LUTPtr {
 func (p *lut) isZero() bool {
 	v := p.cpuLUT()
 	for c := range v {
-		for i := 0; i < NREGION; i++ {
+		for i := range v[c] {
 			if v[c][i] != 0 {
 				return false
 			}
@@ -72,7 +99,7 @@ func (p *lut) nonZero() bool { return !p.isZero() }
 func (p *lut) assureAlloc() {
 	if p.gpu_buf[0] == nil {
 		for i := range p.gpu_buf {
-			p.gpu_buf[i] = cuda.MemAlloc(NREGION * cu.SIZEOF_FLOAT32)
+			p.gpu_buf[i] = cuda.MemAlloc(int64(len(p.cpu_buf[i])) * cu.SIZEOF_FLOAT32)
 		}
 	}
 }
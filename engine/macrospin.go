@@ -0,0 +1,188 @@
+package engine
+
+// Macrospin mode: every region is a single moment instead of a micromagnetic mesh.
+//
+// Each macrospin occupies one cell of an N x 1 x 1 grid, in its own region, so that all
+// region-wise parameters, excitations, torques, the thermal field and table outputs apply unchanged.
+// The cell volume fraction (geometry) holds the volume of each macrospin,
+// so averages and energies are weighted by volume.
+// The demag field is the self-field of each macrospin's demag tensor N
+// plus the point-dipole field of all other macrospins:
+// 	B_i = -μ0 N_i M_i + Σ_j μ0/(4π r³) (3(μ_j·r̂)r̂ - μ_j),  μ_j = M_j V_j
+// Exchange, DMI and the Zhang-Li torque act between neighbouring cells and are absent.
+// Bodies (SetBody) are not supported.
+// Unless set by the user, FreeLayerThickness is the z size of each macrospin.
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/mumax/3/cuda"
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/mag"
+	"github.com/mumax/3/util"
+)
+
+var macrospins []*macrospin // cell i holds macrospins[i]
+
+func init() {
+	DeclFunc("DefMacrospin", DefMacrospin, `Turns region into a single moment with given center, size and shape ("ellipsoid" or "cuboid"), replacing the mesh by one cell per macrospin`)
+	DeclFunc("MacrospinDemagTensor", MacrospinDemagTensor, "Sets the demag tensor (Nxx, Nyy, Nzz, Nxy, Nxz, Nyz) of a macrospin region")
+}
+
+type macrospin struct {
+	region int
+	center data.Vector
+	size   data.Vector
+	volume float64
+	N      [3][3]float64 // demag tensor
+	flt    float64       // FreeLayerThickness set by setupMacrospins, 0 if set by the user
+}
+
+// Defines region as a macrospin with given center, size and shape,
+// its demag tensor is computed analytically.
+func DefMacrospin(region int, center, size data.Vector, shape string) {
+	defRegionId(region)
+	util.Argument(size[X] > 0 && size[Y] > 0 && size[Z] > 0)
+	if haveBodies() {
+		util.Fatal("DefMacrospin: not possible with bodies (SetBody)")
+	}
+
+	s := &macrospin{region: region, center: center, size: size}
+	var N [3]float64
+	switch strings.ToLower(shape) {
+	default:
+		util.Fatal("DefMacrospin: shape should be \"ellipsoid\" or \"cuboid\", have:", shape)
+	case "ellipsoid":
+		N = mag.EllipsoidDemagFactors(size)
+		s.volume = math.Pi / 6 * size[X] * size[Y] * size[Z]
+	case "cuboid":
+		N = mag.PrismDemagFactors(size)
+		s.volume = size[X] * size[Y] * size[Z]
+	}
+	for c := 0; c < 3; c++ {
+		s.N[c][c] = N[c]
+	}
+
+	replaced := false
+	for i, o := range macrospins {
+		if o.region == region {
+			s.flt = o.flt
+			macrospins[i] = s
+			replaced = true
+		} else {
+			util.AssertMsg(o.center != center, fmt.Sprint("DefMacrospin: region ", region, " has the same center as region ", o.region))
+		}
+	}
+	if !replaced {
+		macrospins = append(macrospins, s)
+	}
+	setupMacrospins()
+}
+
+// Sets the (symmetric) demag tensor of a macrospin region.
+func MacrospinDemagTensor(region int, Nxx, Nyy, Nzz, Nxy, Nxz, Nyz float64) {
+	s := macrospinOf(region)
+	s.N = [3][3]float64{
+		{Nxx, Nxy, Nxz},
+		{Nxy, Nyy, Nyz},
+		{Nxz, Nyz, Nzz}}
+}
+
+func macrospinOf(region int) *macrospin {
+	for _, s := range macrospins {
+		if s.region == region {
+			return s
+		}
+	}
+	util.Fatal("region", region, "is not a macrospin, use DefMacrospin first")
+	return nil
+}
+
+func macrospinMode() bool {
+	return len(macrospins) > 0
+}
+
+// (Re-)creates the mesh with one cell per macrospin, the largest one filling its cell.
+func setupMacrospins() {
+	n := len(macrospins)
+	vmax := 0.
+	for _, s := range macrospins {
+		vmax = math.Max(vmax, s.volume)
+	}
+	c := math.Cbrt(vmax)
+	SetMesh(n, 1, 1, c, c, c, 0, 0, 0)
+
+	l := make([]uint16, n)
+	vol := data.NewSlice(1, Mesh().Size())
+	scale := data.NewSlice(1, Mesh().Size())
+	v, sc := vol.Host()[0], scale.Host()[0]
+	for i, s := range macrospins {
+		l[i] = uint16(s.region)
+		v[i] = float32(s.volume / cellVolume())
+		sc[i] = float32(1 / math.Sqrt(s.volume/cellVolume()))
+
+		// Slonczewski torque acts on the macrospin thickness, unless set otherwise.
+		// Follows the thickness when the macrospin is redefined, as long as it was not changed by the user.
+		if t := FreeLayerThickness.GetRegion(s.region); t == 0 || t == s.flt {
+			FreeLayerThickness.SetRegionValueGo(s.region, s.size[Z])
+			s.flt = FreeLayerThickness.GetRegion(s.region)
+		} else {
+			s.flt = 0
+		}
+	}
+	regions.upload(l)
+
+	if geometry.Gpu().IsNil() {
+		geometry.buffer = cuda.NewSlice(1, Mesh().Size())
+	}
+	data.Copy(geometry.buffer, vol)
+
+	if thermScale != nil {
+		thermScale.Free()
+	}
+	thermScale = cuda.NewSlice(1, Mesh().Size())
+	data.Copy(thermScale, scale)
+}
+
+// Sets dst to the demag field of the macrospins: their self-demag field and mutual dipolar field.
+func setMacrospinDemagField(dst *data.Slice) {
+	Mfull := cuda.Buffer(VECTOR, dst.Size())
+	defer cuda.Recycle(Mfull)
+	setMagnetMoment(Mfull)
+	Mh := Mfull.HostCopy().Vectors()
+	moment := func(i int) data.Vector {
+		return data.Vector{float64(Mh[X][0][0][i]), float64(Mh[Y][0][0][i]), float64(Mh[Z][0][0][i])}
+	}
+	noDemag := NoDemagSpins.cpuLUT()[0]
+
+	B := data.NewSlice(VECTOR, dst.Size())
+	b := B.Vectors()
+	for i, si := range macrospins {
+		if noDemag[si.region] != 0 {
+			continue
+		}
+		var Bi data.Vector
+		Mi := moment(i)
+		for c := 0; c < 3; c++ {
+			for d := 0; d < 3; d++ {
+				Bi[c] -= mag.Mu0 * si.N[c][d] * Mi[d]
+			}
+		}
+		for j, sj := range macrospins {
+			if j == i || noDemag[sj.region] != 0 {
+				continue
+			}
+			r := si.center.Sub(sj.center)
+			d := r.Len()
+			rhat := r.Div(d)
+			mu := moment(j).Mul(sj.volume)
+			Bi = Bi.Add(rhat.Mul(3 * mu.Dot(rhat)).Sub(mu).Mul(mag.Mu0 / (4 * math.Pi * d * d * d)))
+		}
+		for c := 0; c < 3; c++ {
+			b[c][0][0][i] = float32(Bi[c])
+		}
+	}
+	data.Copy(dst, B)
+}
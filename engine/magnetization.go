@@ -7,7 +7,7 @@ import (
 	"reflect"
 )
 
-var M magnetization // reduced magnetization (unit length)
+var M = magnetization{name: "m"} // reduced magnetization (unit length)
 
 func init() { DeclLValue("m", &M, `Reduced magnetization (unit length)`) }
 
@@ -15,11 +15,12 @@ func init() { DeclLValue("m", &M, `Reduced magnetization (unit length)`) }
 // makes sure it's normalized etc.
 type magnetization struct {
 	buffer_ *data.Slice
+	name    string
 }
 
 func (m *magnetization) Mesh() *data.Mesh    { return Mesh() }
 func (m *magnetization) NComp() int          { return 3 }
-func (m *magnetization) Name() string        { return "m" }
+func (m *magnetization) Name() string        { return m.name }
 func (m *magnetization) Unit() string        { return "" }
 func (m *magnetization) Buffer() *data.Slice { return m.buffer_ } // todo: rename Gpu()?
 
@@ -28,10 +29,17 @@ func (m *magnetization) SetValue(v interface{})  { m.SetInShape(nil, v.(Config))
 func (m *magnetization) InputType() reflect.Type { return reflect.TypeOf(Config(nil)) }
 func (m *magnetization) Type() reflect.Type      { return reflect.TypeOf(new(magnetization)) }
 func (m *magnetization) Eval() interface{}       { return m }
-func (m *magnetization) average() []float64      { return sAverageMagnet(M.Buffer()) }
+func (m *magnetization) average() []float64      { return sAverageMagnet(m.Buffer()) }
 func (m *magnetization) Average() data.Vector    { return unslice(m.average()) }
 func (m *magnetization) normalize()              { cuda.Normalize(m.Buffer(), geometry.Gpu()) }
 
+// sublattices other than m are only allocated by SetNumSublattices
+func (m *magnetization) checkAlloc() {
+	if m.buffer_ == nil {
+		util.Fatal(m.name, ": sublattice not enabled, use SetNumSublattices first")
+	}
+}
+
 // allocate storage (not done by init, as mesh size may not yet be known then)
 func (m *magnetization) alloc() {
 	m.buffer_ = cuda.NewSlice(3, m.Mesh().Size())
@@ -39,6 +47,7 @@ func (m *magnetization) alloc() {
 }
 
 func (b *magnetization) SetArray(src *data.Slice) {
+	b.checkAlloc()
 	if src.Size() != b.Mesh().Size() {
 		src = data.Resample(src, b.Mesh().Size())
 	}
@@ -92,6 +101,7 @@ func (m *magnetization) Quantity() []float64 { return slice(m.Average()) }
 // Sets the magnetization inside the shape
 func (m *magnetization) SetInShape(region Shape, conf Config) {
 	checkMesh()
+	m.checkAlloc()
 
 	if region == nil {
 		region = universe
@@ -119,10 +129,11 @@ func (m *magnetization) SetInShape(region Shape, conf Config) {
 
 // set m to config in region
 func (m *magnetization) SetRegion(region int, conf Config) {
+	m.checkAlloc()
 	host := m.Buffer().HostCopy()
 	h := host.Vectors()
 	n := m.Mesh().Size()
-	r := byte(region)
+	r := uint16(region)
 
 	regionsArr := regions.HostArray()
 
@@ -37,6 +37,8 @@ func SetMesh(Nx, Ny, Nz int, cellSizeX, cellSizeY, cellSizeZ float64, pbcx, pbcy
 	arg("CellSize", cellSizeX > 0 && cellSizeY > 0 && cellSizeZ > 0)
 	arg("PBC", pbcx >= 0 && pbcy >= 0 && pbcz >= 0)
 
+	clearLayers() // see SetCellSizeZ
+
 	prevSize := globalmesh_.Size()
 	pbc := []int{pbcx, pbcy, pbcz}
 
@@ -52,13 +54,19 @@ func SetMesh(Nx, Ny, Nz int, cellSizeX, cellSizeY, cellSizeZ float64, pbcx, pbcy
 		// free everything
 		conv_.Free()
 		conv_ = nil
+		freeDemagTree()
 		mfmconv_.Free()
 		mfmconv_ = nil
+		oerstedconv_.Free()
+		oerstedconv_ = nil
 		cuda.FreeBuffers()
 
 		// resize everything
 		globalmesh_ = *data.NewMesh(Nx, Ny, Nz, cellSizeX, cellSizeY, cellSizeZ, pbc...)
 		M.resize()
+		for _, s := range sublattices[1:numSublattices] {
+			s.m.resize()
+		}
 		regions.resize()
 		geometry.buffer.Free()
 		geometry.buffer = data.NilSlice(1, Mesh().Size())
@@ -72,10 +80,14 @@ func SetMesh(Nx, Ny, Nz int, cellSizeX, cellSizeY, cellSizeZ float64, pbcx, pbcy
 		}
 
 		if Mesh().Size() != prevSize {
-			B_therm.noise.Free()
-			B_therm.noise = nil
+			for _, s := range sublattices {
+				s.therm.noise.Free()
+				s.therm.noise = nil
+			}
 		}
 	}
+	vcdmi.invalidate() // depends on the cell size
+
 	lazy_gridsize = []int{Nx, Ny, Nz}
 	lazy_cellsize = []float64{cellSizeX, cellSizeY, cellSizeZ}
 	lazy_pbc = []int{pbcx, pbcy, pbcz}
@@ -20,6 +20,7 @@ func init() {
 }
 
 func SetMFM(dst *data.Slice) {
+	checkLayers("MFM")
 	buf := cuda.Buffer(3, Mesh().Size())
 	defer cuda.Recycle(buf)
 	if mfmconv_ == nil {
@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"math"
+
+	"github.com/mumax/3/cuda"
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/util"
+)
+
+var (
+	MidpointTolerance = 1e-6 // convergence of the implicit midpoint iteration, max |Δm|
+	MidpointMaxIter   = 20   // maximum number of implicit midpoint iterations
+)
+
+func init() {
+	DeclVar("MidpointTolerance", &MidpointTolerance, "Convergence tolerance (max |Δm|) of the implicit midpoint solver (default=1e-6)")
+	DeclVar("MidpointMaxIter", &MidpointMaxIter, "Maximum number of iterations per step of the implicit midpoint solver (default=20)")
+}
+
+// Adaptive implicit midpoint solver:
+//
+//	m1 = m0 + dt τ((m0+m1)/2)
+//
+// solved by fixed-point iteration, with each iterate written as the Cayley rotation
+//
+//	m1 = cay(dt ω((m0+m1)/2)) m0
+//
+// which is equivalent, but keeps |m| exactly 1 even before convergence.
+// At alpha=0, the energy is conserved for energies quadratic in m (exchange, demag,
+// uniaxial anisotropy, Zeeman). The error is estimated against the explicit
+// first-order step cay(dt ω(m0)) m0, which is also the initial guess.
+type ImplicitMidpoint struct{}
+
+func (_ *ImplicitMidpoint) Step() {
+	y := solverState()
+	y0 := cuda.Buffer(y.NComp(), y.Size())
+	defer cuda.Recycle(y0)
+	data.Copy(y0, y)
+	t0 := Time
+
+	if FixDt != 0 {
+		Dt_si = FixDt
+	}
+
+	dt := float32(Dt_si * GammaLL)
+	util.Assert(dt > 0)
+
+	// explicit predictor, kept for error estimate
+	dy := cuda.Buffer(y.NComp(), y.Size())
+	defer cuda.Recycle(dy)
+	torqueFn(dy)
+	yp := cuda.Buffer(y.NComp(), y.Size())
+	defer cuda.Recycle(yp)
+	cuda.Cayley(yp, y0, y0, dy, dt)
+
+	y1 := cuda.Buffer(y.NComp(), y.Size())
+	defer cuda.Recycle(y1)
+	data.Copy(y1, yp)
+
+	// fixed-point iteration on the midpoint
+	Time = t0 + 0.5*Dt_si
+	converged := false
+	for i := 0; i < MidpointMaxIter && !converged; i++ {
+		cuda.Madd2(y, y0, y1, 0.5, 0.5) // state = midpoint
+		torqueFn(dy)
+		cuda.Cayley(y, y0, y, dy, dt) // midpoint no longer needed
+		converged = cuda.MaxVecDiff(y, y1) < MidpointTolerance
+		data.Copy(y1, y)
+	}
+	Time = t0 + Dt_si
+	err := cuda.MaxVecDiff(y1, yp)
+
+	// adjust next time step
+	if (err < MaxErr && converged) || Dt_si <= MinDt || FixDt != 0 { // mindt check to avoid infinite loop
+		// step OK, no normalization needed
+		if !converged {
+			LogOut("implicit midpoint iteration did not converge, consider smaller FixDt")
+		}
+		NSteps++
+		adaptDt(math.Pow(MaxErr/err, 1./2.))
+		setLastErr(err)
+		setMaxTorque(dy)
+	} else {
+		// undo bad step
+		util.Assert(FixDt == 0)
+		Time = t0
+		data.Copy(y, y0)
+		NUndone++
+		if converged {
+			adaptDt(math.Pow(MaxErr/err, 1./3.))
+		} else {
+			adaptDt(0.5)
+		}
+	}
+}
+
+func (_ *ImplicitMidpoint) Free() {}
@@ -5,6 +5,7 @@ package engine
 import (
 	"github.com/mumax/3/cuda"
 	"github.com/mumax/3/data"
+	"github.com/mumax/3/util"
 )
 
 var (
@@ -122,6 +123,9 @@ func (mini *Minimizer) Free() {
 func Minimize() {
 	Refer("exl2014")
 	SanityCheck()
+	if numSublattices != 1 {
+		util.Fatal("Minimize does not support multiple sublattices, use Relax")
+	}
 	// Save the settings we are changing...
 	prevType := solvertype
 	prevFixDt := FixDt
@@ -0,0 +1,290 @@
+package engine
+
+// Metropolis Monte Carlo sampling of thermal equilibrium states.
+// Selected with SetSolver(MONTECARLO). Each step is one sweep over all cells
+// in two checkerboard half-sweeps, so that all cells of one color can be updated
+// in parallel: their nearest neighbours (exchange, DMI) are all of the other color.
+// Energy changes include exchange, DMI, uniaxial anisotropy, Zeeman, Oersted and body fields
+// and the demag field, which is only refreshed every MCDemagRefresh sweeps,
+// except for the field of each cell on itself, which is evaluated exactly.
+// Energies scale with the cell volume fraction. Other terms are not supported and stop the simulation.
+// Frozen spins and cells outside the geometry are not updated.
+// Random numbers come from the thermal noise generator, seeded by ThermSeed.
+// There is no physical time: each sweep advances t by FixDt (if set) or dt,
+// so that Run() can be used as well as Steps().
+
+import (
+	"fmt"
+
+	"github.com/mumax/3/cuda"
+	"github.com/mumax/3/cuda/curand"
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/mag"
+	"github.com/mumax/3/util"
+)
+
+var (
+	MCStepSize     = 0.2   // standard deviation of trial moves
+	MCDemagRefresh = 10    // demag field refresh interval, in sweeps
+	MCHeatBath     = false // use heat-bath instead of Metropolis acceptance
+	mcTried        float64 // tried moves in last sweep
+	mcAccepted     float64 // accepted moves in last sweep
+	mcAcceptedAll  float64 // total accepted moves
+	mcTriedAll     float64 // total tried moves
+)
+
+func init() {
+	DeclVar("MCStepSize", &MCStepSize, "Standard deviation of Monte Carlo trial moves m' = m + MCStepSize*gaussian (default=0.2)")
+	DeclVar("MCDemagRefresh", &MCDemagRefresh, "Number of Monte Carlo sweeps between demag field updates (default=10)")
+	DeclVar("MCHeatBath", &MCHeatBath, "Use heat-bath (Glauber) instead of Metropolis acceptance (default=false)")
+	NewScalarValue("MCAcceptance", "", "Acceptance rate of the last Monte Carlo sweep", func() float64 { return acceptanceRate(mcAccepted, mcTried) })
+	NewScalarValue("MCAcceptanceTotal", "", "Acceptance rate of all Monte Carlo sweeps", func() float64 { return acceptanceRate(mcAcceptedAll, mcTriedAll) })
+}
+
+func acceptanceRate(a, b float64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return a / b
+}
+
+// Monte Carlo stepper.
+type MonteCarlo struct {
+	demag     *data.Slice     // cached demag field
+	selfDemag *data.Slice     // demag self-tensor of each cell, see setSelfDemag
+	selfN     [][3][3]float64 // demag self-tensor of a full cell in each layer
+	selfKey   string          // mesh for which selfN was calculated
+	checker   [2]*data.Slice  // -1 on cells of color c, +1 elsewhere
+	sweeps    int             // sweeps since the demag field was refreshed
+}
+
+func (mc *MonteCarlo) Step() {
+	checkMonteCarloTerms()
+	if MCDemagRefresh < 1 {
+		util.Fatal("MCDemagRefresh: need at least 1 sweep, have: ", MCDemagRefresh)
+	}
+	size := Mesh().Size()
+	for c, n := range size {
+		if Mesh().PBC()[c] != 0 && n%2 != 0 {
+			util.Fatal("Monte Carlo solver needs an even number of cells along periodic directions")
+		}
+	}
+	if mc.demag == nil || mc.demag.Size() != size {
+		mc.Free()
+		mc.demag = cuda.NewSlice(3, size)
+		mc.selfDemag = cuda.NewSlice(6, size)
+		for color := range mc.checker {
+			mc.checker[color] = checkerboard(size, color)
+		}
+	}
+	if mc.sweeps%MCDemagRefresh == 0 {
+		SetDemagField(mc.demag)
+		mc.setSelfDemag()
+		mc.sweeps = 0
+	}
+	mc.sweeps++
+
+	if B_therm.generator == 0 {
+		B_therm.generator = curand.CreateGenerator(curand.PSEUDO_DEFAULT)
+		B_therm.generator.SetSeed(B_therm.seed)
+	}
+
+	B := cuda.Buffer(3, size)
+	defer cuda.Recycle(B)
+	noise := cuda.Buffer(3, size)
+	defer cuda.Recycle(noise)
+	rnd := cuda.Buffer(1, size)
+	defer cuda.Recycle(rnd)
+	tried := cuda.Buffer(1, size)
+	defer cuda.Recycle(tried)
+	acc := cuda.Buffer(1, size)
+	defer cuda.Recycle(acc)
+	cuda.Zero(tried)
+	cuda.Zero(acc)
+
+	ms := Msat.MSlice()
+	defer ms.Recycle()
+	ku1 := Ku1.MSlice()
+	defer ku1.Recycle()
+	ku2 := Ku2.MSlice()
+	defer ku2.Recycle()
+	u := AnisU.MSlice()
+	defer u.Recycle()
+	temp := Temp.MSlice()
+	defer temp.Recycle()
+	frozen := FrozenSpins.MSlice()
+	defer frozen.Recycle()
+
+	N := int64(Mesh().NCell())
+	for color := 0; color < 2; color++ {
+		mc.setField(B, color)
+		for c := 0; c < 3; c++ {
+			B_therm.generator.GenerateNormal(uintptr(noise.DevPtr(c)), N, 0, 1)
+		}
+		B_therm.generator.GenerateUniform(uintptr(rnd.DevPtr(0)), N)
+		cuda.MonteCarloStep(M.Buffer(), B, noise, rnd, tried, acc, geometry.Gpu(), mc.selfDemag, ms, ku1, ku2, u, temp, frozen,
+			cellVolume(), MCStepSize, MCHeatBath, color, M.Mesh())
+	}
+
+	mcTried = float64(cuda.Sum(tried))
+	mcAccepted = float64(cuda.Sum(acc))
+	mcTriedAll += mcTried
+	mcAcceptedAll += mcAccepted
+
+	if FixDt != 0 {
+		Dt_si = FixDt
+	}
+	Time += Dt_si
+	NSteps++
+}
+
+// field of the neighbours of the cells of the given color: all terms linear in m,
+// with the cached demag field. The exchange self-term of these cells cancels in the
+// average of the exchange fields of m and of m with these cells reversed.
+func (mc *MonteCarlo) setField(dst *data.Slice, color int) {
+	data.Copy(dst, mc.demag)
+	B_ext.AddTo(dst)
+	AddOerstedField(dst)
+	AddBodiesField(dst)
+
+	size := dst.Size()
+	flipped := cuda.Buffer(3, size)
+	defer cuda.Recycle(flipped)
+	for c := 0; c < 3; c++ {
+		cuda.Mul(flipped.Comp(c), M.Buffer().Comp(c), mc.checker[color])
+	}
+	Bex := cuda.Buffer(3, size)
+	defer cuda.Recycle(Bex)
+	cuda.Zero(Bex)
+	AddExchangeField(Bex)
+	addExchangeFrom(Bex, magnetization{buffer_: flipped}, Msat, &lex2)
+	cuda.Madd2(dst, dst, Bex, 1, 0.5)
+}
+
+// stops the simulation if any term is active that the Monte Carlo energy change does not include.
+func checkMonteCarloTerms() {
+	for _, t := range []struct {
+		active bool
+		name   string
+	}{
+		{numSublattices != 1, "multiple sublattices"},
+		{Kc1.nonZero() || Kc2.nonZero() || Kc3.nonZero(), "cubic anisotropy"},
+		{len(interlayers) != 0, "interlayer exchange"},
+		{!VCMACoefficient.isZero() && !Efield.isZero(), "VCMA"},
+		{B1.nonZero() || B2.nonZero(), "magnetoelastic coupling"},
+		{!Dspin.isZero() && !LambdaJ.isZero(), "spin accumulation"},
+		{len(customTerms) != 0, "custom fields"},
+	} {
+		if t.active {
+			util.Fatal("Monte Carlo solver does not support ", t.name)
+		}
+	}
+}
+
+// Sets mc.selfDemag to the demag self-tensor N of each cell (xx, yy, zz, yz, xz, xy),
+// so that the demag field of a cell on itself is -μ0 Ms N m, like in SetDemagField:
+// scaled by the volume fraction on a mesh, not for macrospins. Zero where there is no demag field.
+func (mc *MonteCarlo) setSelfDemag() {
+	size := Mesh().Size()
+	h := data.NewSlice(6, size)
+	if EnableDemag {
+		if !macrospinMode() {
+			mc.initSelfN()
+		}
+		var vol []float32
+		if !geometry.Gpu().IsNil() {
+			vol = geometry.Gpu().HostCopy().Host()[0]
+		}
+		noDemag := NoDemagSpins.cpuLUT()[0]
+		regs := regions.HostList()
+		a := h.Host()
+		for i := range regs {
+			if noDemag[regs[i]] != 0 {
+				continue
+			}
+			var N [3][3]float64
+			s := 1.
+			if macrospinMode() {
+				N = macrospins[i].N
+			} else {
+				N = mc.selfN[i/(size[X]*size[Y])]
+				if vol != nil {
+					s = float64(vol[i])
+				}
+			}
+			for k, c := range [6][2]int{{X, X}, {Y, Y}, {Z, Z}, {Y, Z}, {X, Z}, {X, Y}} {
+				a[k][i] = float32(s * N[c[0]][c[1]])
+			}
+		}
+	}
+	data.Copy(mc.selfDemag, h)
+}
+
+// initializes mc.selfN from the demag kernel of a single cell (or a column of layers) at zero offset.
+func (mc *MonteCarlo) initSelfN() {
+	key := fmt.Sprint(Mesh().Size(), Mesh().CellSize(), layerThickness, DemagAccuracy)
+	if mc.selfKey == key {
+		return
+	}
+	Nz := Mesh().Size()[Z]
+	c := Mesh().CellSize()
+	pbc := [3]int{0, 0, 0} // periodic images are not part of the cell
+	mc.selfN = make([][3][3]float64, Nz)
+	var kernel [][3][3]*data.Slice // kernel at zero offset of each layer
+	if layered() {
+		k := mag.CalcLayeredDemagKernel([3]int{1, 1, Nz}, pbc, c, layerThickness, DemagAccuracy)
+		for iz := range k {
+			kernel = append(kernel, k[iz][iz])
+		}
+	} else {
+		k := mag.DemagKernel([3]int{1, 1, 1}, pbc, c, DemagAccuracy, *Flag_cachedir)
+		for iz := 0; iz < Nz; iz++ {
+			kernel = append(kernel, k)
+		}
+	}
+	for iz, k := range kernel {
+		for i := 0; i < 3; i++ {
+			for j := i; j < 3; j++ {
+				if k[i][j] != nil {
+					N := -float64(k[i][j].Host()[0][0]) // kernel is -N
+					mc.selfN[iz][i][j] = N
+					mc.selfN[iz][j][i] = N
+				}
+			}
+		}
+	}
+	mc.selfKey = key
+}
+
+// returns a GPU mask which is -1 on cells with (ix+iy+iz)%2 == color, +1 elsewhere.
+func checkerboard(size [3]int, color int) *data.Slice {
+	h := data.NewSlice(1, size)
+	a := h.Scalars()
+	for iz := range a {
+		for iy := range a[iz] {
+			for ix := range a[iz][iy] {
+				a[iz][iy][ix] = 1
+				if (ix+iy+iz)%2 == color {
+					a[iz][iy][ix] = -1
+				}
+			}
+		}
+	}
+	return cuda.GPUCopy(h)
+}
+
+func (mc *MonteCarlo) Free() {
+	if mc.demag != nil {
+		mc.demag.Free()
+		mc.demag = nil
+		mc.selfDemag.Free()
+		mc.selfDemag = nil
+	}
+	for color, c := range mc.checker {
+		if c != nil {
+			c.Free()
+			mc.checker[color] = nil
+		}
+	}
+	mc.sweeps = 0
+}
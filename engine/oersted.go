@@ -0,0 +1,61 @@
+package engine
+
+// Oersted field generated by the current density J,
+// by FFT convolution with the Biot-Savart kernel.
+
+import (
+	"github.com/mumax/3/cuda"
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/mag"
+)
+
+var (
+	B_oersted     = NewVectorField("B_oersted", "T", "Oersted field of current density J", AddOerstedField)
+	Edens_oersted = NewScalarField("Edens_oersted", "J/m3", "Oersted field energy density", AddEdens_oersted)
+	E_oersted     = NewScalarValue("E_oersted", "J", "Oersted field energy", GetOerstedEnergy)
+	EnableOersted = false                  // enable/disable Oersted field of J
+	oerstedconv_  *cuda.OerstedConvolution // does the heavy lifting
+)
+
+var AddEdens_oersted = makeEdensAdder(&B_oersted, -1)
+
+func init() {
+	DeclVar("EnableOersted", &EnableOersted, "Enables/disables the Oersted field of current density J (default=false)")
+	registerEnergy(GetOerstedEnergy, AddEdens_oersted)
+}
+
+// Adds the Oersted field of J to dst, if enabled.
+// J only flows inside the geometry.
+func AddOerstedField(dst *data.Slice) {
+	if !EnableOersted || J.isZero() {
+		return
+	}
+	checkLayers("Oersted field")
+	j, rec := J.Slice()
+	if rec {
+		defer cuda.Recycle(j)
+	}
+	B := cuda.Buffer(3, Mesh().Size())
+	defer cuda.Recycle(B)
+	oerstedConv().Exec(B, j, geometry.Gpu())
+	cuda.Add(dst, dst, B)
+}
+
+// The Oersted field is not generated by m, so the energy is Zeeman-like.
+func GetOerstedEnergy() float64 {
+	if !EnableOersted {
+		return 0
+	}
+	return -1 * cellVolume() * dot(&M_full, &B_oersted)
+}
+
+// returns the Oersted convolution, making sure it's initialized
+func oerstedConv() *cuda.OerstedConvolution {
+	if oerstedconv_ == nil {
+		SetBusy(true)
+		defer SetBusy(false)
+		kernel := mag.OerstedKernel(Mesh().Size(), Mesh().PBC(), Mesh().CellSize(), DemagAccuracy, *Flag_cachedir)
+		oerstedconv_ = cuda.NewOersted(Mesh().Size(), Mesh().PBC(), kernel, *Flag_selftest)
+	}
+	return oerstedconv_
+}
@@ -20,14 +20,16 @@ import (
 // input parameter, settable by user
 type regionwise struct {
 	lut
-	upd_reg    [NREGION]func() []float64 // time-dependent values
-	timestamp  float64                   // used not to double-evaluate f(t)
-	children   []derived                 // derived parameters
+	upd_reg    []func() []float64 // time-dependent values
+	timestamp  float64            // used not to double-evaluate f(t)
+	children   []derived          // derived parameters
+	thermal    *thermalScaling    // temperature dependence, nil if none (see thermalscaling.go)
 	name, unit string
 }
 
 func (p *regionwise) init(nComp int, name, unit string, children []derived) {
 	p.lut.init(nComp, p)
+	p.upd_reg = make([]func() []float64, nregion)
 	p.name = name
 	p.unit = unit
 	p.children = children
@@ -67,11 +69,29 @@ func contains(s []derived, x derived) bool {
 	return false
 }
 
+// make room for all regions after switching to 16-bit regions (SetRegionBits).
+// New regions get the value (or function of time) of region 0.
+func (p *regionwise) grow() {
+	if p.lut.grow() {
+		upd := make([]func() []float64, nregion)
+		copy(upd, p.upd_reg)
+		for r := len(p.upd_reg); r < nregion; r++ {
+			upd[r] = p.upd_reg[0]
+		}
+		p.upd_reg = upd
+		if p.thermal != nil {
+			p.thermal.grow()
+		}
+		p.invalidate()
+	}
+}
+
 func (p *regionwise) update() {
+	p.grow()
 	if p.timestamp != Time {
 		changed := false
 		// update functions of time
-		for r := 0; r < NREGION; r++ {
+		for r := range p.upd_reg {
 			updFunc := p.upd_reg[r]
 			if updFunc != nil {
 				p.bufset_(r, updFunc())
@@ -83,6 +103,9 @@ func (p *regionwise) update() {
 			p.invalidate()
 		}
 	}
+	if p.thermal != nil {
+		p.thermal.apply(p)
+	}
 }
 
 // set in one region
@@ -96,13 +119,14 @@ func (p *regionwise) setRegion(region int, v []float64) {
 
 // set in all regions
 func (p *regionwise) setUniform(v []float64) {
-	p.setRegions(0, NREGION, v)
+	p.setRegions(0, nregion, v)
 }
 
 // set in regions r1..r2(excl)
 func (p *regionwise) setRegions(r1, r2 int, v []float64) {
 	util.Argument(len(v) == len(p.cpu_buf))
 	util.Argument(r1 < r2) // exclusive upper bound
+	p.grow()
 	for r := r1; r < r2; r++ {
 		p.upd_reg[r] = nil
 		p.bufset_(r, v)
@@ -111,6 +135,10 @@ func (p *regionwise) setRegions(r1, r2 int, v []float64) {
 }
 
 func (p *regionwise) bufset_(region int, v []float64) {
+	if p.thermal != nil {
+		p.thermal.set(region, v)
+		return
+	}
 	for c := range p.cpu_buf {
 		p.cpu_buf[c][region] = float32(v[c])
 	}
@@ -118,6 +146,7 @@ func (p *regionwise) bufset_(region int, v []float64) {
 
 func (p *regionwise) setFunc(r1, r2 int, f func() []float64) {
 	util.Argument(r1 < r2) // exclusive upper bound
+	p.grow()
 	for r := r1; r < r2; r++ {
 		p.upd_reg[r] = f
 	}
@@ -144,7 +173,7 @@ func (p *regionwise) getRegion(region int) []float64 {
 func (p *regionwise) IsUniform() bool {
 	cpu := p.cpuLUT()
 	v1 := p.getRegion(0)
-	for r := 1; r < NREGION; r++ {
+	for r := 1; r < len(cpu[0]); r++ {
 		for c := range v1 {
 			if cpu[c][r] != float32(v1[c]) {
 				return false
@@ -201,6 +230,9 @@ func (p *DerivedParam) update() {
 	for _, par := range p.parents {
 		par.update() // may invalidate me
 	}
+	if p.lut.grow() {
+		p.uptodate = false
+	}
 	if !p.uptodate {
 		p.updater(p)
 		p.gpu_ok = false
@@ -242,7 +274,7 @@ func NewScalarParam(name, unit, desc string, children ...derived) *RegionwiseSca
 
 func (p *RegionwiseScalar) SetRegion(region int, f script.ScalarFunction) {
 	if region == -1 {
-		p.setRegionsFunc(0, NREGION, f) // uniform
+		p.setRegionsFunc(0, nregion, f) // uniform
 	} else {
 		p.setRegionsFunc(region, region+1, f) // upper bound exclusive
 	}
@@ -250,11 +282,11 @@ func (p *RegionwiseScalar) SetRegion(region int, f script.ScalarFunction) {
 
 func (p *RegionwiseScalar) SetValue(v interface{}) {
 	f := v.(script.ScalarFunction)
-	p.setRegionsFunc(0, NREGION, f)
+	p.setRegionsFunc(0, nregion, f)
 }
 
 func (p *RegionwiseScalar) Set(v float64) {
-	p.setRegions(0, NREGION, []float64{v})
+	p.setRegions(0, nregion, []float64{v})
 }
 
 func (p *RegionwiseScalar) setRegionsFunc(r1, r2 int, f script.ScalarFunction) {
@@ -296,7 +328,7 @@ func cat(desc, unit string) string {
 
 func (p *RegionwiseScalar) SetRegionValueGo(region int, v float64) {
 	if region == -1 {
-		p.setRegions(0, NREGION, []float64{v})
+		p.setRegions(0, nregion, []float64{v})
 	} else {
 		p.setRegions(region, region+1, []float64{v})
 	}
@@ -304,7 +336,7 @@ func (p *RegionwiseScalar) SetRegionValueGo(region int, v float64) {
 
 func (p *RegionwiseScalar) SetRegionFuncGo(region int, f func() float64) {
 	if region == -1 {
-		p.setFunc(0, NREGION, func() []float64 {
+		p.setFunc(0, nregion, func() []float64 {
 			return []float64{f()}
 		})
 	} else {
@@ -330,7 +362,7 @@ func NewVectorParam(name, unit, desc string) *RegionwiseVector {
 
 func (p *RegionwiseVector) SetRegion(region int, f script.VectorFunction) {
 	if region == -1 {
-		p.setRegionsFunc(0, NREGION, f) //uniform
+		p.setRegionsFunc(0, nregion, f) //uniform
 	} else {
 		p.setRegionsFunc(region, region+1, f)
 	}
@@ -338,7 +370,7 @@ func (p *RegionwiseVector) SetRegion(region int, f script.VectorFunction) {
 
 func (p *RegionwiseVector) SetValue(v interface{}) {
 	f := v.(script.VectorFunction)
-	p.setRegionsFunc(0, NREGION, f)
+	p.setRegionsFunc(0, nregion, f)
 }
 
 func (p *RegionwiseVector) setRegionsFunc(r1, r2 int, f script.VectorFunction) {
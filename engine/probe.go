@@ -0,0 +1,215 @@
+package engine
+
+// Probes, line profiles and plane cuts sample a quantity at arbitrary
+// world positions using trilinear interpolation between cell centers.
+// They produce only a handful of numbers, so they can be tabulated or
+// saved at a much higher rate than full OVF files.
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/mumax/3/cuda"
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/util"
+)
+
+func init() {
+	DeclFunc("Probe", Probe, "Samples a quantity at position x,y,z (m) with trilinear interpolation, e.g. for use with TableAdd")
+	DeclFunc("LineProfile", LineProfile, "Samples a quantity in n points on the line from p1 to p2 (m), can be saved as a 1D file")
+	DeclFunc("PlaneCut", PlaneCut, "Samples a quantity in n1 x n2 points on the plane spanned by vectors e1 and e2 (m) from corner p0 (m), can be saved as a 2D file")
+}
+
+// Quantity sampled in a single point.
+type probe struct {
+	parent Quantity
+	name   string
+	pos    data.Vector
+}
+
+// Probe returns the value of parent at world position (x,y,z),
+// interpolated between the surrounding cell centers.
+func Probe(parent Quantity, x, y, z float64) *probe {
+	util.Argument(SizeOf(parent) == MeshSize())
+	name := fmt.Sprint(NameOf(parent), "_probe", x, "_", y, "_", z, "_")
+	return &probe{parent, name, data.Vector{x, y, z}}
+}
+
+func (q *probe) NComp() int   { return q.parent.NComp() }
+func (q *probe) Name() string { return q.name }
+func (q *probe) Unit() string { return UnitOf(q.parent) }
+
+func (q *probe) EvalTo(dst *data.Slice) {
+	v := q.average()
+	for c := range v {
+		cuda.Memset(dst.Comp(c), float32(v[c]))
+	}
+}
+
+// only the 2x2x2 cells surrounding the probe are downloaded.
+func (q *probe) average() []float64 {
+	i0, i1, w := interpolationStencil(q.pos)
+	x1, y1, z1 := i0[X], i0[Y], i0[Z]
+	cell := Download(Crop(q.parent, x1, i1[X]+1, y1, i1[Y]+1, z1, i1[Z]+1))
+	for c := 0; c < 3; c++ {
+		i1[c] -= i0[c]
+		i0[c] = 0
+	}
+	return trilinear(cell, i0, i1, w)
+}
+
+func (q *probe) Average() []float64 { return q.average() } // handy for script
+
+// Quantity sampled in n equidistant points on a line.
+type lineProfile struct {
+	parent Quantity
+	name   string
+	p1, p2 data.Vector
+	n      int
+}
+
+// LineProfile samples parent in n equidistant points from p1 to p2 (inclusive).
+// The result is a 1D quantity of n x 1 x 1 cells.
+func LineProfile(parent Quantity, p1, p2 data.Vector, n int) *lineProfile {
+	util.Argument(SizeOf(parent) == MeshSize())
+	util.Argument(n > 0)
+	return &lineProfile{parent, NameOf(parent) + "_line", p1, p2, n}
+}
+
+func (q *lineProfile) NComp() int             { return q.parent.NComp() }
+func (q *lineProfile) Name() string           { return q.name }
+func (q *lineProfile) Unit() string           { return UnitOf(q.parent) }
+func (q *lineProfile) EvalTo(dst *data.Slice) { EvalTo(q, dst) }
+
+// Cell size along x is the distance between sampling points.
+func (q *lineProfile) Mesh() *data.Mesh {
+	c := Mesh().CellSize()
+	return data.NewMesh(q.n, 1, 1, sampleStep(q.p2.Sub(q.p1), q.n, c[X]), c[Y], c[Z])
+}
+
+func (q *lineProfile) average() []float64 { return qAverageUniverse(q) } // needed for table
+func (q *lineProfile) Average() []float64 { return q.average() }         // handy for script
+
+func (q *lineProfile) Slice() (*data.Slice, bool) {
+	src := Download(q.parent)
+	out := data.NewSlice(q.NComp(), q.Mesh().Size())
+	Δ := q.p2.Sub(q.p1).Div(math.Max(float64(q.n-1), 1))
+	for i := 0; i < q.n; i++ {
+		sampleTo(out, i, 0, src, q.p1.MAdd(float64(i), Δ))
+	}
+	dst := cuda.Buffer(q.NComp(), out.Size())
+	data.Copy(dst, out)
+	return dst, true
+}
+
+// Quantity sampled on a regular grid in a (possibly oblique) plane.
+type planeCut struct {
+	parent Quantity
+	name   string
+	p0     data.Vector
+	e1, e2 data.Vector
+	n1, n2 int
+}
+
+// PlaneCut samples parent in the plane p0 + u*e1 + v*e2, with u,v in [0,1],
+// using n1 x n2 equidistant points. The result is a 2D quantity of n1 x n2 x 1 cells.
+func PlaneCut(parent Quantity, p0, e1, e2 data.Vector, n1, n2 int) *planeCut {
+	util.Argument(SizeOf(parent) == MeshSize())
+	util.Argument(n1 > 0 && n2 > 0)
+	return &planeCut{parent, NameOf(parent) + "_plane", p0, e1, e2, n1, n2}
+}
+
+func (q *planeCut) NComp() int             { return q.parent.NComp() }
+func (q *planeCut) Name() string           { return q.name }
+func (q *planeCut) Unit() string           { return UnitOf(q.parent) }
+func (q *planeCut) EvalTo(dst *data.Slice) { EvalTo(q, dst) }
+
+func (q *planeCut) Mesh() *data.Mesh {
+	c := Mesh().CellSize()
+	return data.NewMesh(q.n1, q.n2, 1, sampleStep(q.e1, q.n1, c[X]), sampleStep(q.e2, q.n2, c[Y]), c[Z])
+}
+
+func (q *planeCut) average() []float64 { return qAverageUniverse(q) } // needed for table
+func (q *planeCut) Average() []float64 { return q.average() }         // handy for script
+
+func (q *planeCut) Slice() (*data.Slice, bool) {
+	src := Download(q.parent)
+	out := data.NewSlice(q.NComp(), q.Mesh().Size())
+	Δ1 := q.e1.Div(math.Max(float64(q.n1-1), 1))
+	Δ2 := q.e2.Div(math.Max(float64(q.n2-1), 1))
+	for j := 0; j < q.n2; j++ {
+		for i := 0; i < q.n1; i++ {
+			sampleTo(out, i, j, src, q.p0.MAdd(float64(i), Δ1).MAdd(float64(j), Δ2))
+		}
+	}
+	dst := cuda.Buffer(q.NComp(), out.Size())
+	data.Copy(dst, out)
+	return dst, true
+}
+
+// distance between n sampling points spread over vector l,
+// or def if there is only one point.
+func sampleStep(l data.Vector, n int, def float64) float64 {
+	if n < 2 || l.Len() == 0 {
+		return def
+	}
+	return l.Len() / float64(n-1)
+}
+
+// interpolate host slice src at position r and store in cell (ix, iy, 0) of dst.
+func sampleTo(dst *data.Slice, ix, iy int, src *data.Slice, r data.Vector) {
+	i0, i1, w := interpolationStencil(r)
+	v := trilinear(src, i0, i1, w)
+	for c := range v {
+		dst.Set(c, ix, iy, 0, v[c])
+	}
+}
+
+// Returns the indices of the cells surrounding position r,
+// and the weights of the upper cells (i1) along each direction.
+// Positions outside the mesh are clamped to the nearest edge.
+func interpolationStencil(r data.Vector) (i0, i1 [3]int, w [3]float64) {
+	n := Mesh().Size()
+	f := coord2Index(r)
+	for c := 0; c < 3; c++ {
+		x := math.Min(math.Max(f[c], 0), float64(n[c]-1))
+		i0[c] = int(math.Floor(x))
+		i1[c] = i0[c] + 1
+		w[c] = x - float64(i0[c])
+		if i1[c] > n[c]-1 {
+			i1[c] = n[c] - 1
+			w[c] = 0
+		}
+	}
+	return
+}
+
+// trilinear interpolation of host slice s between cells i0 and i1 with weights w.
+func trilinear(s *data.Slice, i0, i1 [3]int, w [3]float64) []float64 {
+	idx := func(k, c int) int {
+		if k == 0 {
+			return i0[c]
+		}
+		return i1[c]
+	}
+	wgt := func(k, c int) float64 {
+		if k == 0 {
+			return 1 - w[c]
+		}
+		return w[c]
+	}
+	v := make([]float64, s.NComp())
+	for c := range v {
+		for kz := 0; kz < 2; kz++ {
+			for ky := 0; ky < 2; ky++ {
+				for kx := 0; kx < 2; kx++ {
+					W := wgt(kx, X) * wgt(ky, Y) * wgt(kz, Z)
+					if W != 0 {
+						v[c] += W * s.Get(c, idx(kx, X), idx(ky, Y), idx(kz, Z))
+					}
+				}
+			}
+		}
+	}
+	return v
+}
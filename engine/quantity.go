@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"fmt"
 	"github.com/mumax/3/cuda"
 	"github.com/mumax/3/data"
 	"reflect"
@@ -60,6 +61,24 @@ func UnitOf(q Quantity) string {
 	return "?"
 }
 
+// Name of component c of q, e.g. for table headers:
+// mx, my, mz for vectors, or numbered if q has more components.
+func CompNameOf(q Quantity, c int) string {
+	if q.NComp() == 1 {
+		return NameOf(q)
+	}
+	// quantity defines its own, custom, implementation:
+	if s, ok := q.(interface {
+		CompName(c int) string
+	}); ok {
+		return s.CompName(c)
+	}
+	if q.NComp() <= 3 {
+		return NameOf(q) + string(rune('x'+c))
+	}
+	return fmt.Sprint(NameOf(q), c)
+}
+
 func MeshOf(q Quantity) *data.Mesh {
 	// quantity defines its own, custom, implementation:
 	if s, ok := q.(interface {
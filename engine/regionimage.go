@@ -0,0 +1,211 @@
+package engine
+
+// Region definitions from label images (e.g. lithography layouts)
+// and stacks of images along z (e.g. tomography segmentations).
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"github.com/mumax/3/httpfs"
+	"github.com/mumax/3/util"
+)
+
+func init() {
+	DeclFunc("RegionsFromImage", RegionsFromImage, "Define regions from image colors. Optional arguments are pairs "+
+		"of color and region, e.g. \"#ff0000\", 1, \"#00ff00\", 2. Without pairs, the gray level (0-255) is the region index")
+	DeclFunc("RegionsFromImageStack", RegionsFromImageStack, "Define regions from a sequence of images along z, "+
+		"e.g. \"slice%03d.png\" numbered from 0. Optional color, region pairs as in RegionsFromImage")
+	DeclFunc("RemapRegions", RemapRegions, "Reassign regions according to pairs of old and new region index, "+
+		"e.g. 1, 2, 2, 1 swaps regions 1 and 2")
+	DeclFunc("MergeRegions", MergeRegions, "Reassign all listed regions (remaining arguments) to the first region")
+}
+
+// maps image colors to region index, -1 means no region
+type colorTable map[color.NRGBA]int
+
+// RegionsFromImage sets the regions from the colors of an image,
+// stretched onto the xy-plane of the grid, for all z.
+func RegionsFromImage(fname string, table ...interface{}) {
+	tab := parseColorTable(table)
+	img := loadRegionImage(fname, tab)
+	f := func(x, y, z float64) int { return img.regionAt(x, y) }
+	regions.render(f)
+	regions.hist = append(regions.hist, f)
+}
+
+// RegionsFromImageStack sets the regions from a sequence of images,
+// fmtName is a printf pattern for the file names, numbered from 0.
+// The images are distributed evenly over the z-layers of the grid.
+func RegionsFromImageStack(fmtName string, table ...interface{}) {
+	tab := parseColorTable(table)
+	var stack []*regionImage
+	for i := 0; ; i++ {
+		fname := fmt.Sprintf(fmtName, i)
+		f, err := httpfs.Open(fname)
+		if err != nil {
+			break
+		}
+		f.Close()
+		stack = append(stack, loadRegionImage(fname, tab))
+	}
+	if len(stack) == 0 {
+		util.Fatal("RegionsFromImageStack: no images found matching ", fmtName)
+	}
+
+	n := Mesh().Size()
+	c := Mesh().CellSize()
+	f := func(x, y, z float64) int {
+		iz := int((z/c[Z] + 0.5*float64(n[Z])) * float64(len(stack)) / float64(n[Z]))
+		if iz < 0 || iz >= len(stack) {
+			return -1
+		}
+		return stack[iz].regionAt(x, y)
+	}
+	regions.render(f)
+	regions.hist = append(regions.hist, f)
+}
+
+// RemapRegions reassigns regions according to pairs of old, new region index.
+// All pairs are applied at once, so regions can be swapped.
+func RemapRegions(pairs ...interface{}) {
+	if len(pairs)%2 != 0 {
+		util.Fatal("RemapRegions: need pairs of region indices, have ", len(pairs), " numbers")
+	}
+	remap := make(map[int]int)
+	for i := 0; i < len(pairs); i += 2 {
+		from, to := toInt(pairs[i]), toInt(pairs[i+1])
+		defRegionId(from)
+		defRegionId(to)
+		remap[from] = to
+	}
+	remapRegions(remap)
+}
+
+// MergeRegions reassigns all given regions to region target.
+func MergeRegions(target int, others ...interface{}) {
+	defRegionId(target)
+	remap := make(map[int]int)
+	for _, r := range others {
+		id := toInt(r)
+		defRegionId(id)
+		remap[id] = target
+	}
+	remapRegions(remap)
+}
+
+func remapRegions(remap map[int]int) {
+	hist_len := len(regions.hist) // Only consider hist before this remap to avoid recursion
+	f := func(x, y, z float64) int {
+		value := -1
+		for i := hist_len - 1; i >= 0; i-- {
+			region := regions.hist[i](x, y, z)
+			if region >= 0 {
+				value = region
+				break
+			}
+		}
+		if to, ok := remap[value]; ok {
+			return to
+		}
+		return value
+	}
+	regions.remap(remap)
+	regions.hist = append(regions.hist, f)
+}
+
+// image decoded into region indices, for fast pixel lookup
+type regionImage struct {
+	width, height int
+	region        [][]int
+}
+
+func loadRegionImage(fname string, tab colorTable) *regionImage {
+	r, err1 := httpfs.Open(fname)
+	CheckRecoverable(err1)
+	defer r.Close()
+	img, _, err2 := image.Decode(r)
+	CheckRecoverable(err2)
+
+	width := img.Bounds().Max.X
+	height := img.Bounds().Max.Y
+	reg := make([][]int, height)
+	for iy := range reg {
+		reg[iy] = make([]int, width)
+		for ix := range reg[iy] {
+			reg[iy][ix] = tab.regionOf(img.At(ix, height-1-iy))
+		}
+	}
+	return &regionImage{width, height, reg}
+}
+
+// region at position x,y, with the image stretched onto the grid (like ImageShape)
+func (img *regionImage) regionAt(x, y float64) int {
+	c := Mesh().CellSize()
+	N := Mesh().Size()
+	nx, ny := float64(N[X]), float64(N[Y])
+	w, h := float64(img.width), float64(img.height)
+	ix := int((w/nx)*(x/c[X]) + 0.5*w)
+	iy := int((h/ny)*(y/c[Y]) + 0.5*h)
+	if ix < 0 || ix >= img.width || iy < 0 || iy >= img.height {
+		return -1
+	}
+	return img.region[iy][ix]
+}
+
+// region for a pixel color. An empty table uses the gray level.
+func (tab colorTable) regionOf(c color.Color) int {
+	if len(tab) == 0 {
+		return int(color.GrayModel.Convert(c).(color.Gray).Y)
+	}
+	if r, ok := tab[color.NRGBAModel.Convert(c).(color.NRGBA)]; ok {
+		return r
+	}
+	return -1
+}
+
+// parse script arguments "#rrggbb", region, "#rrggbb", region, ...
+func parseColorTable(args []interface{}) colorTable {
+	if len(args)%2 != 0 {
+		util.Fatal("color table: need pairs of color and region, have ", len(args), " arguments")
+	}
+	tab := make(colorTable)
+	for i := 0; i < len(args); i += 2 {
+		str, ok := args[i].(string)
+		if !ok {
+			util.Fatal("color table: need color string like \"#ff0000\", have: ", args[i])
+		}
+		id := toInt(args[i+1])
+		defRegionId(id)
+		tab[parseColor(str)] = id
+	}
+	return tab
+}
+
+func parseColor(str string) color.NRGBA {
+	hex := strings.TrimPrefix(str, "#")
+	if len(hex) != 6 {
+		util.Fatal("color: need #rrggbb, have: ", str)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		util.Fatal("color: need #rrggbb, have: ", str)
+	}
+	return color.NRGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff}
+}
+
+// integer script argument passed through interface{}
+func toInt(v interface{}) int {
+	switch v := v.(type) {
+	case int:
+		return v
+	case float64:
+		if float64(int(v)) == v {
+			return int(v)
+		}
+	}
+	panic(UserErr(fmt.Sprint("need integer, have: ", v)))
+}
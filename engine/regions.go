@@ -8,10 +8,17 @@ import (
 
 var regions = Regions{info: info{1, "regions", ""}} // global regions map
 
-const NREGION = 256 // maximum number of regions, limited by size of byte.
+const (
+	NREGION   = 256   // maximum number of regions, limited by size of byte.
+	NREGION16 = 65536 // maximum number of regions with 16-bit region indices, see SetRegionBits.
+)
+
+var nregion = NREGION // current maximum number of regions: NREGION or NREGION16
 
 func init() {
-	DeclFunc("DefRegion", DefRegion, "Define a material region with given index (0-255) and shape")
+	DeclFunc("DefRegion", DefRegion, "Define a material region with given index (0-255, or 0-65535 after SetRegionBits(16)) and shape")
+	DeclFunc("SetRegionBits", SetRegionBits, "Use 8-bit (default, 256 regions) or 16-bit (65536 regions) region indices, "+
+		"preferably before setting the mesh")
 	DeclFunc("RedefRegion", RedefRegion, "Reassign all cells with a given region (first argument) to a new region (second argument)")
 	DeclROnly("regions", &regions, "Outputs the region index for each cell")
 	DeclFunc("DefRegionCell", DefRegionCell, "Set a material region (first argument) in one cell "+
@@ -20,26 +27,82 @@ func init() {
 
 // stores the region index for each cell
 type Regions struct {
-	gpuCache *cuda.Bytes                 // TODO: rename: buffer
-	hist     []func(x, y, z float64) int // history of region set operations
+	gpuCache   *cuda.Bytes                 // TODO: rename: buffer
+	gpuCache16 *cuda.Shorts                // used instead of gpuCache with 16-bit region indices
+	hist       []func(x, y, z float64) int // history of region set operations
+	changes    int                         // incremented upon every change, to invalidate derived data
 	info
 }
 
 func (r *Regions) alloc() {
-	mesh := r.Mesh()
-	r.gpuCache = cuda.NewBytes(mesh.NCell())
+	r.allocBuffer(r.Mesh().NCell())
 	DefRegion(0, universe)
 }
 
 func (r *Regions) resize() {
 	newSize := Mesh().Size()
-	r.gpuCache.Free()
-	r.gpuCache = cuda.NewBytes(prod(newSize))
+	r.free()
+	r.allocBuffer(prod(newSize))
 	for _, f := range r.hist {
 		r.render(f)
 	}
 }
 
+func (r *Regions) allocBuffer(ncell int) {
+	if r.wide() {
+		r.gpuCache16 = cuda.NewShorts(ncell)
+	} else {
+		r.gpuCache = cuda.NewBytes(ncell)
+	}
+}
+
+func (r *Regions) free() {
+	if r.gpuCache != nil {
+		r.gpuCache.Free()
+		r.gpuCache = nil
+	}
+	if r.gpuCache16 != nil {
+		r.gpuCache16.Free()
+		r.gpuCache16 = nil
+	}
+}
+
+// are regions stored as 16-bit indices?
+func (r *Regions) wide() bool {
+	return nregion > NREGION
+}
+
+// SetRegionBits selects 8-bit (default, up to 256 regions)
+// or 16-bit (up to 65536 regions, e.g. for many grains) region indices.
+// The byte path is faster and uses less memory, so it is kept for small problems.
+// With 16 bits, inter-region exchange is not stored as a full table
+// but computed from the per-region values, only explicitly set pairs are stored.
+func SetRegionBits(bits int) {
+	switch bits {
+	default:
+		util.Fatal("SetRegionBits: need 8 or 16, have:", bits)
+	case 8:
+		if regions.wide() {
+			util.Fatal("SetRegionBits: cannot go back to 8 bits")
+		}
+	case 16:
+		if regions.wide() {
+			return
+		}
+		var l []uint16
+		if regions.gpuCache != nil { // mesh already set: convert existing regions
+			l = regions.HostList()
+			regions.free()
+		}
+		nregion = NREGION16
+		initUnitMap()
+		if l != nil {
+			regions.allocBuffer(len(l))
+			regions.upload(l)
+		}
+	}
+}
+
 // Define a region with id (0-255) to be inside the Shape.
 func DefRegion(id int, s Shape) {
 	defRegionId(id)
@@ -59,26 +122,7 @@ func RedefRegion(startId, endId int) {
 	// Checks validity of input region IDs
 	defRegionId(startId)
 	defRegionId(endId)
-
-	hist_len := len(regions.hist) // Only consider hist before this Redef to avoid recursion
-	f := func(x, y, z float64) int {
-		value := -1
-		for i := hist_len - 1; i >= 0; i-- {
-			f_other := regions.hist[i]
-			region := f_other(x, y, z)
-			if region >= 0 {
-				value = region
-				break
-			}
-		}
-		if value == startId {
-			return endId
-		} else {
-			return value
-		}
-	}
-	regions.redefine(startId, endId)
-	regions.hist = append(regions.hist, f)
+	remapRegions(map[int]int{startId: endId})
 }
 
 // renders (rasterizes) shape, filling it with region number #id, between x1 and x2
@@ -86,7 +130,7 @@ func RedefRegion(startId, endId int) {
 func (r *Regions) render(f func(x, y, z float64) int) {
 	n := Mesh().Size()
 	l := r.HostList() // need to start from previous state
-	arr := reshapeBytes(l, r.Mesh().Size())
+	arr := reshapeRegions(l, r.Mesh().Size())
 
 	for iz := 0; iz < n[Z]; iz++ {
 		for iy := 0; iy < n[Y]; iy++ {
@@ -94,31 +138,29 @@ func (r *Regions) render(f func(x, y, z float64) int) {
 				r := Index2Coord(ix, iy, iz)
 				region := f(r[X], r[Y], r[Z])
 				if region >= 0 {
-					arr[iz][iy][ix] = byte(region)
+					arr[iz][iy][ix] = uint16(region)
 				}
 			}
 		}
 	}
 	//log.Print("regions.upload")
-	r.gpuCache.Upload(l)
+	r.upload(l)
 }
 
-func (r *Regions) redefine(startId, endId int) {
-	// Loop through all cells, if their region ID matches startId, change it to endId
-	n := Mesh().Size()
+// reassign all cells with a region in the remap table to the new region.
+func (r *Regions) remap(remap map[int]int) {
+	table := make([]uint16, nregion)
+	for i := range table {
+		table[i] = uint16(i)
+	}
+	for from, to := range remap {
+		table[from] = uint16(to)
+	}
 	l := r.HostList() // need to start from previous state
-	arr := reshapeBytes(l, r.Mesh().Size())
-
-	for iz := 0; iz < n[Z]; iz++ {
-		for iy := 0; iy < n[Y]; iy++ {
-			for ix := 0; ix < n[X]; ix++ {
-				if arr[iz][iy][ix] == byte(startId) {
-					arr[iz][iy][ix] = byte(endId)
-				}
-			}
-		}
+	for i, reg := range l {
+		l[i] = table[reg]
 	}
-	r.gpuCache.Upload(l)
+	r.upload(l)
 }
 
 // get the region for position R based on the history
@@ -134,44 +176,76 @@ func (r *Regions) get(R data.Vector) int {
 	return 0
 }
 
-func (r *Regions) HostArray() [][][]byte {
-	return reshapeBytes(r.HostList(), r.Mesh().Size())
+func (r *Regions) HostArray() [][][]uint16 {
+	return reshapeRegions(r.HostList(), r.Mesh().Size())
 }
 
-func (r *Regions) HostList() []byte {
-	regionsList := make([]byte, r.Mesh().NCell())
-	regions.gpuCache.Download(regionsList)
+// region index for each cell, for both 8-bit and 16-bit region storage.
+func (r *Regions) HostList() []uint16 {
+	regionsList := make([]uint16, r.Mesh().NCell())
+	if r.gpuCache16 != nil {
+		r.gpuCache16.Download(regionsList)
+	} else {
+		bytes := make([]byte, len(regionsList))
+		r.gpuCache.Download(bytes)
+		for i, b := range bytes {
+			regionsList[i] = uint16(b)
+		}
+	}
 	return regionsList
 }
 
+// copy region indices from host to GPU.
+func (r *Regions) upload(l []uint16) {
+	r.changes++
+	if r.gpuCache16 != nil {
+		r.gpuCache16.Upload(l)
+	} else {
+		bytes := make([]byte, len(l))
+		for i, v := range l {
+			bytes[i] = byte(v)
+		}
+		r.gpuCache.Upload(bytes)
+	}
+}
+
 func DefRegionCell(id int, x, y, z int) {
 	defRegionId(id)
 	index := data.Index(Mesh().Size(), x, y, z)
-	regions.gpuCache.Set(index, byte(id))
+	regions.setIndex(index, id)
+}
+
+func (r *Regions) setIndex(index, region int) {
+	r.changes++
+	if r.gpuCache16 != nil {
+		r.gpuCache16.Set(index, uint16(region))
+	} else {
+		r.gpuCache.Set(index, byte(region))
+	}
 }
 
 // Load regions from ovf file, use first component.
-// Regions should be between 0 and 256
+// Regions should be between 0 and 255 (or 65535 with 16-bit regions)
 func (r *Regions) LoadFile(fname string) {
 	inSlice := LoadFile(fname)
 	n := r.Mesh().Size()
 	inSlice = data.Resample(inSlice, n)
 	inArr := inSlice.Tensors()[0]
 	l := r.HostList()
-	arr := reshapeBytes(l, n)
+	arr := reshapeRegions(l, n)
 
 	for iz := 0; iz < n[Z]; iz++ {
 		for iy := 0; iy < n[Y]; iy++ {
 			for ix := 0; ix < n[X]; ix++ {
 				val := inArr[iz][iy][ix]
-				if val < 0 || val > 256 {
-					util.Fatal("regions.LoadFile(", fname, "): all values should be between 0 & 256, have: ", val)
+				if val < 0 || int(val) >= nregion {
+					util.Fatal("regions.LoadFile(", fname, "): all values should be between 0 & ", nregion-1, ", have: ", val)
 				}
-				arr[iz][iy][ix] = byte(val)
+				arr[iz][iy][ix] = uint16(val)
 			}
 		}
 	}
-	r.gpuCache.Upload(l)
+	r.upload(l)
 }
 
 func (r *Regions) average() []float64 {
@@ -188,18 +262,21 @@ func (r *Regions) Average() float64 { return r.average()[0] }
 func (r *Regions) SetCell(ix, iy, iz int, region int) {
 	size := Mesh().Size()
 	i := data.Index(size, ix, iy, iz)
-	r.gpuCache.Set(i, byte(region))
+	r.setIndex(i, region)
 }
 
 func (r *Regions) GetCell(ix, iy, iz int) int {
 	size := Mesh().Size()
 	i := data.Index(size, ix, iy, iz)
+	if r.gpuCache16 != nil {
+		return int(r.gpuCache16.Get(i))
+	}
 	return int(r.gpuCache.Get(i))
 }
 
 func defRegionId(id int) {
-	if id < 0 || id > NREGION {
-		util.Fatalf("region id should be 0 -%v, have: %v", NREGION, id)
+	if id < 0 || id >= nregion {
+		util.Fatalf("region id should be 0 -%v, have: %v", nregion-1, id)
 	}
 	checkMesh()
 }
@@ -207,7 +284,7 @@ func defRegionId(id int) {
 // normalized volume (0..1) of region.
 // TODO: a tidbit too expensive
 func (r *Regions) volume(region_ int) float64 {
-	region := byte(region_)
+	region := uint16(region_)
 	vol := 0
 	list := r.HostList()
 	for _, reg := range list {
@@ -220,7 +297,10 @@ func (r *Regions) volume(region_ int) float64 {
 }
 
 // Get the region data on GPU
-func (r *Regions) Gpu() *cuda.Bytes {
+func (r *Regions) Gpu() cuda.RegionMap {
+	if r.gpuCache16 != nil {
+		return r.gpuCache16
+	}
 	return r.gpuCache
 }
 
@@ -228,7 +308,11 @@ var unitMap regionwise // unit map used to output regions quantity
 
 func init() {
 	unitMap.init(1, "unit", "", nil)
-	for r := 0; r < NREGION; r++ {
+	initUnitMap()
+}
+
+func initUnitMap() {
+	for r := 0; r < nregion; r++ {
 		unitMap.setRegion(r, []float64{float64(r)})
 	}
 }
@@ -245,12 +329,12 @@ func (r *Regions) EvalTo(dst *data.Slice) { EvalTo(r, dst) }
 var _ Quantity = &regions
 
 // Re-interpret a contiguous array as a multi-dimensional array of given size.
-func reshapeBytes(array []byte, size [3]int) [][][]byte {
+func reshapeRegions(array []uint16, size [3]int) [][][]uint16 {
 	Nx, Ny, Nz := size[X], size[Y], size[Z]
 	util.Argument(Nx*Ny*Nz == len(array))
-	sliced := make([][][]byte, Nz)
+	sliced := make([][][]uint16, Nz)
 	for i := range sliced {
-		sliced[i] = make([][]byte, Ny)
+		sliced[i] = make([][]uint16, Ny)
 	}
 	for i := range sliced {
 		for j := range sliced[i] {
@@ -261,16 +345,25 @@ func reshapeBytes(array []byte, size [3]int) [][][]byte {
 }
 
 func (b *Regions) shift(dx int) {
+	b.changes++
 	// TODO: return if no regions defined
-	r1 := b.Gpu()
-	r2 := cuda.NewBytes(b.Mesh().NCell()) // TODO: somehow recycle
-	defer r2.Free()
-	newreg := byte(0) // new region at edge
-	cuda.ShiftBytes(r2, r1, b.Mesh(), dx, newreg)
-	r1.Copy(r2)
+	if b.gpuCache16 != nil {
+		r1 := b.gpuCache16
+		r2 := cuda.NewShorts(b.Mesh().NCell())
+		defer r2.Free()
+		cuda.ShiftShorts(r2, r1, b.Mesh(), dx, 0) // new region 0 at edge
+		r1.Copy(r2)
+	} else {
+		r1 := b.gpuCache
+		r2 := cuda.NewBytes(b.Mesh().NCell()) // TODO: somehow recycle
+		defer r2.Free()
+		newreg := byte(0) // new region at edge
+		cuda.ShiftBytes(r2, r1, b.Mesh(), dx, newreg)
+		r1.Copy(r2)
+	}
 
 	n := Mesh().Size()
-	x1, x2 := shiftDirtyRange(dx)
+	x1, x2 := shiftDirtyRange(dx, n[X])
 
 	for iz := 0; iz < n[Z]; iz++ {
 		for iy := 0; iy < n[Y]; iy++ {
@@ -286,16 +379,25 @@ func (b *Regions) shift(dx int) {
 }
 
 func (b *Regions) shiftY(dy int) {
+	b.changes++
 	// TODO: return if no regions defined
-	r1 := b.Gpu()
-	r2 := cuda.NewBytes(b.Mesh().NCell()) // TODO: somehow recycle
-	defer r2.Free()
-	newreg := byte(0) // new region at edge
-	cuda.ShiftBytesY(r2, r1, b.Mesh(), dy, newreg)
-	r1.Copy(r2)
+	if b.gpuCache16 != nil {
+		r1 := b.gpuCache16
+		r2 := cuda.NewShorts(b.Mesh().NCell())
+		defer r2.Free()
+		cuda.ShiftShortsY(r2, r1, b.Mesh(), dy, 0) // new region 0 at edge
+		r1.Copy(r2)
+	} else {
+		r1 := b.gpuCache
+		r2 := cuda.NewBytes(b.Mesh().NCell()) // TODO: somehow recycle
+		defer r2.Free()
+		newreg := byte(0) // new region at edge
+		cuda.ShiftBytesY(r2, r1, b.Mesh(), dy, newreg)
+		r1.Copy(r2)
+	}
 
 	n := Mesh().Size()
-	y1, y2 := shiftDirtyRange(dy)
+	y1, y2 := shiftDirtyRange(dy, n[Y])
 
 	for iz := 0; iz < n[Z]; iz++ {
 		for ix := 0; ix < n[X]; ix++ {
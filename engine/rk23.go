@@ -21,21 +21,21 @@ type RK23 struct {
 }
 
 func (rk *RK23) Step() {
-	m := M.Buffer()
+	m := solverState()
 	size := m.Size()
 
 	if FixDt != 0 {
 		Dt_si = FixDt
 	}
 
-	// upon resize: remove wrongly sized k1
-	if rk.k1.Size() != m.Size() {
+	// upon resize or change of sublattices: remove wrongly sized k1
+	if rk.k1.Size() != m.Size() || rk.k1 != nil && rk.k1.NComp() != m.NComp() {
 		rk.Free()
 	}
 
 	// first step ever: one-time k1 init and eval
 	if rk.k1 == nil {
-		rk.k1 = cuda.NewSlice(3, size)
+		rk.k1 = cuda.NewSlice(m.NComp(), size)
 		torqueFn(rk.k1)
 	}
 
@@ -46,11 +46,11 @@ func (rk *RK23) Step() {
 
 	t0 := Time
 	// backup magnetization
-	m0 := cuda.Buffer(3, size)
+	m0 := cuda.Buffer(m.NComp(), size)
 	defer cuda.Recycle(m0)
 	data.Copy(m0, m)
 
-	k2, k3, k4 := cuda.Buffer(3, size), cuda.Buffer(3, size), cuda.Buffer(3, size)
+	k2, k3, k4 := cuda.Buffer(m.NComp(), size), cuda.Buffer(m.NComp(), size), cuda.Buffer(m.NComp(), size)
 	defer cuda.Recycle(k2)
 	defer cuda.Recycle(k3)
 	defer cuda.Recycle(k4)
@@ -62,18 +62,18 @@ func (rk *RK23) Step() {
 	// stage 2
 	Time = t0 + (1./2.)*Dt_si
 	cuda.Madd2(m, m, rk.k1, 1, (1./2.)*h) // m = m*1 + k1*h/2
-	M.normalize()
+	normalizeState()
 	torqueFn(k2)
 
 	// stage 3
 	Time = t0 + (3./4.)*Dt_si
 	cuda.Madd2(m, m0, k2, 1, (3./4.)*h) // m = m0*1 + k2*3/4
-	M.normalize()
+	normalizeState()
 	torqueFn(k3)
 
 	// 3rd order solution
 	cuda.Madd4(m, m0, rk.k1, k2, k3, 1, (2./9.)*h, (1./3.)*h, (4./9.)*h)
-	M.normalize()
+	normalizeState()
 
 	// error estimate
 	Time = t0 + Dt_si
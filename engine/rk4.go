@@ -12,7 +12,7 @@ type RK4 struct {
 }
 
 func (rk *RK4) Step() {
-	m := M.Buffer()
+	m := solverState()
 	size := m.Size()
 
 	if FixDt != 0 {
@@ -21,11 +21,11 @@ func (rk *RK4) Step() {
 
 	t0 := Time
 	// backup magnetization
-	m0 := cuda.Buffer(3, size)
+	m0 := cuda.Buffer(m.NComp(), size)
 	defer cuda.Recycle(m0)
 	data.Copy(m0, m)
 
-	k1, k2, k3, k4 := cuda.Buffer(3, size), cuda.Buffer(3, size), cuda.Buffer(3, size), cuda.Buffer(3, size)
+	k1, k2, k3, k4 := cuda.Buffer(m.NComp(), size), cuda.Buffer(m.NComp(), size), cuda.Buffer(m.NComp(), size), cuda.Buffer(m.NComp(), size)
 
 	defer cuda.Recycle(k1)
 	defer cuda.Recycle(k2)
@@ -40,18 +40,18 @@ func (rk *RK4) Step() {
 	// stage 2
 	Time = t0 + (1./2.)*Dt_si
 	cuda.Madd2(m, m, k1, 1, (1./2.)*h) // m = m*1 + k1*h/2
-	M.normalize()
+	normalizeState()
 	torqueFn(k2)
 
 	// stage 3
 	cuda.Madd2(m, m0, k2, 1, (1./2.)*h) // m = m0*1 + k2*1/2
-	M.normalize()
+	normalizeState()
 	torqueFn(k3)
 
 	// stage 4
 	Time = t0 + Dt_si
 	cuda.Madd2(m, m0, k3, 1, 1.*h) // m = m0*1 + k3*1
-	M.normalize()
+	normalizeState()
 	torqueFn(k4)
 
 	err := cuda.MaxVecDiff(k1, k4) * float64(h)
@@ -61,7 +61,7 @@ func (rk *RK4) Step() {
 		// step OK
 		// 4th order solution
 		cuda.Madd5(m, m0, k1, k2, k3, k4, 1, (1./6.)*h, (1./3.)*h, (1./3.)*h, (1./6.)*h)
-		M.normalize()
+		normalizeState()
 		NSteps++
 		adaptDt(math.Pow(MaxErr/err, 1./4.))
 		setLastErr(err)
@@ -12,21 +12,21 @@ type RK45DP struct {
 }
 
 func (rk *RK45DP) Step() {
-	m := M.Buffer()
+	m := solverState()
 	size := m.Size()
 
 	if FixDt != 0 {
 		Dt_si = FixDt
 	}
 
-	// upon resize: remove wrongly sized k1
-	if rk.k1.Size() != m.Size() {
+	// upon resize or change of sublattices: remove wrongly sized k1
+	if rk.k1.Size() != m.Size() || rk.k1 != nil && rk.k1.NComp() != m.NComp() {
 		rk.Free()
 	}
 
 	// first step ever: one-time k1 init and eval
 	if rk.k1 == nil {
-		rk.k1 = cuda.NewSlice(3, size)
+		rk.k1 = cuda.NewSlice(m.NComp(), size)
 		torqueFn(rk.k1)
 	}
 
@@ -37,11 +37,11 @@ func (rk *RK45DP) Step() {
 
 	t0 := Time
 	// backup magnetization
-	m0 := cuda.Buffer(3, size)
+	m0 := cuda.Buffer(m.NComp(), size)
 	defer cuda.Recycle(m0)
 	data.Copy(m0, m)
 
-	k2, k3, k4, k5, k6 := cuda.Buffer(3, size), cuda.Buffer(3, size), cuda.Buffer(3, size), cuda.Buffer(3, size), cuda.Buffer(3, size)
+	k2, k3, k4, k5, k6 := cuda.Buffer(m.NComp(), size), cuda.Buffer(m.NComp(), size), cuda.Buffer(m.NComp(), size), cuda.Buffer(m.NComp(), size), cuda.Buffer(m.NComp(), size)
 	defer cuda.Recycle(k2)
 	defer cuda.Recycle(k3)
 	defer cuda.Recycle(k4)
@@ -56,43 +56,43 @@ func (rk *RK45DP) Step() {
 	// stage 2
 	Time = t0 + (1./5.)*Dt_si
 	cuda.Madd2(m, m, rk.k1, 1, (1./5.)*h) // m = m*1 + k1*h/5
-	M.normalize()
+	normalizeState()
 	torqueFn(k2)
 
 	// stage 3
 	Time = t0 + (3./10.)*Dt_si
 	cuda.Madd3(m, m0, rk.k1, k2, 1, (3./40.)*h, (9./40.)*h)
-	M.normalize()
+	normalizeState()
 	torqueFn(k3)
 
 	// stage 4
 	Time = t0 + (4./5.)*Dt_si
 	cuda.Madd4(m, m0, rk.k1, k2, k3, 1, (44./45.)*h, (-56./15.)*h, (32./9.)*h)
-	M.normalize()
+	normalizeState()
 	torqueFn(k4)
 
 	// stage 5
 	Time = t0 + (8./9.)*Dt_si
 	cuda.Madd5(m, m0, rk.k1, k2, k3, k4, 1, (19372./6561.)*h, (-25360./2187.)*h, (64448./6561.)*h, (-212./729.)*h)
-	M.normalize()
+	normalizeState()
 	torqueFn(k5)
 
 	// stage 6
 	Time = t0 + (1.)*Dt_si
 	cuda.Madd6(m, m0, rk.k1, k2, k3, k4, k5, 1, (9017./3168.)*h, (-355./33.)*h, (46732./5247.)*h, (49./176.)*h, (-5103./18656.)*h)
-	M.normalize()
+	normalizeState()
 	torqueFn(k6)
 
 	// stage 7: 5th order solution
 	Time = t0 + (1.)*Dt_si
 	// no k2
 	cuda.Madd6(m, m0, rk.k1, k3, k4, k5, k6, 1, (35./384.)*h, (500./1113.)*h, (125./192.)*h, (-2187./6784.)*h, (11./84.)*h) // 5th
-	M.normalize()
+	normalizeState()
 	k7 := k2     // re-use k2
 	torqueFn(k7) // next torque if OK
 
 	// error estimate
-	Err := cuda.Buffer(3, size) //k3 // re-use k3 as error estimate
+	Err := cuda.Buffer(m.NComp(), size) //k3 // re-use k3 as error estimate
 	defer cuda.Recycle(Err)
 	cuda.Madd6(Err, rk.k1, k3, k4, k5, k6, k7, (35./384.)-(5179./57600.), (500./1113.)-(7571./16695.), (125./192.)-(393./640.), (-2187./6784.)-(-92097./339200.), (11./84.)-(187./2100.), (0.)-(1./40.))
 
@@ -12,7 +12,7 @@ type RK56 struct {
 
 func (rk *RK56) Step() {
 
-	m := M.Buffer()
+	m := solverState()
 	size := m.Size()
 
 	if FixDt != 0 {
@@ -21,11 +21,11 @@ func (rk *RK56) Step() {
 
 	t0 := Time
 	// backup magnetization
-	m0 := cuda.Buffer(3, size)
+	m0 := cuda.Buffer(m.NComp(), size)
 	defer cuda.Recycle(m0)
 	data.Copy(m0, m)
 
-	k1, k2, k3, k4, k5, k6, k7, k8 := cuda.Buffer(3, size), cuda.Buffer(3, size), cuda.Buffer(3, size), cuda.Buffer(3, size), cuda.Buffer(3, size), cuda.Buffer(3, size), cuda.Buffer(3, size), cuda.Buffer(3, size)
+	k1, k2, k3, k4, k5, k6, k7, k8 := cuda.Buffer(m.NComp(), size), cuda.Buffer(m.NComp(), size), cuda.Buffer(m.NComp(), size), cuda.Buffer(m.NComp(), size), cuda.Buffer(m.NComp(), size), cuda.Buffer(m.NComp(), size), cuda.Buffer(m.NComp(), size), cuda.Buffer(m.NComp(), size)
 	defer cuda.Recycle(k1)
 	defer cuda.Recycle(k2)
 	defer cuda.Recycle(k3)
@@ -44,54 +44,54 @@ func (rk *RK56) Step() {
 	// stage 2
 	Time = t0 + (1./6.)*Dt_si
 	cuda.Madd2(m, m, k1, 1, (1./6.)*h) // m = m*1 + k1*h/6
-	M.normalize()
+	normalizeState()
 	torqueFn(k2)
 
 	// stage 3
 	Time = t0 + (4./15.)*Dt_si
 	cuda.Madd3(m, m0, k1, k2, 1, (4./75.)*h, (16./75.)*h)
-	M.normalize()
+	normalizeState()
 	torqueFn(k3)
 
 	// stage 4
 	Time = t0 + (2./3.)*Dt_si
 	cuda.Madd4(m, m0, k1, k2, k3, 1, (5./6.)*h, (-8./3.)*h, (5./2.)*h)
-	M.normalize()
+	normalizeState()
 	torqueFn(k4)
 
 	// stage 5
 	Time = t0 + (4./5.)*Dt_si
 	cuda.Madd5(m, m0, k1, k2, k3, k4, 1, (-8./5.)*h, (144./25.)*h, (-4.)*h, (16./25.)*h)
-	M.normalize()
+	normalizeState()
 	torqueFn(k5)
 
 	// stage 6
 	Time = t0 + (1.)*Dt_si
 	cuda.Madd6(m, m0, k1, k2, k3, k4, k5, 1, (361./320.)*h, (-18./5.)*h, (407./128.)*h, (-11./80.)*h, (55./128.)*h)
-	M.normalize()
+	normalizeState()
 	torqueFn(k6)
 
 	// stage 7
 	Time = t0
 	cuda.Madd5(m, m0, k1, k3, k4, k5, 1, (-11./640.)*h, (11./256.)*h, (-11/160.)*h, (11./256.)*h)
-	M.normalize()
+	normalizeState()
 	torqueFn(k7)
 
 	// stage 8
 	Time = t0 + (1.)*Dt_si
 	cuda.Madd7(m, m0, k1, k2, k3, k4, k5, k7, 1, (93./640.)*h, (-18./5.)*h, (803./256.)*h, (-11./160.)*h, (99./256.)*h, (1.)*h)
-	M.normalize()
+	normalizeState()
 	torqueFn(k8)
 
 	// stage 9: 6th order solution
 	Time = t0 + (1.)*Dt_si
 	//madd6(m, m0, k1, k3, k4, k5, k6, 1, (31./384.)*h, (1125./2816.)*h, (9./32.)*h, (125./768.)*h, (5./66.)*h)
 	cuda.Madd7(m, m0, k1, k3, k4, k5, k7, k8, 1, (7./1408.)*h, (1125./2816.)*h, (9./32.)*h, (125./768.)*h, (5./66.)*h, (5./66.)*h)
-	M.normalize()
+	normalizeState()
 	torqueFn(k2) // re-use k2
 
 	// error estimate
-	Err := cuda.Buffer(3, size)
+	Err := cuda.Buffer(m.NComp(), size)
 	defer cuda.Recycle(Err)
 	cuda.Madd4(Err, k1, k6, k7, k8, (-5. / 66.), (-5. / 66.), (5. / 66.), (5. / 66.))
 
@@ -33,7 +33,7 @@ func init() {
 	DeclFunc("Run", Run, "Run the simulation for a time in seconds")
 	DeclFunc("Steps", Steps, "Run the simulation for a number of time steps")
 	DeclFunc("RunWhile", RunWhile, "Run while condition function is true")
-	DeclFunc("SetSolver", SetSolver, "Set solver type. 1:Euler, 2:Heun, 3:Bogaki-Shampine, 4: Runge-Kutta (RK45), 5: Dormand-Prince, 6: Fehlberg, -1: Backward Euler")
+	DeclFunc("SetSolver", SetSolver, "Set solver type. 1:Euler, 2:Heun, 3:Bogaki-Shampine, 4: Runge-Kutta (RK45), 5: Dormand-Prince, 6: Fehlberg, -1: Backward Euler, 7: Landau-Lifshitz-Bloch, 8: Monte Carlo, 9: Cayley (norm-preserving), 10: Implicit midpoint (norm- and energy-preserving)")
 	DeclTVar("t", &Time, "Total simulated time (s)")
 	DeclVar("step", &NSteps, "Total number of time steps taken")
 	DeclVar("MinDt", &MinDt, "Minimum time step the solver can take (s)")
@@ -64,6 +64,10 @@ const (
 	RUNGEKUTTA     = 4
 	DORMANDPRINCE  = 5
 	FEHLBERG       = 6
+	LLB            = 7
+	MONTECARLO     = 8
+	CAYLEY         = 9
+	MIDPOINT       = 10
 )
 
 func SetSolver(typ int) {
@@ -88,13 +92,25 @@ func SetSolver(typ int) {
 		stepper = new(RK45DP)
 	case FEHLBERG:
 		stepper = new(RK56)
+	case LLB:
+		stepper = new(LLBSolver)
+	case MONTECARLO:
+		stepper = new(MonteCarlo)
+	case CAYLEY:
+		stepper = new(Cayley)
+	case MIDPOINT:
+		stepper = new(ImplicitMidpoint)
 	}
 	solvertype = typ
 }
 
 // write torque to dst and increment NEvals
 func torqueFn(dst *data.Slice) {
-	SetTorque(dst)
+	if dst.NComp() == VECTOR {
+		SetTorque(dst)
+	} else {
+		setSublatticeTorques(dst)
+	}
 	NEvals++
 }
 
@@ -199,6 +215,8 @@ func RunInteractive() {
 // take one time step
 func step(output bool) {
 	stepper.Step()
+	stepElastic()
+	stepBodies()
 	for _, f := range postStep {
 		f()
 	}
@@ -70,7 +70,7 @@ func SaveAs(q Quantity, fname string) {
 	}
 	buffer := ValueOf(q) // TODO: check and optimize for Buffer()
 	defer cuda.Recycle(buffer)
-	info := data.Meta{Time: Time, Name: NameOf(q), Unit: UnitOf(q), CellSize: MeshOf(q).CellSize()}
+	info := data.Meta{Time: Time, Name: NameOf(q), Unit: UnitOf(q), CellSize: MeshOf(q).CellSize(), LayerThickness: layerMeta(MeshOf(q))}
 	data := buffer.HostCopy() // must be copy (async io)
 	queOutput(func() { saveAs_sync(fname, data, info, outputFormat) })
 }
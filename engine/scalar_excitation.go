@@ -21,6 +21,7 @@ func NewScalarExcitation(name, unit, desc string) *ScalarExcitation {
 	e.name = name
 	e.perRegion.init("_"+name+"_perRegion", unit, desc, nil) // name starts with underscore: unexported
 	DeclLValue(name, e, cat(desc, unit))
+	excitations = append(excitations, e)
 	return e
 }
 
@@ -44,6 +45,17 @@ func (e *ScalarExcitation) AddTo(dst *data.Slice) {
 	}
 }
 
+// masks of the extra terms, shifted with the simulation window.
+func (e *ScalarExcitation) masks() []*data.Slice {
+	var m []*data.Slice
+	for _, t := range e.extraTerms {
+		if t.mask != nil {
+			m = append(m, t.mask)
+		}
+	}
+	return m
+}
+
 func (e *ScalarExcitation) isZero() bool {
 	return e.perRegion.isZero() && len(e.extraTerms) == 0
 }
@@ -102,7 +114,7 @@ func (e *ScalarExcitation) SetRegion(region int, f script.ScalarFunction) {
 	e.perRegion.SetRegion(region, f)
 }
 func (e *ScalarExcitation) SetValue(v interface{})         { e.perRegion.SetValue(v) }
-func (e *ScalarExcitation) Set(v float64)                  { e.perRegion.setRegions(0, NREGION, []float64{v}) }
+func (e *ScalarExcitation) Set(v float64)                  { e.perRegion.setRegions(0, nregion, []float64{v}) }
 func (e *ScalarExcitation) getRegion(region int) []float64 { return e.perRegion.getRegion(region) } // for gui
 
 func (e *ScalarExcitation) SetRegionFn(region int, f func() [3]float64) {
@@ -0,0 +1,189 @@
+package engine
+
+// Signed distance functions (SDF) describe a shape by the distance to its surface,
+// negative inside. Unlike a plain Shape, they let SetGeomSDF compute the partial
+// fill fraction of boundary cells from the local plane through the surface,
+// which is exact for flat surfaces and converges quickly for curved ones.
+
+import (
+	"math"
+)
+
+func init() {
+	DeclFunc("SetGeomSDF", SetGeomSDF, "Sets the geometry to a signed distance shape. With EdgeSmooth > 0, "+
+		"fill fractions of edge cells are computed from the distance function instead of by sampling")
+	DeclFunc("SphereSDF", SphereSDF, "Signed distance function of a sphere with given diameter in meter")
+	DeclFunc("CuboidSDF", CuboidSDF, "Signed distance function of a cuboid with sides in meter")
+	DeclFunc("CylinderSDF", CylinderSDF, "Signed distance function of a cylinder along z with diameter and height in meter")
+	DeclFunc("TorusSDF", TorusSDF, "Signed distance function of a torus in the xy-plane with ring diameter and tube diameter in meter")
+}
+
+// Signed distance to a surface (m), negative inside.
+type SDF func(x, y, z float64) float64
+
+func SphereSDF(diam float64) SDF {
+	return func(x, y, z float64) float64 {
+		return math.Sqrt(x*x+y*y+z*z) - diam/2
+	}
+}
+
+func CuboidSDF(sidex, sidey, sidez float64) SDF {
+	return func(x, y, z float64) float64 {
+		qx := math.Abs(x) - sidex/2
+		qy := math.Abs(y) - sidey/2
+		qz := math.Abs(z) - sidez/2
+		outside := math.Sqrt(sqr64(math.Max(qx, 0)) + sqr64(math.Max(qy, 0)) + sqr64(math.Max(qz, 0)))
+		inside := math.Min(math.Max(qx, math.Max(qy, qz)), 0)
+		return outside + inside
+	}
+}
+
+func CylinderSDF(diam, height float64) SDF {
+	return func(x, y, z float64) float64 {
+		qr := math.Sqrt(x*x+y*y) - diam/2
+		qz := math.Abs(z) - height/2
+		outside := math.Sqrt(sqr64(math.Max(qr, 0)) + sqr64(math.Max(qz, 0)))
+		inside := math.Min(math.Max(qr, qz), 0)
+		return outside + inside
+	}
+}
+
+func TorusSDF(diam, tubediam float64) SDF {
+	return func(x, y, z float64) float64 {
+		q := math.Sqrt(x*x+y*y) - diam/2
+		return math.Sqrt(q*q+z*z) - tubediam/2
+	}
+}
+
+// Shape returns the inside (distance <= 0) of the SDF as a Shape.
+func (d SDF) Shape() Shape {
+	return func(x, y, z float64) bool {
+		return d(x, y, z) <= 0
+	}
+}
+
+// Transl returns a translated copy of the SDF.
+func (d SDF) Transl(dx, dy, dz float64) SDF {
+	return func(x, y, z float64) float64 {
+		return d(x-dx, y-dy, z-dz)
+	}
+}
+
+// Rotates the SDF around the Z-axis, over θ radians.
+func (d SDF) RotZ(θ float64) SDF {
+	cos := math.Cos(θ)
+	sin := math.Sin(θ)
+	return func(x, y, z float64) float64 {
+		return d(x*cos+y*sin, -x*sin+y*cos, z)
+	}
+}
+
+// Union of a and b. Distances are exact outside, a lower bound inside.
+func (a SDF) Add(b SDF) SDF {
+	return func(x, y, z float64) float64 {
+		return math.Min(a(x, y, z), b(x, y, z))
+	}
+}
+
+// Intersection of a and b.
+func (a SDF) Intersect(b SDF) SDF {
+	return func(x, y, z float64) float64 {
+		return math.Max(a(x, y, z), b(x, y, z))
+	}
+}
+
+// Removes b from a.
+func (a SDF) Sub(b SDF) SDF {
+	return func(x, y, z float64) float64 {
+		return math.Max(a(x, y, z), -b(x, y, z))
+	}
+}
+
+// Inverse (outside) of the SDF.
+func (d SDF) Inverse() SDF {
+	return func(x, y, z float64) float64 {
+		return -d(x, y, z)
+	}
+}
+
+func SetGeomSDF(d SDF) {
+	geometry.sdf = d
+	geometry.setGeom(d.Shape())
+}
+
+// Fill fraction of the cell centered at (x0,y0,z0) with sides c,
+// approximating the surface by the plane through the SDF linearized at the cell center.
+func sdfCellVolume(d SDF, x0, y0, z0 float64, c [3]float64) float32 {
+	d0 := d(x0, y0, z0)
+
+	// central difference gradient
+	var g [3]float64
+	for i := range g {
+		h := 1e-3 * c[i]
+		var Δ [3]float64
+		Δ[i] = h
+		g[i] = (d(x0+Δ[X], y0+Δ[Y], z0+Δ[Z]) - d(x0-Δ[X], y0-Δ[Y], z0-Δ[Z])) / (2 * h)
+	}
+
+	// inside: g·(r-r0) <= -d0. With box coordinates u in [0,c]: g·u <= -d0 + g·c/2
+	s := -d0
+	for i := range g {
+		s += g[i] * c[i] / 2
+	}
+	return float32(boxBelowPlane(g, c, s) / (c[X] * c[Y] * c[Z]))
+}
+
+// Volume of the part of box [0,L0]x[0,L1]x... where a·u <= s.
+func boxBelowPlane(a [3]float64, L [3]float64, s float64) float64 {
+	// make all coefficients non-negative by mirroring u -> L-u
+	var total float64
+	for i := range a {
+		if a[i] < 0 {
+			s -= a[i] * L[i]
+			a[i] = -a[i]
+		}
+		total += a[i] * L[i]
+	}
+	if s <= 0 {
+		return 0
+	}
+	if s >= total {
+		return L[X] * L[Y] * L[Z]
+	}
+
+	// drop directions in which the plane is (nearly) constant,
+	// they just contribute their length.
+	vol := 1.
+	var as, Ls []float64
+	for i := range a {
+		if a[i]*L[i] < 1e-6*total {
+			vol *= L[i]
+		} else {
+			as = append(as, a[i])
+			Ls = append(Ls, L[i])
+		}
+	}
+
+	// inclusion-exclusion over the box corners:
+	// V = 1/(n! Π a) Σ_corners (-1)^k max(0, s - a·corner)^n
+	n := len(as)
+	sum := 0.
+	for corner := 0; corner < 1<<uint(n); corner++ {
+		t := s
+		sgn := 1.
+		for i := 0; i < n; i++ {
+			if corner&(1<<uint(i)) != 0 {
+				t -= as[i] * Ls[i]
+				sgn = -sgn
+			}
+		}
+		if t > 0 {
+			sum += sgn * math.Pow(t, float64(n))
+		}
+	}
+	norm := 1.
+	for i := 0; i < n; i++ {
+		norm *= as[i] * float64(i+1)
+	}
+	return vol * sum / norm
+}
@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"fmt"
 	"image"
 	_ "image/jpeg"
 	_ "image/png"
@@ -26,6 +27,9 @@ func init() {
 	DeclFunc("Layer", Layer, "Single layer (along z), by integer index starting from 0")
 	DeclFunc("Universe", Universe, "Entire space")
 	DeclFunc("Cell", Cell, "Single cell with given integer index (i, j, k)")
+	DeclFunc("Torus", Torus, "3D Torus in the xy-plane with ring diameter (center line) and tube diameter in meter")
+	DeclFunc("Prism", Prism, "3D Prism along z with a regular n-sided base of given circumscribed diameter and height in meter")
+	DeclFunc("Polygon", Polygon, "2D polygon with vertices x1, y1, x2, y2, ... in meter. Use Extrude() to give it a finite height")
 	DeclFunc("ImageShape", ImageShape, "Use black/white image as shape")
 	DeclFunc("GrainRoughness", GrainRoughness, "Grainy surface with different heights per grain "+
 		"with a typical grain size (first argument), minimal height (second argument), and maximal "+
@@ -113,10 +117,7 @@ func Layers(a, b int) Shape {
 	if a < 0 || a > Nz || b < 0 || b < a {
 		util.Fatal("layers ", a, ":", b, " out of bounds (0 - ", Nz, ")")
 	}
-	c := Mesh().CellSize()[Z]
-	z1 := Index2Coord(0, 0, a)[Z] - c/2
-	z2 := Index2Coord(0, 0, b)[Z] - c/2
-	return ZRange(z1, z2)
+	return ZRange(layerBottom(a), layerBottom(b))
 }
 
 func Layer(index int) Shape {
@@ -129,10 +130,10 @@ func Cell(ix, iy, iz int) Shape {
 	pos := Index2Coord(ix, iy, iz)
 	x1 := pos[X] - c[X]/2
 	y1 := pos[Y] - c[Y]/2
-	z1 := pos[Z] - c[Z]/2
+	z1 := pos[Z] - layerThicknessOf(iz)/2
 	x2 := pos[X] + c[X]/2
 	y2 := pos[Y] + c[Y]/2
-	z2 := pos[Z] + c[Z]/2
+	z2 := pos[Z] + layerThicknessOf(iz)/2
 	return func(x, y, z float64) bool {
 		return x > x1 && x < x2 &&
 			y > y1 && y < y2 &&
@@ -140,6 +141,69 @@ func Cell(ix, iy, iz int) Shape {
 	}
 }
 
+// Torus in the xy-plane, centered at the origin.
+func Torus(diam, tubediam float64) Shape {
+	R, r := diam/2, tubediam/2
+	return func(x, y, z float64) bool {
+		return sqr64(math.Sqrt(x*x+y*y)-R)+z*z <= r*r
+	}
+}
+
+// Prism along z with a regular n-sided base, one vertex along +x.
+func Prism(n int, diam, height float64) Shape {
+	if n < 3 {
+		util.Fatal("Prism: need at least 3 sides, have: ", n)
+	}
+	xs, ys := make([]float64, n), make([]float64, n)
+	for i := range xs {
+		θ := 2 * math.Pi * float64(i) / float64(n)
+		xs[i], ys[i] = diam/2*math.Cos(θ), diam/2*math.Sin(θ)
+	}
+	return polygon(xs, ys).Extrude(height)
+}
+
+// 2D polygon with vertices x1, y1, x2, y2, ... (float or int).
+// The polygon may be concave, cells are inside according to the even-odd rule.
+func Polygon(xy ...interface{}) Shape {
+	if len(xy)%2 != 0 || len(xy) < 6 {
+		util.Fatal("Polygon: need at least 3 x,y pairs, have ", len(xy), " numbers")
+	}
+	n := len(xy) / 2
+	xs, ys := make([]float64, n), make([]float64, n)
+	for i := 0; i < n; i++ {
+		xs[i] = toFloat64(xy[2*i])
+		ys[i] = toFloat64(xy[2*i+1])
+	}
+	return polygon(xs, ys)
+}
+
+func polygon(xs, ys []float64) Shape {
+	n := len(xs)
+	return func(x, y, z float64) bool {
+		// crossing number, half-open edges so shared vertices count once
+		inside := false
+		for i, j := 0, n-1; i < n; j, i = i, i+1 {
+			if (ys[i] > y) != (ys[j] > y) &&
+				x < xs[i]+(y-ys[i])*(xs[j]-xs[i])/(ys[j]-ys[i]) {
+				inside = !inside
+			}
+		}
+		return inside
+	}
+}
+
+// numerical script argument passed through interface{}
+func toFloat64(v interface{}) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	default:
+		panic(UserErr(fmt.Sprint("need number, have: ", v)))
+	}
+}
+
 func Universe() Shape {
 	return universe
 }
@@ -270,6 +334,14 @@ func (s Shape) RotX(θ float64) Shape {
 	}
 }
 
+// Extrude returns the part of the shape between z = -height/2 and +height/2.
+// Typically used to give a 2D shape like Polygon a finite thickness.
+func (s Shape) Extrude(height float64) Shape {
+	return func(x, y, z float64) bool {
+		return z <= height/2 && z >= -height/2 && s(x, y, z)
+	}
+}
+
 // Union of shapes a and b (logical OR).
 func (a Shape) Add(b Shape) Shape {
 	return func(x, y, z float64) bool {
@@ -7,20 +7,29 @@ import (
 
 var (
 	TotalShift, TotalYShift                    float64                        // accumulated window shift (X and Y) in meter
-	ShiftMagL, ShiftMagR, ShiftMagU, ShiftMagD data.Vector                    // when shifting m, put these value at the left/right edge.
+	ShiftMagL, ShiftMagR, ShiftMagU, ShiftMagD data.Vector                    // when shifting m, put these value at the left/right/top/bottom edge.
 	ShiftM, ShiftGeom, ShiftRegions            bool        = true, true, true // should shift act on magnetization, geometry, regions?
+	ShiftMasks                                 bool                           // should shift act on excitation masks?
 )
 
+// all excitations, to shift their masks
+var excitations []interface {
+	masks() []*data.Slice
+}
+
 func init() {
 	DeclFunc("Shift", Shift, "Shifts the simulation by +1/-1 cells along X")
+	DeclFunc("YShift", YShift, "Shifts the simulation by +1/-1 cells along Y")
 	DeclVar("ShiftMagL", &ShiftMagL, "Upon shift, insert this magnetization from the left")
 	DeclVar("ShiftMagR", &ShiftMagR, "Upon shift, insert this magnetization from the right")
-	DeclVar("ShiftMagU", &ShiftMagU, "Upon shift, insert this magnetization from the top")
-	DeclVar("ShiftMagD", &ShiftMagD, "Upon shift, insert this magnetization from the bottom")
-	DeclVar("ShiftM", &ShiftM, "Whether Shift() acts on magnetization")
+	DeclVar("ShiftMagU", &ShiftMagU, "Upon YShift, insert this magnetization from the top (+y)")
+	DeclVar("ShiftMagD", &ShiftMagD, "Upon YShift, insert this magnetization from the bottom (-y)")
+	DeclVar("ShiftM", &ShiftM, "Whether Shift() acts on magnetization (and the elastic displacement)")
 	DeclVar("ShiftGeom", &ShiftGeom, "Whether Shift() acts on geometry")
 	DeclVar("ShiftRegions", &ShiftRegions, "Whether Shift() acts on regions")
+	DeclVar("ShiftMasks", &ShiftMasks, "Whether Shift() acts on excitation masks (new edge cells are zero, default=false)")
 	DeclVar("TotalShift", &TotalShift, "Amount by which the simulation has been shifted (m).")
+	DeclVar("TotalYShift", &TotalYShift, "Amount by which the simulation has been shifted along Y (m).")
 }
 
 // position of the window lab frame
@@ -31,7 +40,10 @@ func GetShiftYPos() float64 { return -TotalYShift }
 func Shift(dx int) {
 	TotalShift += float64(dx) * Mesh().CellSize()[X] // needed to re-init geom, regions
 	if ShiftM {
-		shiftMag(M.Buffer(), dx) // TODO: M.shift?
+		for _, s := range sublattices[:numSublattices] {
+			shiftMag(s.m.Buffer(), dx) // TODO: M.shift?
+		}
+		elastic.shift(dx, X)
 	}
 	if ShiftRegions {
 		regions.shift(dx)
@@ -39,7 +51,10 @@ func Shift(dx int) {
 	if ShiftGeom {
 		geometry.shift(dx)
 	}
-	M.normalize()
+	if ShiftMasks {
+		shiftMasks(dx, X)
+	}
+	normalizeState()
 }
 
 func shiftMag(m *data.Slice, dx int) {
@@ -56,7 +71,10 @@ func shiftMag(m *data.Slice, dx int) {
 func YShift(dy int) {
 	TotalYShift += float64(dy) * Mesh().CellSize()[Y] // needed to re-init geom, regions
 	if ShiftM {
-		shiftMagY(M.Buffer(), dy)
+		for _, s := range sublattices[:numSublattices] {
+			shiftMagY(s.m.Buffer(), dy)
+		}
+		elastic.shift(dy, Y)
 	}
 	if ShiftRegions {
 		regions.shiftY(dy)
@@ -64,7 +82,10 @@ func YShift(dy int) {
 	if ShiftGeom {
 		geometry.shiftY(dy)
 	}
-	M.normalize()
+	if ShiftMasks {
+		shiftMasks(dy, Y)
+	}
+	normalizeState()
 }
 
 func shiftMagY(m *data.Slice, dy int) {
@@ -72,7 +93,32 @@ func shiftMagY(m *data.Slice, dy int) {
 	defer cuda.Recycle(m2)
 	for c := 0; c < m.NComp(); c++ {
 		comp := m.Comp(c)
-		cuda.ShiftY(m2, comp, dy, float32(ShiftMagU[c]), float32(ShiftMagD[c]))
+		cuda.ShiftY(m2, comp, dy, float32(ShiftMagD[c]), float32(ShiftMagU[c]))
 		data.Copy(comp, m2) // str0 ?
 	}
 }
+
+// shift the masks of all excitations over d cells along direction dir.
+// A mask shared by several excitations is shifted only once.
+func shiftMasks(d, dir int) {
+	done := make(map[*data.Slice]bool)
+	for _, e := range excitations {
+		for _, m := range e.masks() {
+			if done[m] {
+				continue
+			}
+			done[m] = true
+			m2 := cuda.Buffer(1, m.Size())
+			for c := 0; c < m.NComp(); c++ {
+				comp := m.Comp(c)
+				if dir == X {
+					cuda.ShiftX(m2, comp, d, 0, 0)
+				} else {
+					cuda.ShiftY(m2, comp, d, 0, 0)
+				}
+				data.Copy(comp, m2)
+			}
+			cuda.Recycle(m2)
+		}
+	}
+}
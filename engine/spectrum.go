@@ -0,0 +1,196 @@
+package engine
+
+// On-the-fly spectral analysis: a streaming discrete Fourier transform
+// of a quantity, evaluated at user-chosen frequencies while the simulation
+// runs. This avoids saving thousands of snapshots for FMR-type spectra.
+//
+// The quantity is sampled at fixed intervals. Under adaptive time stepping
+// the samples are linearly interpolated between the surrounding time steps.
+// For each frequency f the running sums
+// 	X(f) = Σ q(t_k) exp(-2πi f t_k)
+// are kept on the GPU, with t_k measured from the start of accumulation.
+// Upon SpectrumSave() (or exit), amplitude 2|X|/N and phase arg(X) are
+// written as OVF files, one pair per frequency, and the spectrum of the
+// spatially averaged quantity is written as a table.
+
+import (
+	"math"
+
+	"github.com/mumax/3/cuda"
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/httpfs"
+	"github.com/mumax/3/util"
+)
+
+var spectra []*spectrum // all active spectrum accumulators
+
+func init() {
+	DeclFunc("SpectrumAccumulate", SpectrumAccumulate, "Accumulate the Fourier transform of a quantity, sampled every period (s), "+
+		"at n frequencies between fmin and fmax (Hz). Output is written by SpectrumSave() or at exit.")
+	DeclFunc("SpectrumSave", SpectrumSave, "Save amplitude and phase maps and the averaged spectrum of all accumulated spectra")
+}
+
+type spectrum struct {
+	q      Quantity
+	name   string
+	freq   []float64     // frequencies (Hz)
+	period float64       // sampling interval (s)
+	start  float64       // time of first sample
+	count  int           // number of samples taken
+	re, im []*data.Slice // running sums per frequency (GPU)
+	prev   *data.Slice   // value at previous time step, for interpolation (GPU)
+	tprev  float64       // time of prev
+	saved  bool          // output written since last sample?
+}
+
+// SpectrumAccumulate starts accumulating the spectrum of q, sampled every period,
+// at n equidistant frequencies from fmin to fmax (inclusive).
+func SpectrumAccumulate(q Quantity, period, fmin, fmax float64, n int) {
+	util.Argument(period > 0 && n > 0 && fmax >= fmin)
+	freq := make([]float64, n)
+	for i := range freq {
+		freq[i] = fmin
+		if n > 1 {
+			freq[i] += float64(i) * (fmax - fmin) / float64(n-1)
+		}
+	}
+	if fmax > 0.5/period {
+		LogOut("SpectrumAccumulate: fmax above Nyquist frequency", 0.5/period, "Hz")
+	}
+
+	size := SizeOf(q)
+	s := &spectrum{q: q, name: NameOf(q) + "_spectrum", freq: freq, period: period, start: Time}
+	for range freq {
+		re := cuda.NewSlice(q.NComp(), size)
+		im := cuda.NewSlice(q.NComp(), size)
+		cuda.Zero(re)
+		cuda.Zero(im)
+		s.re = append(s.re, re)
+		s.im = append(s.im, im)
+	}
+	s.prev = cuda.NewSlice(q.NComp(), size)
+	s.update() // first sample at current time
+	spectra = append(spectra, s)
+	PostStep(s.update)
+}
+
+// Save the output of all spectrum accumulators.
+func SpectrumSave() {
+	for _, s := range spectra {
+		s.save()
+	}
+}
+
+// called after every time step: add all sample times passed since the previous step.
+func (s *spectrum) update() {
+	now := ValueOf(s.q)
+	defer cuda.Recycle(now)
+
+	for tk := s.sampleTime(); tk <= Time; tk = s.sampleTime() {
+		if s.count == 0 || Time == s.tprev {
+			s.add(now, tk)
+		} else {
+			// linear interpolation between previous and current step
+			a := float32((tk - s.tprev) / (Time - s.tprev))
+			buf := cuda.Buffer(s.q.NComp(), now.Size())
+			cuda.Madd2(buf, s.prev, now, 1-a, a)
+			s.add(buf, tk)
+			cuda.Recycle(buf)
+		}
+	}
+
+	data.Copy(s.prev, now)
+	s.tprev = Time
+}
+
+// time of the next sample
+func (s *spectrum) sampleTime() float64 {
+	return s.start + float64(s.count)*s.period
+}
+
+// add sample v, taken at time t, to the running sums.
+func (s *spectrum) add(v *data.Slice, t float64) {
+	for i, f := range s.freq {
+		ph := 2 * math.Pi * f * (t - s.start)
+		cuda.Madd2(s.re[i], s.re[i], v, 1, float32(math.Cos(ph)))
+		cuda.Madd2(s.im[i], s.im[i], v, 1, float32(-math.Sin(ph)))
+	}
+	s.count++
+	s.saved = false
+}
+
+// write amplitude and phase maps per frequency plus the averaged spectrum.
+func (s *spectrum) save() {
+	if s.saved || s.count == 0 {
+		return
+	}
+	norm := 2 / float64(s.count)
+	info := data.Meta{Time: Time, Name: s.name, Unit: UnitOf(s.q), CellSize: MeshOf(s.q).CellSize(), LayerThickness: layerMeta(MeshOf(s.q))}
+	ncomp := s.q.NComp()
+
+	table, err := httpfs.Create(OD() + s.name + ".txt")
+	util.FatalErr(err)
+	defer table.Close()
+	fprint(table, "# f (Hz)")
+	for c := 0; c < ncomp; c++ {
+		fprint(table, "\tamp", s.compName(c), " (", UnitOf(s.q), ")")
+	}
+	for c := 0; c < ncomp; c++ {
+		fprint(table, "\tphase", s.compName(c), " (rad)")
+	}
+	for c := 0; c < ncomp; c++ {
+		fprint(table, "\tpower", s.compName(c), " (", UnitOf(s.q), "^2)")
+	}
+	fprintln(table)
+
+	for i, f := range s.freq {
+		re := s.re[i].HostCopy().Host()
+		im := s.im[i].HostCopy().Host()
+		size := s.re[i].Size()
+		amp := data.NewSlice(ncomp, size)
+		phase := data.NewSlice(ncomp, size)
+		a, p := amp.Host(), phase.Host()
+
+		avgRe, avgIm, power := make([]float64, ncomp), make([]float64, ncomp), make([]float64, ncomp)
+		for c := 0; c < ncomp; c++ {
+			for j := range re[c] {
+				x, y := float64(re[c][j]), float64(im[c][j])
+				a[c][j] = float32(norm * math.Hypot(x, y))
+				p[c][j] = float32(math.Atan2(y, x))
+				avgRe[c] += x
+				avgIm[c] += y
+				power[c] += sqr(norm * math.Hypot(x, y))
+			}
+		}
+
+		ampInfo, phaseInfo := info, info
+		ampInfo.Name = s.name + "_amp"
+		phaseInfo.Name = s.name + "_phase"
+		phaseInfo.Unit = "rad"
+		ampFile := autoFname(ampInfo.Name, outputFormat, i)
+		phaseFile := autoFname(phaseInfo.Name, outputFormat, i)
+		queOutput(func() { saveAs_sync(ampFile, amp, ampInfo, outputFormat) })
+		queOutput(func() { saveAs_sync(phaseFile, phase, phaseInfo, outputFormat) })
+
+		ncell := float64(prod(size))
+		fprint(table, f)
+		for c := 0; c < ncomp; c++ {
+			fprint(table, "\t", float32(norm*math.Hypot(avgRe[c], avgIm[c])/ncell))
+		}
+		for c := 0; c < ncomp; c++ {
+			fprint(table, "\t", float32(math.Atan2(avgIm[c], avgRe[c])))
+		}
+		for c := 0; c < ncomp; c++ {
+			fprint(table, "\t", float32(power[c]/ncell))
+		}
+		fprintln(table)
+	}
+	s.saved = true
+}
+
+func (s *spectrum) compName(c int) string {
+	if s.q.NComp() == 1 {
+		return ""
+	}
+	return compname[c]
+}
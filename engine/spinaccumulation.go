@@ -0,0 +1,144 @@
+package engine
+
+// Spin accumulation by drift-diffusion (Zhang, Levy and Fert).
+//
+// The spin accumulation s (A/m) is solved in its steady state
+// 	Dspin ∇²s - Dspin s/λsf² - Dspin s×m/λJ² - (μB/e) ∇·(β m⊗j) = 0
+// with λsf = sqrt(Dspin τsf) and λJ = sqrt(ħ Dspin/J_sd), so that Dspin/λJ² = J_sd/ħ,
+// with red-black SOR, starting from the previous solution.
+// It is solved again whenever m, the time (for time-dependent J) or one of the parameters has changed.
+// The spin current is polarized by Pol (β) along m and driven by the current density J.
+// Spin transport takes place in all cells with non-zero Dspin, also non-magnetic ones
+// (spacers, channels of non-local spin valves). No spin current flows out of that medium.
+//
+// s acts on m through the s-d exchange, as a field
+// 	B_sa = J_sd/(ħ γ Msat) s = Dspin/(γ λJ² Msat) s
+// This torque supplements the Zhang-Li and Slonczewski torques,
+// disable those (DisableZhangLiTorque, DisableSlonczewskiTorque) to replace them.
+
+import (
+	"github.com/mumax/3/cuda"
+	"github.com/mumax/3/data"
+)
+
+var (
+	Dspin            = NewScalarParam("Dspin", "m2/s", "Spin diffusion constant (∝ conductivity), non-zero enables spin accumulation")
+	LambdaSF         = NewScalarParam("lambda_sf", "m", "Spin-flip length sqrt(Dspin τsf)")
+	LambdaJ          = NewScalarParam("lambda_J", "m", "Spin dephasing length sqrt(ħ Dspin/J_sd)")
+	B_sa             = NewVectorField("B_sa", "T", "Spin accumulation field", AddSpinAccumulationField)
+	SpinAcc          = NewVectorField("s_acc", "A/m", "Spin accumulation", setSpinAccumulation)
+	SpinAccTolerance = 1e-5 // relative tolerance on the change of s per iteration
+	SpinAccMaxIter   = 5000 // maximum number of SOR iterations per solve
+	SpinAccOmega     = 1.5  // over-relaxation
+	spinAcc          spinAccumulation
+)
+
+const spinAccCheckEvery = 10 // iterations between convergence checks
+
+func init() {
+	DeclVar("SpinAccTolerance", &SpinAccTolerance, "Relative tolerance of the spin accumulation solver (default=1e-5)")
+	DeclVar("SpinAccMaxIter", &SpinAccMaxIter, "Maximum number of spin accumulation solver iterations per time step (default=5000)")
+	DeclVar("SpinAccOmega", &SpinAccOmega, "Over-relaxation of the spin accumulation solver, between 1 and 2 (default=1.5)")
+	for _, p := range spinAccParents() {
+		p.addChild(&spinAcc)
+	}
+}
+
+type spinAccumulation struct {
+	s     *data.Slice // solution, kept as initial guess for the next solve
+	m     *data.Slice // magnetization at the last solve
+	time  float64     // Time at the last solve
+	valid bool        // cleared when a parameter changes
+}
+
+// parameters the spin accumulation depends on, besides m
+func spinAccParents() []parent {
+	return []parent{Dspin, LambdaSF, LambdaJ, Pol, &J.perRegion}
+}
+
+func (sa *spinAccumulation) invalidate() {
+	sa.valid = false
+}
+
+// Adds the spin accumulation field to dst.
+func AddSpinAccumulationField(dst *data.Slice) {
+	if Dspin.isZero() || LambdaJ.isZero() {
+		return
+	}
+	s := spinAcc.get()
+	d := Dspin.MSlice()
+	defer d.Recycle()
+	lJ := LambdaJ.MSlice()
+	defer lJ.Recycle()
+	ms := Msat.MSlice()
+	defer ms.Recycle()
+	cuda.AddSpinAccField(dst, s, d, lJ, ms, GammaLL)
+}
+
+func setSpinAccumulation(dst *data.Slice) {
+	if Dspin.isZero() {
+		return
+	}
+	data.Copy(dst, spinAcc.get())
+}
+
+// returns the spin accumulation, solved if m, the time or the parameters have changed since the last solve.
+func (sa *spinAccumulation) get() *data.Slice {
+	checkLayers("Spin accumulation")
+	if sa.s == nil || sa.s.Size() != Mesh().Size() {
+		sa.free()
+		sa.s = cuda.NewSlice(3, Mesh().Size())
+		sa.m = cuda.NewSlice(3, Mesh().Size())
+	}
+	for _, p := range spinAccParents() {
+		p.update() // may invalidate me
+	}
+	if !sa.valid || sa.time != Time || cuda.MaxVecDiff(M.Buffer(), sa.m) != 0 {
+		sa.solve()
+	}
+	return sa.s
+}
+
+func (sa *spinAccumulation) solve() {
+	size := Mesh().Size()
+	S := cuda.Buffer(3, size)
+	defer cuda.Recycle(S)
+	diff := cuda.Buffer(1, size)
+	defer cuda.Recycle(diff)
+
+	d := Dspin.MSlice()
+	defer d.Recycle()
+	lsf := LambdaSF.MSlice()
+	defer lsf.Recycle()
+	lJ := LambdaJ.MSlice()
+	defer lJ.Recycle()
+	j := J.MSlice()
+	defer j.Recycle()
+	pol := Pol.MSlice()
+	defer pol.Recycle()
+
+	m := M.Buffer()
+	cuda.SetSpinAccSource(S, m, j, pol, d, Mesh())
+	for iter := 1; iter <= SpinAccMaxIter; iter++ {
+		for color := 0; color < 2; color++ {
+			cuda.SpinAccUpdate(sa.s, diff, m, S, d, lsf, lJ, SpinAccOmega, color, Mesh())
+		}
+		// convergence check every few iterations, it needs to synchronize
+		if iter%spinAccCheckEvery == 0 && float64(cuda.MaxAbs(diff)) <= SpinAccTolerance*cuda.MaxVecNorm(sa.s) {
+			break
+		}
+	}
+	data.Copy(sa.m, m)
+	sa.time = Time
+	sa.valid = true
+}
+
+func (sa *spinAccumulation) free() {
+	if sa.s != nil {
+		sa.s.Free()
+		sa.s = nil
+		sa.m.Free()
+		sa.m = nil
+	}
+	sa.valid = false
+}
@@ -0,0 +1,60 @@
+package engine
+
+// Spin-orbit torque from a charge current J flowing in an adjacent heavy metal.
+
+import (
+	"github.com/mumax/3/cuda"
+	"github.com/mumax/3/data"
+)
+
+var (
+	SpinHallAngle         = NewScalarParam("SpinHallAngle", "", "Spin Hall angle of the heavy metal, for spin-orbit torque")
+	SOTDampingLike        = NewScalarParam("SOTDampingLike", "", "Damping-like spin-orbit torque efficiency, relative to the spin Hall angle (default=1)")
+	SOTFieldLike          = NewScalarParam("SOTFieldLike", "", "Field-like spin-orbit torque efficiency, relative to the spin Hall angle (default=0)")
+	HMThickness           = NewScalarParam("HMThickness", "m", "Heavy metal thickness, reduces the spin Hall angle if HMSpinDiffusionLength is set")
+	HMSpinDiffusionLength = NewScalarParam("HMSpinDiffusionLength", "m", "Spin diffusion length of the heavy metal (if set to zero (default), no reduction of the spin Hall angle)")
+	SOTPolarization       = NewExcitation("SOTPolarization", "", "Spin-orbit torque polarization (if set to zero (default), it is along J × SOTNormal)")
+	SOTorque              = NewVectorField("SOTorque", "T", "Spin-orbit torque/γ0", AddSOTorque)
+	SOTNormal             = data.Vector{0, 0, 1} // interface normal, pointing from the heavy metal into the magnet
+	DisableSOTorque       = false
+)
+
+func init() {
+	SOTDampingLike.Set(1)
+	DeclVar("SOTNormal", &SOTNormal, "Heavy metal/magnet interface normal, pointing into the magnet (default=(0,0,1))")
+	DeclVar("DisableSOTorque", &DisableSOTorque, "Disables spin-orbit torque (default=false)")
+}
+
+// Adds the current spin-orbit torque to dst
+func AddSOTorque(dst *data.Slice) {
+	addSOTorqueFrom(dst, M, Msat, Alpha)
+}
+
+// Adds the spin-orbit torque on magnetization M to dst.
+func addSOTorqueFrom(dst *data.Slice, M magnetization, Msat, Alpha *RegionwiseScalar) {
+	if DisableSOTorque || J.isZero() || SpinHallAngle.isZero() {
+		return
+	}
+	msat := Msat.MSlice()
+	defer msat.Recycle()
+	j := J.MSlice()
+	defer j.Recycle()
+	p := SOTPolarization.MSlice()
+	defer p.Recycle()
+	alpha := Alpha.MSlice()
+	defer alpha.Recycle()
+	θSH := SpinHallAngle.MSlice()
+	defer θSH.Recycle()
+	ξDL := SOTDampingLike.MSlice()
+	defer ξDL.Recycle()
+	ξFL := SOTFieldLike.MSlice()
+	defer ξFL.Recycle()
+	thickness := FreeLayerThickness.MSlice()
+	defer thickness.Recycle()
+	tHM := HMThickness.MSlice()
+	defer tHM.Recycle()
+	λsf := HMSpinDiffusionLength.MSlice()
+	defer λsf.Recycle()
+	cuda.AddSpinOrbitTorque(dst, M.Buffer(), msat, j, p, alpha, θSH, ξDL, ξFL,
+		thickness, tHM, λsf, SOTNormal, Mesh())
+}
@@ -0,0 +1,211 @@
+package engine
+
+// Shapes from triangulated surface meshes (STL files), e.g. exported from CAD.
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/httpfs"
+)
+
+func init() {
+	DeclFunc("STLShape", STLShape, "Use a closed triangle mesh from an ASCII or binary STL file as shape. "+
+		"Coordinates are used as-is (meter), use Scale() for other units")
+}
+
+type triangle [3]data.Vector
+
+// closed surface mesh with a 2D grid (in xy) of buckets
+// to quickly find the triangles above or below a point.
+type stlMesh struct {
+	tri        []triangle
+	min, max   data.Vector // bounding box
+	nx, ny     int         // number of buckets
+	bx, by     float64     // bucket size
+	bucket     [][]int32   // triangle indices per bucket
+	εx, εy, εz float64     // ray origin perturbation
+}
+
+// STLShape loads a closed triangle mesh from file.
+// A point is inside when a ray from the point along +z has a non-zero winding number.
+func STLShape(fname string) Shape {
+	raw, err := httpfs.Read(fname)
+	CheckRecoverable(err)
+	tri, err := parseSTL(raw)
+	CheckRecoverable(err)
+	if len(tri) == 0 {
+		CheckRecoverable(fmt.Errorf("STLShape %v: no triangles", fname))
+	}
+	m := newSTLMesh(tri)
+	return m.inside
+}
+
+func newSTLMesh(tri []triangle) *stlMesh {
+	m := &stlMesh{tri: tri}
+	inf := math.Inf(1)
+	m.min = data.Vector{inf, inf, inf}
+	m.max = data.Vector{-inf, -inf, -inf}
+	for _, t := range tri {
+		for _, v := range t {
+			for c := 0; c < 3; c++ {
+				m.min[c] = math.Min(m.min[c], v[c])
+				m.max[c] = math.Max(m.max[c], v[c])
+			}
+		}
+	}
+
+	// about one triangle per bucket
+	n := int(math.Sqrt(float64(len(tri)))) + 1
+	m.nx, m.ny = n, n
+	m.bx = (m.max[X] - m.min[X]) / float64(n)
+	m.by = (m.max[Y] - m.min[Y]) / float64(n)
+	if m.bx == 0 {
+		m.bx = 1
+	}
+	if m.by == 0 {
+		m.by = 1
+	}
+	m.bucket = make([][]int32, n*n)
+	for i, t := range tri {
+		x1, y1 := m.bucketOf(math.Min(t[0][X], math.Min(t[1][X], t[2][X])), math.Min(t[0][Y], math.Min(t[1][Y], t[2][Y])))
+		x2, y2 := m.bucketOf(math.Max(t[0][X], math.Max(t[1][X], t[2][X])), math.Max(t[0][Y], math.Max(t[1][Y], t[2][Y])))
+		for iy := y1; iy <= y2; iy++ {
+			for ix := x1; ix <= x2; ix++ {
+				m.bucket[iy*m.nx+ix] = append(m.bucket[iy*m.nx+ix], int32(i))
+			}
+		}
+	}
+
+	// Cell centers often coincide exactly with mesh vertices or edges.
+	// Perturb the ray origin by a tiny, irrational amount to avoid these degenerate cases.
+	size := m.max.Sub(m.min).Len()
+	m.εx, m.εy, m.εz = 1.31e-9*size, 0.73e-9*size, 0.57e-9*size
+	return m
+}
+
+// bucket index for position x,y, clamped to the grid.
+func (m *stlMesh) bucketOf(x, y float64) (ix, iy int) {
+	ix = int((x - m.min[X]) / m.bx)
+	iy = int((y - m.min[Y]) / m.by)
+	if ix < 0 {
+		ix = 0
+	}
+	if ix >= m.nx {
+		ix = m.nx - 1
+	}
+	if iy < 0 {
+		iy = 0
+	}
+	if iy >= m.ny {
+		iy = m.ny - 1
+	}
+	return
+}
+
+func (m *stlMesh) inside(x, y, z float64) bool {
+	x, y, z = x+m.εx, y+m.εy, z+m.εz
+	if x < m.min[X] || x > m.max[X] || y < m.min[Y] || y > m.max[Y] || z < m.min[Z] || z > m.max[Z] {
+		return false
+	}
+	ix, iy := m.bucketOf(x, y)
+	winding := 0
+	for _, i := range m.bucket[iy*m.nx+ix] {
+		winding += m.tri[i].crossing(x, y, z)
+	}
+	return winding != 0
+}
+
+// Returns +1 or -1 if the ray from (x,y,z) along +z crosses the triangle,
+// depending on the triangle's orientation, 0 otherwise.
+func (t *triangle) crossing(x, y, z float64) int {
+	a, b, c := t[0], t[1], t[2]
+	// signed areas of the sub-triangles in the xy projection
+	w0 := (b[X]-x)*(c[Y]-y) - (c[X]-x)*(b[Y]-y)
+	w1 := (c[X]-x)*(a[Y]-y) - (a[X]-x)*(c[Y]-y)
+	w2 := (a[X]-x)*(b[Y]-y) - (b[X]-x)*(a[Y]-y)
+	sign := 0
+	switch {
+	case w0 > 0 && w1 > 0 && w2 > 0:
+		sign = 1
+	case w0 < 0 && w1 < 0 && w2 < 0:
+		sign = -1
+	default:
+		return 0
+	}
+	// z of the intersection point
+	W := w0 + w1 + w2
+	zt := (w0*a[Z] + w1*b[Z] + w2*c[Z]) / W
+	if zt > z {
+		return sign
+	}
+	return 0
+}
+
+// parse ASCII or binary STL data.
+func parseSTL(raw []byte) ([]triangle, error) {
+	// binary files may start with "solid" too, so check the size first
+	if len(raw) >= 84 {
+		n := int(binary.LittleEndian.Uint32(raw[80:84]))
+		if len(raw) == 84+50*n {
+			return parseBinarySTL(raw[84:], n), nil
+		}
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("solid")) {
+		return parseASCIISTL(raw)
+	}
+	return nil, fmt.Errorf("STL: unrecognized file format")
+}
+
+func parseBinarySTL(raw []byte, n int) []triangle {
+	tri := make([]triangle, n)
+	for i := range tri {
+		rec := raw[50*i:]
+		for v := 0; v < 3; v++ {
+			for c := 0; c < 3; c++ {
+				bits := binary.LittleEndian.Uint32(rec[12+12*v+4*c:]) // skip normal
+				tri[i][v][c] = float64(math.Float32frombits(bits))
+			}
+		}
+	}
+	return tri
+}
+
+func parseASCIISTL(raw []byte) ([]triangle, error) {
+	var (
+		tri  []triangle
+		t    triangle
+		nv   int
+		line int
+	)
+	in := bufio.NewScanner(bytes.NewReader(raw))
+	for in.Scan() {
+		line++
+		f := strings.Fields(in.Text())
+		if len(f) == 0 || f[0] != "vertex" {
+			continue
+		}
+		if len(f) != 4 {
+			return nil, fmt.Errorf("STL line %v: need vertex x y z", line)
+		}
+		for c := 0; c < 3; c++ {
+			v, err := strconv.ParseFloat(f[c+1], 64)
+			if err != nil {
+				return nil, fmt.Errorf("STL line %v: %v", line, err)
+			}
+			t[nv][c] = v
+		}
+		nv++
+		if nv == 3 {
+			tri = append(tri, t)
+			nv = 0
+		}
+	}
+	return tri, in.Err()
+}
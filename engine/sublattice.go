@@ -0,0 +1,331 @@
+package engine
+
+// Multi-sublattice magnets: antiferromagnets and ferrimagnets.
+//
+// Sublattice 1 is the usual magnetization m with the usual material parameters.
+// Additional sublattices i = 2, 3 (see SetNumSublattices) share the mesh, geometry and
+// regions, but have their own magnetization m_i and parameters Msat_i, alpha_i,
+// GammaLL_i, Aex_i, Ku1_i, Ku2_i, anisU_i, Kc1_i, Kc2_i, Kc3_i, B1_i, B2_i and VCMACoefficient_i.
+//
+// Each sublattice feels the demag field of the net moment Σ Msat_i m_i, the external,
+// Oersted and body fields, its own exchange, uniaxial and cubic anisotropy (the cubic axes anisC1, anisC2
+// are those of the shared crystal lattice), VCMA and magneto-elastic coupling (to the shared strain),
+// DMI (Dind, Dbulk and Dfilm are shared by all sublattices), the interlayer exchange
+// (which couples each sublattice to itself across the spacer), its own thermal field
+// and the exchange with the other sublattices, with energy density
+// 	e_ij = -Ahom_ij m_i·m_j + Ainh_ij ∇m_i:∇m_j
+// so that the field on sublattice i reads
+// 	B_i = Ahom_ij/Msat_i m_j + Ainh_ij/Msat_i ∇²m_j.
+// Negative Ahom_ij, Ainh_ij are antiferromagnetic.
+// Spin-transfer and spin-orbit torques act on each sublattice with its own Msat and alpha.
+//
+// The solvers integrate all sublattices at once, their state holds the components
+// of all sublattices (see solverState). Minimize, the LLB and Monte Carlo solvers,
+// spin accumulation and custom fields only support a single sublattice,
+// and stop the simulation otherwise.
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/mumax/3/cuda"
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/util"
+)
+
+const MAXSUBLATTICE = 3 // maximum number of sublattices
+
+var (
+	sublattices    [MAXSUBLATTICE]*sublattice // sublattices[0] is m
+	numSublattices = 1
+
+	M_net            = NewVectorField("m_net", "A/m", "Net magnetization of all sublattices", SetNetMagnetization)
+	NeelVector       = NewVectorField("neel", "", "Néel vector (m - m_2)/2", SetNeelVector)
+	Edens_sublattice = NewScalarField("Edens_sublattice", "J/m3", "Energy density of sublattices 2, 3 and of the intersublattice exchange", AddSublatticeEnergyDensity)
+	E_sublattice     = NewScalarValue("E_sublattice", "J", "Energy of sublattices 2, 3 and of the intersublattice exchange", GetSublatticeEnergy)
+)
+
+func init() {
+	DeclFunc("SetNumSublattices", SetNumSublattices, "Sets the number of magnetic sublattices (1-3, default=1)")
+	registerEnergy(GetSublatticeEnergy, AddSublatticeEnergyDensity)
+
+	sublattices[0] = &sublattice{m: &M, Msat: Msat, Alpha: Alpha, Aex: Aex, Ku1: Ku1, Ku2: Ku2, Kc1: Kc1, Kc2: Kc2, Kc3: Kc3,
+		B1: B1, B2: B2, VCMACoefficient: VCMACoefficient, AnisU: AnisU, gamma: &GammaLL, lex2: &lex2, therm: &B_therm}
+	for i := 1; i < MAXSUBLATTICE; i++ {
+		sublattices[i] = newSublattice(i)
+	}
+	for i := 0; i < MAXSUBLATTICE; i++ {
+		for j := i + 1; j < MAXSUBLATTICE; j++ {
+			pair := fmt.Sprint("_", i+1, j+1)
+			hom := NewScalarParam("Ahom"+pair, "J/m3", fmt.Sprint("Homogeneous exchange between sublattice ", i+1, " and ", j+1, " (negative: antiferromagnetic)"))
+			inh := NewScalarParam("Ainh"+pair, "J/m", fmt.Sprint("Inhomogeneous exchange between sublattice ", i+1, " and ", j+1, " (negative: antiferromagnetic)"))
+			sublattices[i].Ahom[j], sublattices[j].Ahom[i] = hom, hom
+			sublattices[i].Ainh[j], sublattices[j].Ainh[i] = inh, inh
+		}
+	}
+}
+
+// One magnetic sublattice: magnetization and material parameters.
+type sublattice struct {
+	m                          *magnetization
+	Msat, Alpha, Aex, Ku1, Ku2 *RegionwiseScalar
+	Kc1, Kc2, Kc3              *RegionwiseScalar
+	B1, B2, VCMACoefficient    *RegionwiseScalar
+	AnisU                      *RegionwiseVector
+	gamma                      *float64                         // gyromagnetic ratio (rad/Ts)
+	lex2                       *exchParam                       // inter-cell Aex
+	Ahom, Ainh                 [MAXSUBLATTICE]*RegionwiseScalar // exchange with the other sublattices
+	therm                      *thermField                      // thermal field
+}
+
+// declares the magnetization and parameters of sublattice i+1
+func newSublattice(i int) *sublattice {
+	n := fmt.Sprint("_", i+1)
+	which := fmt.Sprint(" of sublattice ", i+1)
+	s := &sublattice{m: &magnetization{name: "m" + n}, gamma: new(float64), lex2: new(exchParam)}
+	*s.gamma = GammaLL
+	s.Msat = NewScalarParam("Msat"+n, "A/m", "Saturation magnetization"+which)
+	s.Alpha = NewScalarParam("alpha"+n, "", "Landau-Lifshitz damping constant"+which)
+	s.Aex = NewScalarParam("Aex"+n, "J/m", "Exchange stiffness"+which, s.lex2)
+	s.Ku1 = NewScalarParam("Ku1"+n, "J/m3", "1st order uniaxial anisotropy constant"+which)
+	s.Ku2 = NewScalarParam("Ku2"+n, "J/m3", "2nd order uniaxial anisotropy constant"+which)
+	s.AnisU = NewVectorParam("anisU"+n, "", "Uniaxial anisotropy direction"+which)
+	s.Kc1 = NewScalarParam("Kc1"+n, "J/m3", "1st order cubic anisotropy constant"+which)
+	s.Kc2 = NewScalarParam("Kc2"+n, "J/m3", "2nd order cubic anisotropy constant"+which)
+	s.Kc3 = NewScalarParam("Kc3"+n, "J/m3", "3rd order cubic anisotropy constant"+which)
+	s.B1 = NewScalarParam("B1"+n, "J/m3", "First magneto-elastic coupling constant"+which)
+	s.B2 = NewScalarParam("B2"+n, "J/m3", "Second magneto-elastic coupling constant"+which)
+	s.VCMACoefficient = NewScalarParam("VCMACoefficient"+n, "J/(V m)", "Voltage-controlled magnetic anisotropy coefficient"+which)
+	s.therm = &thermField{step: -1, msat: s.Msat, alpha: s.Alpha, gamma: s.gamma}
+	s.lex2.init(s.Aex)
+	DeclLValue(s.m.name, s.m, "Reduced magnetization"+which+" (unit length)")
+	DeclVar("GammaLL"+n, s.gamma, "Gyromagnetic ratio"+which+" in rad/Ts")
+	return s
+}
+
+// Sets the number of sublattices. New sublattices start with a random magnetization.
+func SetNumSublattices(n int) {
+	if n < 1 || n > MAXSUBLATTICE {
+		util.Fatal("SetNumSublattices: need 1-", MAXSUBLATTICE, " sublattices, have: ", n)
+	}
+	checkMesh()
+	for i := 1; i < MAXSUBLATTICE; i++ {
+		m := sublattices[i].m
+		if i < n && m.buffer_ == nil {
+			m.alloc()
+		}
+		if i >= n && m.buffer_ != nil {
+			m.buffer_.Free()
+			m.buffer_ = nil
+		}
+	}
+	numSublattices = n
+	if stepper != nil {
+		stepper.Free() // may hold state for the previous number of sublattices
+	}
+}
+
+// The state integrated by the solvers: the components of the magnetization of all sublattices.
+func solverState() *data.Slice {
+	if numSublattices == 1 {
+		return M.Buffer()
+	}
+	var ptrs []unsafe.Pointer
+	for i := 0; i < numSublattices; i++ {
+		m := sublattices[i].m.Buffer()
+		for c := 0; c < VECTOR; c++ {
+			ptrs = append(ptrs, m.DevPtr(c))
+		}
+	}
+	return data.SliceFromPtrs(Mesh().Size(), data.GPUMemory, ptrs)
+}
+
+// the 3 components of sublattice i in state
+func sublatticeComps(state *data.Slice, i int) *data.Slice {
+	ptrs := make([]unsafe.Pointer, VECTOR)
+	for c := range ptrs {
+		ptrs[c] = state.DevPtr(VECTOR*i + c)
+	}
+	return data.SliceFromPtrs(state.Size(), data.GPUMemory, ptrs)
+}
+
+// normalize the magnetization of all sublattices
+func normalizeState() {
+	for i := 0; i < numSublattices; i++ {
+		sublattices[i].m.normalize()
+	}
+}
+
+// Sets dst, with the components of all sublattices, to their torques.
+func setSublatticeTorques(dst *data.Slice) {
+	checkSublatticeTerms()
+	util.Assert(dst.NComp() == VECTOR*numSublattices)
+	SetTorque(sublatticeComps(dst, 0))
+	for i := 1; i < numSublattices; i++ {
+		sublattices[i].setTorque(sublatticeComps(dst, i))
+	}
+}
+
+// Sets dst to the torque on sublattice s, other than sublattice 1.
+func (s *sublattice) setTorque(dst *data.Slice) {
+	s.setEffectiveField(dst)
+	alpha := s.Alpha.MSlice()
+	defer alpha.Recycle()
+	if Precess {
+		cuda.LLTorque(dst, s.m.Buffer(), dst, alpha) // overwrite dst with torque
+	} else {
+		cuda.LLNoPrecess(dst, s.m.Buffer(), dst)
+	}
+	addSTTorqueFrom(dst, *s.m, s.Msat, s.Alpha)
+	addSOTorqueFrom(dst, *s.m, s.Msat, s.Alpha)
+	FreezeSpins(dst)
+	if *s.gamma != GammaLL {
+		// solvers step in units of 1/GammaLL
+		cuda.Madd2(dst, dst, dst, float32(*s.gamma/GammaLL), 0)
+	}
+}
+
+// stops the simulation if any term is active that does not support multiple sublattices.
+func checkSublatticeTerms() {
+	for _, t := range []struct {
+		active bool
+		name   string
+	}{
+		{!Dspin.isZero() && !LambdaJ.isZero(), "spin accumulation"},
+		{len(customTerms) != 0, "custom fields"},
+	} {
+		if t.active {
+			util.Fatal("Multiple sublattices (SetNumSublattices) do not support ", t.name)
+		}
+	}
+}
+
+// Sets dst to the effective field on sublattice s, other than sublattice 1.
+func (s *sublattice) setEffectiveField(dst *data.Slice) {
+	SetDemagField(dst) // of the net moment
+	s.addLocalField(dst)
+	B_ext.AddTo(dst)
+	AddOerstedField(dst)
+	AddBodiesField(dst)
+	s.addExchangeWithOthers(dst)
+	if !relaxing && solvertype != LLB && solvertype != MONTECARLO {
+		s.therm.AddTo(dst)
+	}
+}
+
+// Adds the field terms that only depend on the sublattice itself: exchange, DMI, anisotropy,
+// VCMA, magneto-elastic coupling and interlayer exchange.
+func (s *sublattice) addLocalField(dst *data.Slice) {
+	addExchangeFrom(dst, *s.m, s.Msat, s.lex2)
+	addUniaxialAnisotropyFrom(dst, *s.m, s.Msat, s.Ku1, s.Ku2, s.AnisU)
+	addCubicAnisotropyFrom(dst, *s.m, s.Msat, s.Kc1, s.Kc2, s.Kc3, AnisC1, AnisC2)
+	addVCMAFieldFrom(dst, *s.m, s.Msat, s.VCMACoefficient, s.AnisU)
+	addMagnetoelasticFieldFrom(dst, *s.m, s.Msat, s.B1, s.B2)
+	addInterlayerExchangeFrom(dst, *s.m, s.Msat)
+}
+
+// Adds the exchange field due to the other sublattices to dst.
+func (s *sublattice) addExchangeWithOthers(dst *data.Slice) {
+	for j := 0; j < numSublattices; j++ {
+		if sublattices[j] != s {
+			s.addExchangeWith(dst, j)
+		}
+	}
+}
+
+// Adds the exchange field due to sublattice j to dst.
+func (s *sublattice) addExchangeWith(dst *data.Slice, j int) {
+	if s.Ahom[j].isZero() && s.Ainh[j].isZero() {
+		return
+	}
+	ms := s.Msat.MSlice()
+	defer ms.Recycle()
+	hom := s.Ahom[j].MSlice()
+	defer hom.Recycle()
+	inh := s.Ainh[j].MSlice()
+	defer inh.Recycle()
+	cuda.AddSublatticeExchange(dst, sublattices[j].m.Buffer(), ms, hom, inh, Mesh())
+}
+
+// Adds the exchange field on sublattice 1 due to the other sublattices to dst.
+func AddSublatticeExchangeField(dst *data.Slice) {
+	sublattices[0].addExchangeWithOthers(dst)
+}
+
+// Sets dst to the net magnetization Σ Msat_i m_i in A/m, times the cell volume fraction.
+func SetNetMagnetization(dst *data.Slice) {
+	SetMFull(dst)
+	if numSublattices == 1 {
+		return
+	}
+	buf := cuda.Buffer(VECTOR, dst.Size())
+	defer cuda.Recycle(buf)
+	for i := 1; i < numSublattices; i++ {
+		setMFullFrom(buf, *sublattices[i].m, sublattices[i].Msat)
+		cuda.Add(dst, dst, buf)
+	}
+}
+
+// Sets dst to the Néel vector (m - m_2)/2.
+func SetNeelVector(dst *data.Slice) {
+	util.AssertMsg(numSublattices > 1, "neel: needs at least 2 sublattices")
+	cuda.Madd2(dst, M.Buffer(), sublattices[1].m.Buffer(), 0.5, -0.5)
+}
+
+// Adds to dst the energy density of sublattices 2, 3 (the same terms as sublattice 1 has in its own energies;
+// the demag energy is included in E_demag) and of the intersublattice exchange.
+func AddSublatticeEnergyDensity(dst *data.Slice) {
+	if numSublattices == 1 {
+		return
+	}
+	B := cuda.Buffer(VECTOR, dst.Size())
+	defer cuda.Recycle(B)
+	M_i := cuda.Buffer(VECTOR, dst.Size())
+	defer cuda.Recycle(M_i)
+
+	for i, s := range sublattices[:numSublattices] {
+		setMFullFrom(M_i, *s.m, s.Msat)
+
+		// intersublattice exchange, each pair once
+		cuda.Zero(B)
+		for j := i + 1; j < numSublattices; j++ {
+			s.addExchangeWith(B, j)
+		}
+		cuda.AddDotProduct(dst, -1, B, M_i)
+
+		if i == 0 {
+			continue // sublattice 1 in the usual energy terms
+		}
+		// quadratic in m
+		cuda.Zero(B)
+		addExchangeFrom(B, *s.m, s.Msat, s.lex2)
+		addVCMAFieldFrom(B, *s.m, s.Msat, s.VCMACoefficient, s.AnisU)
+		cuda.AddDotProduct(dst, -0.5, B, M_i)
+
+		addAnisotropyEnergyDensityFrom(dst, *s.m, s.Msat, s.Ku1, s.Ku2, s.Kc1, s.Kc2, s.Kc3, s.AnisU)
+		addMagnetoelasticEnergyDensityFrom(dst, *s.m, s.Msat, s.B1, s.B2)
+		addInterlayerExchangeEnergyDensityFrom(dst, *s.m)
+
+		// linear in m
+		cuda.Zero(B)
+		B_ext.AddTo(B)
+		AddOerstedField(B)
+		AddBodiesField(B)
+		if !Temp.isZero() && !relaxing {
+			s.therm.AddTo(B)
+		}
+		cuda.AddDotProduct(dst, -1, B, M_i)
+	}
+}
+
+// Returns the energy of sublattices 2, 3 and of the intersublattice exchange, in J.
+func GetSublatticeEnergy() float64 {
+	if numSublattices == 1 {
+		return 0
+	}
+	edens := cuda.Buffer(SCALAR, Mesh().Size())
+	defer cuda.Recycle(edens)
+	cuda.Zero(edens)
+	AddSublatticeEnergyDensity(edens)
+	return cellVolume() * float64(cuda.Sum(edens))
+}
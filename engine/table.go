@@ -152,12 +152,8 @@ func (t *DataTable) init() {
 	// write header
 	fprint(t, "# t (s)")
 	for _, o := range t.outputs {
-		if o.NComp() == 1 {
-			fprint(t, "\t", NameOf(o), " (", UnitOf(o), ")")
-		} else {
-			for c := 0; c < o.NComp(); c++ {
-				fprint(t, "\t", NameOf(o)+string('x'+c), " (", UnitOf(o), ")")
-			}
+		for c := 0; c < o.NComp(); c++ {
+			fprint(t, "\t", CompNameOf(o, c), " (", UnitOf(o), ")")
 		}
 	}
 	fprintln(t)
@@ -13,24 +13,28 @@ var (
 	Temp        = NewScalarParam("Temp", "K", "Temperature")
 	E_therm     = NewScalarValue("E_therm", "J", "Thermal energy", GetThermalEnergy)
 	Edens_therm = NewScalarField("Edens_therm", "J/m3", "Thermal energy density", AddThermalEnergyDensity)
-	B_therm     thermField // Thermal effective field (T)
+	B_therm     thermField  // Thermal effective field (T)
+	thermScale  *data.Slice // noise scale 1/sqrt(volume fraction) if cells have unequal volume (macrospins, layers), else nil
 )
 
 var AddThermalEnergyDensity = makeEdensAdder(&B_therm, -1)
 
 // thermField calculates and caches thermal noise.
 type thermField struct {
-	seed      int64            // seed for generator
-	generator curand.Generator //
-	noise     *data.Slice      // noise buffer
-	step      int              // solver step corresponding to noise
-	dt        float64          // solver timestep corresponding to noise
+	seed        int64             // seed for generator
+	generator   curand.Generator  // generator, of B_therm only: shared by all sublattices
+	noise       *data.Slice       // noise buffer
+	step        int               // solver step corresponding to noise
+	dt          float64           // solver timestep corresponding to noise
+	msat, alpha *RegionwiseScalar // parameters of the magnetization this field acts on (sublattice)
+	gamma       *float64          // gyromagnetic ratio of the magnetization this field acts on
 }
 
 func init() {
 	DeclFunc("ThermSeed", ThermSeed, "Set a random seed for thermal noise")
 	registerEnergy(GetThermalEnergy, AddThermalEnergyDensity)
 	B_therm.step = -1 // invalidate noise cache
+	B_therm.msat, B_therm.alpha, B_therm.gamma = Msat, Alpha, &GammaLL
 	DeclROnly("B_therm", &B_therm, "Thermal field (T)")
 }
 
@@ -48,15 +52,12 @@ func (b *thermField) update() {
 		Dt_si = FixDt
 	}
 
-	if b.generator == 0 {
-		b.generator = curand.CreateGenerator(curand.PSEUDO_DEFAULT)
-		b.generator.SetSeed(b.seed)
-	}
+	generator := thermGenerator()
 	if b.noise == nil {
 		b.noise = cuda.NewSlice(b.NComp(), b.Mesh().Size())
 		// when noise was (re-)allocated it's invalid for sure.
-		B_therm.step = -1
-		B_therm.dt = -1
+		b.step = -1
+		b.dt = -1
 	}
 
 	if Temp.isZero() {
@@ -87,28 +88,40 @@ func (b *thermField) update() {
 	}
 
 	N := Mesh().NCell()
-	k2_VgammaDt := 2 * mag.Kb / (GammaLL * cellVolume() * Dt_si)
+	k2_VgammaDt := 2 * mag.Kb / (*b.gamma * cellVolume() * Dt_si)
 	noise := cuda.Buffer(1, Mesh().Size())
 	defer cuda.Recycle(noise)
 
 	const mean = 0
 	const stddev = 1
 	dst := b.noise
-	ms := Msat.MSlice()
+	ms := b.msat.MSlice()
 	defer ms.Recycle()
 	temp := Temp.MSlice()
 	defer temp.Recycle()
-	alpha := Alpha.MSlice()
+	alpha := b.alpha.MSlice()
 	defer alpha.Recycle()
 	for i := 0; i < 3; i++ {
-		b.generator.GenerateNormal(uintptr(noise.DevPtr(0)), int64(N), mean, stddev)
+		generator.GenerateNormal(uintptr(noise.DevPtr(0)), int64(N), mean, stddev)
 		cuda.SetTemperature(dst.Comp(i), noise, k2_VgammaDt, ms, temp, alpha)
+		if thermScale != nil {
+			cuda.Mul(dst.Comp(i), dst.Comp(i), thermScale) // per-cell volume
+		}
 	}
 
 	b.step = NSteps
 	b.dt = Dt_si
 }
 
+// returns the thermal noise generator, shared by all sublattices so that ThermSeed seeds all of them.
+func thermGenerator() curand.Generator {
+	if B_therm.generator == 0 {
+		B_therm.generator = curand.CreateGenerator(curand.PSEUDO_DEFAULT)
+		B_therm.generator.SetSeed(B_therm.seed)
+	}
+	return B_therm.generator
+}
+
 func GetThermalEnergy() float64 {
 	if Temp.isZero() || relaxing {
 		return 0
@@ -0,0 +1,179 @@
+package engine
+
+// Temperature dependence of Msat, Aex and Ku1.
+//
+// When the Curie temperature Tc is set, the user values of Msat, Aex and Ku1 are
+// taken at zero temperature and scaled with the equilibrium magnetization
+// 	m_e(T) = (1 - T/Tc)^β
+// according to the Callen-Callen power laws
+// 	Msat(T) = Msat(0) m_e,  Aex(T) = Aex(0) m_e^n,  Ku1(T) = Ku1(0) m_e^l
+// with n=2 and l=3 by default. Alternatively, the scaling factor of each parameter
+// can be given as a function of temperature, e.g. from FunctionFromDatafile.
+//
+// The scaling factors are derived parameters of Temp and Tc, so they follow
+// region-wise and time-dependent temperatures. In LLB mode, the parameters are not
+// scaled: the temperature dependence then follows from the length of m.
+
+import (
+	"math"
+
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/util"
+)
+
+var (
+	Tc               = NewScalarParam("Tc", "K", "Curie temperature, enables temperature dependence of Msat, Aex and Ku1 (if set)")
+	CriticalExponent = NewScalarParam("CriticalExponent", "", "Exponent β of the equilibrium magnetization m_e = (1-T/Tc)^β (default=0.5)")
+	AexExponent      = NewScalarParam("AexExponent", "", "Callen-Callen exponent n of Aex(T) = Aex(0) m_e^n (default=2)")
+	Ku1Exponent      = NewScalarParam("Ku1Exponent", "", "Callen-Callen exponent l of Ku1(T) = Ku1(0) m_e^l (default=3)")
+	M_e              = NewScalarField("m_e", "", "Equilibrium magnetization at temperature Temp, relative to zero temperature", SetEquilibriumMagnetization)
+
+	msatScaling, aexScaling, ku1Scaling *thermalScaling
+)
+
+func init() {
+	CriticalExponent.Set(0.5)
+	AexExponent.Set(2)
+	Ku1Exponent.Set(3)
+	msatScaling = newThermalScaling(nil)
+	aexScaling = newThermalScaling(AexExponent)
+	ku1Scaling = newThermalScaling(Ku1Exponent)
+	msatScaling.enable(&Msat.regionwise)
+	aexScaling.enable(&Aex.regionwise)
+	ku1Scaling.enable(&Ku1.regionwise)
+	DeclFunc("SetTemperatureScaling", SetTemperatureScaling, "Scale Msat, Aex or Ku1 with a function of temperature (K), "+
+		"e.g. FunctionFromDatafile, instead of the power law. The function returns the factor relative to zero temperature")
+}
+
+// SetTemperatureScaling makes parameter p (Msat, Aex or Ku1) proportional to f(T).
+// For Msat, f(T) is also used as equilibrium magnetization m_e(T) by the power laws of Aex and Ku1 and by LLB.
+func SetTemperatureScaling(p *RegionwiseScalar, f func(float64) float64) {
+	if p.thermal == nil {
+		util.Fatal("SetTemperatureScaling: only supported for Msat, Aex and Ku1, not ", p.Name())
+	}
+	p.thermal.table = f
+	for _, s := range []*thermalScaling{msatScaling, aexScaling, ku1Scaling} {
+		s.factor.invalidate() // all depend on m_e
+	}
+}
+
+// Set dst to the equilibrium magnetization m_e(T) per cell.
+func SetEquilibriumMagnetization(dst *data.Slice) {
+	msatScaling.factor.EvalTo(dst)
+}
+
+// Temperature scaling of a parameter.
+type thermalScaling struct {
+	raw      [][]float32           // values set by the user (at zero temperature)
+	applied  []float32             // scale factors currently applied to the parameter
+	dirty    bool                  // raw values changed since last apply
+	factor   DerivedParam          // scale factor per region
+	exponent *RegionwiseScalar     // power of m_e, nil for Msat itself
+	table    func(float64) float64 // user-defined scale factor vs. temperature, overrides the power law
+}
+
+func newThermalScaling(exponent *RegionwiseScalar) *thermalScaling {
+	s := &thermalScaling{exponent: exponent}
+	parents := []parent{Temp, Tc, CriticalExponent}
+	if exponent != nil {
+		parents = append(parents, exponent)
+	}
+	s.factor.init(SCALAR, parents, s.update)
+	return s
+}
+
+// make the parameter temperature-dependent, keeping its current values as zero-temperature values.
+func (s *thermalScaling) enable(p *regionwise) {
+	s.raw = make([][]float32, len(p.cpu_buf))
+	for c := range s.raw {
+		s.raw[c] = append([]float32{}, p.cpu_buf[c]...)
+	}
+	s.dirty = true
+	p.thermal = s
+}
+
+// calculates the scale factors
+func (s *thermalScaling) update(p *DerivedParam) {
+	T := Temp.cpuLUT()[0]
+	f := p.cpu_buf[0]
+	if s.exponent == nil || s.table != nil {
+		tc := Tc.cpuLUT()[0]
+		β := CriticalExponent.cpuLUT()[0]
+		for r := range f {
+			if s.table != nil {
+				f[r] = float32(s.table(float64(T[r])))
+			} else {
+				f[r] = float32(equilibriumMagnetization(float64(T[r]), float64(tc[r]), float64(β[r])))
+			}
+		}
+	} else {
+		me := msatScaling.factor.cpuLUT()[0]
+		n := s.exponent.cpuLUT()[0]
+		for r := range f {
+			f[r] = float32(math.Pow(float64(me[r]), float64(n[r])))
+		}
+	}
+}
+
+// (1 - T/Tc)^β, or 1 if Tc is not set.
+func equilibriumMagnetization(T, Tc, β float64) float64 {
+	switch {
+	case Tc <= 0:
+		return 1
+	case T >= Tc:
+		return 0
+	default:
+		return math.Pow(1-T/Tc, β)
+	}
+}
+
+// set zero-temperature value of region
+func (s *thermalScaling) set(region int, v []float64) {
+	for c := range s.raw {
+		s.raw[c][region] = float32(v[c])
+	}
+	s.dirty = true
+}
+
+// make room for all regions after switching to 16-bit regions (SetRegionBits).
+func (s *thermalScaling) grow() {
+	for c, old := range s.raw {
+		buf := make([]float32, nregion)
+		copy(buf, old)
+		for r := len(old); r < nregion; r++ {
+			buf[r] = old[0]
+		}
+		s.raw[c] = buf
+	}
+	s.applied = nil
+}
+
+// update the values of p if the raw values or scale factors changed.
+func (s *thermalScaling) apply(p *regionwise) {
+	f := s.factor.cpuLUT()[0]
+	changed := s.dirty
+	if len(s.applied) != len(f) {
+		s.applied = make([]float32, len(f))
+		changed = true
+	}
+	llb := solvertype == LLB
+	for r := range f {
+		want := f[r]
+		if llb {
+			want = 1
+		}
+		if s.applied[r] != want {
+			s.applied[r] = want
+			changed = true
+		}
+	}
+	if changed {
+		for c := range s.raw {
+			for r := range s.raw[c] {
+				p.cpu_buf[c][r] = s.raw[c][r] * s.applied[r]
+			}
+		}
+		s.dirty = false
+		p.invalidate()
+	}
+}
@@ -43,8 +43,14 @@ func init() {
 
 // Sets dst to the current total torque
 func SetTorque(dst *data.Slice) {
+	if solvertype == LLB {
+		SetLLBTorque(dst)
+		FreezeSpins(dst)
+		return
+	}
 	SetLLTorque(dst)
 	AddSTTorque(dst)
+	AddSOTorque(dst)
 	FreezeSpins(dst)
 }
 
@@ -62,6 +68,11 @@ func SetLLTorque(dst *data.Slice) {
 
 // Adds the current spin transfer torque to dst
 func AddSTTorque(dst *data.Slice) {
+	addSTTorqueFrom(dst, M, Msat, Alpha)
+}
+
+// Adds the spin transfer torque on magnetization M to dst.
+func addSTTorqueFrom(dst *data.Slice, M magnetization, Msat, Alpha *RegionwiseScalar) {
 	if J.isZero() {
 		return
 	}
@@ -74,7 +85,8 @@ func AddSTTorque(dst *data.Slice) {
 	if rec {
 		defer cuda.Recycle(fl)
 	}
-	if !DisableZhangLiTorque {
+	if !DisableZhangLiTorque && !macrospinMode() { // macrospins are uniform
+		checkLayers("Zhang-Li torque (see DisableZhangLiTorque)")
 		msat := Msat.MSlice()
 		defer msat.Recycle()
 		j := J.MSlice()
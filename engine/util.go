@@ -136,9 +136,26 @@ func Index2Coord(ix, iy, iz int) data.Vector {
 	x := c[X]*(float64(ix)-0.5*float64(n[X]-1)) - TotalShift
 	y := c[Y]*(float64(iy)-0.5*float64(n[Y]-1)) - TotalYShift
 	z := c[Z] * (float64(iz) - 0.5*float64(n[Z]-1))
+	if layered() {
+		z = layerCenter(iz)
+	}
 	return data.Vector{x, y, z}
 }
 
+// converts x,y,z coordinate to (fractional) cell index, inverse of Index2Coord
+func coord2Index(r data.Vector) [3]float64 {
+	m := Mesh()
+	n := m.Size()
+	c := m.CellSize()
+	ix := (r[X]+TotalShift)/c[X] + 0.5*float64(n[X]-1)
+	iy := (r[Y]+TotalYShift)/c[Y] + 0.5*float64(n[Y]-1)
+	iz := r[Z]/c[Z] + 0.5*float64(n[Z]-1)
+	if layered() {
+		iz = layerIndex(r[Z])
+	}
+	return [3]float64{ix, iy, iz}
+}
+
 func sign(x float64) float64 {
 	switch {
 	case x > 0:
@@ -160,9 +177,9 @@ func safediv(a, b float32) float32 {
 }
 
 // dst = a/b, unless b == 0
-func paramDiv(dst, a, b [][NREGION]float32) {
+func paramDiv(dst, a, b [][]float32) {
 	util.Assert(len(dst) == 1 && len(a) == 1 && len(b) == 1)
-	for i := 0; i < NREGION; i++ { // not regions.maxreg
+	for i := range dst[0] { // not regions.maxreg
 		dst[0][i] = safediv(a[0][i], b[0][i])
 	}
 }
@@ -0,0 +1,95 @@
+package engine
+
+// Voltage-controlled magnetic anisotropy (VCMA) and DMI.
+//
+// An electric field E across the interface of an ultrathin film changes its
+// interfacial anisotropy by ξ E, with ξ the VCMA coefficient in J/(V m).
+// Distributed over the cell thickness dz, this gives an extra uniaxial anisotropy
+// 	ΔKu1 = ξ E / dz
+// along AnisU, in the cells where ξ is non-zero (the interface layer).
+// With non-uniform layers (SetCellSizeZ), dz is the thickness of the layer of each cell.
+// Likewise, the interfacial DMI changes by ΔDind = ξ_D E / dz, which is region-wise
+// and therefore not supported with non-uniform layers.
+//
+// Efield is an excitation: it can be set per region, time-dependent,
+// or with masks (e.g. for gate electrodes) like B_ext.
+// A voltage V across an oxide of thickness d corresponds to Efield = V/d.
+// The DMI modulation only uses the region-wise part of Efield.
+
+import (
+	"github.com/mumax/3/cuda"
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/util"
+)
+
+var (
+	Efield           = NewScalarExcitation("Efield", "V/m", "Electric field across the interface, for voltage-controlled anisotropy and DMI")
+	VCMACoefficient  = NewScalarParam("VCMACoefficient", "J/(V m)", "Voltage-controlled magnetic anisotropy coefficient ξ: ΔKu1 = ξ Efield / dz")
+	VCDMICoefficient = NewScalarParam("VCDMICoefficient", "J/V", "Voltage-controlled DMI coefficient ξ_D: ΔDind = ξ_D Efield / dz")
+	B_vcma           = NewVectorField("B_vcma", "T", "Voltage-controlled anisotropy field", AddVCMAField)
+	Edens_vcma       = NewScalarField("Edens_vcma", "J/m3", "Voltage-controlled anisotropy energy density", AddVCMAEnergyDensity)
+	E_vcma           = NewScalarValue("E_vcma", "J", "Voltage-controlled anisotropy energy", GetVCMAEnergy)
+	vcdmi            DerivedParam // ΔDind per region, added to Dind
+)
+
+var AddVCMAEnergyDensity = makeEdensAdder(&B_vcma, -0.5)
+
+func init() {
+	registerEnergy(GetVCMAEnergy, AddVCMAEnergyDensity)
+	vcdmi.init(SCALAR, []parent{VCDMICoefficient, &Efield.perRegion}, func(p *DerivedParam) {
+		ξ := VCDMICoefficient.cpuLUT()[0]
+		E := Efield.perRegion.cpuLUT()[0]
+		dz := float32(Mesh().CellSize()[Z])
+		dD := p.cpu_buf[0]
+		for r := range dD {
+			dD[r] = ξ[r] * E[r] / dz
+			if dD[r] != 0 && layered() {
+				util.Fatal("VCDMICoefficient: not supported with non-uniform layers (SetCellSizeZ)")
+			}
+		}
+	})
+	din2.offset = &vcdmi
+}
+
+// Adds the voltage-controlled anisotropy field to dst.
+func AddVCMAField(dst *data.Slice) {
+	addVCMAFieldFrom(dst, M, Msat, VCMACoefficient, AnisU)
+}
+
+// Adds the voltage-controlled anisotropy field on magnetization M to dst,
+// with VCMA coefficient ξ along AnisU.
+func addVCMAFieldFrom(dst *data.Slice, M magnetization, Msat, VCMACoefficient *RegionwiseScalar, AnisU *RegionwiseVector) {
+	if VCMACoefficient.isZero() || Efield.isZero() {
+		return
+	}
+	ms := Msat.MSlice()
+	defer ms.Recycle()
+	u := AnisU.MSlice()
+	defer u.Recycle()
+
+	// ΔKu1 = ξ E / dz
+	dK := cuda.Buffer(SCALAR, Mesh().Size())
+	defer cuda.Recycle(dK)
+	cuda.Zero(dK)
+	Efield.AddTo(dK)
+	ξ, _ := VCMACoefficient.Slice()
+	defer cuda.Recycle(ξ)
+	cuda.Mul(dK, dK, ξ)
+	ku1 := cuda.MakeMSlice(dK, []float64{1 / Mesh().CellSize()[Z]})
+	if layered() {
+		cuda.Mul(dK, dK, layerInvDz_)
+		ku1 = cuda.ToMSlice(dK)
+	}
+	ku2 := sZero.MSlice()
+	defer ku2.Recycle()
+
+	cuda.AddUniaxialAnisotropy2(dst, M.Buffer(), ms, ku1, ku2, u)
+}
+
+// Returns the voltage-controlled anisotropy energy in J.
+func GetVCMAEnergy() float64 {
+	if VCMACoefficient.isZero() || Efield.isZero() {
+		return 0
+	}
+	return -0.5 * cellVolume() * dot(&M_full, &B_vcma)
+}
@@ -0,0 +1,74 @@
+package mag
+
+// Analytical demagnetizing factors of uniformly magnetized bodies.
+
+import "math"
+
+// Demagnetizing factors Nx, Ny, Nz of an ellipsoid with axes size (full lengths) along x, y, z.
+// J.A. Osborn, Phys. Rev. 67, 351 (1945), in terms of Carlson's elliptic integral R_D.
+func EllipsoidDemagFactors(size [3]float64) [3]float64 {
+	a2, b2, c2 := sq(size[X]/2), sq(size[Y]/2), sq(size[Z]/2)
+	p := size[X] * size[Y] * size[Z] / 24 // abc/3 with semi-axes
+	return [3]float64{
+		p * carlsonRD(b2, c2, a2),
+		p * carlsonRD(a2, c2, b2),
+		p * carlsonRD(a2, b2, c2)}
+}
+
+// Demagnetizing factors Nx, Ny, Nz of a rectangular prism with edges size along x, y, z.
+// A. Aharoni, J. Appl. Phys. 83, 3432 (1998).
+func PrismDemagFactors(size [3]float64) [3]float64 {
+	a, b, c := size[X]/2, size[Y]/2, size[Z]/2
+	return [3]float64{
+		prismDemagZ(b, c, a),
+		prismDemagZ(c, a, b),
+		prismDemagZ(a, b, c)}
+}
+
+// Aharoni's demagnetizing factor along z of the prism 2a x 2b x 2c.
+func prismDemagZ(a, b, c float64) float64 {
+	r := math.Sqrt(a*a + b*b + c*c)
+	ab := math.Sqrt(a*a + b*b)
+	bc := math.Sqrt(b*b + c*c)
+	ac := math.Sqrt(a*a + c*c)
+	abc := a * b * c
+	piN := (b*b-c*c)/(2*b*c)*math.Log((r-a)/(r+a)) +
+		(a*a-c*c)/(2*a*c)*math.Log((r-b)/(r+b)) +
+		b/(2*c)*math.Log((ab+a)/(ab-a)) +
+		a/(2*c)*math.Log((ab+b)/(ab-b)) +
+		c/(2*a)*math.Log((bc-b)/(bc+b)) +
+		c/(2*b)*math.Log((ac-a)/(ac+a)) +
+		2*math.Atan(a*b/(c*r)) +
+		(a*a*a+b*b*b-2*c*c*c)/(3*abc) +
+		(a*a+b*b-2*c*c)/(3*abc)*r +
+		c/(a*b)*(ac+bc) -
+		(ab*ab*ab+bc*bc*bc+ac*ac*ac)/(3*abc)
+	return piN / math.Pi
+}
+
+// Carlson's symmetric elliptic integral of the second kind R_D(x, y, z),
+// by the duplication algorithm (Numerical Recipes 6.11).
+func carlsonRD(x, y, z float64) float64 {
+	sum, fac := 0.0, 1.0
+	mu := (x + y + 3*z) / 5
+	for i := 0; i < 100; i++ {
+		if math.Max(math.Abs(x-mu), math.Max(math.Abs(y-mu), math.Abs(z-mu))) < 1e-10*mu {
+			break
+		}
+		sx, sy, sz := math.Sqrt(x), math.Sqrt(y), math.Sqrt(z)
+		l := sx*(sy+sz) + sy*sz
+		sum += fac / (sz * (z + l))
+		fac *= 0.25
+		x, y, z = 0.25*(x+l), 0.25*(y+l), 0.25*(z+l)
+		mu = (x + y + 3*z) / 5
+	}
+	dx, dy, dz := (mu-x)/mu, (mu-y)/mu, (mu-z)/mu
+	ea, eb := dx*dy, dz*dz
+	ec, ed := ea-eb, ea-6*eb
+	ee := ed + 2*ec
+	const c1, c2, c3, c4 = 3.0 / 14, 1.0 / 6, 9.0 / 22, 3.0 / 26
+	s := 1 + ed*(-c1+0.25*c3*ed-1.5*c4*dz*ee) + dz*(c2*ee+dz*(-c3*ec+dz*c4*ea))
+	return 3*sum + fac*s/(mu*math.Sqrt(mu))
+}
+
+func sq(x float64) float64 { return x * x }
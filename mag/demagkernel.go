@@ -9,6 +9,8 @@ import (
 	"github.com/mumax/3/util"
 	"math"
 	"os"
+	"runtime"
+	"strings"
 )
 
 // Obtains the demag kernel either from cacheDir/ or by calculating (and then storing in cacheDir for next time).
@@ -36,33 +38,24 @@ func DemagKernel(inputSize, pbc [3]int, cellsize [3]float64, accuracy float64, c
 	}()
 
 	// Try to load kernel
-	basename := fmt.Sprint(cacheDir, "/", "mumax3kernel_", inputSize, "_", pbc, "_", cellsize, "_", accuracy, "_")
-	var errLoad error
-	for i := 0; i < 3; i++ {
-		for j := i; j < 3; j++ {
-			if inputSize[Z] == 1 && ((i == X && j == Z) || (i == Y && j == Z)) {
-				continue // element not needed in 2D
-			}
-			kernel[i][j], errLoad = LoadKernel(fmt.Sprint(basename, i, j, ".ovf"))
-			if errLoad != nil {
-				break
-			}
-		}
-		if errLoad != nil {
-			break
-		}
-	}
-	// make result symmetric for tools that expect it so.
-	kernel[Y][X] = kernel[X][Y]
-	kernel[Z][X] = kernel[X][Z]
-	kernel[Z][Y] = kernel[Y][Z]
-
-	if errLoad != nil {
-		util.Log("//Did not use cached kernel:", errLoad)
-	} else {
+	basename := kernelBasename(cacheDir, inputSize, pbc, cellsize, accuracy)
+	kernel, errLoad := loadKernels(basename, inputSize)
+	if errLoad == nil {
 		util.Log("//Using cached kernel:", basename)
 		return kernel
 	}
+	util.Log("//Did not use cached kernel:", errLoad)
+
+	// Try to crop the kernel of a larger grid
+	if size, ok := findLargerKernel(cacheDir, inputSize, pbc, cellsize, accuracy); ok {
+		larger := kernelBasename(cacheDir, size, pbc, cellsize, accuracy)
+		k, err := loadKernels(larger, size)
+		if err == nil {
+			util.Log("//Using cached kernel of larger grid:", larger)
+			return cropKernel(k, inputSize, pbc)
+		}
+		util.Log("//Did not use cached kernel:", err)
+	}
 
 	// Could not load kernel: calculate it and save
 	var errSave error
@@ -93,6 +86,117 @@ func DemagKernel(inputSize, pbc [3]int, cellsize [3]float64, accuracy float64, c
 	return kernel
 }
 
+// Cache file names start with this, followed by the component index and ".ovf".
+func kernelBasename(cacheDir string, inputSize, pbc [3]int, cellsize [3]float64, accuracy float64) string {
+	return fmt.Sprint(cacheDir, "/", "mumax3kernel_", inputSize, "_", pbc, "_", cellsize, "_", accuracy, "_")
+}
+
+// Loads all kernel components needed for inputSize from the cache files starting with basename.
+func loadKernels(basename string, inputSize [3]int) (kernel [3][3]*data.Slice, err error) {
+	for i := 0; i < 3; i++ {
+		for j := i; j < 3; j++ {
+			if inputSize[Z] == 1 && ((i == X && j == Z) || (i == Y && j == Z)) {
+				continue // element not needed in 2D
+			}
+			kernel[i][j], err = LoadKernel(fmt.Sprint(basename, i, j, ".ovf"))
+			if err != nil {
+				return kernel, err
+			}
+		}
+	}
+	// make result symmetric for tools that expect it so.
+	kernel[Y][X] = kernel[X][Y]
+	kernel[Z][X] = kernel[X][Z]
+	kernel[Z][Y] = kernel[Y][Z]
+	return kernel, nil
+}
+
+// Looks in cacheDir for the smallest cached kernel that contains the kernel for inputSize:
+// same PBC, cell size and accuracy, at least as large in each direction without PBC and equally large with PBC.
+// Kernel elements only depend on the distance between cells, so such a kernel can be cropped (see cropKernel).
+func findLargerKernel(cacheDir string, inputSize, pbc [3]int, cellsize [3]float64, accuracy float64) (size [3]int, ok bool) {
+	dir, err := os.Open(cacheDir)
+	if err != nil {
+		return size, false
+	}
+	defer dir.Close()
+	names, err := dir.Readdirnames(-1)
+	if err != nil {
+		return size, false
+	}
+
+	prefix := "mumax3kernel_"
+	suffix := fmt.Sprint("_", pbc, "_", cellsize, "_", accuracy, "_", X, X, ".ovf") // file name of component xx
+	for _, name := range names {
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		str := name[len(prefix) : len(name)-len(suffix)]
+		var n [3]int
+		if _, err := fmt.Sscanf(str, "[%d %d %d]", &n[X], &n[Y], &n[Z]); err != nil || fmt.Sprint(n) != str {
+			continue
+		}
+		fits := true
+		for c := 0; c < 3; c++ {
+			if (pbc[c] == 0 && n[c] < inputSize[c]) || (pbc[c] != 0 && n[c] != inputSize[c]) {
+				fits = false
+			}
+		}
+		if fits && (!ok || prod(n) < prod(size)) {
+			size, ok = n, true
+		}
+	}
+	return size, ok
+}
+
+// Crops a kernel, calculated for a larger grid with the same PBC, to the kernel for inputSize.
+func cropKernel(large [3][3]*data.Slice, inputSize, pbc [3]int) (kernel [3][3]*data.Slice) {
+	size := padSize(inputSize, pbc)
+	lsize := large[X][X].Size()
+
+	// pairs of indices into the cropped and the large kernel, per direction
+	var idx [3][][2]int
+	for c := 0; c < 3; c++ {
+		if pbc[c] != 0 {
+			util.Argument(lsize[c] == size[c])
+			for i := 0; i < size[c]; i++ {
+				idx[c] = append(idx[c], [2]int{i, i})
+			}
+			continue
+		}
+		for d := -(inputSize[c] - 1); d <= inputSize[c]-1; d++ {
+			idx[c] = append(idx[c], [2]int{wrap(d, size[c]), wrap(d, lsize[c])})
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		for j := i; j < 3; j++ {
+			if size[Z] == 1 && ((i == X && j == Z) || (i == Y && j == Z)) {
+				continue // element not needed in 2D
+			}
+			kernel[i][j] = data.NewSlice(1, size)
+			dst := kernel[i][j].Scalars()
+			src := large[i][j].Scalars()
+			for _, z := range idx[Z] {
+				for _, y := range idx[Y] {
+					for _, x := range idx[X] {
+						dst[z[0]][y[0]][x[0]] = src[z[1]][y[1]][x[1]]
+					}
+				}
+			}
+		}
+	}
+	// make result symmetric for tools that expect it so.
+	kernel[Y][X] = kernel[X][Y]
+	kernel[Z][X] = kernel[X][Z]
+	kernel[Z][Y] = kernel[Y][Z]
+	return kernel
+}
+
+func prod(s [3]int) int {
+	return s[X] * s[Y] * s[Z]
+}
+
 func LoadKernel(fname string) (kernel *data.Slice, err error) {
 	kernel, _, err = oommf.ReadFile(fname)
 	return
@@ -111,7 +215,15 @@ func SaveKernel(fname string, kernel *data.Slice, info data.Meta) error {
 
 // Calculates the magnetostatic kernel by brute-force integration
 // of magnetic charges over the faces and averages over cell volumes.
+// Far from the source, where the error is below 10^-accuracy relative to the dipole field,
+// the integration is replaced by an asymptotic expansion (see asymptoticField).
+// The integration is parallelized over all CPUs.
 func CalcDemagKernel(inputSize, pbc [3]int, cellsize [3]float64, accuracy float64) (kernel [3][3]*data.Slice) {
+	return calcDemagKernel(inputSize, pbc, cellsize, accuracy, asymptoticDistance(cellsize, cellsize, accuracy))
+}
+
+// CalcDemagKernel, using the asymptotic expansion for cells farther than rAsymptotic from the source.
+func calcDemagKernel(inputSize, pbc [3]int, cellsize [3]float64, accuracy, rAsymptotic float64) (kernel [3][3]*data.Slice) {
 
 	// Add zero-padding in non-PBC directions
 	size := padSize(inputSize, pbc)
@@ -137,144 +249,69 @@ func CalcDemagKernel(inputSize, pbc [3]int, cellsize [3]float64, accuracy float6
 	r1, r2 := kernelRanges(size, pbc)
 
 	// smallest cell dimension is our typical length scale
-	L := cellsize[X]
-	{
-		if cellsize[Y] < L {
-			L = cellsize[Y]
-		}
-		if cellsize[Z] < L {
-			L = cellsize[Z]
+	L := math.Min(cellsize[X], math.Min(cellsize[Y], cellsize[Z]))
+
+	// Parallel integration, one row of the kernel (wrapped y, z index) at a time.
+	// Only one goroutine writes to a row, accumulating the PBC images in the same order as a serial loop.
+	// Skip one half in each direction, reconstruct from symmetry later.
+	type row struct{ yw, zw int }
+	rows := make(chan row)
+	go func() {
+		for zw := 0; zw <= size[Z]/2; zw++ {
+			for yw := 0; yw <= size[Y]/2; yw++ {
+				rows <- row{yw, zw}
+			}
 		}
-	}
-
-	progress, progmax := 0, (1+(r2[Y]-r1[Y]))*(1+(r2[Z]-r1[Z])) // progress bar
-	done := make(chan struct{}, 3)                              // parallel calculation of one component done?
-
-	// Start brute integration
-	// 9 nested loops, does that stress you out?
-	// Fortunately, the 5 inner ones usually loop over just one element.
+		close(rows)
+	}()
 
-	for s := 0; s < 3; s++ { // source index Ksdxyz (parallelized over)
-		go func(s int) {
-			u, v, w := s, (s+1)%3, (s+2)%3 // u = direction of source (s), v & w are the orthogonal directions
-			var (
-				R, R2  [3]float64 // field and source cell center positions
-				pole   [3]float64 // position of point charge on the surface
-				points int        // counts used integration points
-			)
+	progress, progmax := 0, (size[Z]/2+1)*(size[Y]/2+1) // progress bar
+	done := make(chan struct{})                         // one row done
 
-			for z := r1[Z]; z <= r2[Z]; z++ {
-				zw := wrap(z, size[Z])
-				// skip one half, reconstruct from symmetry later
+	for w := 0; w < runtime.NumCPU(); w++ {
+		go func() {
+			var R [3]float64 // field position relative to source cell
+			for r := range rows {
 				// check on wrapped index instead of loop range so it also works for PBC
-				if zw > size[Z]/2 {
-					if s == 0 {
-						progress += (1 + (r2[Y] - r1[Y]))
-					}
-					continue
-				}
-				R[Z] = float64(z) * cellsize[Z]
-
-				for y := r1[Y]; y <= r2[Y]; y++ {
-
-					if s == 0 { // show progress of only one component
-						progress++
-						util.Progress(progress, progmax, "Calculating demag kernel")
-					}
-
-					yw := wrap(y, size[Y])
-					if yw > size[Y]/2 {
+				for z := r1[Z]; z <= r2[Z]; z++ {
+					if wrap(z, size[Z]) != r.zw {
 						continue
 					}
-					R[Y] = float64(y) * cellsize[Y]
-
-					for x := r1[X]; x <= r2[X]; x++ {
-						xw := wrap(x, size[X])
-						if xw > size[X]/2 {
+					R[Z] = float64(z) * cellsize[Z]
+					for y := r1[Y]; y <= r2[Y]; y++ {
+						if wrap(y, size[Y]) != r.yw {
 							continue
 						}
-						R[X] = float64(x) * cellsize[X]
+						R[Y] = float64(y) * cellsize[Y]
+						for x := r1[X]; x <= r2[X]; x++ {
+							xw := wrap(x, size[X])
+							if xw > size[X]/2 {
+								continue
+							}
+							R[X] = float64(x) * cellsize[X]
 
-						// choose number of integration points depending on how far we are from source.
-						dx, dy, dz := delta(x)*cellsize[X], delta(y)*cellsize[Y], delta(z)*cellsize[Z]
-						d := math.Sqrt(dx*dx + dy*dy + dz*dz)
-						if d == 0 {
-							d = L
-						}
-						maxSize := d / accuracy // maximum acceptable integration size
-
-						nv := int(math.Max(cellsize[v]/maxSize, 1) + 0.5)
-						nw := int(math.Max(cellsize[w]/maxSize, 1) + 0.5)
-						nx := int(math.Max(cellsize[X]/maxSize, 1) + 0.5)
-						ny := int(math.Max(cellsize[Y]/maxSize, 1) + 0.5)
-						nz := int(math.Max(cellsize[Z]/maxSize, 1) + 0.5)
-						// Stagger source and destination grids.
-						// Massively improves accuracy, see note.
-						nv *= 2
-						nw *= 2
-
-						util.Assert(nv > 0 && nw > 0 && nx > 0 && ny > 0 && nz > 0)
-
-						scale := 1 / float64(nv*nw*nx*ny*nz)
-						surface := cellsize[v] * cellsize[w] // the two directions perpendicular to direction s
-						charge := surface * scale
-						pu1 := cellsize[u] / 2. // positive pole center
-						pu2 := -pu1             // negative pole center
-
-						// Do surface integral over source cell, accumulate  in B
-						var B [3]float64
-						for i := 0; i < nv; i++ {
-							pv := -(cellsize[v] / 2.) + cellsize[v]/float64(2*nv) + float64(i)*(cellsize[v]/float64(nv))
-							pole[v] = pv
-							for j := 0; j < nw; j++ {
-								pw := -(cellsize[w] / 2.) + cellsize[w]/float64(2*nw) + float64(j)*(cellsize[w]/float64(nw))
-								pole[w] = pw
-
-								// Do volume integral over destination cell
-								for α := 0; α < nx; α++ {
-									rx := R[X] - cellsize[X]/2 + cellsize[X]/float64(2*nx) + (cellsize[X]/float64(nx))*float64(α)
-
-									for β := 0; β < ny; β++ {
-										ry := R[Y] - cellsize[Y]/2 + cellsize[Y]/float64(2*ny) + (cellsize[Y]/float64(ny))*float64(β)
-
-										for γ := 0; γ < nz; γ++ {
-											rz := R[Z] - cellsize[Z]/2 + cellsize[Z]/float64(2*nz) + (cellsize[Z]/float64(nz))*float64(γ)
-											points++
-
-											pole[u] = pu1
-											R2[X], R2[Y], R2[Z] = rx-pole[X], ry-pole[Y], rz-pole[Z]
-											r := math.Sqrt(R2[X]*R2[X] + R2[Y]*R2[Y] + R2[Z]*R2[Z])
-											qr := charge / (4 * math.Pi * r * r * r)
-											bx := R2[X] * qr
-											by := R2[Y] * qr
-											bz := R2[Z] * qr
-
-											pole[u] = pu2
-											R2[X], R2[Y], R2[Z] = rx-pole[X], ry-pole[Y], rz-pole[Z]
-											r = math.Sqrt(R2[X]*R2[X] + R2[Y]*R2[Y] + R2[Z]*R2[Z])
-											qr = -charge / (4 * math.Pi * r * r * r)
-											B[X] += (bx + R2[X]*qr) // addition ordered for accuracy
-											B[Y] += (by + R2[Y]*qr)
-											B[Z] += (bz + R2[Z]*qr)
-
-										}
-									}
+							// closest distance between the cells
+							dx, dy, dz := delta(x)*cellsize[X], delta(y)*cellsize[Y], delta(z)*cellsize[Z]
+							d := math.Sqrt(dx*dx + dy*dy + dz*dz)
+
+							for s := 0; s < 3; s++ { // source index Ksdxyz
+								B := cellField(s, R, d, cellsize, cellsize, accuracy, L, rAsymptotic)
+								for d := s; d < 3; d++ { // destination index Ksdxyz
+									array[s][d][r.zw][r.yw][xw] += float32(B[d]) // += needed in case of PBC
 								}
 							}
 						}
-						for d := s; d < 3; d++ { // destination index Ksdxyz
-							array[s][d][zw][yw][xw] += float32(B[d]) // += needed in case of PBC
-						}
 					}
 				}
+				done <- struct{}{}
 			}
-			done <- struct{}{} // notify parallel computation of this component is done
-		}(s)
+		}()
+	}
+	for progress < progmax {
+		<-done
+		progress++
+		util.Progress(progress, progmax, "Calculating demag kernel")
 	}
-	// wait for all 3 components to finish
-	<-done
-	<-done
-	<-done
 
 	// Reconstruct skipped parts from symmetry (X)
 	for z := 0; z < size[Z]; z++ {
@@ -334,6 +371,133 @@ func CalcDemagKernel(inputSize, pbc [3]int, cellsize [3]float64, accuracy float6
 	return kernel
 }
 
+// Field averaged over a destination cell of size dst centered at R,
+// due to a source cell of size src centered at the origin with unit magnetization along u.
+// d is the closest distance between the cells.
+// Uses the asymptotic expansion beyond distance rAsymptotic, integration otherwise.
+func cellField(u int, R [3]float64, d float64, src, dst [3]float64, accuracy, L, rAsymptotic float64) [3]float64 {
+	if R[X]*R[X]+R[Y]*R[Y]+R[Z]*R[Z] > rAsymptotic*rAsymptotic {
+		return asymptoticField(u, R, src, dst)
+	}
+	return cellPairField(u, R, d, src, dst, accuracy, L)
+}
+
+// Field averaged over a destination cell of size dst centered at R,
+// due to a source cell of size src centered at the origin with unit magnetization along u,
+// by brute-force integration of the magnetic charges over the source faces.
+// d is the closest distance between the cells, L the typical length scale for touching cells.
+func cellPairField(u int, R [3]float64, d float64, src, dst [3]float64, accuracy, L float64) (B [3]float64) {
+	v, w := (u+1)%3, (u+2)%3 // v & w are the directions orthogonal to the source
+
+	// choose number of integration points depending on how far we are from source.
+	if d == 0 {
+		d = L
+	}
+	maxSize := d / accuracy // maximum acceptable integration size
+
+	nv := int(math.Max(src[v]/maxSize, 1) + 0.5)
+	nw := int(math.Max(src[w]/maxSize, 1) + 0.5)
+	var n [3]int
+	for c := range n {
+		n[c] = int(math.Max(dst[c]/maxSize, 1) + 0.5)
+	}
+	// Stagger source and destination grids.
+	// Massively improves accuracy, see note.
+	nv *= 2
+	nw *= 2
+
+	scale := 1 / float64(nv*nw*n[X]*n[Y]*n[Z])
+	surface := src[v] * src[w] // the two directions perpendicular to direction u
+	charge := surface * scale
+
+	// Do surface integral over source cell, accumulate in B
+	var pole, R2 [3]float64
+	for i := 0; i < nv; i++ {
+		pole[v] = -(src[v] / 2.) + src[v]/float64(2*nv) + float64(i)*(src[v]/float64(nv))
+		for j := 0; j < nw; j++ {
+			pole[w] = -(src[w] / 2.) + src[w]/float64(2*nw) + float64(j)*(src[w]/float64(nw))
+
+			// Do volume integral over destination cell
+			for α := 0; α < n[X]; α++ {
+				rx := R[X] - dst[X]/2 + dst[X]/float64(2*n[X]) + (dst[X]/float64(n[X]))*float64(α)
+				for β := 0; β < n[Y]; β++ {
+					ry := R[Y] - dst[Y]/2 + dst[Y]/float64(2*n[Y]) + (dst[Y]/float64(n[Y]))*float64(β)
+					for γ := 0; γ < n[Z]; γ++ {
+						rz := R[Z] - dst[Z]/2 + dst[Z]/float64(2*n[Z]) + (dst[Z]/float64(n[Z]))*float64(γ)
+
+						pole[u] = src[u] / 2 // positive pole
+						R2[X], R2[Y], R2[Z] = rx-pole[X], ry-pole[Y], rz-pole[Z]
+						r := math.Sqrt(R2[X]*R2[X] + R2[Y]*R2[Y] + R2[Z]*R2[Z])
+						qr := charge / (4 * math.Pi * r * r * r)
+						bx, by, bz := R2[X]*qr, R2[Y]*qr, R2[Z]*qr
+
+						pole[u] = -src[u] / 2 // negative pole
+						R2[X], R2[Y], R2[Z] = rx-pole[X], ry-pole[Y], rz-pole[Z]
+						r = math.Sqrt(R2[X]*R2[X] + R2[Y]*R2[Y] + R2[Z]*R2[Z])
+						qr = -charge / (4 * math.Pi * r * r * r)
+						B[X] += (bx + R2[X]*qr) // addition ordered for accuracy
+						B[Y] += (by + R2[Y]*qr)
+						B[Z] += (bz + R2[Z]*qr)
+					}
+				}
+			}
+		}
+	}
+	return B
+}
+
+// Asymptotic expansion of cellPairField far from the source:
+// the point dipole field, corrected for the source and destination cell sizes up to second order.
+// Averaging the dipole field over the displacement t - s between destination and source points
+// adds 1/2 <(t_c-s_c)²> ∂_c² = (src_c² + dst_c²)/24 ∂_c² for each direction c (odd orders vanish).
+// The remaining error is below asymptoticError (largest cell size / distance)^4 relative to the dipole field.
+func asymptoticField(u int, R, src, dst [3]float64) (B [3]float64) {
+	r2 := R[X]*R[X] + R[Y]*R[Y] + R[Z]*R[Z]
+	r := math.Sqrt(r2)
+	r3 := r * r2
+	r5 := r3 * r2
+	r7 := r5 * r2
+	r9 := r7 * r2
+	V := src[X] * src[Y] * src[Z] // source moment for unit magnetization
+
+	for d := 0; d < 3; d++ {
+		δud := kronecker(u, d)
+		// dipole field: ∂u∂d 1/r
+		b := 3*R[u]*R[d]/r5 - δud/r3
+		// second order: ∂u∂d∂c∂c 1/r
+		for c := 0; c < 3; c++ {
+			δuc, δdc := kronecker(u, c), kronecker(d, c)
+			d4 := 105*R[u]*R[d]*R[c]*R[c]/r9 -
+				15*(δud*R[c]*R[c]+2*δuc*R[d]*R[c]+2*δdc*R[u]*R[c]+R[u]*R[d])/r7 +
+				3*(δud+2*δuc*δdc)/r5
+			b += (sq(src[c]) + sq(dst[c])) / 24 * d4
+		}
+		B[d] = V / (4 * math.Pi) * b
+	}
+	return B
+}
+
+// Prefactor of the error of asymptoticField: the error relative to the dipole field is below
+// asymptoticError (largest cell size / distance)^4. Measured against accurate integration,
+// it is up to 0.8 for cubes and approaches 2.2 for flat or elongated cells.
+const asymptoticError = 3
+
+// Distance from the source beyond which asymptoticField has a relative error below 10^-accuracy.
+func asymptoticDistance(src, dst [3]float64, accuracy float64) float64 {
+	a := 0.
+	for c := 0; c < 3; c++ {
+		a = math.Max(a, math.Max(src[c], dst[c]))
+	}
+	return a * math.Pow(asymptoticError*math.Pow(10, accuracy), 1./4.)
+}
+
+func kronecker(i, j int) float64 {
+	if i == j {
+		return 1
+	}
+	return 0
+}
+
 // integration ranges for kernel. size=kernelsize, so padded for no PBC, not padded for PBC
 func kernelRanges(size, pbc [3]int) (r1, r2 [3]int) {
 	for c := 0; c < 3; c++ {
@@ -0,0 +1,152 @@
+package mag
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/mumax/3/data"
+)
+
+// Far from the source, the asymptotic expansion should agree with accurate integration
+// to within the documented error asymptoticError (cell size / distance)^4, relative to the dipole field,
+// also between cells of different size (layers).
+func TestAsymptoticField(t *testing.T) {
+	for _, c := range [][2][3]float64{{{1, 1, 1}, {1, 1, 1}}, {{4, 4, 1}, {4, 4, 1}}, {{1, 2, 3}, {1, 2, 3}}, {{2, 2, 1}, {2, 2, 0.3}}} {
+		src, dst := c[0], c[1]
+		a := 0.
+		for i := 0; i < 3; i++ {
+			a = math.Max(a, math.Max(src[i], dst[i]))
+		}
+		for _, n := range []float64{10, 20} {
+			R := [3]float64{n * src[X], math.Round(0.6*n) * src[Y], math.Round(0.3*n) * src[Z]}
+			r := math.Sqrt(R[X]*R[X] + R[Y]*R[Y] + R[Z]*R[Z])
+			dipole := src[X] * src[Y] * src[Z] / (4 * math.Pi * r * r * r)
+			tol := asymptoticError * math.Pow(a/r, 4) * dipole
+			for s := 0; s < 3; s++ {
+				have := asymptoticField(s, R, src, dst)
+				want := cellPairField(s, R, r, src, dst, 20*r/a, a)
+				for d := 0; d < 3; d++ {
+					if math.Abs(have[d]-want[d]) > tol {
+						t.Errorf("cells %v, R %v, K%v%v: have %v, want %v", c, R, s, d, have[d], want[d])
+					}
+				}
+			}
+		}
+	}
+}
+
+// Where the kernel uses the asymptotic expansion, it should agree with accurate integration
+// to within 10^-accuracy relative to the dipole field.
+func TestDemagKernelAsymptotic(t *testing.T) {
+	size, pbc := [3]int{48, 40, 1}, [3]int{0, 0, 0}
+	cell := [3]float64{2e-9, 2e-9, 1e-9}
+	const accuracy = 6
+	have := CalcDemagKernel(size, pbc, cell, accuracy)
+	rAsymptotic := asymptoticDistance(cell, cell, accuracy)
+
+	psize := have[X][X].Size()
+	a := math.Max(cell[X], math.Max(cell[Y], cell[Z]))
+	used := 0
+	for iy := 0; iy < psize[Y]; iy++ {
+		for ix := 0; ix < psize[X]; ix++ {
+			x, y := unwrap(ix, psize[X]), unwrap(iy, psize[Y])
+			R := [3]float64{float64(x) * cell[X], float64(y) * cell[Y], 0}
+			r := math.Sqrt(R[X]*R[X] + R[Y]*R[Y])
+			if r <= rAsymptotic || x <= -size[X] || x >= size[X] || y <= -size[Y] || y >= size[Y] {
+				continue
+			}
+			used++
+			if used%50 != 1 { // accurate integration is slow, check a sample
+				continue
+			}
+			dipole := cell[X] * cell[Y] * cell[Z] / (4 * math.Pi * r * r * r)
+			for i := 0; i < 3; i++ {
+				want := cellPairField(i, R, r, cell, cell, 20*r/a, a)
+				for j := i; j < 3; j++ {
+					if have[i][j] == nil {
+						continue
+					}
+					h := float64(have[i][j].Scalars()[0][iy][ix])
+					if math.Abs(h-want[j]) > math.Pow(10, -accuracy)*dipole {
+						t.Errorf("K%v%v[%v][%v]: have %v, want %v", i, j, iy, ix, h, want[j])
+					}
+				}
+			}
+		}
+	}
+	if used == 0 {
+		t.Error("asymptotic expansion not used")
+	}
+}
+
+// The kernel of a small grid is contained in the kernel of a larger grid with the same PBC.
+func TestCropKernel(t *testing.T) {
+	cell := [3]float64{1e-9, 2e-9, 1.5e-9}
+	for _, c := range []struct{ small, large, pbc [3]int }{
+		{[3]int{8, 6, 1}, [3]int{12, 10, 3}, [3]int{0, 0, 0}},
+		{[3]int{6, 5, 2}, [3]int{6, 9, 7}, [3]int{1, 0, 0}},
+	} {
+		want := CalcDemagKernel(c.small, c.pbc, cell, 6)
+		have := cropKernel(CalcDemagKernel(c.large, c.pbc, cell, 6), c.small, c.pbc)
+		compareKernels(t, have, want)
+	}
+}
+
+// A cached kernel of a larger grid should be used for a smaller one.
+func TestDemagKernelCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mumax3kernel")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cell := [3]float64{1e-9, 1e-9, 2e-9}
+	pbc := [3]int{0, 0, 0}
+	small, large := [3]int{7, 5, 1}, [3]int{10, 8, 3}
+	DemagKernel(large, pbc, cell, 6, dir)
+
+	size, ok := findLargerKernel(dir, small, pbc, cell, 6)
+	if !ok || size != large {
+		t.Fatalf("findLargerKernel: have %v, %v, want %v", size, ok, large)
+	}
+	if _, ok := findLargerKernel(dir, [3]int{7, 9, 1}, pbc, cell, 6); ok {
+		t.Error("findLargerKernel: found kernel that is too small")
+	}
+	if _, ok := findLargerKernel(dir, small, [3]int{1, 0, 0}, cell, 6); ok {
+		t.Error("findLargerKernel: found kernel with other PBC")
+	}
+
+	compareKernels(t, DemagKernel(small, pbc, cell, 6, dir), CalcDemagKernel(small, pbc, cell, 6))
+}
+
+func compareKernels(t *testing.T, have, want [3][3]*data.Slice) {
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			if (have[i][j] == nil) != (want[i][j] == nil) {
+				t.Fatalf("K%v%v: have %v, want %v", i, j, have[i][j], want[i][j])
+			}
+			if want[i][j] == nil {
+				continue
+			}
+			if have[i][j].Size() != want[i][j].Size() {
+				t.Fatalf("K%v%v: have size %v, want %v", i, j, have[i][j].Size(), want[i][j].Size())
+			}
+			h, w := have[i][j].Host()[0], want[i][j].Host()[0]
+			for n := range w {
+				if h[n] != w[n] {
+					t.Fatalf("K%v%v[%v]: have %v, want %v", i, j, n, h[n], w[n])
+				}
+			}
+		}
+	}
+}
+
+// inverse of wrap: index in [0, n) to offset in [-n/2, n/2]
+func unwrap(i, n int) int {
+	if i > n/2 {
+		return i - n
+	}
+	return i
+}
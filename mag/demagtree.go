@@ -0,0 +1,349 @@
+package mag
+
+import (
+	"math"
+	"sort"
+
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/util"
+)
+
+// Octree of the occupied cells of a mesh, for the hierarchical demag evaluation (treecode)
+// of sparse geometries, where the FFT convolution pays for all the empty space.
+//
+// The occupied cells are grouped in leaves of TREE_LEAF^3 cells. The leaves are sorted in Morton order,
+// so that every node of the octree holds a contiguous range of leaves and cells.
+// The field in each target cell is the sum over
+//
+//	near leaves: exact demag kernel between the cells (the kernel of a small grid, NearSize)
+//	far nodes: multipole expansion of the node's moments (total moment and first moments) about its center,
+//	           for nodes with radius < theta * distance to the target leaf.
+//
+// The relative error is of the order of theta², at a cost of O(N log N) for N occupied cells.
+type DemagTree struct {
+	Size               [3]int       // mesh size
+	CellSize           [3]float64   // mesh cell size
+	Theta              float64      // multipole acceptance criterion
+	Unit               float64      // length unit of Center and Pos (smallest cell size), avoids float32 underflow
+	Cells              []int32      // linear index of the occupied cells, grouped per leaf
+	CellLeaf           []int32      // leaf of each occupied cell
+	NodeStart, NodeEnd []int32      // leaves: range of cells, other nodes: range of child nodes
+	Center             [3][]float32 // center of each node, in units of Unit
+	Levels             []int        // nodes of level l are Levels[l] to Levels[l+1], leaves first, root last
+	NearStart, Near    []int32      // per leaf: leaves Near[NearStart[i]:NearStart[i+1]], evaluated with the kernel
+	FarStart, Far      []int32      // per leaf: nodes Far[FarStart[i]:FarStart[i+1]], evaluated by multipole expansion
+	NearSize           [3]int       // input size of the demag kernel needed for the near leaves
+}
+
+// Leaves span TREE_LEAF cells in each direction.
+const TREE_LEAF = 4
+
+// Builds the octree of the cells with non-zero volume fraction vol (all cells if vol is nil),
+// with multipole acceptance criterion theta: a node is far if its radius < theta * its distance.
+func NewDemagTree(size [3]int, cellsize [3]float64, vol *data.Slice, theta float64) *DemagTree {
+	util.Argument(theta > 0 && theta < 1)
+	t := &DemagTree{Size: size, CellSize: cellsize, Theta: theta}
+	t.Unit = math.Min(cellsize[X], math.Min(cellsize[Y], cellsize[Z]))
+
+	// group the occupied cells per leaf
+	var v [][][]float32
+	if vol != nil && !vol.IsNil() {
+		util.Argument(vol.Size() == size)
+		v = vol.Scalars()
+	}
+	leafCells := make(map[uint64][]int32)
+	for iz := 0; iz < size[Z]; iz++ {
+		for iy := 0; iy < size[Y]; iy++ {
+			for ix := 0; ix < size[X]; ix++ {
+				if v != nil && v[iz][iy][ix] == 0 {
+					continue
+				}
+				code := morton(ix/TREE_LEAF, iy/TREE_LEAF, iz/TREE_LEAF)
+				leafCells[code] = append(leafCells[code], int32(data.Index(size, ix, iy, iz)))
+			}
+		}
+	}
+	if len(leafCells) == 0 {
+		util.Fatal("demag tree: geometry is empty")
+	}
+	codes := make([]uint64, 0, len(leafCells))
+	for c := range leafCells {
+		codes = append(codes, c)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+
+	// leaves
+	var cellStart, cellEnd []int // range of cells of every node
+	for i, c := range codes {
+		t.NodeStart = append(t.NodeStart, int32(len(t.Cells)))
+		for _, cell := range leafCells[c] {
+			t.Cells = append(t.Cells, cell)
+			t.CellLeaf = append(t.CellLeaf, int32(i))
+		}
+		t.NodeEnd = append(t.NodeEnd, int32(len(t.Cells)))
+		cellStart = append(cellStart, int(t.NodeStart[i]))
+		cellEnd = append(cellEnd, len(t.Cells))
+	}
+	t.Levels = []int{0, len(codes)}
+
+	// parents, level by level: consecutive nodes with the same parent code are siblings.
+	for len(codes) > 1 {
+		first := t.Levels[len(t.Levels)-2]
+		var parents []uint64
+		for i := 0; i < len(codes); {
+			j := i
+			for j < len(codes) && codes[j]>>3 == codes[i]>>3 {
+				j++
+			}
+			parents = append(parents, codes[i]>>3)
+			t.NodeStart = append(t.NodeStart, int32(first+i))
+			t.NodeEnd = append(t.NodeEnd, int32(first+j))
+			cellStart = append(cellStart, cellStart[first+i])
+			cellEnd = append(cellEnd, cellEnd[first+j-1])
+			i = j
+		}
+		codes = parents
+		t.Levels = append(t.Levels, len(t.NodeStart))
+	}
+
+	// node centers (middle of the bounding box of the occupied cells) and radii, including the cell extent.
+	nNodes := len(t.NodeStart)
+	radius := make([]float64, nNodes)
+	lo := make([][3]int, nNodes)
+	hi := make([][3]int, nNodes)
+	halfDiag := 0.5 * math.Sqrt(sq(cellsize[X])+sq(cellsize[Y])+sq(cellsize[Z])) / t.Unit
+	for c := range t.Center {
+		t.Center[c] = make([]float32, nNodes)
+	}
+	for n := 0; n < nNodes; n++ {
+		lo[n] = t.index(t.Cells[cellStart[n]])
+		hi[n] = lo[n]
+		for _, cell := range t.Cells[cellStart[n]:cellEnd[n]] {
+			idx := t.index(cell)
+			for c := 0; c < 3; c++ {
+				lo[n][c] = imin(lo[n][c], idx[c])
+				hi[n][c] = imax(hi[n][c], idx[c])
+			}
+		}
+		var center [3]float64
+		for c := 0; c < 3; c++ {
+			center[c] = 0.5 * float64(lo[n][c]+hi[n][c]) * cellsize[c] / t.Unit
+			t.Center[c][n] = float32(center[c])
+		}
+		for _, cell := range t.Cells[cellStart[n]:cellEnd[n]] {
+			p := t.pos(cell)
+			radius[n] = math.Max(radius[n], math.Sqrt(sq(p[X]-center[X])+sq(p[Y]-center[Y])+sq(p[Z]-center[Z])))
+		}
+		radius[n] += halfDiag
+	}
+
+	// interaction lists of each leaf, by traversal from the root
+	nLeaves := t.Levels[1]
+	root := int32(nNodes - 1)
+	var maxOffset [3]int
+	for l := 0; l < nLeaves; l++ {
+		t.NearStart = append(t.NearStart, int32(len(t.Near)))
+		t.FarStart = append(t.FarStart, int32(len(t.Far)))
+		stack := []int32{root}
+		for len(stack) > 0 {
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			d := 0.
+			for c := 0; c < 3; c++ {
+				d += sq(float64(t.Center[c][n] - t.Center[c][l]))
+			}
+			d = math.Sqrt(d) - radius[l] // closest distance from the target leaf to the node center
+			switch {
+			case d > 0 && radius[n] < theta*d:
+				t.Far = append(t.Far, n)
+			case int(n) < nLeaves:
+				t.Near = append(t.Near, n)
+				for c := 0; c < 3; c++ {
+					maxOffset[c] = imax(maxOffset[c], imax(hi[l][c]-lo[n][c], hi[n][c]-lo[l][c]))
+				}
+			default:
+				for child := t.NodeStart[n]; child < t.NodeEnd[n]; child++ {
+					stack = append(stack, child)
+				}
+			}
+		}
+	}
+	t.NearStart = append(t.NearStart, int32(len(t.Near)))
+	t.FarStart = append(t.FarStart, int32(len(t.Far)))
+	for c := 0; c < 3; c++ {
+		t.NearSize[c] = imin(maxOffset[c]+1, size[c])
+	}
+	return t
+}
+
+// Reference implementation on the host of the field (T) of M (A/m, already scaled by the volume fraction),
+// with kernel the demag kernel for input size NearSize.
+// Mirrors the GPU implementation, see cuda.DemagTree.
+func (t *DemagTree) Field(M [3][]float32, kernel [3][3]*data.Slice) (B [3][]float32) {
+	nNodes := len(t.NodeStart)
+	nLeaves := t.Levels[1]
+	V := float32(t.CellSize[X] * t.CellSize[Y] * t.CellSize[Z] / (t.Unit * t.Unit * t.Unit)) // cell volume in units of Unit
+
+	// moments of the leaves
+	mom := make([][12]float32, nNodes) // total moment p and first moments Q[b][c] = Σ m_b d_c
+	for n := 0; n < nLeaves; n++ {
+		for _, cell := range t.Cells[t.NodeStart[n]:t.NodeEnd[n]] {
+			r := t.pos(cell)
+			var m, d [3]float32
+			for c := 0; c < 3; c++ {
+				m[c] = V * M[c][cell]
+				d[c] = float32(r[c]) - t.Center[c][n]
+			}
+			addMoments(&mom[n], m, d)
+		}
+	}
+
+	// moments of the other nodes from their children, shifted to the parent center
+	for l := 1; l < len(t.Levels)-1; l++ {
+		for n := t.Levels[l]; n < t.Levels[l+1]; n++ {
+			for ch := t.NodeStart[n]; ch < t.NodeEnd[n]; ch++ {
+				var p, d [3]float32
+				for c := 0; c < 3; c++ {
+					p[c] = mom[ch][c]
+					d[c] = t.Center[c][ch] - t.Center[c][n]
+				}
+				for i := range mom[n] {
+					mom[n][i] += mom[ch][i]
+				}
+				addMoments(&mom[n], p, d)
+			}
+		}
+	}
+
+	// field in every occupied cell
+	for c := range B {
+		B[c] = make([]float32, prod(t.Size))
+	}
+	var K [3][3][]float32
+	for i := range K {
+		for j := range K[i] {
+			switch {
+			case kernel[i][j] != nil:
+				K[i][j] = kernel[i][j].Host()[0]
+			case kernel[j][i] != nil:
+				K[i][j] = kernel[j][i].Host()[0] // symmetric
+			}
+		}
+	}
+	ksize := kernel[X][X].Size()
+	for i, cell := range t.Cells {
+		leaf := t.CellLeaf[i]
+		dst := t.index(cell)
+		var H [3]float32
+
+		for _, n := range t.Near[t.NearStart[leaf]:t.NearStart[leaf+1]] {
+			for _, s := range t.Cells[t.NodeStart[n]:t.NodeEnd[n]] {
+				src := t.index(s)
+				k := data.Index(ksize, wrap(dst[X]-src[X], ksize[X]), wrap(dst[Y]-src[Y], ksize[Y]), wrap(dst[Z]-src[Z], ksize[Z]))
+				for a := 0; a < 3; a++ {
+					for b := 0; b < 3; b++ {
+						if K[a][b] != nil {
+							H[a] += K[a][b][k] * M[b][s]
+						}
+					}
+				}
+			}
+		}
+
+		r := t.pos(cell)
+		for _, n := range t.Far[t.FarStart[leaf]:t.FarStart[leaf+1]] {
+			var R [3]float32
+			for c := 0; c < 3; c++ {
+				R[c] = float32(r[c]) - t.Center[c][n]
+			}
+			h := multipoleField(R, &mom[n])
+			for c := 0; c < 3; c++ {
+				H[c] += h[c]
+			}
+		}
+
+		for c := 0; c < 3; c++ {
+			B[c][cell] = float32(Mu0) * H[c]
+		}
+	}
+	return B
+}
+
+// adds moment m at position d to the moments mom: p += m, Q[b][c] += m_b d_c.
+func addMoments(mom *[12]float32, m, d [3]float32) {
+	for b := 0; b < 3; b++ {
+		mom[b] += m[b]
+		for c := 0; c < 3; c++ {
+			mom[3+3*b+c] += m[b] * d[c]
+		}
+	}
+}
+
+// Field H at R from a node with moments mom about its center:
+//
+//	H_a = 1/4π (T_ab p_b - ∂_c T_ab Q_bc),  T_ab = ∂_a ∂_b 1/r
+func multipoleField(R [3]float32, mom *[12]float32) (H [3]float32) {
+	var p, QR, QtR [3]float32
+	trQ, RQR := float32(0), float32(0)
+	for b := 0; b < 3; b++ {
+		p[b] = mom[b]
+		trQ += mom[3+3*b+b]
+		for c := 0; c < 3; c++ {
+			Q := mom[3+3*b+c]
+			QR[b] += Q * R[c]
+			QtR[c] += Q * R[b]
+			RQR += R[b] * Q * R[c]
+		}
+	}
+	r2 := R[X]*R[X] + R[Y]*R[Y] + R[Z]*R[Z]
+	r := float32(math.Sqrt(float64(r2)))
+	r3 := r * r2
+	r5 := r3 * r2
+	r7 := r5 * r2
+	Rp := R[X]*p[X] + R[Y]*p[Y] + R[Z]*p[Z]
+	for a := 0; a < 3; a++ {
+		dipole := 3*R[a]*Rp/r5 - p[a]/r3
+		first := -15*R[a]*RQR/r7 + 3*(QR[a]+QtR[a]+R[a]*trQ)/r5
+		H[a] = (dipole - first) / (4 * math.Pi)
+	}
+	return H
+}
+
+// cell index of linear index i
+func (t *DemagTree) index(i int32) [3]int {
+	Nx, Ny := t.Size[X], t.Size[Y]
+	return [3]int{int(i) % Nx, (int(i) / Nx) % Ny, int(i) / (Nx * Ny)}
+}
+
+// position of cell with linear index i, in units of t.Unit
+func (t *DemagTree) pos(i int32) [3]float64 {
+	idx := t.index(i)
+	var r [3]float64
+	for c := 0; c < 3; c++ {
+		r[c] = float64(idx[c]) * t.CellSize[c] / t.Unit
+	}
+	return r
+}
+
+// interleaves the bits of x, y, z (up to 21 bits each)
+func morton(x, y, z int) uint64 {
+	var code uint64
+	for b := uint(0); b < 21; b++ {
+		code |= uint64(x>>b&1)<<(3*b) | uint64(y>>b&1)<<(3*b+1) | uint64(z>>b&1)<<(3*b+2)
+	}
+	return code
+}
+
+func imin(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func imax(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,102 @@
+package mag
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mumax/3/data"
+)
+
+// The treecode field should agree with brute-force convolution with the full kernel
+// to within the expected relative error of about theta²/10.
+func TestDemagTree(t *testing.T) {
+	cell := [3]float64{2e-9, 3e-9, 1e-9}
+	for _, size := range [][3]int{{48, 40, 1}, {16, 16, 8}} {
+		vol, M := sparseTestInput(size)
+		want := bruteDemag(size, cell, vol, M)
+		for _, theta := range []float64{0.3, 0.5} {
+			tree := NewDemagTree(size, cell, vol, theta)
+			have := tree.Field(M, CalcDemagKernel(tree.NearSize, [3]int{0, 0, 0}, cell, 6))
+			max, err := 0., 0.
+			for c := range want {
+				for i := range want[c] {
+					max = math.Max(max, math.Abs(float64(want[c][i])))
+					err = math.Max(err, math.Abs(float64(have[c][i]-want[c][i])))
+				}
+			}
+			if err > 0.2*theta*theta*max {
+				t.Errorf("size %v, theta %v: relative error %v", size, theta, err/max)
+			}
+			if len(tree.Far) == 0 {
+				t.Errorf("size %v, theta %v: no far interactions", size, theta)
+			}
+		}
+	}
+}
+
+// stripes of cells with varying magnetization (A/m)
+func sparseTestInput(size [3]int) (*data.Slice, [3][]float32) {
+	vol := data.NewSlice(1, size)
+	v := vol.Scalars()
+	var M [3][]float32
+	for c := range M {
+		M[c] = make([]float32, prod(size))
+	}
+	for iz := range v {
+		for iy := range v[iz] {
+			for ix := range v[iz][iy] {
+				if (ix/5+iy/7)%3 != 0 && ix >= 3 {
+					continue
+				}
+				v[iz][iy][ix] = 1
+				i := data.Index(size, ix, iy, iz)
+				M[X][i] = float32(8e5 * math.Cos(0.3*float64(ix+iz)))
+				M[Y][i] = float32(8e5 * math.Sin(0.3*float64(ix+iz)))
+				M[Z][i] = float32(2e5 * math.Cos(0.2*float64(iy)))
+			}
+		}
+	}
+	return vol, M
+}
+
+// field (T) of M by direct summation over all pairs of occupied cells
+func bruteDemag(size [3]int, cell [3]float64, vol *data.Slice, M [3][]float32) (B [3][]float32) {
+	kernel := CalcDemagKernel(size, [3]int{0, 0, 0}, cell, 6)
+	ksize := kernel[X][X].Size()
+	var K [3][3][]float32
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			if kernel[i][j] != nil {
+				K[i][j] = kernel[i][j].Host()[0]
+			} else if kernel[j][i] != nil {
+				K[i][j] = kernel[j][i].Host()[0]
+			}
+		}
+	}
+	t := &DemagTree{Size: size}
+	v := vol.Host()[0]
+	for c := range B {
+		B[c] = make([]float32, prod(size))
+	}
+	for d := range v {
+		if v[d] == 0 {
+			continue
+		}
+		dst := t.index(int32(d))
+		for s := range v {
+			if v[s] == 0 {
+				continue
+			}
+			src := t.index(int32(s))
+			k := data.Index(ksize, wrap(dst[X]-src[X], ksize[X]), wrap(dst[Y]-src[Y], ksize[Y]), wrap(dst[Z]-src[Z], ksize[Z]))
+			for a := 0; a < 3; a++ {
+				for b := 0; b < 3; b++ {
+					if K[a][b] != nil {
+						B[a][d] += float32(Mu0) * K[a][b][k] * M[b][s]
+					}
+				}
+			}
+		}
+	}
+	return B
+}
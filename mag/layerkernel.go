@@ -0,0 +1,185 @@
+package mag
+
+import (
+	"math"
+	"runtime"
+
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/util"
+)
+
+// Calculates the magnetostatic kernel for a stack of layers with non-equal thickness dz (bottom to top),
+// all with in-plane cell size cellsize[X], cellsize[Y] and Nx x Ny cells.
+// The layers break the translation invariance along z, so instead of one 3D kernel
+// there is a 2D kernel for each pair of layers: kernel[i][j] yields the field in layer i due to layer j.
+// Like CalcDemagKernel, the magnetic charges are integrated over the source cell faces
+// and the field is averaged over the destination cell volume.
+//
+// The source is the moment per reference cell volume: M_j * dz_j / cellsize[Z].
+// Then reciprocity makes kernel[j][i] equal to kernel[i][j] with the xz and yz elements reversed,
+// so only j >= i are integrated.
+func CalcLayeredDemagKernel(inputSize, pbc [3]int, cellsize [3]float64, dz []float64, accuracy float64) (kernel [][][3][3]*data.Slice) {
+	Nz := inputSize[Z]
+	util.Argument(len(dz) == Nz)
+	util.Argument(pbc[Z] == 0) // layers can not be periodic
+	util.Assert(inputSize[X] > 0 && inputSize[Y] > 0)
+	util.Assert(cellsize[X] > 0 && cellsize[Y] > 0 && cellsize[Z] > 0)
+	util.Assert(accuracy > 0)
+
+	// 2D kernels, zero-padded in-plane
+	size := padSize([3]int{inputSize[X], inputSize[Y], 1}, pbc)
+	r1, r2 := kernelRanges(size, pbc)
+
+	// layer centers
+	zc := make([]float64, Nz)
+	L := math.Min(cellsize[X], cellsize[Y]) // smallest cell dimension is our typical length scale
+	z := 0.
+	for i := range dz {
+		util.Assert(dz[i] > 0)
+		zc[i] = z + dz[i]/2
+		z += dz[i]
+		L = math.Min(L, dz[i])
+	}
+
+	kernel = make([][][3][3]*data.Slice, Nz)
+	for i := range kernel {
+		kernel[i] = make([][3][3]*data.Slice, Nz)
+	}
+
+	type pair struct{ i, j int }
+	todo := make(chan pair, Nz*Nz)
+	for i := 0; i < Nz; i++ {
+		for j := i; j < Nz; j++ {
+			todo <- pair{i, j}
+		}
+	}
+	close(todo)
+
+	progress, progmax := 0, Nz*(Nz+1)/2
+	done := make(chan struct{})
+	for w := 0; w < runtime.NumCPU(); w++ {
+		go func() {
+			for p := range todo {
+				src := [3]float64{cellsize[X], cellsize[Y], dz[p.j]}
+				dst := [3]float64{cellsize[X], cellsize[Y], dz[p.i]}
+				rAsymptotic := asymptoticDistance(src, dst, accuracy)
+				kernel[p.i][p.j] = calcLayerPairKernel(size, r1, r2, src, dst, zc[p.i]-zc[p.j], accuracy, L, rAsymptotic)
+				done <- struct{}{}
+			}
+		}()
+	}
+	for progress < progmax {
+		<-done
+		progress++
+		util.Progress(progress, progmax, "Calculating layered demag kernel")
+	}
+
+	// source moment per reference cell volume, and reciprocity
+	for i := 0; i < Nz; i++ {
+		for j := i; j < Nz; j++ {
+			k := &kernel[i][j]
+			for s := 0; s < 3; s++ {
+				for d := s; d < 3; d++ {
+					scaleKernel(k[s][d], cellsize[Z]/dz[j])
+				}
+			}
+			if j == i {
+				continue
+			}
+			t := &kernel[j][i]
+			for s := 0; s < 3; s++ {
+				for d := s; d < 3; d++ {
+					t[s][d] = k[s][d].HostCopy()
+				}
+			}
+			scaleKernel(t[X][Z], -1)
+			scaleKernel(t[Y][Z], -1)
+		}
+	}
+
+	// make result symmetric for tools that expect it so.
+	for i := range kernel {
+		for j := range kernel[i] {
+			k := &kernel[i][j]
+			k[Y][X] = k[X][Y]
+			k[Z][X] = k[X][Z]
+			k[Z][Y] = k[Y][Z]
+		}
+	}
+	return kernel
+}
+
+// 2D kernel (upper triangular part) of a destination cell of size dst, a distance Δz above a source cell of size src.
+func calcLayerPairKernel(size, r1, r2 [3]int, src, dst [3]float64, Δz, accuracy, L, rAsymptotic float64) (kernel [3][3]*data.Slice) {
+	var array [3][3][][][]float32
+	for s := 0; s < 3; s++ {
+		for d := s; d < 3; d++ {
+			kernel[s][d] = data.NewSlice(1, size)
+			array[s][d] = kernel[s][d].Scalars()
+		}
+	}
+
+	var R [3]float64
+	R[Z] = Δz
+	for y := r1[Y]; y <= r2[Y]; y++ {
+		// skip one half, reconstruct from symmetry later
+		// check on wrapped index instead of loop range so it also works for PBC
+		yw := wrap(y, size[Y])
+		if yw > size[Y]/2 {
+			continue
+		}
+		R[Y] = float64(y) * src[Y]
+		for x := r1[X]; x <= r2[X]; x++ {
+			xw := wrap(x, size[X])
+			if xw > size[X]/2 {
+				continue
+			}
+			R[X] = float64(x) * src[X]
+			d := 0. // closest distance between the cells
+			for c := 0; c < 3; c++ {
+				d += sq(math.Max(math.Abs(R[c])-(src[c]+dst[c])/2, 0))
+			}
+			d = math.Sqrt(d)
+			for s := 0; s < 3; s++ {
+				B := cellField(s, R, d, src, dst, accuracy, L, rAsymptotic)
+				for d := s; d < 3; d++ {
+					array[s][d][0][yw][xw] += float32(B[d]) // += needed in case of PBC
+				}
+			}
+		}
+	}
+
+	// Reconstruct skipped parts from symmetry (X)
+	for y := 0; y < size[Y]; y++ {
+		for x := size[X]/2 + 1; x < size[X]; x++ {
+			x2 := size[X] - x
+			array[X][X][0][y][x] = array[X][X][0][y][x2]
+			array[X][Y][0][y][x] = -array[X][Y][0][y][x2]
+			array[X][Z][0][y][x] = -array[X][Z][0][y][x2]
+			array[Y][Y][0][y][x] = array[Y][Y][0][y][x2]
+			array[Y][Z][0][y][x] = array[Y][Z][0][y][x2]
+			array[Z][Z][0][y][x] = array[Z][Z][0][y][x2]
+		}
+	}
+
+	// Reconstruct skipped parts from symmetry (Y)
+	for y := size[Y]/2 + 1; y < size[Y]; y++ {
+		y2 := size[Y] - y
+		for x := 0; x < size[X]; x++ {
+			array[X][X][0][y][x] = array[X][X][0][y2][x]
+			array[X][Y][0][y][x] = -array[X][Y][0][y2][x]
+			array[X][Z][0][y][x] = array[X][Z][0][y2][x]
+			array[Y][Y][0][y][x] = array[Y][Y][0][y2][x]
+			array[Y][Z][0][y][x] = -array[Y][Z][0][y2][x]
+			array[Z][Z][0][y][x] = array[Z][Z][0][y2][x]
+		}
+	}
+	return kernel
+}
+
+func scaleKernel(k *data.Slice, factor float64) {
+	l := k.Host()[0]
+	for i := range l {
+		l[i] *= float32(factor)
+	}
+}
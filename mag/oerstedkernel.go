@@ -0,0 +1,236 @@
+package mag
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/timer"
+	"github.com/mumax/3/util"
+)
+
+// Obtains the Oersted (Biot-Savart) kernel either from cacheDir/ or by calculating (and then storing in cacheDir for next time).
+// Empty cacheDir disables caching.
+//
+// The kernel is a vector G(R), in meter, so that the field of a current density J is
+//	B(r) = μ0 Σ J(r') × G(r-r')
+// with G(R) = 1/4π R/|R|³, averaged over the destination cell and integrated over the source cell.
+func OerstedKernel(inputSize, pbc [3]int, cellsize [3]float64, accuracy float64, cacheDir string) (kernel [3]*data.Slice) {
+	timer.Start("kernel_init")
+	timer.Stop("kernel_init") // warm-up
+
+	timer.Start("kernel_init")
+	defer timer.Stop("kernel_init")
+
+	sanityCheck(cellsize, pbc)
+	// Cache disabled
+	if cacheDir == "" {
+		util.Log(`//Not using kernel cache (-cache="")`)
+		return CalcOerstedKernel(inputSize, pbc, cellsize, accuracy)
+	}
+
+	// Error-resilient kernel cache: if anything goes wrong, return calculated kernel.
+	defer func() {
+		if err := recover(); err != nil {
+			util.Log("//Unable to use kernel cache:", err)
+			kernel = CalcOerstedKernel(inputSize, pbc, cellsize, accuracy)
+		}
+	}()
+
+	// Try to load kernel
+	basename := fmt.Sprint(cacheDir, "/", "mumax3oerstedkernel_", inputSize, "_", pbc, "_", cellsize, "_", accuracy, "_")
+	var errLoad error
+	for i := 0; i < 3; i++ {
+		kernel[i], errLoad = LoadKernel(fmt.Sprint(basename, i, ".ovf"))
+		if errLoad != nil {
+			break
+		}
+	}
+
+	if errLoad != nil {
+		util.Log("//Did not use cached kernel:", errLoad)
+	} else {
+		util.Log("//Using cached kernel:", basename)
+		return kernel
+	}
+
+	// Could not load kernel: calculate it and save
+	var errSave error
+	kernel = CalcOerstedKernel(inputSize, pbc, cellsize, accuracy)
+	for i := 0; i < 3; i++ {
+		info := data.Meta{Time: float64(0.0), Name: fmt.Sprint("G_", i), Unit: "m", CellSize: cellsize, MeshUnit: "m"}
+		errSave = SaveKernel(fmt.Sprint(basename, i, ".ovf"), kernel[i], info)
+		if errSave != nil {
+			break
+		}
+	}
+	if errSave != nil {
+		util.Log("//Failed to cache kernel:", errSave)
+	} else {
+		util.Log("//Cached kernel:", basename)
+	}
+
+	return kernel
+}
+
+// Calculates the Oersted kernel by brute-force integration
+// of line currents over the source cell volume and averaging over the destination cell volume.
+func CalcOerstedKernel(inputSize, pbc [3]int, cellsize [3]float64, accuracy float64) (kernel [3]*data.Slice) {
+
+	// Add zero-padding in non-PBC directions
+	size := padSize(inputSize, pbc)
+
+	// Sanity check
+	{
+		util.Assert(size[Z] > 0 && size[Y] > 0 && size[X] > 0)
+		util.Assert(cellsize[X] > 0 && cellsize[Y] > 0 && cellsize[Z] > 0)
+		util.Assert(pbc[X] >= 0 && pbc[Y] >= 0 && pbc[Z] >= 0)
+		util.Assert(accuracy > 0)
+	}
+
+	var array [3][][][]float32
+	for c := 0; c < 3; c++ {
+		kernel[c] = data.NewSlice(1, size)
+		array[c] = kernel[c].Scalars()
+	}
+
+	// Field (destination) loop ranges
+	r1, r2 := kernelRanges(size, pbc)
+
+	// smallest cell dimension is our typical length scale
+	L := math.Min(cellsize[X], math.Min(cellsize[Y], cellsize[Z]))
+	vol := cellsize[X] * cellsize[Y] * cellsize[Z]
+
+	// Parallelize over wrapped Y indices, so that periodic images
+	// adding to the same element are handled by the same goroutine.
+	nRoutines := runtime.NumCPU()
+	var wg sync.WaitGroup
+	var progress, progmax = 0, (1 + (r2[Y] - r1[Y])) * (1 + (r2[Z] - r1[Z]))
+
+	for g := 0; g < nRoutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			var R [3]float64 // destination cell center, relative to source
+
+			for z := r1[Z]; z <= r2[Z]; z++ {
+				zw := wrap(z, size[Z])
+				// skip one half, reconstruct from symmetry later
+				if zw > size[Z]/2 {
+					if g == 0 {
+						progress += (1 + (r2[Y] - r1[Y]))
+					}
+					continue
+				}
+				R[Z] = float64(z) * cellsize[Z]
+
+				for y := r1[Y]; y <= r2[Y]; y++ {
+					if g == 0 { // show progress of only one routine
+						progress++
+						util.Progress(progress, progmax, "Calculating Oersted kernel")
+					}
+
+					yw := wrap(y, size[Y])
+					if yw > size[Y]/2 || yw%nRoutines != g {
+						continue
+					}
+					R[Y] = float64(y) * cellsize[Y]
+
+					for x := r1[X]; x <= r2[X]; x++ {
+						xw := wrap(x, size[X])
+						if xw > size[X]/2 {
+							continue
+						}
+						if x == 0 && y == 0 && z == 0 {
+							continue // self-field vanishes by symmetry
+						}
+						R[X] = float64(x) * cellsize[X]
+
+						// choose number of integration points depending on how far we are from source.
+						dx, dy, dz := delta(x)*cellsize[X], delta(y)*cellsize[Y], delta(z)*cellsize[Z]
+						d := math.Sqrt(dx*dx + dy*dy + dz*dz)
+						if d == 0 {
+							d = L
+						}
+						maxSize := d / accuracy // maximum acceptable integration size
+
+						var n, ns [3]int // destination and source points per direction
+						for c := range n {
+							n[c] = int(math.Max(cellsize[c]/maxSize, 1) + 0.5)
+							ns[c] = 2 * n[c] // stagger source and destination grids
+						}
+
+						G := oerstedIntegral(R, cellsize, n, ns)
+						for c := 0; c < 3; c++ {
+							array[c][zw][yw][xw] += float32(G[c] * vol / (4 * math.Pi)) // += needed in case of PBC
+						}
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	// Reconstruct skipped parts from symmetry:
+	// G_c is odd in direction c and even in the others.
+	for z := 0; z < size[Z]; z++ {
+		for y := 0; y < size[Y]; y++ {
+			for x := size[X]/2 + 1; x < size[X]; x++ {
+				x2 := size[X] - x
+				array[X][z][y][x] = -array[X][z][y][x2]
+				array[Y][z][y][x] = array[Y][z][y][x2]
+				array[Z][z][y][x] = array[Z][z][y][x2]
+			}
+		}
+	}
+	for z := 0; z < size[Z]; z++ {
+		for y := size[Y]/2 + 1; y < size[Y]; y++ {
+			y2 := size[Y] - y
+			for x := 0; x < size[X]; x++ {
+				array[X][z][y][x] = array[X][z][y2][x]
+				array[Y][z][y][x] = -array[Y][z][y2][x]
+				array[Z][z][y][x] = array[Z][z][y2][x]
+			}
+		}
+	}
+	for z := size[Z]/2 + 1; z < size[Z]; z++ {
+		z2 := size[Z] - z
+		for y := 0; y < size[Y]; y++ {
+			for x := 0; x < size[X]; x++ {
+				array[X][z][y][x] = array[X][z2][y][x]
+				array[Y][z][y][x] = array[Y][z2][y][x]
+				array[Z][z][y][x] = -array[Z][z2][y][x]
+			}
+		}
+	}
+	return kernel
+}
+
+// Average of R/|R|³ between n destination points and ns source points per direction,
+// for destination and source cells with given size, centers separated by R0.
+func oerstedIntegral(R0, cellsize [3]float64, n, ns [3]int) (G [3]float64) {
+	pos := func(c, i, n int) float64 {
+		return -cellsize[c]/2 + cellsize[c]/float64(2*n) + float64(i)*cellsize[c]/float64(n)
+	}
+	for α := 0; α < n[X]*ns[X]; α++ {
+		rx := R0[X] + pos(X, α%n[X], n[X]) - pos(X, α/n[X], ns[X])
+		for β := 0; β < n[Y]*ns[Y]; β++ {
+			ry := R0[Y] + pos(Y, β%n[Y], n[Y]) - pos(Y, β/n[Y], ns[Y])
+			for γ := 0; γ < n[Z]*ns[Z]; γ++ {
+				rz := R0[Z] + pos(Z, γ%n[Z], n[Z]) - pos(Z, γ/n[Z], ns[Z])
+				r := math.Sqrt(rx*rx + ry*ry + rz*rz)
+				qr := 1 / (r * r * r)
+				G[X] += rx * qr
+				G[Y] += ry * qr
+				G[Z] += rz * qr
+			}
+		}
+	}
+	scale := 1 / float64(n[X]*ns[X]*n[Y]*ns[Y]*n[Z]*ns[Z])
+	for c := range G {
+		G[c] *= scale
+	}
+	return
+}
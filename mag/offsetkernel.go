@@ -0,0 +1,112 @@
+package mag
+
+import (
+	"math"
+
+	"github.com/mumax/3/data"
+	"github.com/mumax/3/util"
+)
+
+// Calculates the magnetostatic kernel between two separate meshes with the same cell size:
+// a source mesh of srcSize cells and a destination lattice of dstSize points,
+// with the first destination point at offset from the first source cell center.
+// Like CalcStrayFieldKernel, the magnetic charges are integrated over the source cell faces
+// and the field is evaluated at the destination points.
+//
+// The kernel has logical size srcSize+dstSize-1, element n holds the field at displacement
+// offset + n*cellsize, for n between -(srcSize-1) and dstSize-1, wrapped around.
+// So the field on the lattice is the cyclic convolution of the (zero-padded) source magnetization
+// with the kernel, without periodic images.
+// The offset breaks the mirror symmetries of the demag kernel, all 6 elements are stored.
+func CalcOffsetKernel(srcSize, dstSize [3]int, cellsize, offset [3]float64, accuracy float64) (kernel [3][3]*data.Slice) {
+	util.Assert(srcSize[X] > 0 && srcSize[Y] > 0 && srcSize[Z] > 0)
+	util.Assert(dstSize[X] > 0 && dstSize[Y] > 0 && dstSize[Z] > 0)
+	util.Assert(cellsize[X] > 0 && cellsize[Y] > 0 && cellsize[Z] > 0)
+
+	var size [3]int
+	for c := range size {
+		size[c] = srcSize[c] + dstSize[c] - 1
+	}
+
+	var array [3][3][][][]float32
+	for i := 0; i < 3; i++ {
+		for j := i; j < 3; j++ {
+			kernel[i][j] = data.NewSlice(1, size)
+			array[i][j] = kernel[i][j].Scalars()
+		}
+	}
+
+	// smallest cell dimension is our typical length scale
+	L := math.Min(cellsize[X], math.Min(cellsize[Y], cellsize[Z]))
+
+	progress, progmax := 0, size[Y]*size[Z]
+	done := make(chan struct{}, 3)
+
+	for s := 0; s < 3; s++ { // source component, parallelized over
+		go func(s int) {
+			u, v, w := s, (s+1)%3, (s+2)%3 // u = direction of source (s), v & w are the orthogonal directions
+			var R, R2, pole [3]float64
+
+			for z := -(srcSize[Z] - 1); z < dstSize[Z]; z++ {
+				R[Z] = offset[Z] + float64(z)*cellsize[Z]
+				for y := -(srcSize[Y] - 1); y < dstSize[Y]; y++ {
+					if s == 0 {
+						progress++
+						util.Progress(progress, progmax, "Calculating offset kernel")
+					}
+					R[Y] = offset[Y] + float64(y)*cellsize[Y]
+					for x := -(srcSize[X] - 1); x < dstSize[X]; x++ {
+						R[X] = offset[X] + float64(x)*cellsize[X]
+
+						// choose number of integration points depending on how far we are from source.
+						d := math.Sqrt(R[X]*R[X] + R[Y]*R[Y] + R[Z]*R[Z])
+						d = math.Max(d-0.5*math.Sqrt(sq(cellsize[X])+sq(cellsize[Y])+sq(cellsize[Z])), L)
+						maxSize := d / accuracy
+						nv := 2 * int(math.Max(cellsize[v]/maxSize, 1)+0.5) // staggered, see demagkernel.go
+						nw := 2 * int(math.Max(cellsize[w]/maxSize, 1)+0.5)
+
+						charge := cellsize[v] * cellsize[w] / float64(nv*nw)
+						pu1 := cellsize[u] / 2. // positive pole center
+						pu2 := -pu1             // negative pole center
+
+						var B [3]float64
+						for i := 0; i < nv; i++ {
+							pole[v] = -(cellsize[v] / 2.) + cellsize[v]/float64(2*nv) + float64(i)*(cellsize[v]/float64(nv))
+							for j := 0; j < nw; j++ {
+								pole[w] = -(cellsize[w] / 2.) + cellsize[w]/float64(2*nw) + float64(j)*(cellsize[w]/float64(nw))
+
+								pole[u] = pu1
+								R2[X], R2[Y], R2[Z] = R[X]-pole[X], R[Y]-pole[Y], R[Z]-pole[Z]
+								r := math.Sqrt(R2[X]*R2[X] + R2[Y]*R2[Y] + R2[Z]*R2[Z])
+								qr := charge / (4 * math.Pi * r * r * r)
+								bx, by, bz := R2[X]*qr, R2[Y]*qr, R2[Z]*qr
+
+								pole[u] = pu2
+								R2[X], R2[Y], R2[Z] = R[X]-pole[X], R[Y]-pole[Y], R[Z]-pole[Z]
+								r = math.Sqrt(R2[X]*R2[X] + R2[Y]*R2[Y] + R2[Z]*R2[Z])
+								qr = -charge / (4 * math.Pi * r * r * r)
+								B[X] += (bx + R2[X]*qr) // addition ordered for accuracy
+								B[Y] += (by + R2[Y]*qr)
+								B[Z] += (bz + R2[Z]*qr)
+							}
+						}
+						xw, yw, zw := wrap(x, size[X]), wrap(y, size[Y]), wrap(z, size[Z])
+						for d := s; d < 3; d++ { // destination component
+							array[s][d][zw][yw][xw] = float32(B[d])
+						}
+					}
+				}
+			}
+			done <- struct{}{}
+		}(s)
+	}
+	<-done
+	<-done
+	<-done
+
+	// make result symmetric for tools that expect it so.
+	kernel[Y][X] = kernel[X][Y]
+	kernel[Z][X] = kernel[X][Z]
+	kernel[Z][Y] = kernel[Y][Z]
+	return kernel
+}
@@ -16,6 +16,10 @@ import (
 func Read(in io.Reader) (s *data.Slice, meta data.Meta, err error) {
 	//in := fullReader{bufio.NewReader(in_)}
 	info := readHeader(in)
+	if strings.ToLower(info.MeshType) == "irregular" {
+		data_, layers := readOVF2Irregular(in, info)
+		return data_, data.Meta{Name: info.Title, Time: info.TotalTime, Unit: info.ValueUnit, CellSize: info.StepSize, LayerThickness: layers}, nil
+	}
 
 	n := info.Size
 	c := info.StepSize
@@ -77,6 +81,9 @@ type Info struct {
 	SizeofFloat     int // 4/8
 	StepSize        [3]float64
 	MeshUnit        string
+	MeshType        string  // rectangular or irregular
+	PointCount      int     // number of points of an irregular mesh
+	ZMin            float64 // bottom of the mesh
 }
 
 // Parses the header part of the OVF1/OVF2 file
@@ -103,8 +110,14 @@ func readHeader(in io.Reader) *Info {
 		default:
 			panic("Unknown key: " + key)
 			// ignored
-		case "oommf", "segment count", "begin", "meshtype", "xbase", "ybase", "zbase", "xmin", "ymin", "zmin", "xmax", "ymax", "zmax", "valuerangeminmag", "valuerangemaxmag", "end": // ignored (OVF1)
+		case "oommf", "segment count", "begin", "xbase", "ybase", "zbase", "xmin", "ymin", "xmax", "ymax", "zmax", "valuerangeminmag", "valuerangemaxmag", "end": // ignored (OVF1)
 		case "", "valuelabels": // ignored (OVF2)
+		case "meshtype":
+			info.MeshType = value
+		case "pointcount":
+			info.PointCount = atoi(value)
+		case "zmin":
+			info.ZMin = atof(value)
 		case "title":
 			info.Title = value
 		case "valueunits":
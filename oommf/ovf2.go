@@ -9,9 +9,15 @@ import (
 	"unsafe"
 )
 
+// Writes q in OVF2 format. If meta.LayerThickness is set, the layers are non-uniform along z
+// and an irregular mesh is written, where each value is preceded by the position of its cell center.
 func WriteOVF2(out io.Writer, q *data.Slice, meta data.Meta, dataformat string) {
 	writeOVF2Header(out, q, meta)
-	writeOVF2Data(out, q, dataformat)
+	if meta.LayerThickness != nil {
+		writeOVF2Irregular(out, q, meta, dataformat)
+	} else {
+		writeOVF2Data(out, q, dataformat)
+	}
 	hdr(out, "End", "Segment")
 }
 
@@ -24,8 +30,21 @@ func writeOVF2Header(out io.Writer, q *data.Slice, meta data.Meta) {
 	hdr(out, "Begin", "Segment")
 	hdr(out, "Begin", "Header")
 
+	irregular := meta.LayerThickness != nil
+	zmax := cellsize[Z] * float64(gridsize[Z])
 	hdr(out, "Title", meta.Name)
-	hdr(out, "meshtype", "rectangular")
+	if irregular {
+		if len(meta.LayerThickness) != gridsize[Z] {
+			log.Fatalf("OVF2: %v layer thicknesses for %v layers", len(meta.LayerThickness), gridsize[Z])
+		}
+		hdr(out, "meshtype", "irregular")
+		zmax = 0
+		for _, t := range meta.LayerThickness {
+			zmax += t
+		}
+	} else {
+		hdr(out, "meshtype", "rectangular")
+	}
 	hdr(out, "meshunit", "m")
 
 	hdr(out, "xmin", 0)
@@ -34,7 +53,7 @@ func writeOVF2Header(out io.Writer, q *data.Slice, meta data.Meta) {
 
 	hdr(out, "xmax", cellsize[X]*float64(gridsize[X]))
 	hdr(out, "ymax", cellsize[Y]*float64(gridsize[Y]))
-	hdr(out, "zmax", cellsize[Z]*float64(gridsize[Z]))
+	hdr(out, "zmax", zmax)
 
 	name := meta.Name
 	var labels []interface{}
@@ -42,7 +61,7 @@ func writeOVF2Header(out io.Writer, q *data.Slice, meta data.Meta) {
 		labels = []interface{}{name}
 	} else {
 		for i := 0; i < q.NComp(); i++ {
-			labels = append(labels, name+"_"+string('x'+i))
+			labels = append(labels, name+"_"+string(rune('x'+i)))
 		}
 	}
 	hdr(out, "valuedim", q.NComp())
@@ -61,6 +80,16 @@ func writeOVF2Header(out io.Writer, q *data.Slice, meta data.Meta) {
 	//fmt.Fprintln(out, "# Desc: Stage simulation time: ", meta.TimeStep, " s") // TODO
 	hdr(out, "Desc", "Total simulation time: ", meta.Time, " s")
 
+	if irregular {
+		// stepsizes are only a hint for display
+		hdr(out, "xstepsize", cellsize[X])
+		hdr(out, "ystepsize", cellsize[Y])
+		hdr(out, "zstepsize", cellsize[Z])
+		hdr(out, "pointcount", q.Len())
+		hdr(out, "End", "Header")
+		return
+	}
+
 	hdr(out, "xbase", cellsize[X]/2)
 	hdr(out, "ybase", cellsize[Y]/2)
 	hdr(out, "zbase", cellsize[Z]/2)
@@ -73,6 +102,61 @@ func writeOVF2Header(out io.Writer, q *data.Slice, meta data.Meta) {
 	hdr(out, "End", "Header")
 }
 
+// Writes the data of an irregular mesh: x, y, z of each cell center followed by its value.
+func writeOVF2Irregular(out io.Writer, q *data.Slice, meta data.Meta, dataformat string) {
+	data := q.Tensors()
+	size := q.Size()
+	ncomp := q.NComp()
+	c := meta.CellSize
+
+	var record []float32
+	var write func()
+	canonicalFormat := ""
+	switch strings.ToLower(dataformat) {
+	case "text":
+		canonicalFormat = "Text"
+		write = func() {
+			for _, v := range record {
+				fmt.Fprint(out, v, " ")
+			}
+			fmt.Fprint(out, "\n")
+		}
+	case "binary", "binary 4":
+		canonicalFormat = "Binary 4"
+		write = func() {
+			for i := range record {
+				out.Write((*[4]byte)(unsafe.Pointer(&record[i]))[:])
+			}
+		}
+	default:
+		log.Fatalf("Illegal OMF data format: %v. Options are: Text, Binary 4", dataformat)
+	}
+	hdr(out, "Begin", "Data "+canonicalFormat)
+	if canonicalFormat == "Binary 4" {
+		var controlnumber float32 = OVF_CONTROL_NUMBER_4
+		out.Write((*[4]byte)(unsafe.Pointer(&controlnumber))[:])
+	}
+
+	record = make([]float32, 3+ncomp)
+	z0 := 0.
+	for iz := 0; iz < size[Z]; iz++ {
+		dz := meta.LayerThickness[iz]
+		record[Z] = float32(z0 + dz/2)
+		for iy := 0; iy < size[Y]; iy++ {
+			record[Y] = float32((float64(iy) + 0.5) * c[Y])
+			for ix := 0; ix < size[X]; ix++ {
+				record[X] = float32((float64(ix) + 0.5) * c[X])
+				for i := 0; i < ncomp; i++ {
+					record[3+i] = data[i][iz][iy][ix]
+				}
+				write()
+			}
+		}
+		z0 += dz
+	}
+	hdr(out, "End", "Data "+canonicalFormat)
+}
+
 func writeOVF2Data(out io.Writer, q *data.Slice, dataformat string) {
 	canonicalFormat := ""
 	switch strings.ToLower(dataformat) {
@@ -185,3 +269,78 @@ func readOVF2DataBinary8(in io.Reader, array *data.Slice) {
 		}
 	}
 }
+
+// Reads the data of an irregular mesh written by writeOVF2Irregular:
+// a grid with x running fastest, then y, then z, where the layers may have non-uniform thickness.
+// Returns the data and the thickness of each layer.
+func readOVF2Irregular(in io.Reader, info *Info) (*data.Slice, []float64) {
+	ncomp := info.NComp
+	N := info.PointCount
+	if N <= 0 {
+		panic("OVF2 irregular mesh: invalid pointcount: " + fmt.Sprint(N))
+	}
+
+	var read func() float32
+	switch strings.ToLower(info.Format) {
+	default:
+		panic("OVF2 irregular mesh: unknown format: " + info.Format)
+	case "text":
+		read = func() float32 {
+			var v float32
+			if _, err := fmt.Fscan(in, &v); err != nil {
+				panic(err)
+			}
+			return v
+		}
+	case "binary 4":
+		if controlnumber := readFloat32(in); controlnumber != OVF_CONTROL_NUMBER_4 {
+			panic("invalid OVF2 control number: " + fmt.Sprint(controlnumber))
+		}
+		read = func() float32 { return readFloat32(in) }
+	case "binary 8":
+		if controlnumber := readFloat64(in); controlnumber != OVF_CONTROL_NUMBER_8 {
+			panic("invalid OVF2 control number: " + fmt.Sprint(controlnumber))
+		}
+		read = func() float32 { return float32(readFloat64(in)) }
+	}
+
+	records := make([][]float32, N)
+	for i := range records {
+		records[i] = make([]float32, 3+ncomp)
+		for j := range records[i] {
+			records[i][j] = read()
+		}
+	}
+
+	// grid size from the order of the points
+	Nx := 1
+	for Nx < N && records[Nx][Y] == records[0][Y] && records[Nx][Z] == records[0][Z] {
+		Nx++
+	}
+	NxNy := Nx
+	for NxNy < N && records[NxNy][Z] == records[0][Z] {
+		NxNy++
+	}
+	if NxNy%Nx != 0 || N%NxNy != 0 {
+		panic("OVF2 irregular mesh: points do not form a grid of layers")
+	}
+	size := [3]int{Nx, NxNy / Nx, N / NxNy}
+
+	s := data.NewSlice(ncomp, size)
+	t := s.Tensors()
+	layers := make([]float64, size[Z])
+	bottom := info.ZMin
+	for iz := range layers {
+		layers[iz] = 2 * (float64(records[iz*NxNy][Z]) - bottom)
+		bottom += layers[iz]
+		for iy := 0; iy < size[Y]; iy++ {
+			for ix := 0; ix < size[X]; ix++ {
+				r := records[(iz*size[Y]+iy)*Nx+ix]
+				for c := 0; c < ncomp; c++ {
+					t[c][iz][iy][ix] = r[3+c]
+				}
+			}
+		}
+	}
+	return s, layers
+}
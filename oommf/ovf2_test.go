@@ -0,0 +1,61 @@
+package oommf
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/mumax/3/data"
+)
+
+// An irregular mesh with non-uniform layers should be read back as it was written,
+// in all formats the writer supports.
+func TestOVF2IrregularRoundtrip(t *testing.T) {
+	size := [3]int{4, 3, 3}
+	layers := []float64{3e-9, 0.5e-9, 1.2e-9}
+	cell := [3]float64{2e-9, 2.5e-9, 3e-9}
+	q := data.NewSlice(3, size)
+	v := q.Tensors()
+	for c := range v {
+		for iz := range v[c] {
+			for iy := range v[c][iz] {
+				for ix := range v[c][iz][iy] {
+					v[c][iz][iy][ix] = float32(c+1) * float32(ix+10*iy+100*iz)
+				}
+			}
+		}
+	}
+	meta := data.Meta{Name: "m", Unit: "", Time: 1e-9, CellSize: cell, LayerThickness: layers}
+
+	for _, format := range []string{"text", "binary 4"} {
+		var buf bytes.Buffer
+		WriteOVF2(&buf, q, meta, format)
+		have, haveMeta, err := Read(&buf)
+		if err != nil {
+			t.Fatal(format, ": ", err)
+		}
+		if have.Size() != size || have.NComp() != q.NComp() {
+			t.Fatalf("%v: read size %v x %v, want %v x %v", format, have.NComp(), have.Size(), q.NComp(), size)
+		}
+		if len(haveMeta.LayerThickness) != len(layers) {
+			t.Fatalf("%v: read %v layers, want %v", format, len(haveMeta.LayerThickness), len(layers))
+		}
+		for i := range layers {
+			if math.Abs(haveMeta.LayerThickness[i]-layers[i]) > 1e-6*layers[i] {
+				t.Errorf("%v: layer %v: thickness %v, want %v", format, i, haveMeta.LayerThickness[i], layers[i])
+			}
+		}
+		h := have.Tensors()
+		for c := range v {
+			for iz := range v[c] {
+				for iy := range v[c][iz] {
+					for ix := range v[c][iz][iy] {
+						if h[c][iz][iy][ix] != v[c][iz][iy][ix] {
+							t.Errorf("%v: [%v][%v][%v][%v]: %v, want %v", format, c, iz, iy, ix, h[c][iz][iy][ix], v[c][iz][iy][ix])
+						}
+					}
+				}
+			}
+		}
+	}
+}
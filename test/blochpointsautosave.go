@@ -0,0 +1,52 @@
+//+build ignore
+
+/*
+Checks that ext_BlochPointsAutoSave writes blochpoints.txt while running:
+a hedgehog holds a Bloch point, which should be saved at t=0 and every period.
+*/
+
+package main
+
+import (
+	"strings"
+
+	. "github.com/mumax/3/engine"
+	"github.com/mumax/3/httpfs"
+	"github.com/mumax/3/util"
+)
+
+func main() {
+
+	defer InitAndClose()()
+
+	Eval(`
+		N := 16
+		SetGridSize(N, N, N)
+		SetCellSize(1e-9, 1e-9, 1e-9)
+		Msat = 8e5
+		Aex = 10e-12
+		Alpha = 1
+		for iz := 0; iz < N; iz++ {
+			for iy := 0; iy < N; iy++ {
+				for ix := 0; ix < N; ix++ {
+					m.setCell(ix, iy, iz, vector(ix-0.5*(N-1), iy-0.5*(N-1), iz-0.5*(N-1)))
+				}
+			}
+		}
+		ext_BlochPointsAutoSave(1e-12)
+		Run(5.5e-12)
+	`)
+
+	out, err := httpfs.Read(OD() + "blochpoints.txt")
+	util.FatalErr(err)
+	times := make(map[string]bool) // time of each row
+	for _, line := range strings.Split(string(out), "\n") {
+		if line != "" && !strings.HasPrefix(line, "#") {
+			times[strings.Fields(line)[0]] = true
+		}
+	}
+	// saved at t = 0, 1, ..., 5 ps
+	if len(times) != 6 {
+		util.Fatal("blochpoints.txt: have rows at ", len(times), " times, want 6")
+	}
+}
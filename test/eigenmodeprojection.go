@@ -0,0 +1,43 @@
+//+build ignore
+
+/*
+Checks the sign convention of the eigenmodes: projecting a small deviation
+δm = ε Re ψ of the first mode onto that mode (b_k) should yield Re b_k = +ε.
+*/
+
+package main
+
+import (
+	"fmt"
+
+	. "github.com/mumax/3/engine"
+)
+
+func main() {
+
+	defer InitAndClose()()
+
+	Eval(`
+		SetGridSize(1, 1, 1)
+		SetCellSize(2e-9, 2e-9, 2e-9)
+		Msat  = 8e5
+		Aex   = 10e-12
+		Ku1   = 5e5
+		AnisU = vector(0, 0, 1)
+		B_ext = vector(0, 0, 0.1)
+		m = uniform(0, 0, 1)
+		ComputeEigenmodes(1, 0)
+		Flush()
+	`)
+
+	Eval(fmt.Sprintf(`
+		M0 = vector(0, 0, 1)
+		psiRe_k.Add(LoadFile(%q), 1)
+		psiIm_k.Add(LoadFile(%q), 1)
+		eps := 1e-3
+		re := psiRe_k.average()
+		m.SetCell(0, 0, 0, vector(eps*re.X(), eps*re.Y(), 1+eps*re.Z()))
+		expect("Re b_k of Re psi", b_k.Get().X()/eps, 1, 1e-2)
+		expect("Im b_k of Re psi", b_k.Get().Y()/eps, 0, 1e-2)
+	`, OD()+"psiRe_k000000.ovf", OD()+"psiIm_k000000.ovf"))
+}
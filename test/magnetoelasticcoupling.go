@@ -0,0 +1,79 @@
+//+build ignore
+
+/*
+Checks both directions of the coupling between m and the elastic displacement u:
+the strain of u feeds the magneto-elastic field B_mel, and the magneto-elastic force F_mel drives u.
+*/
+
+package main
+
+import (
+	"math"
+
+	"github.com/mumax/3/data"
+	. "github.com/mumax/3/engine"
+	"github.com/mumax/3/util"
+)
+
+const (
+	c    = 5e-9   // cell size
+	ms   = 8e5    // Msat
+	b1   = -8.8e6 // B1
+	rho  = 7900   // mass density
+	eps  = 1e-3   // strain
+	tmax = 0.5e-12
+)
+
+func main() {
+
+	defer InitAndClose()()
+
+	Eval(`
+		SetGridSize(16, 4, 2)
+		SetCellSize(5e-9, 5e-9, 5e-9)
+		Msat = 8e5
+		Aex  = 13e-12
+		m    = uniform(1, 0, 0)
+		rho  = 7900
+		C11  = 2.5e11
+		C12  = 1.5e11
+		C44  = 1.2e11
+		B1   = -8.8e6
+		EnableElastodynamics = true
+	`)
+
+	// A uniform elastic strain exx = eps of the open medium, for m along x,
+	// gives B_mel = -2 B1 eps / Msat along x and E_mel = B1 eps V.
+	SetDisplacement(func(x, y, z float64) data.Vector { return data.Vector{eps * x, 0, 0} })
+	Bmel := -2 * b1 * eps / ms
+	Expect("B_mel", B_mel.Average()[X], Bmel, 1e-4*math.Abs(Bmel))
+	V := 16 * 4 * 2 * c * c * c
+	Expect("E_mel", GetMagnetoelasticEnergy(), b1*eps*V, 1e-4*math.Abs(b1*eps*V))
+
+	// A frozen helix m = (cos kx, sin kx, 0) exerts the force density fx = 2 B1 mx ∂x mx = -B1 k sin(2kx)
+	// on the periodic medium at rest, so that after a short time t (ω t << 1 for the elastic waves)
+	// its velocity is fx t / rho.
+	Eval(`
+		SetPBC(1, 0, 0)
+		SetGridSize(64, 1, 1)
+		FrozenSpins = 1
+		FixDt = 1e-14
+	`)
+	n := Mesh().Size()[X]
+	k := 2 * math.Pi / (float64(n) * c)
+	for ix := 0; ix < n; ix++ {
+		x := float64(ix) * c
+		M.SetCell(ix, 0, 0, data.Vector{math.Cos(k * x), math.Sin(k * x), 0})
+	}
+	Eval(`Run(0.5e-12)`)
+
+	v := V_el.HostCopy().Vectors()[X][0][0]
+	fmax := math.Abs(b1) * k
+	for ix := 0; ix < n; ix++ {
+		x := float64(ix) * c
+		want := -b1 * k * math.Sin(2*k*x) * tmax / rho
+		if math.Abs(float64(v[ix])-want) > 1e-2*fmax*tmax/rho {
+			util.Fatal("du[", ix, "]: have ", v[ix], ", want ", want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+//+build ignore
+
+/*
+Test on-the-fly spectrum accumulation.
+The averaged spectrum of the ringdown of a periodic film should peak at its Kittel frequency,
+and the amplitude and phase maps of a single-frequency drive, with a different phase
+in two regions, should be exact, with a fixed as well as with an adaptive time step.
+*/
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	. "github.com/mumax/3/engine"
+	"github.com/mumax/3/httpfs"
+	"github.com/mumax/3/mag"
+	"github.com/mumax/3/util"
+)
+
+const (
+	ms = 800e3 // Msat
+	bx = 0.1   // static field along x
+	A  = 0.01  // drive amplitude
+)
+
+func main() {
+
+	defer InitAndClose()()
+
+	Eval(`
+		SetGridSize(16, 16, 1)
+		SetCellSize(4e-9, 4e-9, 2e-9)
+		SetPBC(8, 8, 0)
+		Msat  = 800e3
+		Aex   = 13e-12
+		alpha = 0.01
+		B_ext = vector(0.1, 0, 0)
+	`)
+
+	// Kittel frequency of the uniform mode along x, with the demag factors of the periodic film
+	var N [3]float64
+	for c, m := range []string{"(1, 0, 0)", "(0, 1, 0)", "(0, 0, 1)"} {
+		Eval("m = uniform" + m)
+		N[c] = -B_demag.Average()[c] / (mag.Mu0 * ms)
+	}
+	fK := GammaLL / (2 * math.Pi) * math.Sqrt((bx+(N[Y]-N[X])*mag.Mu0*ms)*(bx+(N[Z]-N[X])*mag.Mu0*ms))
+	util.Log("Kittel frequency:", fK)
+
+	// ringdown after a small tilt, adaptive time step
+	Eval(`
+		m = uniform(1, 0.02, 0)
+		SpectrumAccumulate(m, 10e-12, 5e9, 15e9, 201)
+		Run(5e-9)
+		SpectrumSave()
+	`)
+
+	// rows of m_spectrum.txt: f, amp x, y, z, phase x, y, z, power x, y, z
+	out, err := httpfs.Read(OD() + "m_spectrum.txt")
+	util.FatalErr(err)
+	var fPeak, ampPeak float64
+	for _, line := range strings.Split(string(out), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		f, err := strconv.ParseFloat(fields[0], 64)
+		util.FatalErr(err)
+		amp, err := strconv.ParseFloat(fields[1+Y], 64)
+		util.FatalErr(err)
+		if amp > ampPeak {
+			fPeak, ampPeak = f, amp
+		}
+	}
+	// within one frequency step of 50 MHz
+	Expect("spectrum peak", fPeak, fK, 50e6)
+
+	// B_ext_y = A cos(2π f0 t) in region 1 and A sin(2π f0 t) in region 2, so that the
+	// phase is 0 and -π/2. Sampled every 10 ps over 1 ns, 4 and 6 GHz are exactly zero.
+	Eval(`
+		DefRegion(1, XRange(-inf, 0))
+		DefRegion(2, XRange(0, inf))
+		f0 := 5e9
+		t0 := t
+	`)
+	drive := `
+		t0 = t
+		B_ext.SetRegion(1, vector(0.1, %v*cos(2*pi*f0*(t-t0)), 0))
+		B_ext.SetRegion(2, vector(0.1, %v*sin(2*pi*f0*(t-t0)), 0))
+		SpectrumAccumulate(%s, 10e-12, 4e9, 6e9, 3)
+		Run(995e-12)
+		SpectrumSave()
+		Flush()
+	`
+
+	Eval(`FixDt = 1e-12`)
+	Eval(fmt.Sprintf(drive, A, A, "B_ext"))
+	checkDrive("fixed dt", "B_ext_spectrum", Y)
+
+	// samples are interpolated between the adaptive time steps, MaxDt bounds the error
+	Eval(`
+		FixDt = 0
+		MaxDt = 1e-12
+	`)
+	Eval(fmt.Sprintf(drive, A, A, "B_ext.Comp(1)"))
+	checkDrive("adaptive dt", "B_ext_y_spectrum", 0)
+}
+
+// check the amplitude and phase maps of component c of the driven spectrum called name.
+func checkDrive(msg, name string, c int) {
+	amp := make([][][][][]float32, 3)
+	phase := make([][][][][]float32, 3)
+	for i := range amp {
+		amp[i] = LoadFile(OD() + fmt.Sprintf("%s_amp%06d.ovf", name, i)).Tensors()
+		phase[i] = LoadFile(OD() + fmt.Sprintf("%s_phase%06d.ovf", name, i)).Tensors()
+	}
+	for _, ix := range []int{2, 13} {
+		want := 0.
+		if ix >= 8 {
+			want = -math.Pi / 2
+		}
+		cell := fmt.Sprint(msg, ", cell ", ix, ": ")
+		Expect(cell+"amplitude at 4 GHz", float64(amp[0][c][0][8][ix]), 0, 1e-3*A)
+		Expect(cell+"amplitude at 5 GHz", float64(amp[1][c][0][8][ix]), A, 1e-3*A)
+		Expect(cell+"amplitude at 6 GHz", float64(amp[2][c][0][8][ix]), 0, 1e-3*A)
+		Expect(cell+"phase at 5 GHz", float64(phase[1][c][0][8][ix]), want, 1e-3)
+	}
+}
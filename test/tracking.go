@@ -0,0 +1,99 @@
+//+build ignore
+
+/*
+Test multi-object tracking: segmentation in mz mode.
+A bubble that moves between two outputs keeps its ID,
+and its saved position and velocity match the displacement.
+*/
+
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	. "github.com/mumax/3/engine"
+	"github.com/mumax/3/httpfs"
+	"github.com/mumax/3/util"
+)
+
+func main() {
+
+	defer InitAndClose()()
+
+	Eval(`
+		setgridsize(128, 64, 1)
+		c := 2e-9
+		setcellsize(c, c, c)
+
+		Msat = 6e5
+		Aex  = 10e-12
+
+		m = uniform(0, 0, 1)
+		m.setInShape(circle(20e-9).transl(-60e-9, 0, 0), uniform(0, 0, -1))
+		m.setInShape(circle(30e-9).transl(40e-9, 10e-9, 0), uniform(0, 0, -1))
+
+		ext_TrackMz = true
+		ext_TrackThreshold = 0
+		expect("objects", ext_objectcount, 2, 0)
+		ext_TrackSave()
+
+		// 1 ns later, the small bubble has moved by 4 nm and a third bubble is added
+		t = 1e-9
+		m = uniform(0, 0, 1)
+		m.setInShape(circle(20e-9).transl(-56e-9, 0, 0), uniform(0, 0, -1))
+		m.setInShape(circle(30e-9).transl(40e-9, 10e-9, 0), uniform(0, 0, -1))
+		m.setInShape(circle(10e-9).transl(0, -40e-9, 0), uniform(0, 0, -1))
+		expect("objects", ext_objectcount, 3, 0)
+		ext_TrackSave()
+	`)
+
+	// rows of objects.txt: t, id, x, y, radius, charge, vx, vy
+	out, err := httpfs.Read(OD() + "objects.txt")
+	util.FatalErr(err)
+	var rows [][]float64
+	for _, line := range strings.Split(string(out), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var r []float64
+		for _, f := range strings.Fields(line) {
+			v, err := strconv.ParseFloat(f, 64)
+			util.FatalErr(err)
+			r = append(r, v)
+		}
+		rows = append(rows, r)
+	}
+	if len(rows) != 5 {
+		util.Fatal("objects.txt: have ", len(rows), " rows, want 5")
+	}
+
+	// the row at time t nearest to x
+	find := func(t, x float64) []float64 {
+		var best []float64
+		for _, r := range rows {
+			if r[0] == t && (best == nil || math.Abs(r[2]-x) < math.Abs(best[2]-x)) {
+				best = r
+			}
+		}
+		return best
+	}
+	before, after := find(0, -60e-9), find(1e-9, -56e-9)
+	if after[1] != before[1] {
+		util.Fatal("moved bubble: have ID ", after[1], ", want ", before[1])
+	}
+	expect := func(name string, have, want, tol float64) {
+		if math.Abs(have-want) > tol {
+			util.Fatal(name, ": have ", have, ", want ", want)
+		}
+	}
+	expect("x before", before[2], -60e-9, 1e-9)
+	expect("x after", after[2], -56e-9, 1e-9)
+	expect("y after", after[3], 0, 1e-9)
+	expect("vx", after[6], 4, 1e-3) // exact: the bubble is moved by two cells
+	expect("vy", after[7], 0, 1e-3)
+	if newID := find(1e-9, 0)[1]; newID <= after[1] || newID == find(1e-9, 40e-9)[1] {
+		util.Fatal("new bubble: have ID ", newID)
+	}
+}
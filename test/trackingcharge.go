@@ -0,0 +1,108 @@
+//+build ignore
+
+/*
+Test multi-object tracking: segmentation of the topological charge density (the default).
+Two skyrmions each have charge -1 and keep their ID when one of them moves.
+Their radius is that of the area where |q| exceeds the threshold.
+*/
+
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	. "github.com/mumax/3/engine"
+	"github.com/mumax/3/httpfs"
+	"github.com/mumax/3/util"
+)
+
+func main() {
+
+	defer InitAndClose()()
+
+	// NeelSkyrmion has mz = 1 - 2 exp(-r²/w²), w = 8 cells, so that its charge density
+	// goes as exp(-r²/w²): above a threshold of 1% of the maximum, the radius is
+	// w sqrt(ln 100) and 99% of the charge is inside.
+	Eval(`
+		setgridsize(128, 64, 1)
+		c := 2e-9
+		setcellsize(c, c, c)
+
+		Msat = 6e5
+		Aex  = 10e-12
+
+		m = NeelSkyrmion(1, -1).transl(-60e-9, 0, 0)
+		m.setInShape(xrange(0, inf), NeelSkyrmion(1, -1).transl(60e-9, 0, 0))
+
+		ext_TrackThreshold = 0.01
+		expect("objects", ext_objectcount, 2, 0)
+		ext_TrackSave()
+
+		// 1 ns later, the left skyrmion has moved by 4 nm
+		t = 1e-9
+		m = NeelSkyrmion(1, -1).transl(-56e-9, 0, 0)
+		m.setInShape(xrange(0, inf), NeelSkyrmion(1, -1).transl(60e-9, 0, 0))
+		expect("objects", ext_objectcount, 2, 0)
+		ext_TrackSave()
+	`)
+
+	// rows of objects.txt: t, id, x, y, radius, charge, vx, vy
+	out, err := httpfs.Read(OD() + "objects.txt")
+	util.FatalErr(err)
+	var rows [][]float64
+	for _, line := range strings.Split(string(out), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var r []float64
+		for _, f := range strings.Fields(line) {
+			v, err := strconv.ParseFloat(f, 64)
+			util.FatalErr(err)
+			r = append(r, v)
+		}
+		rows = append(rows, r)
+	}
+	if len(rows) != 4 {
+		util.Fatal("objects.txt: have ", len(rows), " rows, want 4")
+	}
+
+	// the row at time t nearest to x
+	find := func(t, x float64) []float64 {
+		var best []float64
+		for _, r := range rows {
+			if r[0] == t && (best == nil || math.Abs(r[2]-x) < math.Abs(best[2]-x)) {
+				best = r
+			}
+		}
+		return best
+	}
+	expect := func(name string, have, want, tol float64) {
+		if math.Abs(have-want) > tol {
+			util.Fatal(name, ": have ", have, ", want ", want)
+		}
+	}
+
+	w := 16e-9
+	radius := w * math.Sqrt(math.Log(100))
+	for _, r := range rows {
+		expect("charge", r[5], -1, 0.03)
+		expect("radius", r[4], radius, 1e-9)
+	}
+
+	left, right := find(0, -60e-9), find(0, 60e-9)
+	if left[1] == right[1] {
+		util.Fatal("both skyrmions have ID ", left[1])
+	}
+	expect("x left", left[2], -60e-9, 1e-9)
+	expect("x right", right[2], 60e-9, 1e-9)
+
+	movedLeft, movedRight := find(1e-9, -56e-9), find(1e-9, 60e-9)
+	if movedLeft[1] != left[1] || movedRight[1] != right[1] {
+		util.Fatal("have IDs ", movedLeft[1], ", ", movedRight[1], " want ", left[1], ", ", right[1])
+	}
+	expect("x moved", movedLeft[2], -56e-9, 1e-9)
+	expect("vx moved", movedLeft[6], 4, 1e-3)
+	expect("vx fixed", movedRight[6], 0, 1e-3)
+}